@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
 	"nikolamilovic/twitchy/accounts/service"
 	"nikolamilovic/twitchy/common/utils"
 
@@ -31,6 +33,7 @@ func (h *AuthHandler) Routes() {
 	h.Router = r
 
 	r.Post("/test", h.handleTest())
+	r.Get("/:id", h.handleGetUser())
 }
 
 func (h *AuthHandler) handleTest() fiber.Handler {
@@ -56,3 +59,23 @@ func (h *AuthHandler) handleTest() fiber.Handler {
 		return nil
 	}
 }
+
+func (h *AuthHandler) handleGetUser() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		user, err := h.accountService.GetUser(id)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(user)
+		case errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
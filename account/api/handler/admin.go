@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// AdminHandler serves the admin API of the account service, every route requires the admin role
+type AdminHandler struct {
+	Router         *fiber.App
+	accountService service.IAccountService
+	audit          audit.ILog
+	jwtSecret      []byte
+	logger         *zap.SugaredLogger
+}
+
+func NewAdminHandler(accounts service.IAccountService, log audit.ILog, jwtSecret []byte, logger *zap.SugaredLogger) *AdminHandler {
+	h := &AdminHandler{}
+
+	h.accountService = accounts
+	h.audit = log
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *AdminHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	admin := authz.Fiber(h.jwtSecret, authz.Admin, nil)
+
+	r.Get("/users", admin, h.handleSearchUsers())
+	r.Get("/audit", admin, h.handleGetAuditLog())
+}
+
+// handleSearchUsers finds users by ?q=, an ID or the start of an email or username. Looking users up is audited as it exposes their email.
+func (h *AdminHandler) handleSearchUsers() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		query := ctx.Query("q")
+		if query == "" {
+			return fiber.NewError(http.StatusBadRequest, "q is required")
+		}
+
+		limit, err := strconv.Atoi(ctx.Query("limit", strconv.Itoa(defaultSearchLimit)))
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			limit = defaultSearchLimit
+		}
+
+		users, err := h.accountService.SearchUsers(query, limit)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		record(h.audit, h.logger, ctx, audit.Entry{
+			ActorID: audit.ID(claims.UserId),
+			Action:  audit.ActionUserSearch,
+			Details: map[string]interface{}{"query": query, "results": len(users)},
+		})
+
+		return ctx.JSON(users)
+	}
+}
+
+// handleGetAuditLog lists the entries newest first, filtered by ?actor=&target=&action= and paged with ?before=<id>
+func (h *AdminHandler) handleGetAuditLog() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		filter, err := audit.ParseFilter(func(key string) string { return ctx.Query(key) })
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		entries, err := h.audit.List(filter)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(entries)
+	}
+}
+
+// record appends to the audit log, a failed write is logged but doesn't fail the operation that already happened
+func record(log audit.ILog, logger *zap.SugaredLogger, ctx *fiber.Ctx, entry audit.Entry) {
+	entry.IP = audit.FiberIP(ctx)
+
+	if err := log.Record(entry); err != nil {
+		logger.Errorf("failed to record %s in the audit log: %v", entry.Action, err)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	secret := "secret"
+	admin, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	staff, err := test_util.GenerateTokens(8, secret, token.RoleStaff)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type adminTest struct {
+		description    string
+		path           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []adminTest{
+		{"search", "/users?q=nik", admin, http.StatusOK},
+		{"search as staff", "/users?q=nik", staff, http.StatusForbidden},
+		{"search without token", "/users?q=nik", "", http.StatusUnauthorized},
+		{"search without query", "/users", admin, http.StatusBadRequest},
+		{"audit log", "/audit?target=5", admin, http.StatusOK},
+		{"audit log invalid filter", "/audit?actor=abc", admin, http.StatusBadRequest},
+		{"audit log as staff", "/audit", staff, http.StatusForbidden},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, scenario.path, nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewAdminHandler(&mock.AccountServiceMock{}, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestSearchUsersShowsEmail(t *testing.T) {
+	secret := "secret"
+	admin, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users?q=nik", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+
+	log := &mock.AuditLogMock{}
+	srv := NewAdminHandler(&mock.AccountServiceMock{}, log, []byte(secret), zap.NewNop().Sugar())
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	var users []model.AdminUser
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(users) != 1 || users[0].Email != "nik@gmail.com" {
+		t.Fatalf("Expected nik@gmail.com, got %+v", users)
+	}
+
+	if len(log.Entries) != 1 || log.Entries[0].Action != audit.ActionUserSearch {
+		t.Fatalf("Expected the search to be audited, got %+v", log.Entries)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/model/response"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type FollowHandler struct {
+	Router        *fiber.App
+	followService service.IFollowService
+	limiter       ratelimit.Limiter
+	jwtSecret     []byte
+	logger        *zap.SugaredLogger
+}
+
+// NewFollowHandler limits how often a user can follow and unfollow with limiter
+func NewFollowHandler(follows service.IFollowService, limiter ratelimit.Limiter, jwtSecret []byte, logger *zap.SugaredLogger) *FollowHandler {
+	h := &FollowHandler{}
+
+	h.followService = follows
+	h.limiter = limiter
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *FollowHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	limit := ratelimit.Fiber(h.limiter, ratelimit.FiberByUser(h.jwtSecret), h.logger)
+
+	r.Post("/:id/follow", limit, h.handleFollow())
+	r.Delete("/:id/follow", limit, h.handleUnfollow())
+	r.Get("/:id/followers", h.handleFollowers())
+	r.Get("/:id/following", h.handleFollowing())
+}
+
+func (h *FollowHandler) handleFollow() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		followerID, err := authenticate(ctx, h.jwtSecret)
+		if err != nil {
+			return err
+		}
+
+		followedID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.followService.Follow(ctx.UserContext(), followerID, followedID)
+
+		switch {
+		case err == nil:
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.SelfFollowError):
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, model.AlreadyFollowingError):
+			return fiber.NewError(http.StatusConflict, err.Error())
+		case errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *FollowHandler) handleUnfollow() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		followerID, err := authenticate(ctx, h.jwtSecret)
+		if err != nil {
+			return err
+		}
+
+		followedID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.followService.Unfollow(ctx.UserContext(), followerID, followedID)
+
+		switch {
+		case err == nil:
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.NotFollowingError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *FollowHandler) handleFollowers() fiber.Handler {
+	return h.handleList(h.followService.GetFollowers)
+}
+
+func (h *FollowHandler) handleFollowing() fiber.Handler {
+	return h.handleList(h.followService.GetFollowing)
+}
+
+func (h *FollowHandler) handleList(list func(userID int, cursor string, limit int) ([]model.Follow, string, error)) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		limit, err := strconv.Atoi(ctx.Query("limit", strconv.Itoa(defaultLimit)))
+		if err != nil || limit < 1 || limit > maxLimit {
+			return fiber.NewError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+
+		users, next, err := list(userID, ctx.Query("cursor"), limit)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(response.FollowsResponse{
+				Users:      users,
+				NextCursor: next,
+			})
+		case errors.Is(err, model.InvalidCursorError):
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// authenticate returns the id of the user making the request, forwarded by the gateway or from the bearer token
+func authenticate(ctx *fiber.Ctx, secret []byte) (int, error) {
+	claims, err := token.FromHeaders(authz.FiberHeader(ctx), secret)
+	if err != nil {
+		return 0, fiber.NewError(http.StatusUnauthorized, "invalid token")
+	}
+
+	return claims.UserId, nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/model/response"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/test_util"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFollowRoutes(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type followTest struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []followTest{
+		{"follow", http.MethodPost, "/2/follow", jwt, http.StatusNoContent},
+		{"follow without token", http.MethodPost, "/2/follow", "", http.StatusUnauthorized},
+		{"self follow", http.MethodPost, "/1/follow", jwt, http.StatusBadRequest},
+		{"duplicate follow", http.MethodPost, "/409/follow", jwt, http.StatusConflict},
+		{"invalid id", http.MethodPost, "/abc/follow", jwt, http.StatusBadRequest},
+		{"unfollow", http.MethodDelete, "/2/follow", jwt, http.StatusNoContent},
+		{"unfollow not followed", http.MethodDelete, "/404/follow", jwt, http.StatusNotFound},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, scenario.path, nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewFollowHandler(&mock.FollowServiceMock{}, ratelimit.NewTokenBucket(ratelimit.NewMemoryStore(), "follow", 1, 10), []byte(secret), zap.NewNop().Sugar())
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestFollowers(t *testing.T) {
+	srv := NewFollowHandler(&mock.FollowServiceMock{}, ratelimit.NewTokenBucket(ratelimit.NewMemoryStore(), "follow", 1, 10), []byte("secret"), zap.NewNop().Sugar())
+
+	resp, err := srv.Router.Test(httptest.NewRequest(http.MethodGet, "/1/followers?limit=1", nil))
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	var responseData response.FollowsResponse
+	json.Unmarshal(data, &responseData)
+
+	if len(responseData.Users) != 1 || responseData.NextCursor != "next" {
+		t.Fatalf("expected a page of followers, instead got: %+v", responseData)
+	}
+
+	for path, status := range map[string]int{
+		"/1/followers?limit=101":     http.StatusBadRequest,
+		"/1/followers?cursor=broken": http.StatusBadRequest,
+		"/1/following":               http.StatusOK,
+	} {
+		resp, _ := srv.Router.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if want, got := status, resp.StatusCode; want != got {
+			t.Fatalf("expected a %d for %s, instead got: %d", want, path, got)
+		}
+	}
+}
+
+func TestFollowRateLimit(t *testing.T) {
+	jwt, err := test_util.GenerateTokens(1, "secret")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	srv := NewFollowHandler(&mock.FollowServiceMock{}, ratelimit.NewTokenBucket(ratelimit.NewMemoryStore(), "follow", 1, 2), []byte("secret"), zap.NewNop().Sugar())
+
+	for _, expectedStatus := range []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/2/follow", nil)
+		req.Header.Set("Authorization", "Bearer "+jwt)
+
+		resp, err := srv.Router.Test(req)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+
+		if want, got := expectedStatus, resp.StatusCode; want != got {
+			t.Fatalf("expected a %d, instead got: %d", want, got)
+		}
+
+		if expectedStatus == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != "1" {
+			t.Fatalf("expected to retry after 1 second, instead got: %s", resp.Header.Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type ModerationHandler struct {
+	Router            *fiber.App
+	validator         *validator.Validate
+	moderationService service.IModerationService
+	audit             audit.ILog
+	jwtSecret         []byte
+	logger            *zap.SugaredLogger
+}
+
+func NewModerationHandler(validator *validator.Validate, moderation service.IModerationService, log audit.ILog, jwtSecret []byte, logger *zap.SugaredLogger) *ModerationHandler {
+	h := &ModerationHandler{}
+
+	h.validator = validator
+	h.moderationService = moderation
+	h.audit = log
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *ModerationHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	moderator := authz.Fiber(h.jwtSecret, authz.ModeratorOf, authz.ChannelParam("id"))
+	staff := authz.Fiber(h.jwtSecret, authz.Staff, nil)
+	user := authz.Fiber(h.jwtSecret, authz.Authenticated, nil)
+
+	r.Get("/:id/bans", moderator, h.handleGetBans())
+	r.Get("/:id/bans/:userId", moderator, h.handleGetBan())
+	r.Put("/:id/bans/:userId", moderator, h.handleBan())
+	r.Delete("/:id/bans/:userId", moderator, h.handleUnban())
+	r.Put("/:id/timeouts/:userId", moderator, h.handleTimeout())
+
+	r.Get("/:id/suspension", staff, h.handleGetSuspension())
+	r.Put("/:id/suspension", staff, h.handleSuspend())
+	r.Delete("/:id/suspension", staff, h.handleUnsuspend())
+
+	r.Post("/:id/block", user, h.handleBlock())
+	r.Delete("/:id/block", user, h.handleUnblock())
+	r.Get("/:id/blocked", user, h.handleGetBlocked())
+}
+
+func (h *ModerationHandler) handleGetBans() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, _ := ctx.ParamsInt("id")
+
+		bans, err := h.moderationService.GetBans(channelID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(bans)
+	}
+}
+
+func (h *ModerationHandler) handleGetBan() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		ban, err := h.moderationService.GetBan(channelID, userID)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(ban)
+		case errors.Is(err, model.NotBannedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *ModerationHandler) handleBan() fiber.Handler {
+	type BanRequest struct {
+		Reason string `json:"reason" validate:"max=500"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		var req BanRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		ban, err := h.moderationService.Ban(ctx.UserContext(), channelID, userID, claims.UserId, req.Reason)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionBan,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"channel_id": channelID, "reason": req.Reason},
+			})
+		}
+
+		return banResponse(ctx, ban, err)
+	}
+}
+
+// handleTimeout bans the user for duration seconds, up to two weeks
+func (h *ModerationHandler) handleTimeout() fiber.Handler {
+	type TimeoutRequest struct {
+		Reason   string `json:"reason" validate:"max=500"`
+		Duration int    `json:"duration" validate:"required,min=1,max=1209600"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		var req TimeoutRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		ban, err := h.moderationService.Timeout(ctx.UserContext(), channelID, userID, claims.UserId, req.Reason, time.Duration(req.Duration)*time.Second)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionTimeout,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"channel_id": channelID, "reason": req.Reason, "duration": req.Duration},
+			})
+		}
+
+		return banResponse(ctx, ban, err)
+	}
+}
+
+func (h *ModerationHandler) handleUnban() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.moderationService.Unban(ctx.UserContext(), channelID, userID, claims.UserId)
+
+		switch {
+		case err == nil:
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionUnban,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"channel_id": channelID},
+			})
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.NotBannedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *ModerationHandler) handleGetSuspension() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		suspension, err := h.moderationService.GetSuspension(userID)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(suspension)
+		case errors.Is(err, model.NotSuspendedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// handleSuspend suspends the user for duration seconds, or until lifted without one
+func (h *ModerationHandler) handleSuspend() fiber.Handler {
+	type SuspendRequest struct {
+		Reason   string `json:"reason" validate:"required,max=500"`
+		Duration int    `json:"duration" validate:"min=0"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		var req SuspendRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		suspension, err := h.moderationService.Suspend(ctx.UserContext(), userID, claims.UserId, req.Reason, time.Duration(req.Duration)*time.Second)
+
+		switch {
+		case err == nil:
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionSuspend,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"reason": req.Reason, "duration": req.Duration},
+			})
+			return ctx.JSON(suspension)
+		case errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *ModerationHandler) handleUnsuspend() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.moderationService.Unsuspend(ctx.UserContext(), userID, claims.UserId)
+
+		switch {
+		case err == nil:
+			record(h.audit, h.logger, ctx, audit.Entry{ActorID: audit.ID(claims.UserId), Action: audit.ActionUnsuspend, TargetID: audit.ID(userID)})
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.NotSuspendedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *ModerationHandler) handleBlock() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		blockedID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.moderationService.Block(ctx.UserContext(), claims.UserId, blockedID)
+
+		switch {
+		case err == nil:
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.SelfBlockError):
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *ModerationHandler) handleUnblock() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		blockedID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.moderationService.Unblock(ctx.UserContext(), claims.UserId, blockedID)
+
+		switch {
+		case err == nil:
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.NotBlockedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+// handleGetBlocked lists the users the caller blocked, block lists are private
+func (h *ModerationHandler) handleGetBlocked() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		if userID != claims.UserId {
+			return fiber.NewError(http.StatusForbidden, "block lists are private")
+		}
+
+		blocks, err := h.moderationService.GetBlocked(userID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(blocks)
+	}
+}
+
+func banResponse(ctx *fiber.Ctx, ban model.Ban, err error) error {
+	switch {
+	case err == nil:
+		return ctx.JSON(ban)
+	case errors.Is(err, model.CannotBanBroadcasterError):
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, model.AlreadyBannedError):
+		return fiber.NewError(http.StatusConflict, err.Error())
+	case errors.Is(err, model.UserNotFoundError):
+		return fiber.NewError(http.StatusNotFound, err.Error())
+	default:
+		return fiber.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+func TestModerationRoutes(t *testing.T) {
+	secret := "secret"
+	broadcaster, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	moderator, err := test_util.GenerateTokens(5, secret, token.ChannelScope(token.RoleModerator, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	staff, err := test_util.GenerateTokens(9, secret, token.RoleStaff)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	viewer, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type moderationTest struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}
+
+	for _, scenario := range []moderationTest{
+		{"ban as broadcaster", http.MethodPut, "/1/bans/3", broadcaster, `{"reason":"spam"}`, http.StatusOK},
+		{"ban as moderator", http.MethodPut, "/1/bans/3", moderator, `{"reason":"spam"}`, http.StatusOK},
+		{"ban as staff", http.MethodPut, "/1/bans/3", staff, `{}`, http.StatusOK},
+		{"ban as viewer", http.MethodPut, "/1/bans/3", viewer, `{}`, http.StatusForbidden},
+		{"ban in another channel", http.MethodPut, "/2/bans/3", moderator, `{}`, http.StatusForbidden},
+		{"ban without token", http.MethodPut, "/1/bans/3", "", `{}`, http.StatusUnauthorized},
+		{"ban the broadcaster", http.MethodPut, "/1/bans/1", moderator, `{}`, http.StatusBadRequest},
+		{"ban twice", http.MethodPut, "/1/bans/409", moderator, `{}`, http.StatusConflict},
+		{"timeout", http.MethodPut, "/1/timeouts/3", moderator, `{"reason":"caps","duration":600}`, http.StatusOK},
+		{"timeout without duration", http.MethodPut, "/1/timeouts/3", moderator, `{"reason":"caps"}`, http.StatusBadRequest},
+		{"timeout too long", http.MethodPut, "/1/timeouts/3", moderator, `{"duration":1209601}`, http.StatusBadRequest},
+		{"unban", http.MethodDelete, "/1/bans/3", moderator, "", http.StatusNoContent},
+		{"unban user not banned", http.MethodDelete, "/1/bans/404", moderator, "", http.StatusNotFound},
+		{"bans", http.MethodGet, "/1/bans", moderator, "", http.StatusOK},
+		{"bans as viewer", http.MethodGet, "/1/bans", viewer, "", http.StatusForbidden},
+		{"ban", http.MethodGet, "/1/bans/3", moderator, "", http.StatusOK},
+		{"ban not found", http.MethodGet, "/1/bans/404", moderator, "", http.StatusNotFound},
+		{"suspend", http.MethodPut, "/3/suspension", staff, `{"reason":"tos","duration":86400}`, http.StatusOK},
+		{"suspend without reason", http.MethodPut, "/3/suspension", staff, `{}`, http.StatusBadRequest},
+		{"suspend as broadcaster", http.MethodPut, "/3/suspension", broadcaster, `{"reason":"tos"}`, http.StatusForbidden},
+		{"suspend unknown user", http.MethodPut, "/404/suspension", staff, `{"reason":"tos"}`, http.StatusNotFound},
+		{"suspension", http.MethodGet, "/3/suspension", staff, "", http.StatusOK},
+		{"unsuspend", http.MethodDelete, "/3/suspension", staff, "", http.StatusNoContent},
+		{"unsuspend user not suspended", http.MethodDelete, "/404/suspension", staff, "", http.StatusNotFound},
+		{"block", http.MethodPost, "/3/block", viewer, "", http.StatusNoContent},
+		{"block self", http.MethodPost, "/7/block", viewer, "", http.StatusBadRequest},
+		{"block without token", http.MethodPost, "/3/block", "", "", http.StatusUnauthorized},
+		{"unblock", http.MethodDelete, "/3/block", viewer, "", http.StatusNoContent},
+		{"unblock user not blocked", http.MethodDelete, "/404/block", viewer, "", http.StatusNotFound},
+		{"blocked", http.MethodGet, "/7/blocked", viewer, "", http.StatusOK},
+		{"blocked of another user", http.MethodGet, "/1/blocked", viewer, "", http.StatusForbidden},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewModerationHandler(validator.New(), &mock.ModerationServiceMock{}, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestTimeoutDuration(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/1/timeouts/3", strings.NewReader(`{"duration":600}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	moderation := &mock.ModerationServiceMock{}
+	srv := NewModerationHandler(validator.New(), moderation, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+
+	if _, err := srv.Router.Test(req); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if len(moderation.Timeouts) != 1 || moderation.Timeouts[0] != 10*time.Minute {
+		t.Fatalf("Expected a 10 minute timeout, got %v", moderation.Timeouts)
+	}
+}
+
+func TestSuspendIsAudited(t *testing.T) {
+	// The IPs are the ones the gateway forwards
+	token.TrustGateway(true)
+	defer token.TrustGateway(false)
+
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(9, secret, token.RoleStaff)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/5/suspension", strings.NewReader(`{"reason":"spam","duration":3600}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+
+	log := &mock.AuditLogMock{}
+	srv := NewModerationHandler(validator.New(), &mock.ModerationServiceMock{}, log, []byte(secret), zap.NewNop().Sugar())
+
+	if _, err := srv.Router.Test(req); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if len(log.Entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(log.Entries))
+	}
+
+	entry := log.Entries[0]
+	if entry.Action != audit.ActionSuspend || *entry.ActorID != 9 || *entry.TargetID != 5 || entry.IP != "10.0.0.1" {
+		t.Fatalf("Expected suspension of 5 by 9 from 10.0.0.1, got %+v", entry)
+	}
+}
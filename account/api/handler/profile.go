@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ProfileHandler struct {
+	Router         *fiber.App
+	validator      *validator.Validate
+	profileService service.IProfileService
+	jwtSecret      []byte
+}
+
+func NewProfileHandler(validator *validator.Validate, profiles service.IProfileService, jwtSecret []byte) *ProfileHandler {
+	h := &ProfileHandler{}
+
+	h.validator = validator
+	h.profileService = profiles
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *ProfileHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	user := authz.Fiber(h.jwtSecret, authz.Authenticated, nil)
+
+	r.Put("/:id/profile", user, h.handleUpdateProfile())
+}
+
+// handleUpdateProfile replaces the display name and bio, users can only edit their own profile
+func (h *ProfileHandler) handleUpdateProfile() fiber.Handler {
+	type ProfileRequest struct {
+		DisplayName string `json:"display_name" validate:"max=50"`
+		Bio         string `json:"bio" validate:"max=300"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		if userID != claims.UserId {
+			return fiber.NewError(http.StatusForbidden, "forbidden")
+		}
+
+		var req ProfileRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		user, err := h.profileService.UpdateProfile(ctx.UserContext(), userID, req.DisplayName, req.Bio)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(user)
+		case errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
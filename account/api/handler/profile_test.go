@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/test_util"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type profileTest struct {
+		description    string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []profileTest{
+		{"update", "/1/profile", `{"display_name":"Nikola","bio":"I stream Go"}`, jwt, http.StatusOK},
+		{"clear", "/1/profile", `{}`, jwt, http.StatusOK},
+		{"someone else's", "/2/profile", `{"display_name":"Nikola"}`, jwt, http.StatusForbidden},
+		{"without token", "/1/profile", `{"display_name":"Nikola"}`, "", http.StatusUnauthorized},
+		{"display name too long", "/1/profile", `{"display_name":"` + strings.Repeat("a", 51) + `"}`, jwt, http.StatusBadRequest},
+		{"bio too long", "/1/profile", `{"bio":"` + strings.Repeat("a", 301) + `"}`, jwt, http.StatusBadRequest},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewProfileHandler(validator.New(), &mock.ProfileServiceMock{}, []byte(secret))
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/model/response"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type RoleHandler struct {
+	Router      *fiber.App
+	roleService service.IRoleService
+	audit       audit.ILog
+	jwtSecret   []byte
+	logger      *zap.SugaredLogger
+}
+
+func NewRoleHandler(roles service.IRoleService, log audit.ILog, jwtSecret []byte, logger *zap.SugaredLogger) *RoleHandler {
+	h := &RoleHandler{}
+
+	h.roleService = roles
+	h.audit = log
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *RoleHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	broadcaster := authz.Fiber(h.jwtSecret, authz.BroadcasterOf, authz.ChannelParam("id"))
+	admin := authz.Fiber(h.jwtSecret, authz.Admin, nil)
+
+	// Roles are public, chat shows them as badges
+	r.Get("/:id/roles", h.handleGetRoles())
+	r.Get("/:id/permissions", h.handleCheck())
+	r.Get("/:id/channel-roles", h.handleGetChannelRoles())
+	r.Put("/:id/channel-roles/:userId/:role", broadcaster, h.handleGrantChannelRole())
+	r.Delete("/:id/channel-roles/:userId/:role", broadcaster, h.handleRevokeChannelRole())
+	r.Put("/:id/platform-roles/:role", admin, h.handleGrantPlatformRole())
+	r.Delete("/:id/platform-roles/:role", admin, h.handleRevokePlatformRole())
+}
+
+func (h *RoleHandler) handleGetRoles() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		roles, err := h.roleService.GetRoles(userID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(roles)
+	}
+}
+
+// handleCheck answers whether the user passes the policy of the role in the channel, e.g. ?role=moderator&channel=42
+func (h *RoleHandler) handleCheck() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		channelID, err := strconv.Atoi(ctx.Query("channel", "0"))
+		if err != nil || channelID < 0 {
+			return fiber.NewError(http.StatusBadRequest, "invalid channel id")
+		}
+
+		role := ctx.Query("role")
+
+		allowed, err := h.roleService.Check(userID, channelID, role)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(response.PermissionResponse{
+				UserID:    userID,
+				ChannelID: channelID,
+				Role:      role,
+				Allowed:   allowed,
+			})
+		case errors.Is(err, model.InvalidRoleError):
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *RoleHandler) handleGetChannelRoles() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		roles, err := h.roleService.GetChannelRoles(channelID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(roles)
+	}
+}
+
+func (h *RoleHandler) handleGrantChannelRole() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.roleService.GrantChannelRole(ctx.UserContext(), channelID, userID, ctx.Params("role"), claims.UserId)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionRoleGranted,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"role": ctx.Params("role"), "channel_id": channelID},
+			})
+		}
+
+		return roleResponse(ctx, err)
+	}
+}
+
+func (h *RoleHandler) handleRevokeChannelRole() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		channelID, _ := ctx.ParamsInt("id")
+		userID, err := ctx.ParamsInt("userId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.roleService.RevokeChannelRole(ctx.UserContext(), channelID, userID, ctx.Params("role"), claims.UserId)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionRoleRevoked,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"role": ctx.Params("role"), "channel_id": channelID},
+			})
+		}
+
+		return roleResponse(ctx, err)
+	}
+}
+
+func (h *RoleHandler) handleGrantPlatformRole() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.roleService.GrantPlatformRole(ctx.UserContext(), userID, ctx.Params("role"), claims.UserId)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionRoleGranted,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"role": ctx.Params("role")},
+			})
+		}
+
+		return roleResponse(ctx, err)
+	}
+}
+
+func (h *RoleHandler) handleRevokePlatformRole() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, _ := authz.FiberClaims(ctx)
+
+		userID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		err = h.roleService.RevokePlatformRole(ctx.UserContext(), userID, ctx.Params("role"), claims.UserId)
+		if err == nil {
+			record(h.audit, h.logger, ctx, audit.Entry{
+				ActorID:  audit.ID(claims.UserId),
+				Action:   audit.ActionRoleRevoked,
+				TargetID: audit.ID(userID),
+				Details:  map[string]interface{}{"role": ctx.Params("role")},
+			})
+		}
+
+		return roleResponse(ctx, err)
+	}
+}
+
+func roleResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case err == nil:
+		return ctx.SendStatus(http.StatusNoContent)
+	case errors.Is(err, model.InvalidRoleError), errors.Is(err, model.SelfGrantError):
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, model.UserNotFoundError), errors.Is(err, model.RoleNotFoundError):
+		return fiber.NewError(http.StatusNotFound, err.Error())
+	default:
+		return fiber.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/model/response"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRoleRoutes(t *testing.T) {
+	secret := "secret"
+	broadcaster, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	moderator, err := test_util.GenerateTokens(5, secret, token.ChannelScope(token.RoleModerator, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	admin, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type roleTest struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []roleTest{
+		{"roles", http.MethodGet, "/5/roles", "", http.StatusOK},
+		{"channel roles", http.MethodGet, "/1/channel-roles", "", http.StatusOK},
+		{"check", http.MethodGet, "/5/permissions?role=moderator&channel=2", "", http.StatusOK},
+		{"check unknown role", http.MethodGet, "/5/permissions?role=owner&channel=2", "", http.StatusBadRequest},
+		{"check invalid channel", http.MethodGet, "/5/permissions?role=moderator&channel=abc", "", http.StatusBadRequest},
+		{"grant as broadcaster", http.MethodPut, "/1/channel-roles/5/moderator", broadcaster, http.StatusNoContent},
+		{"grant as admin", http.MethodPut, "/1/channel-roles/5/vip", admin, http.StatusNoContent},
+		{"grant as moderator", http.MethodPut, "/1/channel-roles/6/vip", moderator, http.StatusForbidden},
+		{"grant without token", http.MethodPut, "/1/channel-roles/5/moderator", "", http.StatusUnauthorized},
+		{"grant in another channel", http.MethodPut, "/2/channel-roles/5/moderator", broadcaster, http.StatusForbidden},
+		{"grant unknown role", http.MethodPut, "/1/channel-roles/5/owner", broadcaster, http.StatusBadRequest},
+		{"grant to self", http.MethodPut, "/1/channel-roles/1/moderator", broadcaster, http.StatusBadRequest},
+		{"revoke", http.MethodDelete, "/1/channel-roles/5/moderator", broadcaster, http.StatusNoContent},
+		{"revoke role not held", http.MethodDelete, "/1/channel-roles/404/moderator", broadcaster, http.StatusNotFound},
+		{"grant platform role", http.MethodPut, "/5/platform-roles/staff", admin, http.StatusNoContent},
+		{"grant platform role as broadcaster", http.MethodPut, "/5/platform-roles/staff", broadcaster, http.StatusForbidden},
+		{"grant channel role as platform role", http.MethodPut, "/5/platform-roles/moderator", admin, http.StatusBadRequest},
+		{"revoke platform role", http.MethodDelete, "/5/platform-roles/staff", admin, http.StatusNoContent},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, scenario.path, nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewRoleHandler(&mock.RoleServiceMock{}, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestGrantRecordsGrantor(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/1/channel-roles/5/editor", nil)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	roles := &mock.RoleServiceMock{}
+	srv := NewRoleHandler(roles, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+
+	if _, err := srv.Router.Test(req); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if len(roles.Granted) != 1 {
+		t.Fatalf("Expected 1 grant, got %d", len(roles.Granted))
+	}
+
+	if got := roles.Granted[0]; got.ChannelID != 1 || got.UserID != 5 || got.Role != token.RoleEditor || got.GrantedBy != 9 {
+		t.Fatalf("Expected editor of 1 granted to 5 by 9, got %+v", got)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/5/permissions?role=moderator&channel=2", nil)
+
+	srv := NewRoleHandler(&mock.RoleServiceMock{}, &mock.AuditLogMock{}, []byte("secret"), zap.NewNop().Sugar())
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	var body response.PermissionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if !body.Allowed || body.UserID != 5 || body.ChannelID != 2 {
+		t.Fatalf("Expected user 5 to be allowed in channel 2, got %+v", body)
+	}
+}
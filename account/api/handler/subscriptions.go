@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SubscriptionHandler struct {
+	Router              *fiber.App
+	validator           *validator.Validate
+	subscriptionService service.ISubscriptionService
+	jwtSecret           []byte
+}
+
+func NewSubscriptionHandler(validator *validator.Validate, subscriptions service.ISubscriptionService, jwtSecret []byte) *SubscriptionHandler {
+	h := &SubscriptionHandler{}
+
+	h.validator = validator
+	h.subscriptionService = subscriptions
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *SubscriptionHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	r.Get("/:id/plans", h.handleGetPlans())
+	r.Put("/:id/plans/:tier", h.handleSetPlan())
+	r.Post("/:id/subscription", h.handleSubscribe())
+	r.Delete("/:id/subscription", h.handleCancel())
+	// Public so chat can look up subscriber badges
+	r.Get("/:id/subscribers/:subscriberId", h.handleGetSubscription())
+}
+
+func (h *SubscriptionHandler) handleGetPlans() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		plans, err := h.subscriptionService.GetPlans(channelID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(plans)
+	}
+}
+
+func (h *SubscriptionHandler) handleSetPlan() fiber.Handler {
+	type PlanRequest struct {
+		Name       string `json:"name" validate:"required,max=50"`
+		PriceCents int    `json:"price_cents" validate:"required,min=1"`
+		Currency   string `json:"currency" validate:"omitempty,len=3,uppercase"`
+		Active     *bool  `json:"active"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		userID, err := authenticate(ctx, h.jwtSecret)
+		if err != nil {
+			return err
+		}
+
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		if userID != channelID {
+			return fiber.NewError(http.StatusForbidden, "only the broadcaster can change the plans")
+		}
+
+		tier, err := ctx.ParamsInt("tier")
+		if err != nil || tier < 1 || tier > 3 {
+			return fiber.NewError(http.StatusBadRequest, "tier must be between 1 and 3")
+		}
+
+		var req PlanRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		plan := model.Plan{
+			ChannelID:  channelID,
+			Tier:       tier,
+			Name:       req.Name,
+			PriceCents: req.PriceCents,
+			Currency:   req.Currency,
+			Active:     req.Active == nil || *req.Active,
+		}
+		if plan.Currency == "" {
+			plan.Currency = "USD"
+		}
+
+		if err := h.subscriptionService.SetPlan(plan); err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(plan)
+	}
+}
+
+func (h *SubscriptionHandler) handleSubscribe() fiber.Handler {
+	type SubscribeRequest struct {
+		Tier int `json:"tier" validate:"required,min=1,max=3"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		subscriberID, err := authenticate(ctx, h.jwtSecret)
+		if err != nil {
+			return err
+		}
+
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		var req SubscribeRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		sub, err := h.subscriptionService.Subscribe(ctx.UserContext(), subscriberID, channelID, req.Tier)
+
+		switch {
+		case err == nil:
+			return ctx.Status(http.StatusCreated).JSON(sub)
+		case errors.Is(err, model.SelfSubscribeError):
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, model.AlreadySubscribedError):
+			return fiber.NewError(http.StatusConflict, err.Error())
+		case errors.Is(err, model.PlanNotFoundError), errors.Is(err, model.UserNotFoundError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		case errors.Is(err, model.PaymentFailedError):
+			return fiber.NewError(http.StatusPaymentRequired, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *SubscriptionHandler) handleCancel() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		subscriberID, err := authenticate(ctx, h.jwtSecret)
+		if err != nil {
+			return err
+		}
+
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		sub, err := h.subscriptionService.Cancel(ctx.UserContext(), subscriberID, channelID)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(sub)
+		case errors.Is(err, model.NotSubscribedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
+
+func (h *SubscriptionHandler) handleGetSubscription() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		subscriberID, err := ctx.ParamsInt("subscriberId")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid user id")
+		}
+
+		sub, err := h.subscriptionService.GetSubscription(subscriberID, channelID)
+
+		switch {
+		case err == nil:
+			return ctx.JSON(sub)
+		case errors.Is(err, model.NotSubscribedError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/test_util"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestSubscriptionRoutes(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type subscriptionTest struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}
+
+	for _, scenario := range []subscriptionTest{
+		{"subscribe", http.MethodPost, "/2/subscription", jwt, `{"tier":1}`, http.StatusCreated},
+		{"subscribe without token", http.MethodPost, "/2/subscription", "", `{"tier":1}`, http.StatusUnauthorized},
+		{"invalid tier", http.MethodPost, "/2/subscription", jwt, `{"tier":4}`, http.StatusBadRequest},
+		{"self subscribe", http.MethodPost, "/1/subscription", jwt, `{"tier":1}`, http.StatusBadRequest},
+		{"already subscribed", http.MethodPost, "/409/subscription", jwt, `{"tier":1}`, http.StatusConflict},
+		{"declined", http.MethodPost, "/402/subscription", jwt, `{"tier":1}`, http.StatusPaymentRequired},
+		{"no such plan", http.MethodPost, "/2/subscription", jwt, `{"tier":3}`, http.StatusNotFound},
+		{"cancel", http.MethodDelete, "/2/subscription", jwt, "", http.StatusOK},
+		{"cancel not subscribed", http.MethodDelete, "/404/subscription", jwt, "", http.StatusNotFound},
+		{"subscriber", http.MethodGet, "/2/subscribers/1", "", "", http.StatusOK},
+		{"not a subscriber", http.MethodGet, "/2/subscribers/404", "", "", http.StatusNotFound},
+		{"plans", http.MethodGet, "/2/plans", "", "", http.StatusOK},
+		{"set plan", http.MethodPut, "/1/plans/1", jwt, `{"name":"Tier 1","price_cents":499}`, http.StatusOK},
+		{"set plan of another channel", http.MethodPut, "/2/plans/1", jwt, `{"name":"Tier 1","price_cents":499}`, http.StatusForbidden},
+		{"set plan without price", http.MethodPut, "/1/plans/1", jwt, `{"name":"Tier 1"}`, http.StatusBadRequest},
+		{"set plan of unknown tier", http.MethodPut, "/1/plans/4", jwt, `{"name":"Tier 4","price_cents":499}`, http.StatusBadRequest},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewSubscriptionHandler(validator.New(), &mock.SubscriptionServiceMock{}, []byte(secret))
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestSetPlanDefaults(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/1/plans/2", strings.NewReader(`{"name":"Tier 2","price_cents":999}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	subscriptions := &mock.SubscriptionServiceMock{}
+	srv := NewSubscriptionHandler(validator.New(), subscriptions, []byte(secret))
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	var plan model.Plan
+	json.NewDecoder(resp.Body).Decode(&plan)
+
+	if len(subscriptions.Plans) != 1 || plan.Currency != "USD" || !plan.Active || plan.Tier != 2 || plan.ChannelID != 1 {
+		t.Fatalf("expected an active USD plan, instead got: %+v", plan)
+	}
+}
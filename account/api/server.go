@@ -3,24 +3,52 @@ package api
 import (
 	"nikolamilovic/twitchy/accounts/api/handler"
 	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/health"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/tracing"
 
 	"github.com/go-playground/validator/v10"
 
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
 )
 
 type Server struct {
 	router         *fiber.App
 	validator      *validator.Validate
 	accountService service.IAccountService
+	followService  service.IFollowService
+	subscriptions  service.ISubscriptionService
+	roles          service.IRoleService
+	moderation     service.IModerationService
+	profiles       service.IProfileService
+	audit          audit.ILog
+	limits         ratelimit.Store
+	probes         *health.Health
+	jwtSecret      []byte
+	logger         *zap.SugaredLogger
 }
 
-func NewServer(service service.IAccountService) *fiber.App {
+func NewServer(service service.IAccountService, follows service.IFollowService, subscriptions service.ISubscriptionService, roles service.IRoleService, moderation service.IModerationService, profiles service.IProfileService, log audit.ILog, limits ratelimit.Store, probes *health.Health, jwtSecret []byte, logger *zap.SugaredLogger) *fiber.App {
 	s := &Server{
 		accountService: service,
+		followService:  follows,
+		subscriptions:  subscriptions,
+		roles:          roles,
+		moderation:     moderation,
+		profiles:       profiles,
+		audit:          log,
+		limits:         limits,
+		probes:         probes,
+		jwtSecret:      jwtSecret,
+		logger:         logger,
 		router:         fiber.New(),
 	}
 	s.validator = validator.New()
+	s.router.Use(tracing.Fiber())
+	s.router.Use(metrics.Fiber())
 	s.routes()
 	return s.router
 }
@@ -29,5 +57,21 @@ func (s *Server) routes() {
 	h := handler.NewAuthHandler(s.validator, s.accountService)
 	h.Routes()
 
+	fh := handler.NewFollowHandler(s.followService, ratelimit.NewTokenBucket(s.limits, "follow", 0.5, 20), s.jwtSecret, s.logger.Named("follow_handler"))
+	sh := handler.NewSubscriptionHandler(s.validator, s.subscriptions, s.jwtSecret)
+	rh := handler.NewRoleHandler(s.roles, s.audit, s.jwtSecret, s.logger.Named("role_handler"))
+	mh := handler.NewModerationHandler(s.validator, s.moderation, s.audit, s.jwtSecret, s.logger.Named("moderation_handler"))
+	ph := handler.NewProfileHandler(s.validator, s.profiles, s.jwtSecret)
+	ah := handler.NewAdminHandler(s.accountService, s.audit, s.jwtSecret, s.logger.Named("admin_handler"))
+
+	s.router.Get("/healthz", s.probes.FiberLive)
+	s.router.Get("/readyz", s.probes.FiberReadiness)
+	s.router.Get("/metrics", metrics.FiberHandler())
+	s.router.Mount("/api/accounts/admin", ah.Router)
 	s.router.Mount("/api/accounts", h.Router)
+	s.router.Mount("/api/accounts", fh.Router)
+	s.router.Mount("/api/accounts", sh.Router)
+	s.router.Mount("/api/accounts", rh.Router)
+	s.router.Mount("/api/accounts", mh.Router)
+	s.router.Mount("/api/accounts", ph.Router)
 }
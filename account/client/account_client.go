@@ -8,7 +8,9 @@ import (
 	"nikolamilovic/twitchy/accounts/service"
 	"nikolamilovic/twitchy/common/constants"
 	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
 	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/tracing"
 	"runtime"
 	"sync"
 	"time"
@@ -18,8 +20,8 @@ import (
 )
 
 const (
-	// When resending messages the server didn't confirm
-	resendDelay = 5 * time.Second
+	// How long closing waits for the messages being processed
+	closeTimeout = 10 * time.Second
 )
 
 //https://www.ribice.ba/golang-rabbitmq-client/
@@ -33,6 +35,7 @@ type AccountClient struct {
 	connection *rabbitmq.ClientConnection
 	threads    int
 	wg         *sync.WaitGroup
+	stop       context.CancelFunc
 }
 
 func New(addr string, l *zap.SugaredLogger, service service.IAccountService, connection *rabbitmq.ClientConnection) *AccountClient {
@@ -54,57 +57,114 @@ func New(addr string, l *zap.SugaredLogger, service service.IAccountService, con
 }
 
 func (c *AccountClient) Consume(cancelCtx context.Context) {
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
 	go func() {
+		defer c.wg.Done()
 		for {
-			err := c.stream(cancelCtx)
+			err := c.stream(ctx)
 			if errors.Is(err, rabbitmq.ErrDisconnected) {
 				continue
 			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
 			break
 		}
 	}()
 }
 
-func (c *AccountClient) push(key string, data []byte) error {
-	if !c.connection.IsConnected {
-		return errors.New("failed to push push: not connected")
+func (c *AccountClient) PublishAccountUpdatedEvent(ctx context.Context, data event.AccountUpdatedEventData) error {
+	return c.publish(ctx, constants.AccountUpdatedKey, event.AccountUpdatedType, data)
+}
+
+func (c *AccountClient) PublishUserFollowedEvent(ctx context.Context, data event.UserFollowedEventData) error {
+	return c.publish(ctx, constants.UserFollowedKey, event.UserFollowedType, data)
+}
+
+func (c *AccountClient) PublishUserUnfollowedEvent(ctx context.Context, data event.UserUnfollowedEventData) error {
+	return c.publish(ctx, constants.UserUnfollowedKey, event.UserUnfollowedType, data)
+}
+
+func (c *AccountClient) PublishSubscriptionStartedEvent(ctx context.Context, data event.SubscriptionStartedEventData) error {
+	return c.publish(ctx, constants.SubscriptionStartedKey, event.SubscriptionStartedType, data)
+}
+
+func (c *AccountClient) PublishSubscriptionEndedEvent(ctx context.Context, data event.SubscriptionEndedEventData) error {
+	return c.publish(ctx, constants.SubscriptionEndedKey, event.SubscriptionEndedType, data)
+}
+
+func (c *AccountClient) PublishRoleGrantedEvent(ctx context.Context, data event.RoleGrantedEventData) error {
+	return c.publish(ctx, constants.RoleGrantedKey, event.RoleGrantedType, data)
+}
+
+func (c *AccountClient) PublishRoleRevokedEvent(ctx context.Context, data event.RoleRevokedEventData) error {
+	return c.publish(ctx, constants.RoleRevokedKey, event.RoleRevokedType, data)
+}
+
+func (c *AccountClient) PublishUserBannedEvent(ctx context.Context, data event.UserBannedEventData) error {
+	return c.publish(ctx, constants.UserBannedKey, event.UserBannedType, data)
+}
+
+func (c *AccountClient) PublishUserTimedOutEvent(ctx context.Context, data event.UserTimedOutEventData) error {
+	return c.publish(ctx, constants.UserTimedOutKey, event.UserTimedOutType, data)
+}
+
+func (c *AccountClient) PublishUserUnbannedEvent(ctx context.Context, data event.UserUnbannedEventData) error {
+	return c.publish(ctx, constants.UserUnbannedKey, event.UserUnbannedType, data)
+}
+
+func (c *AccountClient) PublishUserSuspendedEvent(ctx context.Context, data event.UserSuspendedEventData) error {
+	return c.publish(ctx, constants.UserSuspendedKey, event.UserSuspendedType, data)
+}
+
+func (c *AccountClient) PublishUserUnsuspendedEvent(ctx context.Context, data event.UserUnsuspendedEventData) error {
+	return c.publish(ctx, constants.UserUnsuspendedKey, event.UserUnsuspendedType, data)
+}
+
+func (c *AccountClient) PublishUserBlockedEvent(ctx context.Context, data event.UserBlockedEventData) error {
+	return c.publish(ctx, constants.UserBlockedKey, event.UserBlockedType, data)
+}
+
+func (c *AccountClient) PublishUserUnblockedEvent(ctx context.Context, data event.UserUnblockedEventData) error {
+	return c.publish(ctx, constants.UserUnblockedKey, event.UserUnblockedType, data)
+}
+
+func (c *AccountClient) publish(ctx context.Context, key, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
 	}
-	for {
-		err := c.unsafePush(key, data)
-		if err != nil {
-			if err == rabbitmq.ErrDisconnected {
-				continue
-			}
-			return err
-		}
-		select {
-		case confirm := <-c.connection.NotifyConfirm:
-			if confirm.Ack {
-				return nil
-			}
-		case <-time.After(resendDelay):
-		}
+
+	baseEv := event.BaseEvent{
+		Type:    eventType,
+		Payload: string(payload),
 	}
-}
 
-func (c *AccountClient) unsafePush(key string, data []byte) error {
-	if !c.connection.IsConnected {
-		return rabbitmq.ErrDisconnected
+	ev, err := json.Marshal(baseEv)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
 	}
 
-	return c.connection.Channel.Publish(
-		constants.AccountsExchange, // Exchange
-		key,                        // Routing key
-		false,                      // Mandatory
-		false,                      // Immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        data,
-		},
-	)
+	return c.push(ctx, key, ev)
+}
+
+func (c *AccountClient) push(ctx context.Context, key string, data []byte) error {
+	span, headers := tracing.StartPublish(ctx, constants.AccountsExchange, key)
+	defer span.End()
+
+	return c.connection.PublishConfirmed(ctx, constants.AccountsExchange, key, amqp.Publishing{
+		ContentType: "application/json",
+		Headers:     headers,
+		Body:        data,
+	})
 }
 
-func (c *AccountClient) connect(ch *amqp.Channel) bool {
+func (c *AccountClient) connect(ch rabbitmq.Channel) bool {
 
 	err := ch.ExchangeDeclare(constants.AccountsExchange, "topic", true, false, false, false, nil)
 
@@ -114,7 +174,7 @@ func (c *AccountClient) connect(ch *amqp.Channel) bool {
 	}
 
 	_, err = ch.QueueDeclare(
-		constants.AccountsQueue+"_accounts",
+		constants.AccountsQueue,
 		true,  // Durable
 		false, // Delete when unused
 		false, // Exclusive
@@ -132,71 +192,97 @@ func (c *AccountClient) connect(ch *amqp.Channel) bool {
 		return false
 	}
 
-	return true
-}
-
-func (c *AccountClient) stream(cancelCtx context.Context) error {
-	c.wg.Add(c.threads)
+	// Auth embeds the roles in the tokens and refuses suspended users, its queue is declared here too so no event is lost while it's down
+	_, err = ch.QueueDeclare(
+		constants.AuthServiceQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.AuthServiceQueue, err)
+		return false
+	}
 
-	for {
-		if c.connection.IsConnected {
-			break
+	for _, key := range []string{constants.RoleGrantedKey, constants.RoleRevokedKey, constants.UserSuspendedKey, constants.UserUnsuspendedKey} {
+		err = ch.QueueBind(constants.AuthServiceQueue, key, constants.AccountsExchange, false, nil)
+		if err != nil {
+			c.logger.Errorf("failed to bind %s to the auth queue: %v", key, err)
+			return false
 		}
-		time.Sleep(1 * time.Second)
 	}
 
-	err := c.connection.Channel.Qos(1, 0, false)
-	if err != nil {
+	return true
+}
+
+func (c *AccountClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
 		return err
 	}
 
-	var connectionDropped bool
-
-	for i := 1; i <= c.threads; i++ {
-		msgs, err := c.connection.Channel.Consume(
-			constants.AccountsQueue,
-			consumerName(i), // Consumer
-			false,           // Auto-Ack
-			false,           // Exclusive
-			false,           // No-local
-			false,           // No-Wait
-			nil,             // Args
-		)
-		if err != nil {
+	var deliveries []<-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		if err := ch.Qos(1, 0, false); err != nil {
 			return err
 		}
 
-		go func() {
-			defer c.wg.Done()
+		for i := 1; i <= c.threads; i++ {
+			msgs, err := ch.Consume(
+				constants.AccountsQueue,
+				consumerName(i), // Consumer
+				false,           // Auto-Ack
+				false,           // Exclusive
+				false,           // No-local
+				false,           // No-Wait
+				nil,             // Args
+			)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, msgs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The consumers only stop early when the channel closes
+	var wg sync.WaitGroup
+	for _, msgs := range deliveries {
+		wg.Add(1)
+		go func(msgs <-chan amqp.Delivery) {
+			defer wg.Done()
 			for {
 				select {
-				case <-cancelCtx.Done():
+				case <-ctx.Done():
 					return
 				case msg, ok := <-msgs:
 					if !ok {
-						connectionDropped = true
 						return
 					}
-					c.parseEvent(msg)
+					metrics.Consume(constants.AccountsQueue, msg, c.parseEvent)
 				}
 			}
-		}()
-
+		}(msgs)
 	}
+	wg.Wait()
 
-	c.wg.Wait()
-
-	if connectionDropped {
-		return rabbitmq.ErrDisconnected
+	if ctx.Err() != nil {
+		return nil
 	}
-
-	return nil
+	return rabbitmq.ErrDisconnected
 }
 
 func (c *AccountClient) parseEvent(msg amqp.Delivery) {
 	l := c.logger.Named("parseEvent")
 	startTime := time.Now()
 
+	ctx, span := tracing.StartConsume(context.Background(), msg)
+	defer span.End()
+
 	var evt event.BaseEvent
 	err := json.Unmarshal(msg.Body, &evt)
 	if err != nil {
@@ -223,11 +309,10 @@ func (c *AccountClient) parseEvent(msg amqp.Delivery) {
 	switch evt.Type {
 	case event.AccountCreatedType:
 		payload := &event.AccountCreatedEventData{}
-		err := json.Unmarshal([]byte(evt.Payload), payload)
-		if err != nil {
-			logAndNack(msg, l, startTime, "failed to parse event data")
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.CreateUser(ctx, *payload)
 		}
-		c.service.CreateUser(*payload)
 	default:
 		msg.Reject(false)
 		return
@@ -248,23 +333,18 @@ func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string
 }
 
 func (c *AccountClient) Close() error {
-	if !c.connection.IsConnected {
-		return nil
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
 	}
-	c.connection.Alive = false
 	c.logger.Info("Waiting for current messages to be processed...")
-	c.wg.Wait()
-	for i := 1; i <= c.threads; i++ {
-		fmt.Println("Closing consumer: ", i)
-		err := c.connection.Channel.Cancel(consumerName(i), false)
-		if err != nil {
-			return fmt.Errorf("error canceling consumer %s: %v", consumerName(i), err)
-		}
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
 	}
 
-	err := c.connection.Close()
-
-	if err != nil {
+	if err := c.connection.Close(ctx); err != nil {
 		return err
 	}
 
@@ -1,11 +1,23 @@
 package client
 
 import (
+	"context"
+	"nikolamilovic/twitchy/accounts/service"
 	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"nikolamilovic/twitchy/common/tracing"
 	"testing"
 
 	gomock "github.com/golang/mock/gomock"
+	"github.com/pashagolub/pgxmock"
 	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 )
 
@@ -19,7 +31,7 @@ func TestParseEventAck(t *testing.T) {
 		service: &mock.AccountServiceMock{},
 	}
 
-	ack := NewMockAcknowledger(ctl)
+	ack := amqpmock.NewMockAcknowledger(ctl)
 
 	ack.EXPECT().Ack(gomock.Any(), false)
 
@@ -45,7 +57,7 @@ func TestParseEventNackWhenNoPayload(t *testing.T) {
 		service: &mock.AccountServiceMock{},
 	}
 
-	ack := NewMockAcknowledger(ctl)
+	ack := amqpmock.NewMockAcknowledger(ctl)
 
 	ack.EXPECT().Nack(gomock.Any(), false, false)
 
@@ -60,3 +72,99 @@ func TestParseEventNackWhenNoPayload(t *testing.T) {
 		},
 	)
 }
+
+func TestParseEventContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	db, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close(context.Background())
+
+	db.ExpectQuery("INSERT INTO users").WithArgs(12345, "test@gmail.com", "username").WillReturnRows(pgxmock.NewRows([]string{"id"}))
+
+	client := &AccountClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service.NewAccountService(tracing.DB(db)),
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	// The auth service publishes the event in the trace of the registration request
+	publish, headers := tracing.StartPublish(context.Background(), constants.AccountsExchange, constants.AccountCreatedKey)
+	publish.End()
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			Headers:      headers,
+			Exchange:     constants.AccountsExchange,
+			RoutingKey:   constants.AccountCreatedKey,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"account_created",
+ 	  "payload":"{\"id\":12345,\"email\":\"test@gmail.com\",\"username\":\"username\"}"
+		}`),
+		},
+	)
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected the publish, process and insert spans, got %d", len(spans))
+	}
+
+	published, insert, processed := spans[0], spans[1], spans[2]
+
+	if want, got := constants.AccountCreatedKey+" process", processed.Name(); want != got {
+		t.Fatalf("expected a %s span, instead got: %s", want, got)
+	}
+
+	if processed.Parent().SpanID() != published.SpanContext().SpanID() || !processed.Parent().IsRemote() {
+		t.Fatalf("expected processing to continue the trace of the publisher, got parent %v", processed.Parent())
+	}
+
+	if insert.Name() != "postgres INSERT" || insert.Parent().SpanID() != processed.SpanContext().SpanID() {
+		t.Fatalf("expected the insert to be a child of processing, got %s with parent %v", insert.Name(), insert.Parent())
+	}
+
+	if insert.SpanContext().TraceID() != published.SpanContext().TraceID() {
+		t.Fatalf("expected a single trace, got %v and %v", published.SpanContext().TraceID(), insert.SpanContext().TraceID())
+	}
+}
+
+func TestConsumeMetrics(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &AccountClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.AccountServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+	ack.EXPECT().Nack(gomock.Any(), false, false)
+
+	labels := map[string]string{"outcome": "nack", "queue": constants.AccountsQueue}
+	before := test_util.MetricValue("rabbitmq_consumed_messages_total", labels)
+
+	metrics.Consume(constants.AccountsQueue, amqp091.Delivery{
+		Acknowledger: ack,
+		ContentType:  "application/json",
+		Body:         []byte(`{"type":"account_created"}`),
+	}, client.parseEvent)
+
+	// The acknowledger passed to the handler is forwarded to, and records the outcome
+	if want, got := 1.0, test_util.MetricValue("rabbitmq_consumed_messages_total", labels)-before; want != got {
+		t.Fatalf("expected %v more nacked messages, instead got: %v", want, got)
+	}
+}
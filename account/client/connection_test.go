@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// createdUsers records the users the consumed events created
+type createdUsers struct {
+	mock.AccountServiceMock
+
+	mu  sync.Mutex
+	ids map[int]bool
+}
+
+func (s *createdUsers) CreateUser(ctx context.Context, ev event.AccountCreatedEventData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[ev.ID] = true
+	return nil
+}
+
+func (s *createdUsers) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ids)
+}
+
+func TestReconnectDuringPublishAndConsume(t *testing.T) {
+	broker := rabbitmq.NewMemoryBroker()
+	connection := rabbitmq.NewClientConnection(zap.NewNop().Sugar()).WithDialer(broker.Dial)
+	users := &createdUsers{ids: map[int]bool{}}
+	client := New("amqp://test", zap.NewNop().Sugar(), users, connection)
+	client.Consume(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := connection.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	const (
+		publishers = 8
+		messages   = 25
+	)
+
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				body := fmt.Sprintf(`{"type":"account_created","payload":"{\"id\":%d,\"email\":\"test@gmail.com\"}"}`, p*messages+i)
+				// Publishing fails right away while disconnected, it's up to the callers to retry
+				for client.push(context.Background(), constants.AccountCreatedKey, []byte(body)) != nil {
+					if err := connection.WaitConnected(ctx); err != nil {
+						t.Errorf("failed to reconnect: %v", err)
+						return
+					}
+				}
+
+				// The other publishers and the consumers race the disconnects
+				if p == 0 && i%5 == 4 {
+					broker.Disconnect()
+				}
+			}
+		}(p)
+	}
+
+	wg.Wait()
+
+	// A message whose confirm was lost with the connection is published again, so it may be consumed twice
+	for users.count() < publishers*messages {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected %d users to be created, instead got: %d", publishers*messages, users.count())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close the client: %v", err)
+	}
+	if connection.IsConnected() {
+		t.Fatal("expected the connection to be closed")
+	}
+	if err := connection.WaitConnected(ctx); err != rabbitmq.ErrClosed {
+		t.Fatalf("expected %v once closed, instead got: %v", rabbitmq.ErrClosed, err)
+	}
+}
+
+func TestTopology(t *testing.T) {
+	broker := rabbitmq.NewMemoryBroker()
+	connection := rabbitmq.NewClientConnection(zap.NewNop().Sugar()).WithDialer(broker.Dial)
+	client := New("amqp://test", zap.NewNop().Sugar(), &mock.AccountServiceMock{}, connection)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := connection.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	// Auth embeds the roles in the tokens, the grants reach its queue even before it first starts
+	if err := client.PublishRoleGrantedEvent(context.Background(), event.RoleGrantedEventData{UserID: 1, Role: "admin"}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	// Nobody consumes the follows from the account exchange yet, they're dropped
+	if err := client.PublishUserFollowedEvent(context.Background(), event.UserFollowedEventData{FollowerID: 1, FollowedID: 2}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	if want, got := 1, broker.Messages(constants.AuthServiceQueue); want != got {
+		t.Fatalf("expected %d message in the auth queue, instead got: %d", want, got)
+	}
+
+	conn, err := broker.Dial("amqp://test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := ch.Consume(constants.AuthServiceQueue, "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatalf("failed to consume: %v", err)
+	}
+
+	msg := <-msgs
+	if msg.RoutingKey != constants.RoleGrantedKey || msg.Exchange != constants.AccountsExchange {
+		t.Fatalf("expected a %s message from %s, instead got %s from %s", constants.RoleGrantedKey, constants.AccountsExchange, msg.RoutingKey, msg.Exchange)
+	}
+
+	var ev event.BaseEvent
+	if err := json.Unmarshal(msg.Body, &ev); err != nil || ev.Type != event.RoleGrantedType {
+		t.Fatalf("expected a %s event, instead got: %s", event.RoleGrantedType, msg.Body)
+	}
+
+	// Nacked with requeue it's delivered again, acked it's gone
+	if err := msg.Nack(false, true); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-msgs
+	if !msg.Redelivered {
+		t.Fatal("expected the nacked message to be redelivered")
+	}
+	if err := msg.Ack(false); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 0, broker.Messages(constants.AuthServiceQueue); want != got {
+		t.Fatalf("expected %d messages in the auth queue, instead got: %d", want, got)
+	}
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS follows;
+
+ALTER TABLE users DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users DROP COLUMN IF EXISTS followers_count;
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS followers_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS follows(
+   follower_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   followed_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   PRIMARY KEY (follower_id, followed_id),
+   CHECK (follower_id <> followed_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followed_idx ON follows (followed_id, created_at DESC, follower_id DESC);
+CREATE INDEX IF NOT EXISTS follows_follower_created_idx ON follows (follower_id, created_at DESC, followed_id DESC);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS subscription_plans(
+   channel_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   tier SMALLINT NOT NULL CHECK (tier BETWEEN 1 AND 3),
+   name VARCHAR(50) NOT NULL,
+   price_cents INTEGER NOT NULL CHECK (price_cents > 0),
+   currency CHAR(3) NOT NULL DEFAULT 'USD',
+   active BOOLEAN NOT NULL DEFAULT TRUE,
+   PRIMARY KEY (channel_id, tier)
+);
+
+-- A cancelled subscription stays valid until the end of its period, after that it's ended and a new one can be started
+CREATE TABLE IF NOT EXISTS subscriptions(
+   id SERIAL PRIMARY KEY,
+   subscriber_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   channel_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   tier SMALLINT NOT NULL,
+   status VARCHAR(20) NOT NULL,
+   auto_renew BOOLEAN NOT NULL DEFAULT TRUE,
+   period_start TIMESTAMPTZ NOT NULL,
+   period_end TIMESTAMPTZ NOT NULL,
+   payment_ref VARCHAR(100) NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   ended_at TIMESTAMPTZ,
+   CHECK (subscriber_id <> channel_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_current_idx ON subscriptions (subscriber_id, channel_id) WHERE status <> 'ended';
+CREATE INDEX IF NOT EXISTS subscriptions_due_idx ON subscriptions (period_end) WHERE status <> 'ended';
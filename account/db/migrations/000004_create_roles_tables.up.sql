@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS platform_roles(
+   user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'staff')),
+   granted_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   PRIMARY KEY (user_id, role)
+);
+
+-- The broadcaster role isn't stored, every user is the broadcaster of their own channel
+CREATE TABLE IF NOT EXISTS channel_roles(
+   channel_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   role VARCHAR(20) NOT NULL CHECK (role IN ('moderator', 'editor', 'vip')),
+   granted_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   PRIMARY KEY (channel_id, user_id, role),
+   CHECK (channel_id <> user_id)
+);
+
+CREATE INDEX IF NOT EXISTS channel_roles_user_idx ON channel_roles (user_id);
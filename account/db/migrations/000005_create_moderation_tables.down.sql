@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_blocks;
+DROP TABLE IF EXISTS suspensions;
+DROP TABLE IF EXISTS channel_bans;
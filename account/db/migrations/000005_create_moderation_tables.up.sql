@@ -0,0 +1,27 @@
+-- A ban has no expiry, a timeout does. Expired timeouts are left in place until the user is banned or timed out again.
+CREATE TABLE IF NOT EXISTS channel_bans(
+   channel_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   moderator_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+   reason VARCHAR(500) NOT NULL DEFAULT '',
+   expires_at TIMESTAMPTZ,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   PRIMARY KEY (channel_id, user_id),
+   CHECK (channel_id <> user_id)
+);
+
+CREATE TABLE IF NOT EXISTS suspensions(
+   user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+   actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+   reason VARCHAR(500) NOT NULL DEFAULT '',
+   expires_at TIMESTAMPTZ,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_blocks(
+   blocker_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   blocked_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   PRIMARY KEY (blocker_id, blocked_id),
+   CHECK (blocker_id <> blocked_id)
+);
@@ -0,0 +1,2 @@
+-- A renewal run holds the subscription until the lease runs out, the period only moves once the renewal is paid
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
@@ -6,9 +6,16 @@ import (
 	"math/rand"
 	"nikolamilovic/twitchy/accounts/api"
 	"nikolamilovic/twitchy/accounts/client"
+	"nikolamilovic/twitchy/accounts/payment"
 	"nikolamilovic/twitchy/accounts/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/config"
 	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/health"
 	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/tracing"
 	"os"
 	"os/signal"
 	"syscall"
@@ -18,6 +25,14 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	renewalInterval = time.Minute
+	// rateLimitRetention is how long the rate limits are kept, longer than the longest window
+	rateLimitRetention = time.Hour
+	// drainDelay is how long the server keeps serving once it's not ready, until the probes took it out of rotation
+	drainDelay = 5 * time.Second
+)
+
 var (
 	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
 	shutdowns []func() error
@@ -27,38 +42,78 @@ func main() {
 	var (
 		shutdown = make(chan struct{})
 		ctx      = context.Background()
-		sigint   = make(chan os.Signal, 1)
 	)
 
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+	token.TrustGateway(cfg.TrustGateway)
+
 	rand.Seed(time.Now().UnixNano())
 
-	dbConn, dbCleanup, err := db.InitDb(ctx, logger.Sugar().Named("db"))
+	stopTracing, err := tracing.Init(ctx, "account")
+	if err != nil {
+		logger.Fatal("failed to init tracing", zap.Error(err))
+	}
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
 	if err != nil {
 		logger.Fatal("failed to init the db", zap.Error(err))
 	}
+	// The probes aren't traced, they would bury the traces of the requests
+	probeConn := dbConn
+	dbConn = tracing.DB(dbConn)
 
-	amqpServerURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		os.Getenv("RABBITMQ_USER"),
-		os.Getenv("RABBITMQ_PASSWORD"),
-		os.Getenv("RABBITMQ_HOST"),
-		os.Getenv("RABBITMQ_PORT"),
-	)
+	migration, err := db.LatestVersion(cfg.Postgres.MigrationPath)
+	if err != nil {
+		logger.Fatal("failed to read the migrations", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
 
 	accountService := service.NewAccountService(dbConn)
 
-	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"), sigint)
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
 	client := client.New(amqpServerURL, logger.Sugar().Named("accounts_rabbitmq_client"), accountService, clientConnection)
 	client.Consume(ctx)
 
-	srv := api.NewServer(accountService)
+	probes := health.New().
+		Add("postgres", health.Postgres(probeConn)).
+		Add("migrations", health.Migrations(probeConn, migration)).
+		Add("broker", health.Broker(clientConnection))
+
+	followService := service.NewFollowService(dbConn, client)
+
+	// There is no payment processor integrated yet, every charge goes through
+	subscriptionService := service.NewSubscriptionService(dbConn, payment.NewFakeProvider(), client, logger.Sugar().Named("subscription_service"))
 
-	shutdowns = append(shutdowns, dbCleanup) //client.Close
+	renewalCtx, stopRenewals := context.WithCancel(ctx)
+	go subscriptionService.RunRenewals(renewalCtx, renewalInterval)
+
+	roleService := service.NewRoleService(dbConn, client)
+	go bootstrapAdmin(roleService, clientConnection, cfg.AdminUserID)
+
+	moderationService := service.NewModerationService(dbConn, client)
+	profileService := service.NewProfileService(dbConn, client)
+
+	rateLimits := ratelimit.NewPostgresStore(dbConn)
+	rateLimitCtx, stopRateLimits := context.WithCancel(ctx)
+	go rateLimits.Run(rateLimitCtx, rateLimitRetention, logger.Sugar().Named("rate_limits"))
+
+	srv := api.NewServer(accountService, followService, subscriptionService, roleService, moderationService, profileService, audit.NewLog(dbConn), rateLimits, probes, []byte(cfg.JWTSecret), logger.Sugar().Named("server"))
+
+	shutdowns = append(shutdowns, func() error {
+		stopRenewals()
+		stopRateLimits()
+		return nil
+	}, client.Close, dbCleanup, stopTracing)
 
 	defer logger.Sync()
 
-	go gracefulShutdown(srv.Server(), shutdown)
+	go gracefulShutdown(srv.Server(), probes, shutdown)
 
-	port := fmt.Sprintf(":%s", os.Getenv("PORT"))
+	port := fmt.Sprintf(":%d", cfg.Port)
 
 	err = srv.Listen(port)
 	if err != nil {
@@ -68,7 +123,24 @@ func main() {
 	logger.Info("Server starting and listening at port " + port)
 }
 
-func gracefulShutdown(server *fasthttp.Server, shutdown chan struct{}) {
+// bootstrapAdmin makes ADMIN_USER_ID an admin, every other role is granted through the API by an admin or broadcaster
+func bootstrapAdmin(roles service.IRoleService, connection *rabbitmq.ClientConnection, userID int) {
+	if userID == 0 {
+		return
+	}
+
+	// The grant has to reach auth, a role stored without its event would never make it into the tokens
+	if err := connection.WaitConnected(context.Background()); err != nil {
+		return
+	}
+
+	// The user may not have registered yet, the next start will pick them up
+	if err := roles.GrantPlatformRole(context.Background(), userID, token.RoleAdmin, 0); err != nil {
+		logger.Warn("failed to grant the admin role", zap.Int("user_id", userID), zap.Error(err))
+	}
+}
+
+func gracefulShutdown(server *fasthttp.Server, probes *health.Health, shutdown chan struct{}) {
 	var (
 		sigint = make(chan os.Signal, 1)
 	)
@@ -78,6 +150,10 @@ func gracefulShutdown(server *fasthttp.Server, shutdown chan struct{}) {
 
 	logger.Info("shutting down server gracefully")
 
+	// fail the readiness so the traffic drains before the server stops.
+	probes.Drain()
+	time.Sleep(drainDelay)
+
 	// stop receiving any request.
 	if err := server.Shutdown(); err != nil {
 		logger.Fatal("shutdown error", zap.Error(err))
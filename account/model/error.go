@@ -0,0 +1,28 @@
+package model
+
+import "errors"
+
+var (
+	UserNotFoundError     = errors.New("user not found")
+	SelfFollowError       = errors.New("users can't follow themselves")
+	AlreadyFollowingError = errors.New("already following the user")
+	NotFollowingError     = errors.New("not following the user")
+	InvalidCursorError    = errors.New("invalid cursor")
+
+	PlanNotFoundError      = errors.New("the channel has no such subscription plan")
+	SelfSubscribeError     = errors.New("users can't subscribe to themselves")
+	AlreadySubscribedError = errors.New("already subscribed to the channel")
+	NotSubscribedError     = errors.New("not subscribed to the channel")
+	PaymentFailedError     = errors.New("the payment failed")
+
+	InvalidRoleError  = errors.New("unknown role")
+	SelfGrantError    = errors.New("broadcasters already hold every role in their own channel")
+	RoleNotFoundError = errors.New("the user doesn't hold the role")
+
+	CannotBanBroadcasterError = errors.New("the broadcaster can't be banned from their own channel")
+	AlreadyBannedError        = errors.New("the user is already banned from the channel")
+	NotBannedError            = errors.New("the user isn't banned from the channel")
+	NotSuspendedError         = errors.New("the user isn't suspended")
+	SelfBlockError            = errors.New("users can't block themselves")
+	NotBlockedError           = errors.New("the user isn't blocked")
+)
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Follow is an entry of a followers or following list, the user on the other side of the follow
+type Follow struct {
+	ID         int       `json:"id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
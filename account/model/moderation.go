@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Ban keeps a user out of a channel's chat, a timeout is a ban with an expiry
+type Ban struct {
+	ChannelID   int        `json:"channel_id"`
+	UserID      int        `json:"user_id"`
+	ModeratorID *int       `json:"moderator_id"`
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Suspension locks the user out of the whole platform
+type Suspension struct {
+	UserID    int        `json:"user_id"`
+	ActorID   *int       `json:"actor_id"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type Block struct {
+	UserID    int       `json:"user_id"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
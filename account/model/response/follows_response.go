@@ -0,0 +1,9 @@
+package response
+
+import "nikolamilovic/twitchy/accounts/model"
+
+type FollowsResponse struct {
+	Users []model.Follow `json:"users"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor"`
+}
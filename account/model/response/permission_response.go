@@ -0,0 +1,8 @@
+package response
+
+type PermissionResponse struct {
+	UserID    int    `json:"user_id"`
+	ChannelID int    `json:"channel_id"`
+	Role      string `json:"role"`
+	Allowed   bool   `json:"allowed"`
+}
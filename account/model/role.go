@@ -0,0 +1,32 @@
+package model
+
+import (
+	"nikolamilovic/twitchy/common/token"
+	"time"
+)
+
+type ChannelRole struct {
+	ChannelID int       `json:"channel_id"`
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Roles are every role a user holds, the broadcaster role of their own channel is implied
+type Roles struct {
+	UserID   int           `json:"user_id"`
+	Platform []string      `json:"platform"`
+	Channels []ChannelRole `json:"channels"`
+}
+
+// Scopes returns the roles the way they are embedded in the tokens
+func (r Roles) Scopes() []string {
+	scopes := make([]string, 0, len(r.Platform)+len(r.Channels)+1)
+	scopes = append(scopes, r.Platform...)
+	scopes = append(scopes, token.ChannelScope(token.RoleBroadcaster, r.UserID))
+	for _, role := range r.Channels {
+		scopes = append(scopes, token.ChannelScope(role.Role, role.ChannelID))
+	}
+
+	return scopes
+}
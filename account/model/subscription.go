@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const (
+	// Pending subscriptions are waiting on their first payment
+	SubscriptionStatusPending = "pending"
+	SubscriptionStatusActive  = "active"
+	// Cancelled subscriptions don't renew but keep their benefits until the period ends
+	SubscriptionStatusCancelled = "cancelled"
+	SubscriptionStatusEnded     = "ended"
+)
+
+// Plan is one of the tiers a channel can be subscribed at
+type Plan struct {
+	ChannelID  int    `json:"channel_id"`
+	Tier       int    `json:"tier"`
+	Name       string `json:"name"`
+	PriceCents int    `json:"price_cents"`
+	Currency   string `json:"currency"`
+	Active     bool   `json:"active"`
+}
+
+type Subscription struct {
+	ID           int        `json:"id"`
+	SubscriberID int        `json:"subscriber_id"`
+	ChannelID    int        `json:"channel_id"`
+	Tier         int        `json:"tier"`
+	Status       string     `json:"status"`
+	AutoRenew    bool       `json:"auto_renew"`
+	PeriodStart  time.Time  `json:"period_start"`
+	PeriodEnd    time.Time  `json:"period_end"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+}
@@ -1,7 +1,21 @@
 package model
 
 type User struct {
-	ID       string
-	Email    string
-	Username string
+	ID             int    `json:"id"`
+	Email          string `json:"-"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
+	FollowersCount int    `json:"followers_count"`
+	FollowingCount int    `json:"following_count"`
+}
+
+// AdminUser is a user as admins see them, with the email and whether a suspension is in effect
+type AdminUser struct {
+	ID             int    `json:"id"`
+	Email          string `json:"email"`
+	Username       string `json:"username"`
+	FollowersCount int    `json:"followers_count"`
+	FollowingCount int    `json:"following_count"`
+	Suspended      bool   `json:"suspended"`
 }
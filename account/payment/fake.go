@@ -0,0 +1,63 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider accepts every charge except the ones of the users in Declined, for tests and development
+type FakeProvider struct {
+	mu       sync.Mutex
+	Declined map[int]bool
+	Charges  []Charge
+	// Refunds are the idempotency keys of the refunded charges
+	Refunds []string
+
+	refs     map[string]string
+	refunded map[string]bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		Declined: map[int]bool{},
+		refs:     map[string]string{},
+		refunded: map[string]bool{},
+	}
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, charge Charge) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ref, ok := p.refs[charge.IdempotencyKey]; ok {
+		return ref, nil
+	}
+
+	if p.Declined[charge.UserID] {
+		return "", DeclinedError
+	}
+
+	p.Charges = append(p.Charges, charge)
+
+	ref := fmt.Sprintf("fake_%d", len(p.Charges))
+	if charge.IdempotencyKey != "" {
+		p.refs[charge.IdempotencyKey] = ref
+	}
+
+	return ref, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.refs[idempotencyKey]; !ok || p.refunded[idempotencyKey] {
+		return nil
+	}
+
+	p.refunded[idempotencyKey] = true
+	p.Refunds = append(p.Refunds, idempotencyKey)
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package payment
+
+import (
+	"context"
+	"errors"
+)
+
+// DeclinedError is returned when the provider refused the charge, as opposed to failing to process it
+var DeclinedError = errors.New("payment declined")
+
+// Charge is a single payment taken from a user
+type Charge struct {
+	// IdempotencyKey identifies the charge, charging the same key again returns the first charge instead of taking another
+	IdempotencyKey string
+	UserID         int
+	AmountCents    int
+	Currency       string
+	Description    string
+}
+
+// Provider takes payments, implementations wrap a payment processor
+type Provider interface {
+	// Charge takes the payment and returns the provider's reference of it
+	Charge(ctx context.Context, charge Charge) (string, error)
+	// Refund gives back the charge taken with the idempotency key, there is nothing to do when none was taken or it was
+	// already refunded
+	Refund(ctx context.Context, idempotencyKey string) error
+}
@@ -3,12 +3,18 @@ package service
 import (
 	"context"
 	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
 	db "nikolamilovic/twitchy/common/db"
 	event "nikolamilovic/twitchy/common/event"
+	"strconv"
+	"strings"
 )
 
 type IAccountService interface {
-	CreateUser(ev event.AccountCreatedEventData) error
+	CreateUser(ctx context.Context, ev event.AccountCreatedEventData) error
+	GetUser(id int) (model.User, error)
+	// SearchUsers matches the query against the ID, or as a prefix of the email and username
+	SearchUsers(query string, limit int) ([]model.AdminUser, error)
 }
 
 type AccountService struct {
@@ -21,8 +27,8 @@ func NewAccountService(db db.PgxIface) IAccountService {
 	}
 }
 
-func (s *AccountService) CreateUser(ev event.AccountCreatedEventData) error {
-	rows, err := s.DB.Query(context.Background(), "INSERT INTO users (id, email, username) VALUES ($1,$2,$3)", ev.ID, ev.Email, ev.Username)
+func (s *AccountService) CreateUser(ctx context.Context, ev event.AccountCreatedEventData) error {
+	rows, err := s.DB.Query(ctx, "INSERT INTO users (id, email, username) VALUES ($1,$2,$3)", ev.ID, ev.Email, ev.Username)
 
 	if err != nil {
 		return fmt.Errorf("CreateUser %w", err)
@@ -34,3 +40,64 @@ func (s *AccountService) CreateUser(ev event.AccountCreatedEventData) error {
 
 	return nil
 }
+
+func (s *AccountService) GetUser(id int) (model.User, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT id, email, username, display_name, bio, followers_count, following_count FROM users WHERE id = $1", id)
+
+	if err != nil {
+		return model.User{}, fmt.Errorf("GetUser: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.User{}, fmt.Errorf("GetUser: %w", model.UserNotFoundError)
+	}
+
+	var user model.User
+	err = rows.Scan(&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.Bio, &user.FollowersCount, &user.FollowingCount)
+	if err != nil {
+		return model.User{}, fmt.Errorf("GetUser: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *AccountService) SearchUsers(query string, limit int) ([]model.AdminUser, error) {
+	var id interface{}
+	if n, err := strconv.Atoi(query); err == nil {
+		id = n
+	}
+
+	rows, err := s.DB.Query(context.Background(), `
+		SELECT u.id, u.email, u.username, u.followers_count, u.following_count,
+			EXISTS (SELECT 1 FROM suspensions s WHERE s.user_id = u.id AND (s.expires_at IS NULL OR s.expires_at > NOW()))
+		FROM users u
+		WHERE u.id = $1 OR u.email ILIKE $2 OR u.username ILIKE $2
+		ORDER BY u.id LIMIT $3`,
+		id, likePrefix(query), limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("SearchUsers: %w", err)
+	}
+
+	defer rows.Close()
+
+	users := []model.AdminUser{}
+	for rows.Next() {
+		var user model.AdminUser
+		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.FollowersCount, &user.FollowingCount, &user.Suspended)
+		if err != nil {
+			return nil, fmt.Errorf("SearchUsers: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// likePrefix escapes the wildcards of the query so it only matches as a prefix
+func likePrefix(query string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+}
@@ -24,7 +24,7 @@ func TestUserCreation(t *testing.T) {
 
 	mock.ExpectQuery("INSERT INTO users").WithArgs(1, "email@gmail.com", "username").WillReturnRows(rows)
 
-	err = sut.CreateUser(event.AccountCreatedEventData{
+	err = sut.CreateUser(context.Background(), event.AccountCreatedEventData{
 		ID:       1,
 		Email:    "email@gmail.com",
 		Username: "username",
@@ -34,3 +34,44 @@ func TestUserCreation(t *testing.T) {
 		t.Fatalf("an error '%s' was not expected when creating user", err)
 	}
 }
+
+func TestSearchUsers(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+
+	sut := &AccountService{
+		DB: mock,
+	}
+
+	type searchTest struct {
+		query   string
+		id      interface{}
+		pattern string
+	}
+
+	for _, scenario := range []searchTest{
+		{"42", 42, "42%"},
+		{"nik", nil, "nik%"},
+		{"50%_off", nil, `50\%\_off%`},
+	} {
+		mock.ExpectQuery("SELECT (.+) FROM users u").WithArgs(scenario.id, scenario.pattern, 20).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "email", "username", "followers_count", "following_count", "suspended"}).
+				AddRow(42, "nik@gmail.com", "nik", 0, 0, true))
+
+		users, err := sut.SearchUsers(scenario.query, 20)
+		if err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+
+		if len(users) != 1 || !users[0].Suspended || users[0].Email != "nik@gmail.com" {
+			t.Fatalf("Expected the suspended user 42, got %+v", users)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
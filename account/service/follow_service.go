@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// foreign_key_violation, the followed user doesn't exist
+const foreignKeyViolation = "23503"
+
+type IFollowService interface {
+	Follow(ctx context.Context, followerID, followedID int) error
+	Unfollow(ctx context.Context, followerID, followedID int) error
+	// GetFollowers returns a page of the users following userID, newest first, and the cursor of the next page
+	GetFollowers(userID int, cursor string, limit int) ([]model.Follow, string, error)
+	// GetFollowing returns a page of the users followed by userID, newest first, and the cursor of the next page
+	GetFollowing(userID int, cursor string, limit int) ([]model.Follow, string, error)
+}
+
+type IFollowPublisher interface {
+	PublishUserFollowedEvent(ctx context.Context, data event.UserFollowedEventData) error
+	PublishUserUnfollowedEvent(ctx context.Context, data event.UserUnfollowedEventData) error
+}
+
+type FollowService struct {
+	DB        db.PgxIface
+	Publisher IFollowPublisher
+}
+
+func NewFollowService(db db.PgxIface, publisher IFollowPublisher) IFollowService {
+	return &FollowService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+// Follow stores the follow and bumps both counters in a single statement so they can't drift apart
+func (s *FollowService) Follow(ctx context.Context, followerID, followedID int) error {
+	if followerID == followedID {
+		return fmt.Errorf("Follow: %w", model.SelfFollowError)
+	}
+
+	rows, err := s.DB.Query(ctx, `
+		WITH inserted AS (
+			INSERT INTO follows (follower_id, followed_id) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING follower_id, followed_id, created_at
+		), following AS (
+			UPDATE users SET following_count = following_count + 1 WHERE id IN (SELECT follower_id FROM inserted)
+		), followers AS (
+			UPDATE users SET followers_count = followers_count + 1 WHERE id IN (SELECT followed_id FROM inserted)
+		)
+		SELECT created_at FROM inserted`, followerID, followedID)
+
+	if err != nil {
+		return fmt.Errorf("Follow: %w", followError(err))
+	}
+
+	var followedAt time.Time
+	inserted := rows.Next()
+	if inserted {
+		err = rows.Scan(&followedAt)
+	}
+	rows.Close()
+
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("Follow: %w", followError(err))
+	}
+
+	if !inserted {
+		return fmt.Errorf("Follow: %w", model.AlreadyFollowingError)
+	}
+
+	return s.Publisher.PublishUserFollowedEvent(ctx, event.UserFollowedEventData{
+		FollowerID: followerID,
+		FollowedID: followedID,
+		FollowedAt: followedAt,
+	})
+}
+
+func (s *FollowService) Unfollow(ctx context.Context, followerID, followedID int) error {
+	tag, err := s.DB.Exec(ctx, `
+		WITH deleted AS (
+			DELETE FROM follows WHERE follower_id = $1 AND followed_id = $2 RETURNING follower_id, followed_id
+		), following AS (
+			UPDATE users SET following_count = following_count - 1 WHERE id IN (SELECT follower_id FROM deleted)
+		)
+		UPDATE users SET followers_count = followers_count - 1 WHERE id IN (SELECT followed_id FROM deleted)`, followerID, followedID)
+
+	if err != nil {
+		return fmt.Errorf("Unfollow: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("Unfollow: %w", model.NotFollowingError)
+	}
+
+	return s.Publisher.PublishUserUnfollowedEvent(ctx, event.UserUnfollowedEventData{
+		FollowerID: followerID,
+		FollowedID: followedID,
+	})
+}
+
+func (s *FollowService) GetFollowers(userID int, cursor string, limit int) ([]model.Follow, string, error) {
+	follows, next, err := s.list("follower_id", "followed_id", userID, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetFollowers: %w", err)
+	}
+
+	return follows, next, nil
+}
+
+func (s *FollowService) GetFollowing(userID int, cursor string, limit int) ([]model.Follow, string, error) {
+	follows, next, err := s.list("followed_id", "follower_id", userID, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("GetFollowing: %w", err)
+	}
+
+	return follows, next, nil
+}
+
+// list pages through the follows of userID on the by column, returning the users on the other column.
+// Pages are keyed on (created_at, user id) so follows made while paging don't shift the results.
+func (s *FollowService) list(other, by string, userID int, cursor string, limit int) ([]model.Follow, string, error) {
+	query := "SELECT u.id, u.username, f.created_at FROM follows f JOIN users u ON u.id = f." + other +
+		" WHERE f." + by + " = $1"
+	args := []interface{}{userID}
+
+	if cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += " AND (f.created_at, f." + other + ") < ($2, $3)"
+		args = append(args, createdAt, id)
+	}
+
+	// One extra row tells us whether there is a next page
+	query += fmt.Sprintf(" ORDER BY f.created_at DESC, f.%s DESC LIMIT $%d", other, len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := s.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	follows := []model.Follow{}
+	for rows.Next() {
+		var follow model.Follow
+		if err := rows.Scan(&follow.ID, &follow.Username, &follow.FollowedAt); err != nil {
+			return nil, "", err
+		}
+		follows = append(follows, follow)
+	}
+
+	if len(follows) <= limit {
+		return follows, "", nil
+	}
+
+	follows = follows[:limit]
+	last := follows[limit-1]
+
+	return follows, encodeCursor(last.FollowedAt, last.ID), nil
+}
+
+func followError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return model.UserNotFoundError
+	}
+	return err
+}
+
+func encodeCursor(createdAt time.Time, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d_%d", createdAt.UnixMicro(), id)))
+}
+
+func decodeCursor(cursor string) (time.Time, int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, model.InvalidCursorError
+	}
+
+	parts := strings.Split(string(data), "_")
+	if len(parts) != 2 {
+		return time.Time{}, 0, model.InvalidCursorError
+	}
+
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, model.InvalidCursorError
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, 0, model.InvalidCursorError
+	}
+
+	return time.UnixMicro(micros), id, nil
+}
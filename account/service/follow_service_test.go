@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestFollow(t *testing.T) {
+	type followTest struct {
+		description     string
+		followerID      int
+		followedID      int
+		rows            *pgxmock.Rows
+		queryErr        error
+		expectedErr     error
+		expectPublished int
+	}
+
+	followedAt := time.Now()
+
+	for _, scenario := range []followTest{
+		{
+			description:     "new follow",
+			followerID:      1,
+			followedID:      2,
+			rows:            pgxmock.NewRows([]string{"created_at"}).AddRow(followedAt),
+			expectPublished: 1,
+		},
+		{
+			description: "duplicate follow",
+			followerID:  1,
+			followedID:  2,
+			rows:        pgxmock.NewRows([]string{"created_at"}),
+			expectedErr: model.AlreadyFollowingError,
+		},
+		{
+			description: "unknown user",
+			followerID:  1,
+			followedID:  3,
+			queryErr:    &pgconn.PgError{Code: foreignKeyViolation},
+			expectedErr: model.UserNotFoundError,
+		},
+		{
+			description: "self follow",
+			followerID:  1,
+			followedID:  1,
+			expectedErr: model.SelfFollowError,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			publisher := &mock.FollowPublisherMock{}
+			sut := &FollowService{
+				DB:        mockDB,
+				Publisher: publisher,
+			}
+
+			if scenario.rows != nil {
+				mockDB.ExpectQuery("INSERT INTO follows").WithArgs(scenario.followerID, scenario.followedID).WillReturnRows(scenario.rows)
+			}
+			if scenario.queryErr != nil {
+				mockDB.ExpectQuery("INSERT INTO follows").WithArgs(scenario.followerID, scenario.followedID).WillReturnError(scenario.queryErr)
+			}
+
+			err = sut.Follow(context.Background(), scenario.followerID, scenario.followedID)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unfulfilled expectations: %s", err)
+			}
+
+			if len(publisher.Followed) != scenario.expectPublished {
+				t.Fatalf("Expected %d events, got %d", scenario.expectPublished, len(publisher.Followed))
+			}
+
+			if scenario.expectPublished > 0 && !publisher.Followed[0].FollowedAt.Equal(followedAt) {
+				t.Fatalf("Expected the event to carry the follow time, got %v", publisher.Followed[0])
+			}
+		})
+	}
+}
+
+func TestUnfollow(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.FollowPublisherMock{}
+	sut := &FollowService{
+		DB:        mockDB,
+		Publisher: publisher,
+	}
+
+	mockDB.ExpectExec("DELETE FROM follows").WithArgs(1, 2).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mockDB.ExpectExec("DELETE FROM follows").WithArgs(1, 2).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := sut.Unfollow(context.Background(), 1, 2); err != nil {
+		t.Fatalf("an error '%s' was not expected when unfollowing", err)
+	}
+
+	if err := sut.Unfollow(context.Background(), 1, 2); !errors.Is(err, model.NotFollowingError) {
+		t.Fatalf("Expected error to be %v, got %v", model.NotFollowingError, err)
+	}
+
+	if len(publisher.Unfollowed) != 1 {
+		t.Fatalf("Expected %d events, got %d", 1, len(publisher.Unfollowed))
+	}
+}
+
+func TestGetFollowersPages(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &FollowService{DB: mockDB}
+
+	newest := time.Now().Truncate(time.Microsecond)
+	older := newest.Add(-time.Minute)
+	oldest := newest.Add(-time.Hour)
+
+	// GIVEN a first page of two with a third follower left
+	mockDB.ExpectQuery("SELECT u.id, u.username, f.created_at FROM follows f").WithArgs(1, 3).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "username", "created_at"}).
+			AddRow(4, "four", newest).
+			AddRow(3, "three", older).
+			AddRow(2, "two", oldest))
+
+	// WHEN the next page is requested it SHOULD continue after the last follower of the first page
+	mockDB.ExpectQuery("SELECT u.id, u.username, f.created_at FROM follows f (.+) < \\(\\$2, \\$3\\)").WithArgs(1, older, 3, 3).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "username", "created_at"}).
+			AddRow(2, "two", oldest))
+
+	page, cursor, err := sut.GetFollowers(1, "", 2)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listing followers", err)
+	}
+
+	if len(page) != 2 || page[1].ID != 3 || cursor == "" {
+		t.Fatalf("Expected the first two followers and a cursor, got %v and %q", page, cursor)
+	}
+
+	page, cursor, err = sut.GetFollowers(1, cursor, 2)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listing followers", err)
+	}
+
+	if len(page) != 1 || page[0].ID != 2 || cursor != "" {
+		t.Fatalf("Expected the last follower without a cursor, got %v and %q", page, cursor)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if _, _, err := sut.GetFollowers(1, "not a cursor", 2); !errors.Is(err, model.InvalidCursorError) {
+		t.Fatalf("Expected error to be %v, got %v", model.InvalidCursorError, err)
+	}
+}
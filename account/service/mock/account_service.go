@@ -1,10 +1,25 @@
 package mock
 
-import "nikolamilovic/twitchy/common/event"
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+)
 
 type AccountServiceMock struct {
 }
 
-func (a *AccountServiceMock) CreateUser(ev event.AccountCreatedEventData) error {
+func (a *AccountServiceMock) CreateUser(ctx context.Context, ev event.AccountCreatedEventData) error {
 	return nil
 }
+
+func (a *AccountServiceMock) GetUser(id int) (model.User, error) {
+	if id == 404 {
+		return model.User{}, model.UserNotFoundError
+	}
+	return model.User{ID: id, Username: "username", FollowersCount: 1}, nil
+}
+
+func (a *AccountServiceMock) SearchUsers(query string, limit int) ([]model.AdminUser, error) {
+	return []model.AdminUser{{ID: 1, Email: query + "@gmail.com", Username: query}}, nil
+}
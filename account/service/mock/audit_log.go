@@ -0,0 +1,16 @@
+package mock
+
+import "nikolamilovic/twitchy/common/audit"
+
+type AuditLogMock struct {
+	Entries []audit.Entry
+}
+
+func (l *AuditLogMock) Record(entry audit.Entry) error {
+	l.Entries = append(l.Entries, entry)
+	return nil
+}
+
+func (l *AuditLogMock) List(filter audit.Filter) ([]audit.Entry, error) {
+	return l.Entries, nil
+}
@@ -0,0 +1,58 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+)
+
+type FollowServiceMock struct {
+	Follows   [][2]int
+	Unfollows [][2]int
+}
+
+func (f *FollowServiceMock) Follow(ctx context.Context, followerID, followedID int) error {
+	if followerID == followedID {
+		return model.SelfFollowError
+	}
+	if followedID == 409 {
+		return model.AlreadyFollowingError
+	}
+	f.Follows = append(f.Follows, [2]int{followerID, followedID})
+	return nil
+}
+
+func (f *FollowServiceMock) Unfollow(ctx context.Context, followerID, followedID int) error {
+	if followedID == 404 {
+		return model.NotFollowingError
+	}
+	f.Unfollows = append(f.Unfollows, [2]int{followerID, followedID})
+	return nil
+}
+
+func (f *FollowServiceMock) GetFollowers(userID int, cursor string, limit int) ([]model.Follow, string, error) {
+	if cursor == "broken" {
+		return nil, "", model.InvalidCursorError
+	}
+	return []model.Follow{{ID: 2, Username: "follower"}}, "next", nil
+}
+
+func (f *FollowServiceMock) GetFollowing(userID int, cursor string, limit int) ([]model.Follow, string, error) {
+	return []model.Follow{{ID: 3, Username: "followed"}}, "", nil
+}
+
+// FollowPublisherMock records the published events
+type FollowPublisherMock struct {
+	Followed   []event.UserFollowedEventData
+	Unfollowed []event.UserUnfollowedEventData
+}
+
+func (p *FollowPublisherMock) PublishUserFollowedEvent(ctx context.Context, data event.UserFollowedEventData) error {
+	p.Followed = append(p.Followed, data)
+	return nil
+}
+
+func (p *FollowPublisherMock) PublishUserUnfollowedEvent(ctx context.Context, data event.UserUnfollowedEventData) error {
+	p.Unfollowed = append(p.Unfollowed, data)
+	return nil
+}
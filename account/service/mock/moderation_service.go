@@ -0,0 +1,131 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+	"time"
+)
+
+type ModerationServiceMock struct {
+	Timeouts []time.Duration
+}
+
+func (m *ModerationServiceMock) Ban(ctx context.Context, channelID, userID, moderatorID int, reason string) (model.Ban, error) {
+	switch {
+	case channelID == userID:
+		return model.Ban{}, model.CannotBanBroadcasterError
+	case userID == 409:
+		return model.Ban{}, model.AlreadyBannedError
+	}
+	return model.Ban{ChannelID: channelID, UserID: userID, ModeratorID: &moderatorID, Reason: reason}, nil
+}
+
+func (m *ModerationServiceMock) Timeout(ctx context.Context, channelID, userID, moderatorID int, reason string, duration time.Duration) (model.Ban, error) {
+	m.Timeouts = append(m.Timeouts, duration)
+	expiresAt := time.Now().Add(duration)
+	return model.Ban{ChannelID: channelID, UserID: userID, ModeratorID: &moderatorID, Reason: reason, ExpiresAt: &expiresAt}, nil
+}
+
+func (m *ModerationServiceMock) Unban(ctx context.Context, channelID, userID, moderatorID int) error {
+	if userID == 404 {
+		return model.NotBannedError
+	}
+	return nil
+}
+
+func (m *ModerationServiceMock) GetBans(channelID int) ([]model.Ban, error) {
+	return []model.Ban{{ChannelID: channelID, UserID: 3}}, nil
+}
+
+func (m *ModerationServiceMock) GetBan(channelID, userID int) (model.Ban, error) {
+	if userID == 404 {
+		return model.Ban{}, model.NotBannedError
+	}
+	return model.Ban{ChannelID: channelID, UserID: userID}, nil
+}
+
+func (m *ModerationServiceMock) Suspend(ctx context.Context, userID, actorID int, reason string, duration time.Duration) (model.Suspension, error) {
+	if userID == 404 {
+		return model.Suspension{}, model.UserNotFoundError
+	}
+	return model.Suspension{UserID: userID, ActorID: &actorID, Reason: reason}, nil
+}
+
+func (m *ModerationServiceMock) Unsuspend(ctx context.Context, userID, actorID int) error {
+	if userID == 404 {
+		return model.NotSuspendedError
+	}
+	return nil
+}
+
+func (m *ModerationServiceMock) GetSuspension(userID int) (model.Suspension, error) {
+	if userID == 404 {
+		return model.Suspension{}, model.NotSuspendedError
+	}
+	return model.Suspension{UserID: userID}, nil
+}
+
+func (m *ModerationServiceMock) Block(ctx context.Context, blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return model.SelfBlockError
+	}
+	return nil
+}
+
+func (m *ModerationServiceMock) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	if blockedID == 404 {
+		return model.NotBlockedError
+	}
+	return nil
+}
+
+func (m *ModerationServiceMock) GetBlocked(userID int) ([]model.Block, error) {
+	return []model.Block{{UserID: 3}}, nil
+}
+
+// ModerationPublisherMock records the published events
+type ModerationPublisherMock struct {
+	Banned      []event.UserBannedEventData
+	TimedOut    []event.UserTimedOutEventData
+	Unbanned    []event.UserUnbannedEventData
+	Suspended   []event.UserSuspendedEventData
+	Unsuspended []event.UserUnsuspendedEventData
+	Blocked     []event.UserBlockedEventData
+	Unblocked   []event.UserUnblockedEventData
+}
+
+func (p *ModerationPublisherMock) PublishUserBannedEvent(ctx context.Context, data event.UserBannedEventData) error {
+	p.Banned = append(p.Banned, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserTimedOutEvent(ctx context.Context, data event.UserTimedOutEventData) error {
+	p.TimedOut = append(p.TimedOut, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserUnbannedEvent(ctx context.Context, data event.UserUnbannedEventData) error {
+	p.Unbanned = append(p.Unbanned, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserSuspendedEvent(ctx context.Context, data event.UserSuspendedEventData) error {
+	p.Suspended = append(p.Suspended, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserUnsuspendedEvent(ctx context.Context, data event.UserUnsuspendedEventData) error {
+	p.Unsuspended = append(p.Unsuspended, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserBlockedEvent(ctx context.Context, data event.UserBlockedEventData) error {
+	p.Blocked = append(p.Blocked, data)
+	return nil
+}
+
+func (p *ModerationPublisherMock) PublishUserUnblockedEvent(ctx context.Context, data event.UserUnblockedEventData) error {
+	p.Unblocked = append(p.Unblocked, data)
+	return nil
+}
@@ -0,0 +1,30 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+)
+
+type ProfileServiceMock struct {
+	Updated []model.User
+}
+
+func (p *ProfileServiceMock) UpdateProfile(ctx context.Context, userID int, displayName, bio string) (model.User, error) {
+	if userID == 404 {
+		return model.User{}, model.UserNotFoundError
+	}
+	user := model.User{ID: userID, Username: "username", DisplayName: displayName, Bio: bio}
+	p.Updated = append(p.Updated, user)
+	return user, nil
+}
+
+// ProfilePublisherMock records the published events
+type ProfilePublisherMock struct {
+	Updated []event.AccountUpdatedEventData
+}
+
+func (p *ProfilePublisherMock) PublishAccountUpdatedEvent(ctx context.Context, data event.AccountUpdatedEventData) error {
+	p.Updated = append(p.Updated, data)
+	return nil
+}
@@ -0,0 +1,79 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/token"
+)
+
+type RoleServiceMock struct {
+	Granted []event.RoleGrantedEventData
+	Revoked []event.RoleRevokedEventData
+}
+
+func (r *RoleServiceMock) GetRoles(userID int) (model.Roles, error) {
+	return model.Roles{UserID: userID, Platform: []string{}, Channels: []model.ChannelRole{{ChannelID: 2, UserID: userID, Role: token.RoleModerator}}}, nil
+}
+
+func (r *RoleServiceMock) GetChannelRoles(channelID int) ([]model.ChannelRole, error) {
+	return []model.ChannelRole{{ChannelID: channelID, UserID: 3, Role: token.RoleVIP}}, nil
+}
+
+func (r *RoleServiceMock) Check(userID, channelID int, role string) (bool, error) {
+	if role != token.RoleModerator {
+		return false, model.InvalidRoleError
+	}
+	return channelID == 2, nil
+}
+
+func (r *RoleServiceMock) GrantPlatformRole(ctx context.Context, userID int, role string, grantedBy int) error {
+	if !token.IsPlatformRole(role) {
+		return model.InvalidRoleError
+	}
+	r.Granted = append(r.Granted, event.RoleGrantedEventData{UserID: userID, Role: role, GrantedBy: grantedBy})
+	return nil
+}
+
+func (r *RoleServiceMock) RevokePlatformRole(ctx context.Context, userID int, role string, revokedBy int) error {
+	if userID == 404 {
+		return model.RoleNotFoundError
+	}
+	r.Revoked = append(r.Revoked, event.RoleRevokedEventData{UserID: userID, Role: role, RevokedBy: revokedBy})
+	return nil
+}
+
+func (r *RoleServiceMock) GrantChannelRole(ctx context.Context, channelID, userID int, role string, grantedBy int) error {
+	if !token.IsChannelRole(role) {
+		return model.InvalidRoleError
+	}
+	if channelID == userID {
+		return model.SelfGrantError
+	}
+	r.Granted = append(r.Granted, event.RoleGrantedEventData{UserID: userID, Role: role, ChannelID: channelID, GrantedBy: grantedBy})
+	return nil
+}
+
+func (r *RoleServiceMock) RevokeChannelRole(ctx context.Context, channelID, userID int, role string, revokedBy int) error {
+	if userID == 404 {
+		return model.RoleNotFoundError
+	}
+	r.Revoked = append(r.Revoked, event.RoleRevokedEventData{UserID: userID, Role: role, ChannelID: channelID, RevokedBy: revokedBy})
+	return nil
+}
+
+// RolePublisherMock records the published events
+type RolePublisherMock struct {
+	Granted []event.RoleGrantedEventData
+	Revoked []event.RoleRevokedEventData
+}
+
+func (p *RolePublisherMock) PublishRoleGrantedEvent(ctx context.Context, data event.RoleGrantedEventData) error {
+	p.Granted = append(p.Granted, data)
+	return nil
+}
+
+func (p *RolePublisherMock) PublishRoleRevokedEvent(ctx context.Context, data event.RoleRevokedEventData) error {
+	p.Revoked = append(p.Revoked, data)
+	return nil
+}
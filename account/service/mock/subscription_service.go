@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/event"
+	"time"
+)
+
+type SubscriptionServiceMock struct {
+	Plans []model.Plan
+}
+
+func (s *SubscriptionServiceMock) GetPlans(channelID int) ([]model.Plan, error) {
+	return []model.Plan{{ChannelID: channelID, Tier: 1, Name: "Tier 1", PriceCents: 499, Currency: "USD", Active: true}}, nil
+}
+
+func (s *SubscriptionServiceMock) SetPlan(plan model.Plan) error {
+	s.Plans = append(s.Plans, plan)
+	return nil
+}
+
+func (s *SubscriptionServiceMock) Subscribe(ctx context.Context, subscriberID, channelID, tier int) (model.Subscription, error) {
+	switch {
+	case subscriberID == channelID:
+		return model.Subscription{}, model.SelfSubscribeError
+	case channelID == 409:
+		return model.Subscription{}, model.AlreadySubscribedError
+	case channelID == 402:
+		return model.Subscription{}, model.PaymentFailedError
+	case tier == 3:
+		return model.Subscription{}, model.PlanNotFoundError
+	}
+	return model.Subscription{SubscriberID: subscriberID, ChannelID: channelID, Tier: tier, Status: model.SubscriptionStatusActive}, nil
+}
+
+func (s *SubscriptionServiceMock) Cancel(ctx context.Context, subscriberID, channelID int) (model.Subscription, error) {
+	if channelID == 404 {
+		return model.Subscription{}, model.NotSubscribedError
+	}
+	return model.Subscription{SubscriberID: subscriberID, ChannelID: channelID, Tier: 1, Status: model.SubscriptionStatusCancelled}, nil
+}
+
+func (s *SubscriptionServiceMock) GetSubscription(subscriberID, channelID int) (model.Subscription, error) {
+	if subscriberID == 404 {
+		return model.Subscription{}, model.NotSubscribedError
+	}
+	return model.Subscription{SubscriberID: subscriberID, ChannelID: channelID, Tier: 2, Status: model.SubscriptionStatusActive}, nil
+}
+
+func (s *SubscriptionServiceMock) RenewDue(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+// SubscriptionPublisherMock records the published events
+type SubscriptionPublisherMock struct {
+	Started []event.SubscriptionStartedEventData
+	Ended   []event.SubscriptionEndedEventData
+	// Err fails the publishes of the started events
+	Err error
+}
+
+func (p *SubscriptionPublisherMock) PublishSubscriptionStartedEvent(ctx context.Context, data event.SubscriptionStartedEventData) error {
+	if p.Err != nil {
+		return p.Err
+	}
+	p.Started = append(p.Started, data)
+	return nil
+}
+
+func (p *SubscriptionPublisherMock) PublishSubscriptionEndedEvent(ctx context.Context, data event.SubscriptionEndedEventData) error {
+	p.Ended = append(p.Ended, data)
+	return nil
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"time"
+)
+
+const banColumns = "channel_id, user_id, moderator_id, reason, expires_at, created_at"
+
+type IModerationService interface {
+	// Ban keeps the user out of the channel until unbanned, it replaces a timeout
+	Ban(ctx context.Context, channelID, userID, moderatorID int, reason string) (model.Ban, error)
+	// Timeout bans the user for the duration, it replaces a previous timeout but not a ban
+	Timeout(ctx context.Context, channelID, userID, moderatorID int, reason string, duration time.Duration) (model.Ban, error)
+	// Unban lifts a ban or a timeout that hasn't expired yet
+	Unban(ctx context.Context, channelID, userID, moderatorID int) error
+	// GetBans returns the bans and timeouts in effect in the channel
+	GetBans(channelID int) ([]model.Ban, error)
+	GetBan(channelID, userID int) (model.Ban, error)
+
+	// Suspend locks the user out of the platform for the duration, or until lifted when it's 0
+	Suspend(ctx context.Context, userID, actorID int, reason string, duration time.Duration) (model.Suspension, error)
+	Unsuspend(ctx context.Context, userID, actorID int) error
+	GetSuspension(userID int) (model.Suspension, error)
+
+	Block(ctx context.Context, blockerID, blockedID int) error
+	Unblock(ctx context.Context, blockerID, blockedID int) error
+	GetBlocked(userID int) ([]model.Block, error)
+}
+
+type IModerationPublisher interface {
+	PublishUserBannedEvent(ctx context.Context, data event.UserBannedEventData) error
+	PublishUserTimedOutEvent(ctx context.Context, data event.UserTimedOutEventData) error
+	PublishUserUnbannedEvent(ctx context.Context, data event.UserUnbannedEventData) error
+	PublishUserSuspendedEvent(ctx context.Context, data event.UserSuspendedEventData) error
+	PublishUserUnsuspendedEvent(ctx context.Context, data event.UserUnsuspendedEventData) error
+	PublishUserBlockedEvent(ctx context.Context, data event.UserBlockedEventData) error
+	PublishUserUnblockedEvent(ctx context.Context, data event.UserUnblockedEventData) error
+}
+
+type ModerationService struct {
+	DB        db.PgxIface
+	Publisher IModerationPublisher
+}
+
+func NewModerationService(db db.PgxIface, publisher IModerationPublisher) IModerationService {
+	return &ModerationService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+func (s *ModerationService) Ban(ctx context.Context, channelID, userID, moderatorID int, reason string) (model.Ban, error) {
+	ban, err := s.ban(ctx, channelID, userID, moderatorID, reason, nil)
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("Ban: %w", err)
+	}
+
+	err = s.Publisher.PublishUserBannedEvent(ctx, event.UserBannedEventData{
+		ChannelID:   channelID,
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Reason:      reason,
+		BannedAt:    ban.CreatedAt,
+	})
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("Ban: %w", err)
+	}
+
+	return ban, nil
+}
+
+func (s *ModerationService) Timeout(ctx context.Context, channelID, userID, moderatorID int, reason string, duration time.Duration) (model.Ban, error) {
+	expiresAt := time.Now().UTC().Add(duration)
+
+	ban, err := s.ban(ctx, channelID, userID, moderatorID, reason, &expiresAt)
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("Timeout: %w", err)
+	}
+
+	err = s.Publisher.PublishUserTimedOutEvent(ctx, event.UserTimedOutEventData{
+		ChannelID:   channelID,
+		UserID:      userID,
+		ModeratorID: moderatorID,
+		Reason:      reason,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("Timeout: %w", err)
+	}
+
+	return ban, nil
+}
+
+// ban stores the ban unless the user is already banned without an expiry
+func (s *ModerationService) ban(ctx context.Context, channelID, userID, moderatorID int, reason string, expiresAt *time.Time) (model.Ban, error) {
+	if channelID == userID {
+		return model.Ban{}, model.CannotBanBroadcasterError
+	}
+
+	rows, err := s.DB.Query(ctx, `
+		INSERT INTO channel_bans (channel_id, user_id, moderator_id, reason, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, user_id) DO UPDATE
+			SET moderator_id = EXCLUDED.moderator_id, reason = EXCLUDED.reason, expires_at = EXCLUDED.expires_at, created_at = NOW()
+			WHERE channel_bans.expires_at IS NOT NULL
+		RETURNING `+banColumns, channelID, userID, nullableID(moderatorID), reason, expiresAt)
+
+	if err != nil {
+		return model.Ban{}, followError(err)
+	}
+
+	var ban model.Ban
+	stored := rows.Next()
+	if stored {
+		ban, err = scanBan(rows)
+	}
+	rows.Close()
+
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return model.Ban{}, followError(err)
+	}
+
+	if !stored {
+		return model.Ban{}, model.AlreadyBannedError
+	}
+
+	return ban, nil
+}
+
+func (s *ModerationService) Unban(ctx context.Context, channelID, userID, moderatorID int) error {
+	tag, err := s.DB.Exec(ctx,
+		"DELETE FROM channel_bans WHERE channel_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > NOW())", channelID, userID)
+
+	if err != nil {
+		return fmt.Errorf("Unban: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("Unban: %w", model.NotBannedError)
+	}
+
+	return s.Publisher.PublishUserUnbannedEvent(ctx, event.UserUnbannedEventData{
+		ChannelID:   channelID,
+		UserID:      userID,
+		ModeratorID: moderatorID,
+	})
+}
+
+func (s *ModerationService) GetBans(channelID int) ([]model.Ban, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+banColumns+" FROM channel_bans WHERE channel_id = $1 AND (expires_at IS NULL OR expires_at > NOW()) ORDER BY created_at DESC", channelID)
+
+	if err != nil {
+		return nil, fmt.Errorf("GetBans: %w", err)
+	}
+
+	defer rows.Close()
+
+	bans := []model.Ban{}
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("GetBans: %w", err)
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
+
+func (s *ModerationService) GetBan(channelID, userID int) (model.Ban, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+banColumns+" FROM channel_bans WHERE channel_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > NOW())", channelID, userID)
+
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("GetBan: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Ban{}, fmt.Errorf("GetBan: %w", model.NotBannedError)
+	}
+
+	ban, err := scanBan(rows)
+	if err != nil {
+		return model.Ban{}, fmt.Errorf("GetBan: %w", err)
+	}
+
+	return ban, nil
+}
+
+func (s *ModerationService) Suspend(ctx context.Context, userID, actorID int, reason string, duration time.Duration) (model.Suspension, error) {
+	var expiresAt *time.Time
+	if duration > 0 {
+		t := time.Now().UTC().Add(duration)
+		expiresAt = &t
+	}
+
+	rows, err := s.DB.Query(ctx, `
+		INSERT INTO suspensions (user_id, actor_id, reason, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+			SET actor_id = EXCLUDED.actor_id, reason = EXCLUDED.reason, expires_at = EXCLUDED.expires_at, created_at = NOW()
+		RETURNING user_id, actor_id, reason, expires_at, created_at`, userID, nullableID(actorID), reason, expiresAt)
+
+	if err != nil {
+		return model.Suspension{}, fmt.Errorf("Suspend: %w", followError(err))
+	}
+
+	var suspension model.Suspension
+	if rows.Next() {
+		suspension, err = scanSuspension(rows)
+	}
+	rows.Close()
+
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return model.Suspension{}, fmt.Errorf("Suspend: %w", followError(err))
+	}
+
+	err = s.Publisher.PublishUserSuspendedEvent(ctx, event.UserSuspendedEventData{
+		UserID:    userID,
+		ActorID:   actorID,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return model.Suspension{}, fmt.Errorf("Suspend: %w", err)
+	}
+
+	return suspension, nil
+}
+
+func (s *ModerationService) Unsuspend(ctx context.Context, userID, actorID int) error {
+	tag, err := s.DB.Exec(ctx,
+		"DELETE FROM suspensions WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())", userID)
+
+	if err != nil {
+		return fmt.Errorf("Unsuspend: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("Unsuspend: %w", model.NotSuspendedError)
+	}
+
+	return s.Publisher.PublishUserUnsuspendedEvent(ctx, event.UserUnsuspendedEventData{
+		UserID:  userID,
+		ActorID: actorID,
+	})
+}
+
+func (s *ModerationService) GetSuspension(userID int) (model.Suspension, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT user_id, actor_id, reason, expires_at, created_at FROM suspensions WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())", userID)
+
+	if err != nil {
+		return model.Suspension{}, fmt.Errorf("GetSuspension: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Suspension{}, fmt.Errorf("GetSuspension: %w", model.NotSuspendedError)
+	}
+
+	suspension, err := scanSuspension(rows)
+	if err != nil {
+		return model.Suspension{}, fmt.Errorf("GetSuspension: %w", err)
+	}
+
+	return suspension, nil
+}
+
+// Block is idempotent, blocking a user twice only publishes the first time
+func (s *ModerationService) Block(ctx context.Context, blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return fmt.Errorf("Block: %w", model.SelfBlockError)
+	}
+
+	tag, err := s.DB.Exec(ctx,
+		"INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", blockerID, blockedID)
+
+	if err != nil {
+		return fmt.Errorf("Block: %w", followError(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	return s.Publisher.PublishUserBlockedEvent(ctx, event.UserBlockedEventData{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+}
+
+func (s *ModerationService) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	tag, err := s.DB.Exec(ctx,
+		"DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerID, blockedID)
+
+	if err != nil {
+		return fmt.Errorf("Unblock: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("Unblock: %w", model.NotBlockedError)
+	}
+
+	return s.Publisher.PublishUserUnblockedEvent(ctx, event.UserUnblockedEventData{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+}
+
+func (s *ModerationService) GetBlocked(userID int) ([]model.Block, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT blocked_id, created_at FROM user_blocks WHERE blocker_id = $1 ORDER BY created_at DESC", userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("GetBlocked: %w", err)
+	}
+
+	defer rows.Close()
+
+	blocks := []model.Block{}
+	for rows.Next() {
+		var block model.Block
+		if err := rows.Scan(&block.UserID, &block.BlockedAt); err != nil {
+			return nil, fmt.Errorf("GetBlocked: %w", err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+func scanBan(row scanner) (model.Ban, error) {
+	var ban model.Ban
+	err := row.Scan(&ban.ChannelID, &ban.UserID, &ban.ModeratorID, &ban.Reason, &ban.ExpiresAt, &ban.CreatedAt)
+
+	return ban, err
+}
+
+func scanSuspension(row scanner) (model.Suspension, error) {
+	var suspension model.Suspension
+	err := row.Scan(&suspension.UserID, &suspension.ActorID, &suspension.Reason, &suspension.ExpiresAt, &suspension.CreatedAt)
+
+	return suspension, err
+}
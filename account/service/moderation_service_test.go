@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+)
+
+func banRows() *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"channel_id", "user_id", "moderator_id", "reason", "expires_at", "created_at"})
+}
+
+func TestBan(t *testing.T) {
+	type banTest struct {
+		description     string
+		userID          int
+		rows            *pgxmock.Rows
+		queryErr        error
+		expectedErr     error
+		expectPublished int
+	}
+
+	moderatorID := 5
+
+	for _, scenario := range []banTest{
+		{
+			description:     "new ban",
+			userID:          3,
+			rows:            banRows().AddRow(1, 3, &moderatorID, "spam", nil, time.Now()),
+			expectPublished: 1,
+		},
+		{
+			description: "already banned",
+			userID:      3,
+			rows:        banRows(),
+			expectedErr: model.AlreadyBannedError,
+		},
+		{
+			description: "unknown user",
+			userID:      404,
+			queryErr:    &pgconn.PgError{Code: foreignKeyViolation},
+			expectedErr: model.UserNotFoundError,
+		},
+		{
+			description: "broadcaster",
+			userID:      1,
+			expectedErr: model.CannotBanBroadcasterError,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			if scenario.rows != nil {
+				mockDB.ExpectQuery("INSERT INTO channel_bans").WithArgs(1, scenario.userID, moderatorID, "spam", (*time.Time)(nil)).WillReturnRows(scenario.rows)
+			}
+			if scenario.queryErr != nil {
+				mockDB.ExpectQuery("INSERT INTO channel_bans").WithArgs(1, scenario.userID, moderatorID, "spam", (*time.Time)(nil)).WillReturnError(scenario.queryErr)
+			}
+
+			publisher := &mock.ModerationPublisherMock{}
+			sut := &ModerationService{DB: mockDB, Publisher: publisher}
+
+			_, err = sut.Ban(context.Background(), 1, scenario.userID, moderatorID, "spam")
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if len(publisher.Banned) != scenario.expectPublished {
+				t.Fatalf("Expected %d published events, got %d", scenario.expectPublished, len(publisher.Banned))
+			}
+
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	expiresAt := time.Now().Add(10 * time.Minute)
+	mockDB.ExpectQuery("INSERT INTO channel_bans").WithArgs(1, 3, 5, "caps", pgxmock.AnyArg()).
+		WillReturnRows(banRows().AddRow(1, 3, nil, "caps", &expiresAt, time.Now()))
+
+	publisher := &mock.ModerationPublisherMock{}
+	sut := &ModerationService{DB: mockDB, Publisher: publisher}
+
+	before := time.Now()
+	ban, err := sut.Timeout(context.Background(), 1, 3, 5, "caps", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if ban.ExpiresAt == nil {
+		t.Fatalf("Expected the timeout to expire")
+	}
+
+	if len(publisher.TimedOut) != 1 {
+		t.Fatalf("Expected a timeout to be published, got %d", len(publisher.TimedOut))
+	}
+
+	if got := publisher.TimedOut[0].ExpiresAt; got.Before(before.Add(10*time.Minute)) || got.After(time.Now().Add(10*time.Minute)) {
+		t.Fatalf("Expected the timeout to expire in 10 minutes, got %v", got)
+	}
+}
+
+func TestUnban(t *testing.T) {
+	for _, scenario := range []struct {
+		description     string
+		rowsAffected    int64
+		expectedErr     error
+		expectPublished int
+	}{
+		{"banned", 1, nil, 1},
+		{"not banned", 0, model.NotBannedError, 0},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			mockDB.ExpectExec("DELETE FROM channel_bans").WithArgs(1, 3).WillReturnResult(pgxmock.NewResult("DELETE", scenario.rowsAffected))
+
+			publisher := &mock.ModerationPublisherMock{}
+			sut := &ModerationService{DB: mockDB, Publisher: publisher}
+
+			err = sut.Unban(context.Background(), 1, 3, 5)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if len(publisher.Unbanned) != scenario.expectPublished {
+				t.Fatalf("Expected %d published events, got %d", scenario.expectPublished, len(publisher.Unbanned))
+			}
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	for _, scenario := range []struct {
+		description string
+		duration    time.Duration
+		expectsEnd  bool
+	}{
+		{"until lifted", 0, false},
+		{"for a day", 24 * time.Hour, true},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			mockDB.ExpectQuery("INSERT INTO suspensions").WithArgs(3, 9, "tos", pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows([]string{"user_id", "actor_id", "reason", "expires_at", "created_at"}).AddRow(3, nil, "tos", nil, time.Now()))
+
+			publisher := &mock.ModerationPublisherMock{}
+			sut := &ModerationService{DB: mockDB, Publisher: publisher}
+
+			if _, err := sut.Suspend(context.Background(), 3, 9, "tos", scenario.duration); err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			if len(publisher.Suspended) != 1 {
+				t.Fatalf("Expected a suspension to be published, got %d", len(publisher.Suspended))
+			}
+
+			if got := publisher.Suspended[0].ExpiresAt != nil; got != scenario.expectsEnd {
+				t.Fatalf("Expected the suspension to expire: %v, got %v", scenario.expectsEnd, got)
+			}
+		})
+	}
+}
+
+func TestBlock(t *testing.T) {
+	for _, scenario := range []struct {
+		description     string
+		blockedID       int
+		rowsAffected    int64
+		expectExec      bool
+		expectedErr     error
+		expectPublished int
+	}{
+		{"new block", 3, 1, true, nil, 1},
+		{"already blocked", 3, 0, true, nil, 0},
+		{"self", 7, 0, false, model.SelfBlockError, 0},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			if scenario.expectExec {
+				mockDB.ExpectExec("INSERT INTO user_blocks").WithArgs(7, scenario.blockedID).WillReturnResult(pgxmock.NewResult("INSERT", scenario.rowsAffected))
+			}
+
+			publisher := &mock.ModerationPublisherMock{}
+			sut := &ModerationService{DB: mockDB, Publisher: publisher}
+
+			err = sut.Block(context.Background(), 7, scenario.blockedID)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if len(publisher.Blocked) != scenario.expectPublished {
+				t.Fatalf("Expected %d published events, got %d", scenario.expectPublished, len(publisher.Blocked))
+			}
+
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+)
+
+type IProfileService interface {
+	// UpdateProfile replaces the display name and bio of the user and returns the updated user
+	UpdateProfile(ctx context.Context, userID int, displayName, bio string) (model.User, error)
+}
+
+type IProfilePublisher interface {
+	PublishAccountUpdatedEvent(ctx context.Context, data event.AccountUpdatedEventData) error
+}
+
+type ProfileService struct {
+	DB        db.PgxIface
+	Publisher IProfilePublisher
+}
+
+func NewProfileService(db db.PgxIface, publisher IProfilePublisher) IProfileService {
+	return &ProfileService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID int, displayName, bio string) (model.User, error) {
+	rows, err := s.DB.Query(ctx,
+		"UPDATE users SET display_name = $2, bio = $3 WHERE id = $1 RETURNING id, email, username, display_name, bio, followers_count, following_count",
+		userID, displayName, bio)
+
+	if err != nil {
+		return model.User{}, fmt.Errorf("UpdateProfile: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.User{}, fmt.Errorf("UpdateProfile: %w", model.UserNotFoundError)
+	}
+
+	var user model.User
+	err = rows.Scan(&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.Bio, &user.FollowersCount, &user.FollowingCount)
+	if err != nil {
+		return model.User{}, fmt.Errorf("UpdateProfile: %w", err)
+	}
+
+	err = s.Publisher.PublishAccountUpdatedEvent(ctx, event.AccountUpdatedEventData{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+	})
+	if err != nil {
+		return model.User{}, fmt.Errorf("UpdateProfile: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"testing"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.ProfilePublisherMock{}
+	sut := NewProfileService(mockDB, publisher)
+
+	mockDB.ExpectQuery("UPDATE users SET display_name").WithArgs(1, "Nikola", "I stream Go").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "username", "display_name", "bio", "followers_count", "following_count"}).
+			AddRow(1, "nik@gmail.com", "nik", "Nikola", "I stream Go", 3, 2))
+
+	user, err := sut.UpdateProfile(context.Background(), 1, "Nikola", "I stream Go")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if user.DisplayName != "Nikola" || user.FollowersCount != 3 {
+		t.Fatalf("Expected the updated user, got %+v", user)
+	}
+
+	if len(publisher.Updated) != 1 || publisher.Updated[0].Username != "nik" || publisher.Updated[0].Bio != "I stream Go" {
+		t.Fatalf("Expected the update to be published, got %+v", publisher.Updated)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProfileUnknownUser(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.ProfilePublisherMock{}
+	sut := NewProfileService(mockDB, publisher)
+
+	mockDB.ExpectQuery("UPDATE users SET display_name").WithArgs(404, "", "").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "email", "username", "display_name", "bio", "followers_count", "following_count"}))
+
+	_, err = sut.UpdateProfile(context.Background(), 404, "", "")
+	if !errors.Is(err, model.UserNotFoundError) {
+		t.Fatalf("Expected error to be %v, got %v", model.UserNotFoundError, err)
+	}
+
+	if len(publisher.Updated) != 0 {
+		t.Fatalf("Expected nothing to be published, got %+v", publisher.Updated)
+	}
+}
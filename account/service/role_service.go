@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/common/authz"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/token"
+)
+
+type IRoleService interface {
+	GetRoles(userID int) (model.Roles, error)
+	// GetChannelRoles returns the users holding a role in the channel
+	GetChannelRoles(channelID int) ([]model.ChannelRole, error)
+	// Check evaluates the same policy as the authz middleware against the stored roles, so revocations apply immediately
+	Check(userID, channelID int, role string) (bool, error)
+	// GrantPlatformRole grants the role, grantedBy is 0 when the platform grants it itself. Granting a held role is a no-op.
+	GrantPlatformRole(ctx context.Context, userID int, role string, grantedBy int) error
+	RevokePlatformRole(ctx context.Context, userID int, role string, revokedBy int) error
+	GrantChannelRole(ctx context.Context, channelID, userID int, role string, grantedBy int) error
+	RevokeChannelRole(ctx context.Context, channelID, userID int, role string, revokedBy int) error
+}
+
+type IRolePublisher interface {
+	PublishRoleGrantedEvent(ctx context.Context, data event.RoleGrantedEventData) error
+	PublishRoleRevokedEvent(ctx context.Context, data event.RoleRevokedEventData) error
+}
+
+type RoleService struct {
+	DB        db.PgxIface
+	Publisher IRolePublisher
+}
+
+func NewRoleService(db db.PgxIface, publisher IRolePublisher) IRoleService {
+	return &RoleService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+func (s *RoleService) GetRoles(userID int) (model.Roles, error) {
+	roles := model.Roles{UserID: userID, Platform: []string{}, Channels: []model.ChannelRole{}}
+
+	rows, err := s.DB.Query(context.Background(), "SELECT role FROM platform_roles WHERE user_id = $1 ORDER BY role", userID)
+	if err != nil {
+		return model.Roles{}, fmt.Errorf("GetRoles: %w", err)
+	}
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			rows.Close()
+			return model.Roles{}, fmt.Errorf("GetRoles: %w", err)
+		}
+		roles.Platform = append(roles.Platform, role)
+	}
+	rows.Close()
+
+	rows, err = s.DB.Query(context.Background(),
+		"SELECT channel_id, user_id, role, created_at FROM channel_roles WHERE user_id = $1 ORDER BY channel_id, role", userID)
+	if err != nil {
+		return model.Roles{}, fmt.Errorf("GetRoles: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		role, err := scanChannelRole(rows)
+		if err != nil {
+			return model.Roles{}, fmt.Errorf("GetRoles: %w", err)
+		}
+		roles.Channels = append(roles.Channels, role)
+	}
+
+	return roles, nil
+}
+
+func (s *RoleService) GetChannelRoles(channelID int) ([]model.ChannelRole, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT channel_id, user_id, role, created_at FROM channel_roles WHERE channel_id = $1 ORDER BY role, created_at", channelID)
+	if err != nil {
+		return nil, fmt.Errorf("GetChannelRoles: %w", err)
+	}
+
+	defer rows.Close()
+
+	roles := []model.ChannelRole{}
+	for rows.Next() {
+		role, err := scanChannelRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("GetChannelRoles: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (s *RoleService) Check(userID, channelID int, role string) (bool, error) {
+	policy, ok := authz.Lookup(role)
+	if !ok {
+		return false, fmt.Errorf("Check: %w", model.InvalidRoleError)
+	}
+
+	roles, err := s.GetRoles(userID)
+	if err != nil {
+		return false, fmt.Errorf("Check: %w", err)
+	}
+
+	claims := &token.UserClaims{UserId: userID, Scopes: roles.Scopes()}
+
+	return policy(claims, channelID), nil
+}
+
+func (s *RoleService) GrantPlatformRole(ctx context.Context, userID int, role string, grantedBy int) error {
+	if !token.IsPlatformRole(role) {
+		return fmt.Errorf("GrantPlatformRole: %w", model.InvalidRoleError)
+	}
+
+	tag, err := s.DB.Exec(ctx,
+		"INSERT INTO platform_roles (user_id, role, granted_by) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		userID, role, nullableID(grantedBy))
+	if err != nil {
+		return fmt.Errorf("GrantPlatformRole: %w", followError(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	return s.Publisher.PublishRoleGrantedEvent(ctx, event.RoleGrantedEventData{
+		UserID:    userID,
+		Role:      role,
+		GrantedBy: grantedBy,
+	})
+}
+
+func (s *RoleService) RevokePlatformRole(ctx context.Context, userID int, role string, revokedBy int) error {
+	if !token.IsPlatformRole(role) {
+		return fmt.Errorf("RevokePlatformRole: %w", model.InvalidRoleError)
+	}
+
+	tag, err := s.DB.Exec(ctx, "DELETE FROM platform_roles WHERE user_id = $1 AND role = $2", userID, role)
+	if err != nil {
+		return fmt.Errorf("RevokePlatformRole: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("RevokePlatformRole: %w", model.RoleNotFoundError)
+	}
+
+	return s.Publisher.PublishRoleRevokedEvent(ctx, event.RoleRevokedEventData{
+		UserID:    userID,
+		Role:      role,
+		RevokedBy: revokedBy,
+	})
+}
+
+func (s *RoleService) GrantChannelRole(ctx context.Context, channelID, userID int, role string, grantedBy int) error {
+	if !token.IsChannelRole(role) {
+		return fmt.Errorf("GrantChannelRole: %w", model.InvalidRoleError)
+	}
+	if channelID == userID {
+		return fmt.Errorf("GrantChannelRole: %w", model.SelfGrantError)
+	}
+
+	tag, err := s.DB.Exec(ctx,
+		"INSERT INTO channel_roles (channel_id, user_id, role, granted_by) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING",
+		channelID, userID, role, nullableID(grantedBy))
+	if err != nil {
+		return fmt.Errorf("GrantChannelRole: %w", followError(err))
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	return s.Publisher.PublishRoleGrantedEvent(ctx, event.RoleGrantedEventData{
+		UserID:    userID,
+		Role:      role,
+		ChannelID: channelID,
+		GrantedBy: grantedBy,
+	})
+}
+
+func (s *RoleService) RevokeChannelRole(ctx context.Context, channelID, userID int, role string, revokedBy int) error {
+	if !token.IsChannelRole(role) {
+		return fmt.Errorf("RevokeChannelRole: %w", model.InvalidRoleError)
+	}
+
+	tag, err := s.DB.Exec(ctx,
+		"DELETE FROM channel_roles WHERE channel_id = $1 AND user_id = $2 AND role = $3", channelID, userID, role)
+	if err != nil {
+		return fmt.Errorf("RevokeChannelRole: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("RevokeChannelRole: %w", model.RoleNotFoundError)
+	}
+
+	return s.Publisher.PublishRoleRevokedEvent(ctx, event.RoleRevokedEventData{
+		UserID:    userID,
+		Role:      role,
+		ChannelID: channelID,
+		RevokedBy: revokedBy,
+	})
+}
+
+func scanChannelRole(row scanner) (model.ChannelRole, error) {
+	var role model.ChannelRole
+	err := row.Scan(&role.ChannelID, &role.UserID, &role.Role, &role.GrantedAt)
+
+	return role, err
+}
+
+// nullableID stores the ID of a user that may not exist, such as the platform granting the first admin
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
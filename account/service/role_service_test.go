@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestCheck(t *testing.T) {
+	type checkTest struct {
+		description string
+		userID      int
+		channelID   int
+		role        string
+		platform    []string
+		channels    [][2]interface{}
+		expected    bool
+	}
+
+	for _, scenario := range []checkTest{
+		{"moderator of the channel", 5, 2, token.RoleModerator, nil, [][2]interface{}{{2, token.RoleModerator}}, true},
+		{"moderator of another channel", 5, 3, token.RoleModerator, nil, [][2]interface{}{{2, token.RoleModerator}}, false},
+		{"broadcaster moderates their channel", 2, 2, token.RoleModerator, nil, nil, true},
+		{"staff moderate every channel", 5, 3, token.RoleModerator, []string{token.RoleStaff}, nil, true},
+		{"staff aren't editors", 5, 3, token.RoleEditor, []string{token.RoleStaff}, nil, false},
+		{"vip isn't a moderator", 5, 2, token.RoleModerator, nil, [][2]interface{}{{2, token.RoleVIP}}, false},
+		{"moderators pass as vip", 5, 2, token.RoleVIP, nil, [][2]interface{}{{2, token.RoleModerator}}, true},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			platform := pgxmock.NewRows([]string{"role"})
+			for _, role := range scenario.platform {
+				platform.AddRow(role)
+			}
+			channels := pgxmock.NewRows([]string{"channel_id", "user_id", "role", "created_at"})
+			for _, role := range scenario.channels {
+				channels.AddRow(role[0], scenario.userID, role[1], time.Now())
+			}
+
+			mockDB.ExpectQuery("SELECT role FROM platform_roles").WithArgs(scenario.userID).WillReturnRows(platform)
+			mockDB.ExpectQuery("SELECT channel_id, user_id, role, created_at FROM channel_roles").WithArgs(scenario.userID).WillReturnRows(channels)
+
+			sut := &RoleService{DB: mockDB, Publisher: &mock.RolePublisherMock{}}
+
+			allowed, err := sut.Check(scenario.userID, scenario.channelID, scenario.role)
+			if err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			if allowed != scenario.expected {
+				t.Fatalf("Expected allowed to be %v, got %v", scenario.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownRole(t *testing.T) {
+	sut := &RoleService{}
+
+	_, err := sut.Check(1, 2, "owner")
+	if !errors.Is(err, model.InvalidRoleError) {
+		t.Fatalf("Expected error to be %v, got %v", model.InvalidRoleError, err)
+	}
+}
+
+func TestGrantChannelRole(t *testing.T) {
+	type grantTest struct {
+		description     string
+		userID          int
+		role            string
+		rowsAffected    int64
+		execErr         error
+		expectExec      bool
+		expectedErr     error
+		expectPublished int
+	}
+
+	for _, scenario := range []grantTest{
+		{"new role", 5, token.RoleModerator, 1, nil, true, nil, 1},
+		{"role already held", 5, token.RoleModerator, 0, nil, true, nil, 0},
+		{"unknown user", 404, token.RoleModerator, 0, &pgconn.PgError{Code: foreignKeyViolation}, true, model.UserNotFoundError, 0},
+		{"broadcaster isn't grantable", 5, token.RoleBroadcaster, 0, nil, false, model.InvalidRoleError, 0},
+		{"platform role", 5, token.RoleAdmin, 0, nil, false, model.InvalidRoleError, 0},
+		{"self grant", 2, token.RoleModerator, 0, nil, false, model.SelfGrantError, 0},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			if scenario.expectExec {
+				exec := mockDB.ExpectExec("INSERT INTO channel_roles").WithArgs(2, scenario.userID, scenario.role, 1)
+				if scenario.execErr != nil {
+					exec.WillReturnError(scenario.execErr)
+				} else {
+					exec.WillReturnResult(pgxmock.NewResult("INSERT", scenario.rowsAffected))
+				}
+			}
+
+			publisher := &mock.RolePublisherMock{}
+			sut := &RoleService{DB: mockDB, Publisher: publisher}
+
+			err = sut.GrantChannelRole(context.Background(), 2, scenario.userID, scenario.role, 1)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if len(publisher.Granted) != scenario.expectPublished {
+				t.Fatalf("Expected %d published events, got %d", scenario.expectPublished, len(publisher.Granted))
+			}
+
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestGrantPlatformRoleWithoutGrantor(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	mockDB.ExpectExec("INSERT INTO platform_roles").WithArgs(1, token.RoleAdmin, nil).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	publisher := &mock.RolePublisherMock{}
+	sut := &RoleService{DB: mockDB, Publisher: publisher}
+
+	if err := sut.GrantPlatformRole(context.Background(), 1, token.RoleAdmin, 0); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(publisher.Granted) != 1 || publisher.Granted[0].ChannelID != 0 {
+		t.Fatalf("Expected a platform role grant to be published, got %+v", publisher.Granted)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRevokeChannelRole(t *testing.T) {
+	type revokeTest struct {
+		description     string
+		rowsAffected    int64
+		expectedErr     error
+		expectPublished int
+	}
+
+	for _, scenario := range []revokeTest{
+		{"held role", 1, nil, 1},
+		{"role not held", 0, model.RoleNotFoundError, 0},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			mockDB.ExpectExec("DELETE FROM channel_roles").WithArgs(2, 5, token.RoleVIP).WillReturnResult(pgxmock.NewResult("DELETE", scenario.rowsAffected))
+
+			publisher := &mock.RolePublisherMock{}
+			sut := &RoleService{DB: mockDB, Publisher: publisher}
+
+			err = sut.RevokeChannelRole(context.Background(), 2, 5, token.RoleVIP, 2)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected error to be %v, got %v", scenario.expectedErr, err)
+			}
+
+			if len(publisher.Revoked) != scenario.expectPublished {
+				t.Fatalf("Expected %d published events, got %d", scenario.expectPublished, len(publisher.Revoked))
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/payment"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSubscriptionPeriod = 30 * 24 * time.Hour
+
+	// unique_violation, the user already has a current subscription to the channel
+	uniqueViolation = "23505"
+
+	// How long a payment may take before the subscription is given up on, or handed to another renewal run
+	paymentLease = 10 * time.Minute
+	renewalBatch = 100
+
+	subscriptionColumns = "id, subscriber_id, channel_id, tier, status, auto_renew, period_start, period_end, ended_at"
+)
+
+type ISubscriptionService interface {
+	GetPlans(channelID int) ([]model.Plan, error)
+	// SetPlan creates or updates the plan of the channel for the tier, existing subscriptions keep their price until they renew
+	SetPlan(plan model.Plan) error
+	// Subscribe charges the first period and starts the subscription, a cancelled subscription at the same tier is resumed instead
+	Subscribe(ctx context.Context, subscriberID, channelID, tier int) (model.Subscription, error)
+	// Cancel stops the renewals, the subscription lasts until the end of the paid period
+	Cancel(ctx context.Context, subscriberID, channelID int) (model.Subscription, error)
+	// GetSubscription returns the current subscription of the user to the channel
+	GetSubscription(subscriberID, channelID int) (model.Subscription, error)
+	// RenewDue renews or ends the subscriptions whose period ended by now, returning how many it handled. The ones that
+	// failed are logged and retried by a later run.
+	RenewDue(ctx context.Context, now time.Time) (int, error)
+}
+
+type ISubscriptionPublisher interface {
+	PublishSubscriptionStartedEvent(ctx context.Context, data event.SubscriptionStartedEventData) error
+	PublishSubscriptionEndedEvent(ctx context.Context, data event.SubscriptionEndedEventData) error
+}
+
+type SubscriptionService struct {
+	DB        db.PgxIface
+	Payments  payment.Provider
+	Publisher ISubscriptionPublisher
+	Period    time.Duration
+	logger    *zap.SugaredLogger
+}
+
+func NewSubscriptionService(db db.PgxIface, payments payment.Provider, publisher ISubscriptionPublisher, logger *zap.SugaredLogger) *SubscriptionService {
+	return &SubscriptionService{
+		DB:        db,
+		Payments:  payments,
+		Publisher: publisher,
+		Period:    DefaultSubscriptionPeriod,
+		logger:    logger,
+	}
+}
+
+func (s *SubscriptionService) GetPlans(channelID int) ([]model.Plan, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT channel_id, tier, name, price_cents, currency, active FROM subscription_plans WHERE channel_id = $1 ORDER BY tier", channelID)
+
+	if err != nil {
+		return nil, fmt.Errorf("GetPlans: %w", err)
+	}
+
+	defer rows.Close()
+
+	plans := []model.Plan{}
+	for rows.Next() {
+		var plan model.Plan
+		if err := rows.Scan(&plan.ChannelID, &plan.Tier, &plan.Name, &plan.PriceCents, &plan.Currency, &plan.Active); err != nil {
+			return nil, fmt.Errorf("GetPlans: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+func (s *SubscriptionService) SetPlan(plan model.Plan) error {
+	_, err := s.DB.Exec(context.Background(), `
+		INSERT INTO subscription_plans (channel_id, tier, name, price_cents, currency, active) VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (channel_id, tier) DO UPDATE SET name = $3, price_cents = $4, currency = $5, active = $6`,
+		plan.ChannelID, plan.Tier, plan.Name, plan.PriceCents, plan.Currency, plan.Active)
+
+	if err != nil {
+		return fmt.Errorf("SetPlan: %w", followError(err))
+	}
+
+	return nil
+}
+
+// Subscribe reserves the subscription before charging so two concurrent requests can't both pay
+func (s *SubscriptionService) Subscribe(ctx context.Context, subscriberID, channelID, tier int) (model.Subscription, error) {
+	if subscriberID == channelID {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", model.SelfSubscribeError)
+	}
+
+	current, err := s.GetSubscription(subscriberID, channelID)
+	if err == nil {
+		if current.Status == model.SubscriptionStatusCancelled && current.Tier == tier {
+			return s.resume(ctx, current)
+		}
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", model.AlreadySubscribedError)
+	}
+	if !errors.Is(err, model.NotSubscribedError) {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", err)
+	}
+
+	plan, err := s.getPlan(channelID, tier)
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", err)
+	}
+
+	// A pending subscription that is never paid is ended by the renewals once the lease runs out
+	now := time.Now().UTC()
+	rows, err := s.DB.Query(ctx,
+		"INSERT INTO subscriptions (subscriber_id, channel_id, tier, status, period_start, period_end) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id",
+		subscriberID, channelID, tier, model.SubscriptionStatusPending, now, now.Add(paymentLease))
+
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", subscribeError(err))
+	}
+
+	var id int
+	if rows.Next() {
+		err = rows.Scan(&id)
+	}
+	rows.Close()
+
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", subscribeError(err))
+	}
+
+	key := firstChargeKey(id)
+	ref, err := s.charge(ctx, key, subscriberID, plan)
+	if err != nil {
+		s.release(ctx, id)
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", err)
+	}
+
+	sub := model.Subscription{
+		ID:           id,
+		SubscriberID: subscriberID,
+		ChannelID:    channelID,
+		Tier:         tier,
+		Status:       model.SubscriptionStatusActive,
+		AutoRenew:    true,
+		PeriodStart:  now,
+		PeriodEnd:    now.Add(s.Period),
+	}
+
+	// The renewals end a pending subscription once its lease runs out, it can't be activated after that
+	tag, err := s.DB.Exec(ctx,
+		"UPDATE subscriptions SET status = $2, period_start = $3, period_end = $4, payment_ref = $5 WHERE id = $1 AND status = $6",
+		sub.ID, sub.Status, sub.PeriodStart, sub.PeriodEnd, ref, model.SubscriptionStatusPending)
+
+	if err == nil && tag.RowsAffected() == 0 {
+		err = errors.New("subscription ended before it was paid")
+	}
+	if err != nil {
+		// Paid but not started, the renewals refund it when this fails too
+		if refundErr := s.Payments.Refund(ctx, key); refundErr != nil {
+			s.logger.Errorf("failed to refund subscription %d: %v", id, refundErr)
+		} else {
+			s.release(ctx, id)
+		}
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", err)
+	}
+
+	s.started(ctx, sub)
+
+	return sub, nil
+}
+
+func (s *SubscriptionService) Cancel(ctx context.Context, subscriberID, channelID int) (model.Subscription, error) {
+	rows, err := s.DB.Query(ctx,
+		"UPDATE subscriptions SET status = $3, auto_renew = false WHERE subscriber_id = $1 AND channel_id = $2 AND status = $4 RETURNING "+subscriptionColumns,
+		subscriberID, channelID, model.SubscriptionStatusCancelled, model.SubscriptionStatusActive)
+
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Cancel: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Subscription{}, fmt.Errorf("Cancel: %w", model.NotSubscribedError)
+	}
+
+	sub, err := scanSubscription(rows)
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Cancel: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (s *SubscriptionService) GetSubscription(subscriberID, channelID int) (model.Subscription, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+subscriptionColumns+" FROM subscriptions WHERE subscriber_id = $1 AND channel_id = $2 AND status IN ($3, $4)",
+		subscriberID, channelID, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled)
+
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("GetSubscription: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Subscription{}, model.NotSubscribedError
+	}
+
+	sub, err := scanSubscription(rows)
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("GetSubscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+// RenewDue leases the due subscriptions so concurrent runs don't renew the same ones. A renewal whose lease ran out is
+// charged again with the same idempotency key, the payment is only taken once.
+func (s *SubscriptionService) RenewDue(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.DB.Query(ctx, `
+		UPDATE subscriptions s SET lease_until = $2
+		FROM (
+			SELECT id FROM subscriptions
+			WHERE status <> 'ended' AND period_end <= $1 AND (lease_until IS NULL OR lease_until <= $1)
+			ORDER BY period_end LIMIT $3
+		) due
+		WHERE s.id = due.id AND s.period_end <= $1 AND (s.lease_until IS NULL OR s.lease_until <= $1)
+		RETURNING s.id, s.subscriber_id, s.channel_id, s.tier, s.status, s.auto_renew, s.period_start, s.period_end, s.ended_at`,
+		now, now.Add(paymentLease), renewalBatch)
+
+	if err != nil {
+		return 0, fmt.Errorf("RenewDue: %w", err)
+	}
+
+	var due []model.Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("RenewDue: %w", err)
+		}
+		due = append(due, sub)
+	}
+	rows.Close()
+
+	handled := 0
+	for _, sub := range due {
+		if err := s.renew(ctx, sub); err != nil {
+			s.logger.Errorf("failed to renew subscription %d: %v", sub.ID, err)
+			continue
+		}
+		handled++
+	}
+
+	return handled, nil
+}
+
+// RunRenewals renews the due subscriptions every interval until ctx is cancelled
+func (s *SubscriptionService) RunRenewals(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.RenewDue(ctx, time.Now().UTC())
+		if err != nil {
+			s.logger.Errorf("failed to renew subscriptions: %v", err)
+		} else if n > 0 {
+			s.logger.Infof("Handled %d due subscriptions", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SubscriptionService) renew(ctx context.Context, sub model.Subscription) error {
+	switch {
+	case sub.Status == model.SubscriptionStatusPending:
+		// The first payment never went through, or it did but the subscription failed to start. There is nothing to announce.
+		if err := s.Payments.Refund(ctx, firstChargeKey(sub.ID)); err != nil {
+			return err
+		}
+		_, err := s.DB.Exec(ctx, "UPDATE subscriptions SET status = $2, ended_at = NOW(), lease_until = NULL WHERE id = $1", sub.ID, model.SubscriptionStatusEnded)
+		return err
+	case !sub.AutoRenew || sub.Status != model.SubscriptionStatusActive:
+		return s.end(ctx, sub)
+	}
+
+	plan, err := s.getPlan(sub.ChannelID, sub.Tier)
+	if errors.Is(err, model.PlanNotFoundError) {
+		return s.end(ctx, sub)
+	}
+	if err != nil {
+		return err
+	}
+
+	ref, err := s.charge(ctx, renewalChargeKey(sub), sub.SubscriberID, plan)
+	if errors.Is(err, model.PaymentFailedError) {
+		s.logger.Infof("renewal of subscription %d declined", sub.ID)
+		return s.end(ctx, sub)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The new period starts where the old one ended so late renewals don't shorten it
+	_, err = s.DB.Exec(ctx,
+		"UPDATE subscriptions SET period_start = $2, period_end = $3, payment_ref = $4, lease_until = NULL WHERE id = $1",
+		sub.ID, sub.PeriodEnd, sub.PeriodEnd.Add(s.Period), ref)
+
+	return err
+}
+
+func (s *SubscriptionService) end(ctx context.Context, sub model.Subscription) error {
+	_, err := s.DB.Exec(ctx,
+		"UPDATE subscriptions SET status = $2, period_end = $3, ended_at = $3, lease_until = NULL WHERE id = $1",
+		sub.ID, model.SubscriptionStatusEnded, sub.PeriodEnd)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Publisher.PublishSubscriptionEndedEvent(ctx, event.SubscriptionEndedEventData{
+		SubscriptionID: sub.ID,
+		SubscriberID:   sub.SubscriberID,
+		ChannelID:      sub.ChannelID,
+		Tier:           sub.Tier,
+		EndedAt:        sub.PeriodEnd,
+	})
+}
+
+// release removes a subscription that was never paid so the user can try again
+func (s *SubscriptionService) release(ctx context.Context, id int) {
+	_, err := s.DB.Exec(ctx, "DELETE FROM subscriptions WHERE id = $1 AND status = $2", id, model.SubscriptionStatusPending)
+	if err != nil {
+		s.logger.Errorf("failed to remove unpaid subscription %d: %v", id, err)
+	}
+}
+
+func (s *SubscriptionService) resume(ctx context.Context, sub model.Subscription) (model.Subscription, error) {
+	_, err := s.DB.Exec(ctx,
+		"UPDATE subscriptions SET status = $2, auto_renew = true WHERE id = $1 AND status = $3",
+		sub.ID, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled)
+
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("Subscribe: %w", err)
+	}
+
+	sub.Status = model.SubscriptionStatusActive
+	sub.AutoRenew = true
+
+	s.started(ctx, sub)
+
+	return sub, nil
+}
+
+// started announces the subscription once it is stored. The subscriber already paid by then, so failing to publish
+// is only logged rather than failing a subscription that went through.
+func (s *SubscriptionService) started(ctx context.Context, sub model.Subscription) {
+	err := s.Publisher.PublishSubscriptionStartedEvent(ctx, event.SubscriptionStartedEventData{
+		SubscriptionID: sub.ID,
+		SubscriberID:   sub.SubscriberID,
+		ChannelID:      sub.ChannelID,
+		Tier:           sub.Tier,
+		PeriodEnd:      sub.PeriodEnd,
+	})
+	if err != nil {
+		s.logger.Errorf("failed to publish the start of subscription %d: %v", sub.ID, err)
+	}
+}
+
+func (s *SubscriptionService) getPlan(channelID, tier int) (model.Plan, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT channel_id, tier, name, price_cents, currency, active FROM subscription_plans WHERE channel_id = $1 AND tier = $2 AND active",
+		channelID, tier)
+
+	if err != nil {
+		return model.Plan{}, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Plan{}, model.PlanNotFoundError
+	}
+
+	var plan model.Plan
+	err = rows.Scan(&plan.ChannelID, &plan.Tier, &plan.Name, &plan.PriceCents, &plan.Currency, &plan.Active)
+
+	return plan, err
+}
+
+func (s *SubscriptionService) charge(ctx context.Context, key string, subscriberID int, plan model.Plan) (string, error) {
+	ref, err := s.Payments.Charge(ctx, payment.Charge{
+		IdempotencyKey: key,
+		UserID:         subscriberID,
+		AmountCents:    plan.PriceCents,
+		Currency:       plan.Currency,
+		Description:    fmt.Sprintf("%s subscription to channel %d", plan.Name, plan.ChannelID),
+	})
+
+	if errors.Is(err, payment.DeclinedError) {
+		return "", model.PaymentFailedError
+	}
+
+	return ref, err
+}
+
+// firstChargeKey identifies the payment that starts the subscription
+func firstChargeKey(id int) string {
+	return fmt.Sprintf("subscription_%d", id)
+}
+
+// renewalChargeKey identifies the payment of the period following the current one, the period only moves once it's paid
+func renewalChargeKey(sub model.Subscription) string {
+	return fmt.Sprintf("subscription_%d_%d", sub.ID, sub.PeriodEnd.Unix())
+}
+
+func subscribeError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return model.AlreadySubscribedError
+	}
+	return followError(err)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row scanner) (model.Subscription, error) {
+	var sub model.Subscription
+	err := row.Scan(&sub.ID, &sub.SubscriberID, &sub.ChannelID, &sub.Tier, &sub.Status, &sub.AutoRenew,
+		&sub.PeriodStart, &sub.PeriodEnd, &sub.EndedAt)
+
+	return sub, err
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/payment"
+	"nikolamilovic/twitchy/accounts/service/mock"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+var (
+	planColumns      = []string{"channel_id", "tier", "name", "price_cents", "currency", "active"}
+	subscriptionRows = []string{"id", "subscriber_id", "channel_id", "tier", "status", "auto_renew", "period_start", "period_end", "ended_at"}
+	noEnd            *time.Time
+)
+
+func newSubscriptionService(t *testing.T) (*SubscriptionService, pgxmock.PgxConnIface, *payment.FakeProvider, *mock.SubscriptionPublisherMock) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	payments := payment.NewFakeProvider()
+	publisher := &mock.SubscriptionPublisherMock{}
+
+	return NewSubscriptionService(mockDB, payments, publisher, zap.L().Sugar().Named("test")), mockDB, payments, publisher
+}
+
+func TestSubscribe(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	mockDB.ExpectQuery("SELECT (.+) FROM subscriptions").WithArgs(1, 2, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows))
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(2, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(2, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(1, 2, 1, model.SubscriptionStatusPending, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(10))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(10, model.SubscriptionStatusActive, pgxmock.AnyArg(), pgxmock.AnyArg(), "fake_1", model.SubscriptionStatusPending).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	sub, err := sut.Subscribe(context.Background(), 1, 2, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when subscribing", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if sub.ID != 10 || sub.Status != model.SubscriptionStatusActive || sub.PeriodEnd.Sub(sub.PeriodStart) != DefaultSubscriptionPeriod {
+		t.Fatalf("Expected an active subscription for a period, got %+v", sub)
+	}
+
+	if len(payments.Charges) != 1 || payments.Charges[0].AmountCents != 499 || payments.Charges[0].UserID != 1 {
+		t.Fatalf("Expected the plan price to be charged, got %+v", payments.Charges)
+	}
+
+	if len(publisher.Started) != 1 || publisher.Started[0].SubscriptionID != 10 || publisher.Started[0].Tier != 1 {
+		t.Fatalf("Expected the subscription start to be published, got %+v", publisher.Started)
+	}
+}
+
+func TestSubscribeSurvivesPublishFailure(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	publisher.Err = errors.New("not connected")
+
+	mockDB.ExpectQuery("SELECT (.+) FROM subscriptions").WithArgs(1, 2, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows))
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(2, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(2, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(1, 2, 1, model.SubscriptionStatusPending, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(10))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(10, model.SubscriptionStatusActive, pgxmock.AnyArg(), pgxmock.AnyArg(), "fake_1", model.SubscriptionStatusPending).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	// The subscription is paid and stored, it stands without the event
+	sub, err := sut.Subscribe(context.Background(), 1, 2, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when the event fails to publish", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if sub.ID != 10 || sub.Status != model.SubscriptionStatusActive || len(payments.Refunds) != 0 {
+		t.Fatalf("Expected an active subscription that isn't refunded, got %+v, %+v", sub, payments.Refunds)
+	}
+}
+
+func TestSubscribeDeclined(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	payments.Declined[1] = true
+
+	mockDB.ExpectQuery("SELECT (.+) FROM subscriptions").WithArgs(1, 2, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows))
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(2, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(2, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(1, 2, 1, model.SubscriptionStatusPending, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(10))
+	// The reservation is released so the user can try again
+	mockDB.ExpectExec("DELETE FROM subscriptions").WithArgs(10, model.SubscriptionStatusPending).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	_, err := sut.Subscribe(context.Background(), 1, 2, 1)
+	if !errors.Is(err, model.PaymentFailedError) {
+		t.Fatalf("Expected %v, got %v", model.PaymentFailedError, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(publisher.Started) != 0 {
+		t.Fatalf("Expected nothing to be published, got %+v", publisher.Started)
+	}
+}
+
+func TestSubscribeRefundsWhenNotStarted(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	mockDB.ExpectQuery("SELECT (.+) FROM subscriptions").WithArgs(1, 2, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows))
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(2, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(2, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(1, 2, 1, model.SubscriptionStatusPending, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(10))
+	// Charged but the subscription couldn't be started
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(10, model.SubscriptionStatusActive, pgxmock.AnyArg(), pgxmock.AnyArg(), "fake_1", model.SubscriptionStatusPending).
+		WillReturnError(errors.New("connection reset"))
+	mockDB.ExpectExec("DELETE FROM subscriptions").WithArgs(10, model.SubscriptionStatusPending).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if _, err := sut.Subscribe(context.Background(), 1, 2, 1); err == nil {
+		t.Fatal("Expected the subscription to fail")
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(payments.Refunds) != 1 || payments.Refunds[0] != firstChargeKey(10) {
+		t.Fatalf("Expected the charge to be refunded, got %+v", payments.Refunds)
+	}
+
+	if len(publisher.Started) != 0 {
+		t.Fatalf("Expected nothing to be published, got %+v", publisher.Started)
+	}
+}
+
+func TestSubscribeResumesCancelled(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	now := time.Now()
+
+	mockDB.ExpectQuery("SELECT (.+) FROM subscriptions").WithArgs(1, 2, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows).AddRow(10, 1, 2, 1, model.SubscriptionStatusCancelled, false, now, now.Add(time.Hour), noEnd))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(10, model.SubscriptionStatusActive, model.SubscriptionStatusCancelled).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	sub, err := sut.Subscribe(context.Background(), 1, 2, 1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when resuming", err)
+	}
+
+	if !sub.AutoRenew || sub.Status != model.SubscriptionStatusActive || len(payments.Charges) != 0 {
+		t.Fatalf("Expected the subscription to resume without a charge, got %+v", sub)
+	}
+
+	if len(publisher.Started) != 1 || publisher.Started[0].SubscriptionID != 10 {
+		t.Fatalf("Expected the resumed subscription to be published, got %+v", publisher.Started)
+	}
+
+	if _, err := sut.Subscribe(context.Background(), 1, 1, 1); !errors.Is(err, model.SelfSubscribeError) {
+		t.Fatalf("Expected %v, got %v", model.SelfSubscribeError, err)
+	}
+}
+
+func TestRenewDue(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	payments.Declined[3] = true
+
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	start := due.Add(-DefaultSubscriptionPeriod)
+
+	mockDB.ExpectQuery("UPDATE subscriptions s SET lease_until").
+		WithArgs(now, now.Add(paymentLease), renewalBatch).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows).
+			AddRow(10, 1, 5, 1, model.SubscriptionStatusActive, true, start, due, noEnd).
+			AddRow(11, 2, 5, 1, model.SubscriptionStatusCancelled, false, start, due, noEnd).
+			AddRow(12, 3, 5, 1, model.SubscriptionStatusActive, true, start, due, noEnd))
+
+	// Renewed from where the last period ended
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(5, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(5, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectExec("UPDATE subscriptions SET period_start").
+		WithArgs(10, due, due.Add(DefaultSubscriptionPeriod), "fake_1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	// Cancelled, ends with its period
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(11, model.SubscriptionStatusEnded, due).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	// Declined renewal
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(5, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(5, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(12, model.SubscriptionStatusEnded, due).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	n, err := sut.RenewDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when renewing", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if n != 3 {
+		t.Fatalf("Expected %d subscriptions to be handled, got %d", 3, n)
+	}
+
+	if len(publisher.Ended) != 2 || publisher.Ended[0].SubscriptionID != 11 || publisher.Ended[1].SubscriptionID != 12 {
+		t.Fatalf("Expected the cancelled and declined subscriptions to end, got %+v", publisher.Ended)
+	}
+}
+
+func TestRenewDueChargedButNotUpdated(t *testing.T) {
+	sut, mockDB, payments, publisher := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	start := due.Add(-DefaultSubscriptionPeriod)
+
+	mockDB.ExpectQuery("UPDATE subscriptions s SET lease_until").
+		WithArgs(now, now.Add(paymentLease), renewalBatch).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows).
+			AddRow(10, 1, 5, 1, model.SubscriptionStatusActive, true, start, due, noEnd).
+			AddRow(11, 2, 5, 1, model.SubscriptionStatusCancelled, false, start, due, noEnd))
+
+	// Charged, but the new period is lost
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(5, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(5, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectExec("UPDATE subscriptions SET period_start").
+		WithArgs(10, due, due.Add(DefaultSubscriptionPeriod), "fake_1").
+		WillReturnError(errors.New("connection reset"))
+
+	// The rest of the batch is still handled
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(11, model.SubscriptionStatusEnded, due).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	n, err := sut.RenewDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when renewing", err)
+	}
+
+	if n != 1 || len(publisher.Ended) != 1 || publisher.Ended[0].SubscriptionID != 11 {
+		t.Fatalf("Expected only the cancelled subscription to be handled, got %d, %+v", n, publisher.Ended)
+	}
+
+	// Once the lease runs out the same period is renewed again, without taking another payment
+	later := now.Add(paymentLease)
+
+	mockDB.ExpectQuery("UPDATE subscriptions s SET lease_until").
+		WithArgs(later, later.Add(paymentLease), renewalBatch).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows).
+			AddRow(10, 1, 5, 1, model.SubscriptionStatusActive, true, start, due, noEnd))
+	mockDB.ExpectQuery("SELECT (.+) FROM subscription_plans").WithArgs(5, 1).
+		WillReturnRows(pgxmock.NewRows(planColumns).AddRow(5, 1, "Tier 1", 499, "USD", true))
+	mockDB.ExpectExec("UPDATE subscriptions SET period_start").
+		WithArgs(10, due, due.Add(DefaultSubscriptionPeriod), "fake_1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if n, err := sut.RenewDue(context.Background(), later); err != nil || n != 1 {
+		t.Fatalf("Expected the subscription to be renewed, got %d, %v", n, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(payments.Charges) != 1 {
+		t.Fatalf("Expected the renewal to be charged once, got %+v", payments.Charges)
+	}
+}
+
+func TestRenewDueRefundsPending(t *testing.T) {
+	sut, mockDB, payments, _ := newSubscriptionService(t)
+	defer mockDB.Close(context.Background())
+
+	// Paid, but neither started nor refunded by Subscribe
+	if _, err := payments.Charge(context.Background(), payment.Charge{IdempotencyKey: firstChargeKey(10), UserID: 1, AmountCents: 499}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	created := now.Add(-2 * paymentLease)
+
+	mockDB.ExpectQuery("UPDATE subscriptions s SET lease_until").
+		WithArgs(now, now.Add(paymentLease), renewalBatch).
+		WillReturnRows(pgxmock.NewRows(subscriptionRows).
+			AddRow(10, 1, 5, 1, model.SubscriptionStatusPending, true, created, created.Add(paymentLease), noEnd).
+			AddRow(11, 2, 5, 1, model.SubscriptionStatusPending, true, created, created.Add(paymentLease), noEnd))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(10, model.SubscriptionStatusEnded).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mockDB.ExpectExec("UPDATE subscriptions SET status").
+		WithArgs(11, model.SubscriptionStatusEnded).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if n, err := sut.RenewDue(context.Background(), now); err != nil || n != 2 {
+		t.Fatalf("Expected the pending subscriptions to end, got %d, %v", n, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	// Only the one that was paid is refunded
+	if len(payments.Refunds) != 1 || payments.Refunds[0] != firstChargeKey(10) {
+		t.Fatalf("Expected the paid subscription to be refunded, got %+v", payments.Refunds)
+	}
+}
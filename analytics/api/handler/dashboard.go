@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/analytics/model"
+	"nikolamilovic/twitchy/analytics/model/response"
+	"nikolamilovic/twitchy/analytics/service"
+	"nikolamilovic/twitchy/common/authz"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// defaultRanges is how far back the rollups go without ?from=
+var defaultRanges = map[string]time.Duration{
+	model.GranularityMinute: time.Hour,
+	model.GranularityHour:   24 * time.Hour,
+	model.GranularityDay:    30 * 24 * time.Hour,
+}
+
+// DashboardHandler serves the analytics of a channel to its broadcaster
+type DashboardHandler struct {
+	router           *chi.Mux
+	dashboardService service.IDashboardService
+	jwtSecret        []byte
+	logger           *zap.SugaredLogger
+}
+
+func NewDashboardHandler(dashboard service.IDashboardService, jwtSecret []byte, logger *zap.SugaredLogger) *DashboardHandler {
+	h := &DashboardHandler{}
+
+	h.dashboardService = dashboard
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *DashboardHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Route("/channels/{id}", func(r chi.Router) {
+		r.Use(authz.Middleware(h.jwtSecret, authz.BroadcasterOf, channelParam))
+
+		r.Get("/rollups", h.handleGetRollups())
+		r.Get("/sessions", h.handleGetSessions())
+		r.Get("/sessions/{session}", h.handleGetSession())
+	})
+}
+
+// handleGetRollups serves ?granularity=minute|hour|day with optional RFC 3339 from and to, by default up to now
+func (h *DashboardHandler) handleGetRollups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, _ := channelParam(r)
+
+		granularity := queryParam(r, "granularity", model.GranularityHour)
+		if _, ok := defaultRanges[granularity]; !ok {
+			http.Error(w, model.InvalidGranularityError.Error(), http.StatusBadRequest)
+			return
+		}
+
+		to, toErr := timeParam(r, "to", time.Now().UTC())
+		from, fromErr := timeParam(r, "from", to.Add(-defaultRanges[granularity]))
+		if toErr != nil || fromErr != nil {
+			http.Error(w, "from and to must be RFC 3339 timestamps", http.StatusBadRequest)
+			return
+		}
+
+		buckets, err := h.dashboardService.GetRollups(channelID, granularity, from, to)
+
+		switch {
+		case err == nil:
+			writeJSON(h.logger, w, response.RollupsResponse{
+				ChannelID:   channelID,
+				Granularity: granularity,
+				From:        from,
+				To:          to,
+				Buckets:     buckets,
+			})
+		case errors.Is(err, model.InvalidGranularityError), errors.Is(err, model.InvalidRangeError):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.logger.Errorf("failed to get the %s rollups of channel %d: %v", granularity, channelID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *DashboardHandler) handleGetSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, _ := channelParam(r)
+
+		page, pageErr := strconv.Atoi(queryParam(r, "page", "1"))
+		limit, limitErr := strconv.Atoi(queryParam(r, "limit", strconv.Itoa(defaultLimit)))
+
+		if pageErr != nil || limitErr != nil || page < 1 || limit < 1 || limit > maxLimit {
+			http.Error(w, "page must be positive and limit between 1 and 100", http.StatusBadRequest)
+			return
+		}
+
+		sessions, total, err := h.dashboardService.GetSessions(channelID, page, limit)
+		if err != nil {
+			h.logger.Errorf("failed to get the sessions of channel %d: %v", channelID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, response.SessionsResponse{
+			Sessions: sessions,
+			Page:     page,
+			Limit:    limit,
+			Total:    total,
+		})
+	}
+}
+
+func (h *DashboardHandler) handleGetSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, _ := channelParam(r)
+
+		session, err := h.dashboardService.GetSession(channelID, chi.URLParam(r, "session"))
+
+		switch {
+		case err == nil:
+			writeJSON(h.logger, w, session)
+		case errors.Is(err, model.SessionNotFoundError):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			h.logger.Errorf("failed to get session %s of channel %d: %v", chi.URLParam(r, "session"), channelID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func channelParam(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "id"))
+}
+
+func timeParam(r *http.Request, key string, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func queryParam(r *http.Request, key, fallback string) string {
+	if value := r.URL.Query().Get(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("failed to write the response: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/analytics/model/response"
+	"nikolamilovic/twitchy/analytics/service/mock"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestGetRollups(t *testing.T) {
+	secret := "secret"
+	broadcaster, err := test_util.GenerateTokens(1, secret, token.ChannelScope(token.RoleBroadcaster, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/channels/1/rollups?granularity=minute&to=2022-10-01T12:00:00Z", nil)
+	req.Header.Set("Authorization", "Bearer "+broadcaster)
+	w := httptest.NewRecorder()
+
+	dashboard := &mock.DashboardServiceMock{}
+	NewDashboardHandler(dashboard, []byte(secret), zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.RollupsResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if responseData.ChannelID != 1 || responseData.Granularity != "minute" || len(responseData.Buckets) != 1 {
+		t.Fatalf("expected the minute rollups of the channel, instead got: %+v", responseData)
+	}
+
+	to := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	if r := dashboard.Ranges[0]; !r[0].Equal(to.Add(-time.Hour)) || !r[1].Equal(to) {
+		t.Fatalf("expected the last hour before to, instead got: %v", r)
+	}
+}
+
+func TestDashboardAccess(t *testing.T) {
+	secret := "secret"
+	broadcaster, err := test_util.GenerateTokens(1, secret, token.ChannelScope(token.RoleBroadcaster, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	moderator, err := test_util.GenerateTokens(2, secret, token.ChannelScope(token.RoleModerator, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type accessTest struct {
+		description    string
+		path           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []accessTest{
+		{"sessions", "/channels/1/sessions?page=1&limit=10", broadcaster, http.StatusOK},
+		{"session", "/channels/1/sessions/abc", broadcaster, http.StatusOK},
+		{"unknown session", "/channels/1/sessions/def", broadcaster, http.StatusNotFound},
+		{"someone else's channel", "/channels/3/sessions", broadcaster, http.StatusForbidden},
+		{"moderator", "/channels/1/sessions", moderator, http.StatusForbidden},
+		{"without token", "/channels/1/sessions", "", http.StatusUnauthorized},
+		{"invalid granularity", "/channels/1/rollups?granularity=week", broadcaster, http.StatusBadRequest},
+		{"invalid time", "/channels/1/rollups?from=yesterday", broadcaster, http.StatusBadRequest},
+		{"limit too high", "/channels/1/sessions?limit=101", broadcaster, http.StatusBadRequest},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, scenario.path, nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+			w := httptest.NewRecorder()
+
+			NewDashboardHandler(&mock.DashboardServiceMock{}, []byte(secret), zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/analytics/api/handler"
+	"nikolamilovic/twitchy/analytics/service"
+	"nikolamilovic/twitchy/common/metrics"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	mux              *chi.Mux
+	dashboardService service.IDashboardService
+	jwtSecret        []byte
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func NewServer(dashboard service.IDashboardService, jwtSecret []byte, logger *zap.SugaredLogger) (*Server, error) {
+	s := &Server{
+		mux:              chi.NewMux(),
+		dashboardService: dashboard,
+		jwtSecret:        jwtSecret,
+	}
+
+	s.mux.Use(metrics.Middleware)
+
+	//Routing
+	dashboardHandler := handler.NewDashboardHandler(s.dashboardService, s.jwtSecret, logger.Named("dashboard_handler"))
+
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/analytics", dashboardHandler)
+	return s, nil
+}
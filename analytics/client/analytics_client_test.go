@@ -0,0 +1,93 @@
+package client
+
+import (
+	"nikolamilovic/twitchy/analytics/service/mock"
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEventViewerCountUpdated(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.AggregationServiceMock{}
+	client := &AnalyticsClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"viewer_count_updated",
+ 	  "payload":"{\"session_id\":\"abc\",\"channel_id\":1,\"channel\":\"nik\",\"viewers\":12,\"unique_viewers\":30,\"counted_at\":\"2022-10-01T12:00:00Z\"}"
+		}`),
+		},
+	)
+
+	if len(service.Counts) != 1 || service.Counts[0].Viewers != 12 || service.Counts[0].CountedAt.IsZero() {
+		t.Fatalf("Expected the viewer count to be aggregated, got %+v", service.Counts)
+	}
+}
+
+func TestParseEventChatMessageSent(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.AggregationServiceMock{}
+	client := &AnalyticsClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"chat_message_sent",
+ 	  "payload":"{\"message_id\":7,\"channel\":\"nik\",\"user_id\":2,\"sent_at\":\"2022-10-01T12:00:00Z\"}"
+		}`),
+		},
+	)
+
+	if len(service.Messages) != 1 || service.Messages[0].MessageID != 7 || service.Messages[0].Channel != "nik" {
+		t.Fatalf("Expected the chat message to be counted, got %+v", service.Messages)
+	}
+}
+
+func TestParseEventRejectUnknown(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &AnalyticsClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.AggregationServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Reject(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body:         []byte(`{"type":"account_created","payload":"{}"}`),
+		},
+	)
+}
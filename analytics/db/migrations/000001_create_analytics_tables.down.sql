@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS processed_events;
+DROP TABLE IF EXISTS rollups;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS channels;
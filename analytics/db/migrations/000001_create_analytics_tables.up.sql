@@ -0,0 +1,45 @@
+-- Stream events carry the name of the channel, chat messages only carry the name
+CREATE TABLE IF NOT EXISTS channels (
+  channel_id integer PRIMARY KEY,
+  channel varchar(50) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  session_id varchar(32) PRIMARY KEY,
+  channel_id integer NOT NULL,
+  title varchar(140) NOT NULL DEFAULT '',
+  category varchar(100) NOT NULL DEFAULT '',
+  started_at timestamptz,
+  ended_at timestamptz,
+  -- The last viewer count and when it was taken, watch time accrues from it until the next one
+  viewers integer NOT NULL DEFAULT 0,
+  counted_at timestamptz,
+  watch_seconds double precision NOT NULL DEFAULT 0,
+  peak_viewers integer NOT NULL DEFAULT 0,
+  unique_viewers integer NOT NULL DEFAULT 0,
+  new_followers integer NOT NULL DEFAULT 0,
+  chat_messages integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS sessions_channel_idx ON sessions (channel_id, started_at DESC NULLS LAST);
+
+-- One row per channel and UTC minute, hour and day. The average viewers of a bucket are watch_seconds / live_seconds.
+CREATE TABLE IF NOT EXISTS rollups (
+  channel_id integer NOT NULL,
+  granularity varchar(6) NOT NULL CHECK (granularity IN ('minute', 'hour', 'day')),
+  bucket timestamptz NOT NULL,
+  live_seconds double precision NOT NULL DEFAULT 0,
+  watch_seconds double precision NOT NULL DEFAULT 0,
+  peak_viewers integer NOT NULL DEFAULT 0,
+  new_followers integer NOT NULL DEFAULT 0,
+  chat_messages integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (channel_id, granularity, bucket)
+);
+
+-- Follow and chat events already counted, a redelivered event finds its key and isn't counted again
+CREATE TABLE IF NOT EXISTS processed_events (
+  key varchar(100) PRIMARY KEY,
+  processed_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS processed_events_processed_at_idx ON processed_events (processed_at);
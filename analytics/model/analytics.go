@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+)
+
+// BucketWidth returns how long a bucket of the granularity lasts
+func BucketWidth(granularity string) (time.Duration, error) {
+	switch granularity {
+	case GranularityMinute:
+		return time.Minute, nil
+	case GranularityHour:
+		return time.Hour, nil
+	case GranularityDay:
+		return 24 * time.Hour, nil
+	default:
+		return 0, InvalidGranularityError
+	}
+}
+
+// Bucket is how a channel did over a minute, hour or day. Averages are over the time the channel was live in it.
+type Bucket struct {
+	Bucket                time.Time `json:"bucket"`
+	LiveSeconds           float64   `json:"live_seconds"`
+	WatchSeconds          float64   `json:"watch_seconds"`
+	AverageViewers        float64   `json:"average_viewers"`
+	PeakViewers           int       `json:"peak_viewers"`
+	NewFollowers          int       `json:"new_followers"`
+	ChatMessages          int       `json:"chat_messages"`
+	ChatMessagesPerMinute float64   `json:"chat_messages_per_minute"`
+}
+
+// SessionStats is how a single stream went, WatchSeconds is the time all viewers together spent watching it
+type SessionStats struct {
+	SessionID             string     `json:"session_id"`
+	ChannelID             int        `json:"channel_id"`
+	Title                 string     `json:"title"`
+	Category              string     `json:"category"`
+	StartedAt             *time.Time `json:"started_at,omitempty"`
+	EndedAt               *time.Time `json:"ended_at,omitempty"`
+	DurationSeconds       float64    `json:"duration_seconds"`
+	WatchSeconds          float64    `json:"watch_seconds"`
+	AverageViewers        float64    `json:"average_viewers"`
+	PeakViewers           int        `json:"peak_viewers"`
+	UniqueViewers         int        `json:"unique_viewers"`
+	NewFollowers          int        `json:"new_followers"`
+	ChatMessages          int        `json:"chat_messages"`
+	ChatMessagesPerMinute float64    `json:"chat_messages_per_minute"`
+}
+
+// Averages fills in the per-minute and average numbers from the totals
+func (b *Bucket) Averages(width time.Duration) {
+	b.AverageViewers = ratio(b.WatchSeconds, b.LiveSeconds)
+
+	minutes := b.LiveSeconds / 60
+	if minutes == 0 {
+		minutes = width.Minutes()
+	}
+	b.ChatMessagesPerMinute = ratio(float64(b.ChatMessages), minutes)
+}
+
+func (s *SessionStats) Averages() {
+	s.AverageViewers = ratio(s.WatchSeconds, s.DurationSeconds)
+	s.ChatMessagesPerMinute = ratio(float64(s.ChatMessages), s.DurationSeconds/60)
+}
+
+func ratio(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a / b
+}
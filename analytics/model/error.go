@@ -0,0 +1,9 @@
+package model
+
+import "errors"
+
+var (
+	InvalidGranularityError = errors.New("granularity must be minute, hour or day")
+	InvalidRangeError       = errors.New("from must be before to and cover at most 1000 buckets")
+	SessionNotFoundError    = errors.New("session not found")
+)
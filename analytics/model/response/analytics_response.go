@@ -0,0 +1,21 @@
+package response
+
+import (
+	"nikolamilovic/twitchy/analytics/model"
+	"time"
+)
+
+type RollupsResponse struct {
+	ChannelID   int            `json:"channel_id"`
+	Granularity string         `json:"granularity"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Buckets     []model.Bucket `json:"buckets"`
+}
+
+type SessionsResponse struct {
+	Sessions []model.SessionStats `json:"sessions"`
+	Page     int                  `json:"page"`
+	Limit    int                  `json:"limit"`
+	Total    int                  `json:"total"`
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/analytics/model"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// granularities are the rollups every event is added to, bucketed in UTC
+const granularities = `(VALUES ('minute', interval '1 minute'), ('hour', interval '1 hour'), ('day', interval '1 day')) AS g(granularity, width)`
+
+// sampleViewers moves the session to a new viewer count at $3. The previous count is added as watch time for the time
+// it held, split over the buckets it spans. Samples that aren't newer than the last one are ignored, which makes
+// redelivered and late events no-ops.
+const sampleViewers = `
+	WITH old AS (
+		SELECT session_id, channel_id, viewers, counted_at FROM sessions
+		WHERE session_id = $1 AND counted_at < $3::timestamptz AND ended_at IS NULL
+		FOR UPDATE
+	), updated AS (
+		UPDATE sessions s SET viewers = $2, counted_at = $3::timestamptz,
+			watch_seconds = s.watch_seconds + old.viewers * EXTRACT(EPOCH FROM $3::timestamptz - old.counted_at),
+			peak_viewers = GREATEST(s.peak_viewers, $2), unique_viewers = GREATEST(s.unique_viewers, $4)
+		FROM old WHERE s.session_id = old.session_id
+		RETURNING old.channel_id, old.viewers, old.counted_at
+	)
+	INSERT INTO rollups (channel_id, granularity, bucket, live_seconds, watch_seconds, peak_viewers)
+	SELECT u.channel_id, g.granularity, b.bucket, o.seconds, u.viewers * o.seconds,
+		CASE WHEN b.bucket = date_trunc(g.granularity, $3::timestamptz, 'UTC') THEN GREATEST(u.viewers, $2) ELSE u.viewers END
+	FROM updated u
+	CROSS JOIN ` + granularities + `
+	CROSS JOIN LATERAL generate_series(date_trunc(g.granularity, u.counted_at, 'UTC'), $3::timestamptz, g.width) AS b(bucket)
+	CROSS JOIN LATERAL (
+		SELECT EXTRACT(EPOCH FROM LEAST(b.bucket + g.width, $3::timestamptz) - GREATEST(b.bucket, u.counted_at)) AS seconds
+	) o
+	ON CONFLICT (channel_id, granularity, bucket) DO UPDATE SET
+		live_seconds = rollups.live_seconds + EXCLUDED.live_seconds,
+		watch_seconds = rollups.watch_seconds + EXCLUDED.watch_seconds,
+		peak_viewers = GREATEST(rollups.peak_viewers, EXCLUDED.peak_viewers)`
+
+// countEvent adds one to the counter of the channel's buckets and of the session live at $3. The event key is
+// stored in the same statement, so an event is counted exactly once however often it's delivered.
+const countEvent = `
+	WITH fresh AS (
+		INSERT INTO processed_events (key) VALUES ($1) ON CONFLICT DO NOTHING RETURNING key
+	), live AS (
+		UPDATE sessions SET %[1]s = %[1]s + 1
+		WHERE channel_id = $2 AND started_at <= $3::timestamptz AND (ended_at IS NULL OR ended_at >= $3::timestamptz)
+		AND EXISTS (SELECT 1 FROM fresh)
+	)
+	INSERT INTO rollups (channel_id, granularity, bucket, %[1]s)
+	SELECT $2, g.granularity, date_trunc(g.granularity, $3::timestamptz, 'UTC'), 1
+	FROM fresh CROSS JOIN ` + granularities + `
+	ON CONFLICT (channel_id, granularity, bucket) DO UPDATE SET %[1]s = rollups.%[1]s + 1`
+
+// IAggregationService rolls the events of the other services up into the analytics, every method is idempotent
+type IAggregationService interface {
+	StreamStatusChanged(ev event.StreamStatusChangedEventData) error
+	ViewerCountUpdated(ev event.ViewerCountUpdatedEventData) error
+	UserFollowed(ev event.UserFollowedEventData) error
+	ChatMessageSent(ev event.ChatMessageSentEventData) error
+}
+
+type AggregationService struct {
+	DB     db.PgxIface
+	logger *zap.SugaredLogger
+}
+
+func NewAggregationService(db db.PgxIface, logger *zap.SugaredLogger) *AggregationService {
+	return &AggregationService{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+// StreamStatusChanged opens the session when it goes live and closes the last viewer count when it ends
+func (s *AggregationService) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	ctx := context.Background()
+
+	if err := s.saveChannel(ctx, ev.ChannelID, ev.Channel); err != nil {
+		return fmt.Errorf("StreamStatusChanged: %w", err)
+	}
+
+	switch ev.Status {
+	case event.StreamStatusLive:
+		// Viewer counts may have arrived first, they already started the clock
+		_, err := s.DB.Exec(ctx, `
+			INSERT INTO sessions (session_id, channel_id, title, category, started_at, counted_at) VALUES ($1, $2, $3, $4, $5, $5)
+			ON CONFLICT (session_id) DO UPDATE SET title = EXCLUDED.title, category = EXCLUDED.category,
+			started_at = EXCLUDED.started_at, counted_at = COALESCE(sessions.counted_at, EXCLUDED.counted_at)`,
+			ev.SessionID, ev.ChannelID, ev.Title, ev.Category, ev.StartedAt)
+		if err != nil {
+			return fmt.Errorf("StreamStatusChanged: %w", err)
+		}
+	case event.StreamStatusEnded:
+		if ev.EndedAt == nil {
+			return nil
+		}
+
+		if err := s.sample(ctx, ev.SessionID, ev.ChannelID, 0, 0, *ev.EndedAt); err != nil {
+			return fmt.Errorf("StreamStatusChanged: %w", err)
+		}
+
+		_, err := s.DB.Exec(ctx,
+			"UPDATE sessions SET ended_at = $2, peak_viewers = GREATEST(peak_viewers, $3) WHERE session_id = $1 AND ended_at IS NULL",
+			ev.SessionID, ev.EndedAt, ev.PeakViewers)
+		if err != nil {
+			return fmt.Errorf("StreamStatusChanged: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *AggregationService) ViewerCountUpdated(ev event.ViewerCountUpdatedEventData) error {
+	ctx := context.Background()
+
+	if err := s.saveChannel(ctx, ev.ChannelID, ev.Channel); err != nil {
+		return fmt.Errorf("ViewerCountUpdated: %w", err)
+	}
+
+	if err := s.sample(ctx, ev.SessionID, ev.ChannelID, ev.Viewers, ev.UniqueViewers, ev.CountedAt); err != nil {
+		return fmt.Errorf("ViewerCountUpdated: %w", err)
+	}
+
+	return nil
+}
+
+func (s *AggregationService) UserFollowed(ev event.UserFollowedEventData) error {
+	key := "follow:" + strconv.Itoa(ev.FollowerID) + ":" + strconv.Itoa(ev.FollowedID) + ":" + strconv.FormatInt(ev.FollowedAt.UnixNano(), 10)
+
+	_, err := s.DB.Exec(context.Background(), fmt.Sprintf(countEvent, "new_followers"), key, ev.FollowedID, ev.FollowedAt)
+	if err != nil {
+		return fmt.Errorf("UserFollowed: %w", err)
+	}
+
+	return nil
+}
+
+// ChatMessageSent counts the message for the channel, messages in channels that never streamed aren't counted
+func (s *AggregationService) ChatMessageSent(ev event.ChatMessageSentEventData) error {
+	ctx := context.Background()
+
+	rows, err := s.DB.Query(ctx, "SELECT channel_id FROM channels WHERE channel = $1", ev.Channel)
+	if err != nil {
+		return fmt.Errorf("ChatMessageSent: %w", err)
+	}
+
+	var channelID int
+	if rows.Next() {
+		err = rows.Scan(&channelID)
+	}
+	rows.Close()
+
+	if err != nil {
+		return fmt.Errorf("ChatMessageSent: %w", err)
+	}
+	if channelID == 0 {
+		return nil
+	}
+
+	_, err = s.DB.Exec(ctx, fmt.Sprintf(countEvent, "chat_messages"), "chat:"+strconv.Itoa(ev.MessageID), channelID, ev.SentAt)
+	if err != nil {
+		return fmt.Errorf("ChatMessageSent: %w", err)
+	}
+
+	return nil
+}
+
+// PruneBefore drops the minute rollups and the keys of the events processed before the cutoff.
+// Hour and day rollups are kept for good.
+func (s *AggregationService) PruneBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := s.DB.Exec(ctx, "DELETE FROM rollups WHERE granularity = $1 AND bucket < $2", model.GranularityMinute, cutoff)
+	if err != nil {
+		return fmt.Errorf("PruneBefore: %w", err)
+	}
+
+	_, err = s.DB.Exec(ctx, "DELETE FROM processed_events WHERE processed_at < $1", cutoff)
+	if err != nil {
+		return fmt.Errorf("PruneBefore: %w", err)
+	}
+
+	return nil
+}
+
+// RunRetention prunes everything older than retention every interval until ctx is cancelled
+func (s *AggregationService) RunRetention(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.PruneBefore(ctx, time.Now().Add(-retention)); err != nil {
+			s.logger.Errorf("failed to prune the analytics: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample records a viewer count of the session, a session seen for the first time starts counting from it
+func (s *AggregationService) sample(ctx context.Context, sessionID string, channelID, viewers, uniques int, at time.Time) error {
+	tag, err := s.DB.Exec(ctx, `
+		INSERT INTO sessions (session_id, channel_id, viewers, counted_at, peak_viewers, unique_viewers) VALUES ($1, $2, $3, $4, $3, $5)
+		ON CONFLICT (session_id) DO NOTHING`,
+		sessionID, channelID, viewers, at, uniques)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	_, err = s.DB.Exec(ctx, sampleViewers, sessionID, viewers, at, uniques)
+
+	return err
+}
+
+func (s *AggregationService) saveChannel(ctx context.Context, channelID int, channel string) error {
+	_, err := s.DB.Exec(ctx,
+		"INSERT INTO channels (channel_id, channel) VALUES ($1, $2) ON CONFLICT (channel_id) DO UPDATE SET channel = EXCLUDED.channel",
+		channelID, channel)
+
+	return err
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+func TestViewerCountUpdated(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewAggregationService(mockDB, zap.L().Sugar())
+
+	countedAt := time.Now()
+
+	mockDB.ExpectExec("INSERT INTO channels").WithArgs(1, "nik").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectExec("INSERT INTO sessions (.+) ON CONFLICT \\(session_id\\) DO NOTHING").WithArgs("abc", 1, 12, countedAt, 30).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mockDB.ExpectExec("WITH old AS (.+) INSERT INTO rollups").WithArgs("abc", 12, countedAt, 30).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+
+	err = sut.ViewerCountUpdated(event.ViewerCountUpdatedEventData{
+		SessionID:     "abc",
+		ChannelID:     1,
+		Channel:       "nik",
+		Viewers:       12,
+		UniqueViewers: 30,
+		CountedAt:     countedAt,
+	})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewerCountFirstSample(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewAggregationService(mockDB, zap.L().Sugar())
+
+	countedAt := time.Now()
+
+	mockDB.ExpectExec("INSERT INTO channels").WithArgs(1, "nik").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectExec("INSERT INTO sessions").WithArgs("abc", 1, 12, countedAt, 30).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	err = sut.ViewerCountUpdated(event.ViewerCountUpdatedEventData{
+		SessionID: "abc", ChannelID: 1, Channel: "nik", Viewers: 12, UniqueViewers: 30, CountedAt: countedAt,
+	})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStreamEnded(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewAggregationService(mockDB, zap.L().Sugar())
+
+	endedAt := time.Now()
+
+	mockDB.ExpectExec("INSERT INTO channels").WithArgs(1, "nik").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectExec("INSERT INTO sessions").WithArgs("abc", 1, 0, endedAt, 0).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mockDB.ExpectExec("WITH old AS").WithArgs("abc", 0, endedAt, 0).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mockDB.ExpectExec("UPDATE sessions SET ended_at (.+) AND ended_at IS NULL").WithArgs("abc", &endedAt, 40).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = sut.StreamStatusChanged(event.StreamStatusChangedEventData{
+		SessionID:   "abc",
+		ChannelID:   1,
+		Channel:     "nik",
+		Status:      event.StreamStatusEnded,
+		EndedAt:     &endedAt,
+		PeakViewers: 40,
+	})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserFollowed(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewAggregationService(mockDB, zap.L().Sugar())
+
+	followedAt := time.Unix(1664625600, 0)
+
+	mockDB.ExpectExec("INSERT INTO processed_events (.+) SET new_followers = new_followers \\+ 1").
+		WithArgs("follow:2:1:1664625600000000000", 1, followedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+
+	err = sut.UserFollowed(event.UserFollowedEventData{FollowerID: 2, FollowedID: 1, FollowedAt: followedAt})
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestChatMessageSent(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewAggregationService(mockDB, zap.L().Sugar())
+
+	sentAt := time.Now()
+
+	mockDB.ExpectQuery("SELECT channel_id FROM channels").WithArgs("nik").
+		WillReturnRows(pgxmock.NewRows([]string{"channel_id"}).AddRow(1))
+	mockDB.ExpectExec("INSERT INTO processed_events (.+) SET chat_messages = chat_messages \\+ 1").
+		WithArgs("chat:7", 1, sentAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mockDB.ExpectQuery("SELECT channel_id FROM channels").WithArgs("unknown").
+		WillReturnRows(pgxmock.NewRows([]string{"channel_id"}))
+
+	if err := sut.ChatMessageSent(event.ChatMessageSentEventData{MessageID: 7, Channel: "nik", UserID: 2, SentAt: sentAt}); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := sut.ChatMessageSent(event.ChatMessageSentEventData{MessageID: 8, Channel: "unknown", UserID: 2, SentAt: sentAt}); err != nil {
+		t.Fatalf("Expected messages in unknown channels to be skipped, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
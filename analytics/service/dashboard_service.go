@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/analytics/model"
+	db "nikolamilovic/twitchy/common/db"
+	"time"
+)
+
+const maxBuckets = 1000
+
+// sessionColumns include the watch time of a live session up to now, the stored one stops at the last viewer count
+const sessionColumns = `session_id, channel_id, title, category, started_at, ended_at,
+	COALESCE(EXTRACT(EPOCH FROM COALESCE(ended_at, NOW()) - started_at), 0)::float8,
+	(watch_seconds + CASE WHEN ended_at IS NULL AND counted_at IS NOT NULL THEN viewers * EXTRACT(EPOCH FROM NOW() - counted_at) ELSE 0 END)::float8,
+	peak_viewers, unique_viewers, new_followers, chat_messages`
+
+type IDashboardService interface {
+	// GetRollups returns the buckets of the channel from from up to to, buckets without any activity are left out
+	GetRollups(channelID int, granularity string, from, to time.Time) ([]model.Bucket, error)
+	// Returns the sessions of the channel for the given page, latest first, and the total number of sessions
+	GetSessions(channelID, page, limit int) ([]model.SessionStats, int, error)
+	GetSession(channelID int, sessionID string) (model.SessionStats, error)
+}
+
+type DashboardService struct {
+	DB db.PgxIface
+}
+
+func NewDashboardService(db db.PgxIface) *DashboardService {
+	return &DashboardService{
+		DB: db,
+	}
+}
+
+func (s *DashboardService) GetRollups(channelID int, granularity string, from, to time.Time) ([]model.Bucket, error) {
+	width, err := model.BucketWidth(granularity)
+	if err != nil {
+		return nil, fmt.Errorf("GetRollups: %w", err)
+	}
+
+	if !from.Before(to) || to.Sub(from) > maxBuckets*width {
+		return nil, fmt.Errorf("GetRollups: %w", model.InvalidRangeError)
+	}
+
+	rows, err := s.DB.Query(context.Background(), `
+		SELECT bucket, live_seconds, watch_seconds, peak_viewers, new_followers, chat_messages FROM rollups
+		WHERE channel_id = $1 AND granularity = $2 AND bucket >= date_trunc($2, $3::timestamptz, 'UTC') AND bucket < $4
+		ORDER BY bucket`,
+		channelID, granularity, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("GetRollups: %w", err)
+	}
+
+	defer rows.Close()
+
+	buckets := []model.Bucket{}
+	for rows.Next() {
+		var b model.Bucket
+		err := rows.Scan(&b.Bucket, &b.LiveSeconds, &b.WatchSeconds, &b.PeakViewers, &b.NewFollowers, &b.ChatMessages)
+		if err != nil {
+			return nil, fmt.Errorf("GetRollups: %w", err)
+		}
+		b.Averages(width)
+		buckets = append(buckets, b)
+	}
+
+	return buckets, nil
+}
+
+func (s *DashboardService) GetSessions(channelID, page, limit int) ([]model.SessionStats, int, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT count(*) FROM sessions WHERE channel_id = $1", channelID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSessions: %w", err)
+	}
+
+	var total int
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+	rows.Close()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSessions: %w", err)
+	}
+
+	rows, err = s.DB.Query(context.Background(),
+		"SELECT "+sessionColumns+" FROM sessions WHERE channel_id = $1 ORDER BY started_at DESC NULLS LAST, session_id LIMIT $2 OFFSET $3",
+		channelID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSessions: %w", err)
+	}
+
+	defer rows.Close()
+
+	sessions := []model.SessionStats{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GetSessions: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, total, nil
+}
+
+func (s *DashboardService) GetSession(channelID int, sessionID string) (model.SessionStats, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+sessionColumns+" FROM sessions WHERE channel_id = $1 AND session_id = $2", channelID, sessionID)
+	if err != nil {
+		return model.SessionStats{}, fmt.Errorf("GetSession: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.SessionStats{}, fmt.Errorf("GetSession: %w", model.SessionNotFoundError)
+	}
+
+	session, err := scanSession(rows)
+	if err != nil {
+		return model.SessionStats{}, fmt.Errorf("GetSession: %w", err)
+	}
+
+	return session, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row scanner) (model.SessionStats, error) {
+	var s model.SessionStats
+	err := row.Scan(&s.SessionID, &s.ChannelID, &s.Title, &s.Category, &s.StartedAt, &s.EndedAt, &s.DurationSeconds,
+		&s.WatchSeconds, &s.PeakViewers, &s.UniqueViewers, &s.NewFollowers, &s.ChatMessages)
+	if err != nil {
+		return s, err
+	}
+
+	s.Averages()
+
+	return s, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/analytics/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+var sessionRowColumns = []string{"session_id", "channel_id", "title", "category", "started_at", "ended_at", "duration",
+	"watch_seconds", "peak_viewers", "unique_viewers", "new_followers", "chat_messages"}
+
+func TestGetRollups(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewDashboardService(mockDB)
+
+	to := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	from := to.Add(-time.Hour)
+
+	mockDB.ExpectQuery("SELECT bucket, (.+) FROM rollups").WithArgs(1, "minute", from, to).
+		WillReturnRows(pgxmock.NewRows([]string{"bucket", "live_seconds", "watch_seconds", "peak_viewers", "new_followers", "chat_messages"}).
+			AddRow(from, 30.0, 300.0, 12, 1, 15))
+
+	buckets, err := sut.GetRollups(1, model.GranularityMinute, from, to)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(buckets) != 1 || buckets[0].AverageViewers != 10 || buckets[0].ChatMessagesPerMinute != 30 {
+		t.Fatalf("Expected 10 average viewers and 30 messages a minute, got %+v", buckets)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetRollupsInvalidRange(t *testing.T) {
+	sut := NewDashboardService(nil)
+
+	to := time.Now()
+
+	if _, err := sut.GetRollups(1, model.GranularityHour, to, to.Add(-time.Hour)); !errors.Is(err, model.InvalidRangeError) {
+		t.Fatalf("Expected an invalid range error, got %v", err)
+	}
+
+	if _, err := sut.GetRollups(1, model.GranularityMinute, to.Add(-1001*time.Minute), to); !errors.Is(err, model.InvalidRangeError) {
+		t.Fatalf("Expected too many buckets to be an invalid range, got %v", err)
+	}
+
+	if _, err := sut.GetRollups(1, "week", to.Add(-time.Hour), to); !errors.Is(err, model.InvalidGranularityError) {
+		t.Fatalf("Expected an invalid granularity error, got %v", err)
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewDashboardService(mockDB)
+
+	startedAt := time.Now().Add(-time.Hour)
+	endedAt := time.Now()
+
+	mockDB.ExpectQuery("SELECT count").WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+	mockDB.ExpectQuery("SELECT session_id, (.+) FROM sessions").WithArgs(1, 2, 2).
+		WillReturnRows(pgxmock.NewRows(sessionRowColumns).
+			AddRow("abc", 1, "Writing Go", "chess", &startedAt, &endedAt, 3600.0, 72000.0, 40, 90, 5, 120))
+
+	sessions, total, err := sut.GetSessions(1, 2, 2)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if total != 3 || len(sessions) != 1 || sessions[0].AverageViewers != 20 || sessions[0].ChatMessagesPerMinute != 2 {
+		t.Fatalf("Expected 1 of 3 sessions with 20 average viewers, got %d %+v", total, sessions)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetSessionNotFound(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewDashboardService(mockDB)
+
+	mockDB.ExpectQuery("SELECT session_id, (.+) FROM sessions").WithArgs(1, "abc").
+		WillReturnRows(pgxmock.NewRows(sessionRowColumns))
+
+	if _, err := sut.GetSession(1, "abc"); !errors.Is(err, model.SessionNotFoundError) {
+		t.Fatalf("Expected a session not found error, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
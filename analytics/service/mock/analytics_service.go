@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/analytics/model"
+	"nikolamilovic/twitchy/common/event"
+	"time"
+)
+
+type AggregationServiceMock struct {
+	Statuses []event.StreamStatusChangedEventData
+	Counts   []event.ViewerCountUpdatedEventData
+	Follows  []event.UserFollowedEventData
+	Messages []event.ChatMessageSentEventData
+}
+
+func (s *AggregationServiceMock) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	s.Statuses = append(s.Statuses, ev)
+	return nil
+}
+
+func (s *AggregationServiceMock) ViewerCountUpdated(ev event.ViewerCountUpdatedEventData) error {
+	s.Counts = append(s.Counts, ev)
+	return nil
+}
+
+func (s *AggregationServiceMock) UserFollowed(ev event.UserFollowedEventData) error {
+	s.Follows = append(s.Follows, ev)
+	return nil
+}
+
+func (s *AggregationServiceMock) ChatMessageSent(ev event.ChatMessageSentEventData) error {
+	s.Messages = append(s.Messages, ev)
+	return nil
+}
+
+type DashboardServiceMock struct {
+	Granularities []string
+	Ranges        [][2]time.Time
+}
+
+func (s *DashboardServiceMock) GetRollups(channelID int, granularity string, from, to time.Time) ([]model.Bucket, error) {
+	s.Granularities = append(s.Granularities, granularity)
+	s.Ranges = append(s.Ranges, [2]time.Time{from, to})
+	return []model.Bucket{{Bucket: from, PeakViewers: 10}}, nil
+}
+
+func (s *DashboardServiceMock) GetSessions(channelID, page, limit int) ([]model.SessionStats, int, error) {
+	return []model.SessionStats{{SessionID: "abc", ChannelID: channelID}}, 1, nil
+}
+
+func (s *DashboardServiceMock) GetSession(channelID int, sessionID string) (model.SessionStats, error) {
+	if sessionID != "abc" {
+		return model.SessionStats{}, model.SessionNotFoundError
+	}
+	return model.SessionStats{SessionID: sessionID, ChannelID: channelID}, nil
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+// AdminHandler serves the admin API of the auth service, every route requires the admin role
+type AdminHandler struct {
+	router         *chi.Mux
+	sessionService service.ISessionService
+	audit          audit.ILog
+	jwtSecret      []byte
+	logger         *zap.SugaredLogger
+}
+
+func NewAdminHandler(sessions service.ISessionService, log audit.ILog, jwtSecret []byte, logger *zap.SugaredLogger) *AdminHandler {
+	h := &AdminHandler{}
+
+	h.sessionService = sessions
+	h.audit = log
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *AdminHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Use(authz.Middleware(h.jwtSecret, authz.Admin, nil))
+
+	r.Get("/users/{id}/sessions", h.handleGetSessions())
+	r.Delete("/users/{id}/sessions", h.handleForceLogout())
+	r.Get("/audit", h.handleGetAuditLog())
+	// There is no 2FA reset, logins are password only. It comes with two-factor authentication itself, which needs
+	// enrolment, secret storage and recovery codes of its own.
+}
+
+func (h *AdminHandler) handleGetSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+
+		sessions, err := h.sessionService.GetSessions(userId)
+		if err != nil {
+			h.logger.Errorf("failed to get the sessions of user %d: %v", userId, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		record(h.audit, h.logger, r, audit.Entry{ActorID: audit.ID(claims.UserId), Action: audit.ActionSessionsViewed, TargetID: audit.ID(userId)})
+
+		writeJSON(h.logger, w, sessions)
+	}
+}
+
+// handleForceLogout drops the sessions of the user, they are logged out once their JWT expires
+func (h *AdminHandler) handleForceLogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+
+		revoked, err := h.sessionService.RevokeSessions(userId)
+		if err != nil {
+			h.logger.Errorf("failed to revoke the sessions of user %d: %v", userId, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		record(h.audit, h.logger, r, audit.Entry{
+			ActorID:  audit.ID(claims.UserId),
+			Action:   audit.ActionForceLogout,
+			TargetID: audit.ID(userId),
+			Details:  map[string]interface{}{"sessions": revoked},
+		})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetAuditLog lists the entries newest first, filtered by ?actor=&target=&action= and paged with ?before=<id>
+func (h *AdminHandler) handleGetAuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := audit.ParseFilter(r.URL.Query().Get)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries, err := h.audit.List(filter)
+		if err != nil {
+			h.logger.Errorf("failed to list the audit log: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, entries)
+	}
+}
+
+// record appends to the audit log, a failed write is logged but doesn't fail the operation that already happened
+func record(log audit.ILog, logger *zap.SugaredLogger, r *http.Request, entry audit.Entry) {
+	entry.IP = audit.IP(r)
+
+	if err := log.Record(entry); err != nil {
+		logger.Errorf("failed to record %s in the audit log: %v", entry.Action, err)
+	}
+}
+
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("failed to write the response: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	secret := "secret"
+	admin, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	staff, err := test_util.GenerateTokens(8, secret, token.RoleStaff)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type adminTest struct {
+		description    string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []adminTest{
+		{"sessions", http.MethodGet, "/users/5/sessions", admin, http.StatusOK},
+		{"sessions as staff", http.MethodGet, "/users/5/sessions", staff, http.StatusForbidden},
+		{"sessions without token", http.MethodGet, "/users/5/sessions", "", http.StatusUnauthorized},
+		{"sessions of invalid user", http.MethodGet, "/users/abc/sessions", admin, http.StatusBadRequest},
+		{"force logout", http.MethodDelete, "/users/5/sessions", admin, http.StatusNoContent},
+		{"force logout as staff", http.MethodDelete, "/users/5/sessions", staff, http.StatusForbidden},
+		{"audit log", http.MethodGet, "/audit?actor=9&action=auth.login", admin, http.StatusOK},
+		{"audit log invalid filter", http.MethodGet, "/audit?before=abc", admin, http.StatusBadRequest},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(scenario.method, scenario.path, nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+			w := httptest.NewRecorder()
+
+			srv := NewAdminHandler(&mock.SessionServiceMock{}, &mock.AuditLogMock{}, []byte(secret), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestForceLogoutIsAudited(t *testing.T) {
+	// The IPs are the ones the gateway forwards
+	token.TrustGateway(true)
+	defer token.TrustGateway(false)
+
+	secret := "secret"
+	admin, err := test_util.GenerateTokens(9, secret, token.RoleAdmin)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/5/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	w := httptest.NewRecorder()
+
+	sessions := &mock.SessionServiceMock{}
+	log := &mock.AuditLogMock{}
+	NewAdminHandler(sessions, log, []byte(secret), zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+	if len(sessions.Revoked) != 1 || sessions.Revoked[0] != 5 {
+		t.Fatalf("Expected the sessions of 5 to be revoked, got %v", sessions.Revoked)
+	}
+
+	if len(log.Entries) != 1 {
+		t.Fatalf("Expected 1 audit entry, got %d", len(log.Entries))
+	}
+
+	entry := log.Entries[0]
+	if entry.Action != audit.ActionForceLogout || *entry.ActorID != 9 || *entry.TargetID != 5 || entry.IP != "10.0.0.1" {
+		t.Fatalf("Expected force logout of 5 by 9 from 10.0.0.1, got %+v", entry)
+	}
+}
@@ -2,14 +2,19 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"nikolamilovic/twitchy/auth/model"
 	"nikolamilovic/twitchy/auth/model/response"
 	"nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/ratelimit"
 	"nikolamilovic/twitchy/common/utils"
 
 	"github.com/go-chi/chi"
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 )
 
 type AuthHandler struct {
@@ -17,14 +22,26 @@ type AuthHandler struct {
 	validator    *validator.Validate
 	authService  service.IAuthService
 	tokenService service.ITokenService
+	audit        audit.ILog
+	limits       Limits
+	logger       *zap.SugaredLogger
 }
 
-func NewAuthHandler(validator *validator.Validate, auth service.IAuthService, token service.ITokenService) *AuthHandler {
+// Limits are how often a client can register and log in, they're checked before the request is looked at
+type Limits struct {
+	Registrations ratelimit.Limiter
+	Logins        ratelimit.Limiter
+}
+
+func NewAuthHandler(validator *validator.Validate, auth service.IAuthService, token service.ITokenService, log audit.ILog, limits Limits, logger *zap.SugaredLogger) *AuthHandler {
 	h := &AuthHandler{}
 
 	h.authService = auth
 	h.tokenService = token
 	h.validator = validator
+	h.audit = log
+	h.limits = limits
+	h.logger = logger
 
 	h.Routes()
 
@@ -39,8 +56,8 @@ func (h *AuthHandler) Routes() {
 	r := chi.NewRouter()
 	h.router = r
 
-	r.Post("/register", h.handleRegistration())
-	r.Post("/login", h.handleLogin())
+	r.With(ratelimit.Middleware(h.limits.Registrations, ratelimit.ByIP, h.logger)).Post("/register", h.handleRegistration())
+	r.With(ratelimit.Middleware(h.limits.Logins, ratelimit.ByIP, h.logger)).Post("/login", h.handleLogin())
 	r.Post("/refresh", h.handleRefresh())
 }
 
@@ -65,7 +82,12 @@ func (h *AuthHandler) handleRegistration() http.HandlerFunc {
 			return
 		}
 
-		jwt, refresh, id, err := h.authService.Register(req.Email, req.Password, req.Username)
+		if !utils.UsernamePattern.MatchString(req.Username) {
+			http.Error(w, "username may only contain letters, digits and underscores, up to 50 of them", http.StatusBadRequest)
+			return
+		}
+
+		jwt, refresh, id, err := h.authService.Register(r.Context(), req.Email, req.Password, req.Username)
 
 		if err != nil {
 			fmt.Println(err.Error())
@@ -73,6 +95,8 @@ func (h *AuthHandler) handleRegistration() http.HandlerFunc {
 			return
 		}
 
+		record(h.audit, h.logger, r, audit.Entry{ActorID: audit.ID(id), Action: audit.ActionRegister, TargetID: audit.ID(id)})
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -108,14 +132,24 @@ func (h *AuthHandler) handleLogin() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		jwt, refresh, id, err := h.authService.Login(req.Email, req.Password)
+		jwt, refresh, id, err := h.authService.Login(r.Context(), req.Email, req.Password)
+
+		// The ID isn't known when the login fails, the email is recorded instead
+		if errors.Is(err, model.UserSuspendedError) {
+			record(h.audit, h.logger, r, audit.Entry{Action: audit.ActionLoginRefused, Details: map[string]interface{}{"email": req.Email}})
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 
 		if err != nil {
+			record(h.audit, h.logger, r, audit.Entry{Action: audit.ActionLoginFailed, Details: map[string]interface{}{"email": req.Email}})
 			fmt.Println(err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		record(h.audit, h.logger, r, audit.Entry{ActorID: audit.ID(id), Action: audit.ActionLogin, TargetID: audit.ID(id)})
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -154,6 +188,12 @@ func (h *AuthHandler) handleRefresh() http.HandlerFunc {
 
 		jwt, refresh, err := h.tokenService.RefreshToken(req.RefreshToken)
 
+		if errors.Is(err, model.UserSuspendedError) {
+			record(h.audit, h.logger, r, audit.Entry{Action: audit.ActionRefreshRefused})
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
 		if err != nil {
 			fmt.Println(err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -6,7 +6,10 @@ import (
 	"io/ioutil"
 	"nikolamilovic/twitchy/auth/model/response"
 	"nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
 	"strings"
+	"time"
 
 	// "net/http"
 	"net/http"
@@ -14,6 +17,7 @@ import (
 	"testing"
 
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 )
 
 func TestRegistration(t *testing.T) {
@@ -26,6 +30,7 @@ func TestRegistration(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	srv := &AuthHandler{}
+	srv.audit = &mock.AuditLogMock{}
 	srv.authService = &mock.AuthServiceMock{}
 	srv.validator = validator.New()
 
@@ -96,6 +101,16 @@ func TestRegistrationErrors(t *testing.T) {
 			expected:       "Key: 'RegistrationRequest.Username' Error:Field validation for 'Username' failed on the 'required' tag\n",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			description: "username that can't be a channel name",
+			input: `{
+				"email":"valid@gmail.com",
+				"password":"123qwe123",
+				"username": "user/name"
+			 }`,
+			expected:       "username may only contain letters, digits and underscores, up to 50 of them\n",
+			expectedStatus: http.StatusBadRequest,
+		},
 	} {
 		t.Run(scenario.description, func(t *testing.T) {
 			//GIVEN
@@ -141,6 +156,7 @@ func TestLogin(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	srv := &AuthHandler{}
+	srv.audit = &mock.AuditLogMock{}
 	srv.authService = &mock.AuthServiceMock{}
 	srv.validator = validator.New()
 
@@ -181,6 +197,7 @@ func TestRefresh(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	srv := &AuthHandler{}
+	srv.audit = &mock.AuditLogMock{}
 	srv.authService = &mock.AuthServiceMock{}
 	srv.tokenService = &mock.TokenServiceMock{}
 	srv.validator = validator.New()
@@ -213,3 +230,61 @@ func TestRefresh(t *testing.T) {
 		t.Fatalf("expected a %v, instead got: %v", want, got)
 	}
 }
+
+func TestRateLimits(t *testing.T) {
+	// The IPs are the ones the gateway forwards
+	token.TrustGateway(true)
+	defer token.TrustGateway(false)
+
+	store := ratelimit.NewMemoryStore()
+	srv := NewAuthHandler(validator.New(), &mock.AuthServiceMock{}, &mock.TokenServiceMock{}, &mock.AuditLogMock{}, Limits{
+		Registrations: ratelimit.NewSlidingWindow(store, "register", 1, time.Hour),
+		Logins:        ratelimit.NewTokenBucket(store, "login", 0.1, 2),
+	}, zap.NewNop().Sugar())
+
+	bodies := map[string]string{
+		"/register": `{"email":"test@gmail.com","password":"123qwe123","username":"username"}`,
+		"/login":    `{"email":"test@gmail.com","password":"123qwe123"}`,
+	}
+
+	request := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(bodies[path]))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Real-IP", ip)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := request("/login", "1.1.1.1")
+		if want, got := http.StatusOK, w.Code; want != got {
+			t.Fatalf("expected a %d, instead got: %d", want, got)
+		}
+		if want, got := "2", w.Header().Get("RateLimit-Limit"); want != got {
+			t.Fatalf("expected a limit of %s, instead got: %s", want, got)
+		}
+	}
+
+	w := request("/login", "1.1.1.1")
+	if want, got := http.StatusTooManyRequests, w.Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+	if want, got := "10", w.Header().Get("Retry-After"); want != got {
+		t.Fatalf("expected to retry after %s, instead got: %s", want, got)
+	}
+	if want, got := "0", w.Header().Get("RateLimit-Remaining"); want != got {
+		t.Fatalf("expected %s remaining, instead got: %s", want, got)
+	}
+
+	// Other clients and endpoints have their own limits
+	if want, got := http.StatusOK, request("/login", "2.2.2.2").Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+	if want, got := http.StatusOK, request("/register", "1.1.1.1").Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+	if want, got := http.StatusTooManyRequests, request("/register", "1.1.1.1").Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/test_util"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	h := NewAuthHandler(validator.New(), &mock.AuthServiceMock{}, &mock.TokenServiceMock{}, &mock.AuditLogMock{}, Limits{
+		Registrations: ratelimit.NewSlidingWindow(store, "register", 5, time.Hour),
+		Logins:        ratelimit.NewTokenBucket(store, "login", 1, 5),
+	}, zap.NewNop().Sugar())
+
+	r := chi.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Handle("/metrics", metrics.Handler())
+	r.Mount("/v1/auth", h)
+
+	registered := map[string]string{"method": "POST", "route": "/v1/auth/register", "status": "400"}
+	unmatched := map[string]string{"method": "GET", "route": "unmatched", "status": "404"}
+	beforeRegistered := test_util.MetricValue("http_request_duration_seconds", registered)
+	beforeUnmatched := test_util.MetricValue("http_request_duration_seconds", unmatched)
+
+	// Invalid bodies are turned away before reaching the service
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader(`{"email":"not an email"}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown/12345", nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if want, got := http.StatusOK, w.Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	if want, got := 2.0, test_util.MetricValue("http_request_duration_seconds", registered)-beforeRegistered; want != got {
+		t.Fatalf("expected %v more registrations, instead got: %v", want, got)
+	}
+	if want, got := 1.0, test_util.MetricValue("http_request_duration_seconds", unmatched)-beforeUnmatched; want != got {
+		t.Fatalf("expected %v more unmatched requests, instead got: %v", want, got)
+	}
+
+	if !strings.Contains(w.Body.String(), "http_request_duration_seconds_count") {
+		t.Fatalf("expected the metrics to contain the request durations, instead got:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	mock_client "nikolamilovic/twitchy/auth/client/mock"
+	"nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/tracing"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/pashagolub/pgxmock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestRegistrationTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	})
+
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	db, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close(context.Background())
+
+	db.ExpectQuery("INSERT INTO users").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(1))
+
+	// The client starts the publish span in the context it's given, the headers of the span go into the message
+	client := mock_client.NewMockIAccountClient(ctl)
+	client.EXPECT().PublishAccountCreatedEvent(gomock.Any(), event.AccountCreatedEventData{ID: 1, Email: "test@gmail.com", Username: "username"}).
+		DoAndReturn(func(ctx context.Context, _ event.AccountCreatedEventData) error {
+			span, _ := tracing.StartPublish(ctx, constants.AccountsExchange, constants.AccountCreatedKey)
+			span.End()
+			return nil
+		})
+
+	store := ratelimit.NewMemoryStore()
+	h := NewAuthHandler(validator.New(), &service.AuthService{
+		DB:                  tracing.DB(db),
+		TokenService:        &mock.TokenServiceMock{},
+		AccountRabbitClient: client,
+	}, &mock.TokenServiceMock{}, &mock.AuditLogMock{}, Limits{
+		Registrations: ratelimit.NewSlidingWindow(store, "register", 1, time.Hour),
+		Logins:        ratelimit.NewTokenBucket(store, "login", 1, 1),
+	}, zap.NewNop().Sugar())
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Mount("/v1/auth", h)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/register", strings.NewReader(`{"email":"test@gmail.com","password":"123qwe123","username":"username"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected the insert, publish and request spans, got %d", len(spans))
+	}
+
+	insert, published, request := spans[0], spans[1], spans[2]
+
+	if want, got := "POST /v1/auth/register", request.Name(); want != got {
+		t.Fatalf("expected a %s span, instead got: %s", want, got)
+	}
+
+	if want, got := "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext().TraceID().String(); want != got {
+		t.Fatalf("expected the request to continue trace %s, instead got: %s", want, got)
+	}
+
+	for _, span := range []sdktrace.ReadOnlySpan{insert, published} {
+		if span.Parent().SpanID() != request.SpanContext().SpanID() {
+			t.Fatalf("expected %s to be a child of the request, got parent %v", span.Name(), span.Parent())
+		}
+	}
+}
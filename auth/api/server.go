@@ -5,10 +5,17 @@ import (
 	"nikolamilovic/twitchy/auth/api/handler"
 	"nikolamilovic/twitchy/auth/client"
 	"nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/common/audit"
 	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/health"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/tracing"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 )
 
 type Server struct {
@@ -22,7 +29,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
-func NewServer(db db.PgxIface, client *client.AccountClient) (*Server, error) {
+func NewServer(db db.PgxIface, client *client.AccountClient, limits ratelimit.Store, probes *health.Health, jwtSecret []byte, logger *zap.SugaredLogger) (*Server, error) {
 	s := &Server{
 		mux: chi.NewMux(),
 		db:  db,
@@ -30,7 +37,8 @@ func NewServer(db db.PgxIface, client *client.AccountClient) (*Server, error) {
 	s.validator = validator.New()
 
 	tokenService := &service.TokenService{
-		DB: s.db,
+		DB:     s.db,
+		Secret: jwtSecret,
 	}
 
 	authService := &service.AuthService{
@@ -39,10 +47,24 @@ func NewServer(db db.PgxIface, client *client.AccountClient) (*Server, error) {
 		AccountRabbitClient: client,
 	}
 
+	auditLog := audit.NewLog(s.db)
+
+	s.mux.Use(tracing.Middleware)
+	s.mux.Use(metrics.Middleware)
+
 	//Routing
-	h := handler.NewAuthHandler(s.validator, authService, tokenService)
+	h := handler.NewAuthHandler(s.validator, authService, tokenService, auditLog, handler.Limits{
+		Registrations: ratelimit.NewSlidingWindow(limits, "register", 5, time.Hour),
+		Logins:        ratelimit.NewTokenBucket(limits, "login", 0.2, 10),
+	}, logger.Named("auth_handler"))
 	h.Routes()
 
+	ah := handler.NewAdminHandler(service.NewSessionService(s.db), auditLog, jwtSecret, logger.Named("admin_handler"))
+
+	s.mux.Get("/healthz", probes.Live)
+	s.mux.Get("/readyz", probes.Readiness)
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/auth/admin", ah)
 	s.mux.Mount("/v1/auth", h)
 	return s, nil
 }
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/health"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"testing"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+type brokerConnection struct {
+	connected bool
+}
+
+func (c *brokerConnection) IsConnected() bool {
+	return c.connected
+}
+
+func TestProbes(t *testing.T) {
+	db, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close(context.Background())
+
+	connection := &brokerConnection{}
+	probes := health.New().
+		Add("postgres", health.Postgres(db)).
+		Add("migrations", health.Migrations(db, 7)).
+		Add("broker", health.Broker(connection))
+
+	srv, err := NewServer(db, nil, ratelimit.NewMemoryStore(), probes, []byte("secret"), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe := func(path string) (int, health.Report) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var report health.Report
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode the report of %s: %v", path, err)
+		}
+		return w.Code, report
+	}
+
+	// Not connected to the broker yet, and behind on the migrations
+	db.ExpectQuery("SELECT 1").WillReturnRows(pgxmock.NewRows([]string{"?column?"}).AddRow(1))
+	db.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(pgxmock.NewRows([]string{"version", "dirty"}).AddRow(int64(6), false))
+
+	code, report := probe("/readyz")
+	if want, got := http.StatusServiceUnavailable, code; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+	if want, got := "ok", report.Checks["postgres"]; want != got {
+		t.Fatalf("expected postgres to be %s, instead got: %s", want, got)
+	}
+	if want, got := "at migration 6, expected 7", report.Checks["migrations"]; want != got {
+		t.Fatalf("expected migrations to be %s, instead got: %s", want, got)
+	}
+	if want, got := rabbitmq.ErrDisconnected.Error(), report.Checks["broker"]; want != got {
+		t.Fatalf("expected broker to be %s, instead got: %s", want, got)
+	}
+
+	connection.connected = true
+	db.ExpectQuery("SELECT 1").WillReturnRows(pgxmock.NewRows([]string{"?column?"}).AddRow(1))
+	db.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(pgxmock.NewRows([]string{"version", "dirty"}).AddRow(int64(7), false))
+
+	if code, report := probe("/readyz"); code != http.StatusOK {
+		t.Fatalf("expected a %d, instead got: %d, %+v", http.StatusOK, code, report)
+	}
+
+	// Draining fails the readiness without checking anything, the service is still live
+	probes.Drain()
+
+	if code, report := probe("/readyz"); code != http.StatusServiceUnavailable || report.Status != health.ErrDraining.Error() {
+		t.Fatalf("expected a %d while draining, instead got: %d, %+v", http.StatusServiceUnavailable, code, report)
+	}
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Fatalf("expected a %d, instead got: %d", http.StatusOK, code)
+	}
+
+	if err := db.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,14 +1,18 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"nikolamilovic/twitchy/common/constants"
 	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
 	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/tracing"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -16,21 +20,42 @@ import (
 )
 
 const (
-	// When resending messages the server didn't confirm
-	resendDelay = 5 * time.Second
+	// How long closing waits for the messages being processed
+	closeTimeout = 10 * time.Second
+	// Events the replicas failed to store are requeued after a delay doubling from retryDelay up to maxRetryDelay
+	retryDelay    = 100 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
 )
 
-//https://www.ribice.ba/golang-rabbitmq-client/
+// https://www.ribice.ba/golang-rabbitmq-client/
 type IAccountClient interface {
-	PublishAccountCreatedEvent(event event.AccountCreatedEventData) error
+	PublishAccountCreatedEvent(ctx context.Context, event event.AccountCreatedEventData) error
+}
+
+// IRoleReplica stores the roles granted in the account service so they can be embedded in the tokens
+type IRoleReplica interface {
+	GrantRole(ctx context.Context, data event.RoleGrantedEventData) error
+	RevokeRole(ctx context.Context, data event.RoleRevokedEventData) error
+}
+
+// ISuspensionReplica stores the suspensions handed out in the account service so suspended users can't get new tokens
+type ISuspensionReplica interface {
+	SuspendUser(ctx context.Context, data event.UserSuspendedEventData) error
+	UnsuspendUser(ctx context.Context, data event.UserUnsuspendedEventData) error
 }
 
 // AccountClient holds necessery information for rabbitMQ
 type AccountClient struct {
-	logger     *zap.SugaredLogger
-	connection *rabbitmq.ClientConnection
-	threads    int
-	wg         *sync.WaitGroup
+	roles       IRoleReplica
+	suspensions ISuspensionReplica
+	logger      *zap.SugaredLogger
+	connection  *rabbitmq.ClientConnection
+	threads     int
+	wg          *sync.WaitGroup
+	stop        context.CancelFunc
+	// failures counts the events the replicas failed to store in a row, for the backoff
+	failures   int32
+	retryDelay time.Duration
 }
 
 func New(addr string, l *zap.SugaredLogger, connection *rabbitmq.ClientConnection) *AccountClient {
@@ -41,6 +66,7 @@ func New(addr string, l *zap.SugaredLogger, connection *rabbitmq.ClientConnectio
 
 	client := AccountClient{
 		logger:     l,
+		retryDelay: retryDelay,
 		threads:    threads,
 		connection: connection,
 		wg:         &sync.WaitGroup{},
@@ -51,8 +77,32 @@ func New(addr string, l *zap.SugaredLogger, connection *rabbitmq.ClientConnectio
 	return &client
 }
 
-// Push a new message that an account has been created
-func (c *AccountClient) PublishAccountCreatedEvent(data event.AccountCreatedEventData) error {
+// Consume starts feeding the role and suspension events into the replicas
+func (c *AccountClient) Consume(cancelCtx context.Context, roles IRoleReplica, suspensions ISuspensionReplica) {
+	c.roles = roles
+	c.suspensions = suspensions
+
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			err := c.stream(ctx)
+			if errors.Is(err, rabbitmq.ErrDisconnected) {
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
+			break
+		}
+	}()
+}
+
+// Push a new message that an account has been created, the trace in ctx is continued by the consumers
+func (c *AccountClient) PublishAccountCreatedEvent(ctx context.Context, data event.AccountCreatedEventData) error {
 	payload, err := json.Marshal(data)
 
 	if err != nil {
@@ -70,7 +120,7 @@ func (c *AccountClient) PublishAccountCreatedEvent(data event.AccountCreatedEven
 		return fmt.Errorf("failed to marshal event: %v", err)
 	}
 
-	return c.push(constants.AccountCreatedKey, ev)
+	return c.push(ctx, constants.AccountCreatedKey, ev)
 }
 
 // Push will push data onto the queue, and wait for a confirmation.
@@ -78,53 +128,21 @@ func (c *AccountClient) PublishAccountCreatedEvent(data event.AccountCreatedEven
 // it continuously resends messages until a confirmation is received.
 // This will block until the server sends a confirm
 
-//TODO add a timeout to the push and store the event into db, this shouldn't block
-func (c *AccountClient) push(key string, data []byte) error {
-	if !c.connection.IsConnected {
-		return errors.New("failed to push push: not connected")
-	}
-	for {
-		err := c.unsafePush(key, data)
-		if err != nil {
-			if err == rabbitmq.ErrDisconnected {
-				continue
-			}
-			return err
-		}
-		select {
-		case confirm := <-c.connection.NotifyConfirm:
-			if confirm.Ack {
-				return nil
-			}
-		case <-time.After(resendDelay):
-		}
-	}
-}
-
-// unsafePush will push to the queue without checking for
-// confirmation. It returns an error if it fails to connect.
-// No guarantees are provided for whether the server will
-// receive the message.
-func (c *AccountClient) unsafePush(key string, data []byte) error {
-	if !c.connection.IsConnected {
-		return rabbitmq.ErrDisconnected
-	}
+// TODO add a timeout to the push and store the event into db, this shouldn't block
+func (c *AccountClient) push(ctx context.Context, key string, data []byte) error {
+	span, headers := tracing.StartPublish(ctx, constants.AccountsExchange, key)
+	defer span.End()
 
-	return c.connection.Channel.Publish(
-		constants.AccountsExchange, // Exchange
-		key,                        // Routing key
-		false,                      // Mandatory
-		false,                      // Immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        data,
-		},
-	)
+	return c.connection.PublishConfirmed(ctx, constants.AccountsExchange, key, amqp.Publishing{
+		ContentType: "application/json",
+		Headers:     headers,
+		Body:        data,
+	})
 }
 
 // connect will make a single attempt to connect to
 // RabbitMq. It returns the success of the attempt.
-func (c *AccountClient) connect(ch *amqp.Channel) bool {
+func (c *AccountClient) connect(ch rabbitmq.Channel) bool {
 
 	err := ch.ExchangeDeclare(constants.AccountsExchange, "topic", true, false, false, false, nil)
 
@@ -133,18 +151,18 @@ func (c *AccountClient) connect(ch *amqp.Channel) bool {
 		return false
 	}
 
-	// _, err = ch.QueueDeclare(
-	// 	constants.AuthServiceQueue,
-	// 	true,  // Durable
-	// 	false, // Delete when unused
-	// 	false, // Exclusive
-	// 	false, // No-wait
-	// 	nil,   // Arguments
-	// )
-	// if err != nil {
-	// 	c.logger.Errorf("failed to declare %s queue: %v", constants.AuthServiceQueue, err)
-	// 	return false
-	// }
+	_, err = ch.QueueDeclare(
+		constants.AuthServiceQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.AuthServiceQueue, err)
+		return false
+	}
 
 	_, err = ch.QueueDeclare(
 		constants.AccountsQueue,
@@ -164,147 +182,188 @@ func (c *AccountClient) connect(ch *amqp.Channel) bool {
 		c.logger.Errorf("failed to bind push queue: %v", err)
 		return false
 	}
-	// err = ch.QueueBind(constants.AuthServiceQueue, "", constants.AccountsExchange, true, nil)
 
-	// if err != nil {
-	// 	c.logger.Errorf("failed to bind stream queue: %v", err)
-	// 	return false
-	// }
+	for _, key := range []string{constants.RoleGrantedKey, constants.RoleRevokedKey, constants.UserSuspendedKey, constants.UserUnsuspendedKey} {
+		err = ch.QueueBind(constants.AuthServiceQueue, key, constants.AccountsExchange, false, nil)
+		if err != nil {
+			c.logger.Errorf("failed to bind %s to the auth queue: %v", key, err)
+			return false
+		}
+	}
 
 	return true
 }
 
-// func (c *AccountClient) stream(cancelCtx context.Context) error {
-// 	c.wg.Add(c.threads)
-
-// 	for {
-// 		if c.isConnected {
-// 			break
-// 		}
-// 		time.Sleep(1 * time.Second)
-// 	}
-
-// 	err := c.channel.Qos(1, 0, false)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	var connectionDropped bool
-
-// 	for i := 1; i <= c.threads; i++ {
-// 		msgs, err := c.channel.Consume(
-// 			c.streamQueue,
-// 			consumerName(i), // Consumer
-// 			false,           // Auto-Ack
-// 			false,           // Exclusive
-// 			false,           // No-local
-// 			false,           // No-Wait
-// 			nil,             // Args
-// 		)
-// 		if err != nil {
-// 			return err
-// 		}
-
-// 		go func() {
-// 			defer c.wg.Done()
-// 			for {
-// 				select {
-// 				case <-cancelCtx.Done():
-// 					return
-// 				case msg, ok := <-msgs:
-// 					if !ok {
-// 						connectionDropped = true
-// 						return
-// 					}
-// 					c.parseEvent(msg)
-// 				}
-// 			}
-// 		}()
-
-// 	}
-
-// 	c.wg.Wait()
-
-// 	if connectionDropped {
-// 		return ErrDisconnected
-// 	}
-
-// 	return nil
-// }
-
-// type event struct {
-// 	Job  string `json:"job"`
-// 	Data string `json:"data"`
-// }
-
-// func (c *AccountClient) parseEvent(msg amqp.Delivery) {
-// 	l := c.logger.Named("parseEvent")
-// 	startTime := time.Now()
-
-// 	var evt event
-// 	err := json.Unmarshal(msg.Body, &evt)
-// 	if err != nil {
-// 		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
-// 		return
-// 	}
-
-// 	if evt.Data == "" {
-// 		logAndNack(msg, l, startTime, "received event without data")
-// 		return
-// 	}
-
-// 	defer func(e event, m amqp.Delivery, logger *zap.SugaredLogger) {
-// 		if err := recover(); err != nil {
-// 			stack := make([]byte, 8096)
-// 			stack = stack[:runtime.Stack(stack, false)]
-// 			logger.Error("panic recovery for rabbitMQ message")
-// 			msg.Nack(false, false)
-// 		}
-// 	}(evt, msg, l)
-
-// 	// switch evt.Job {
-// 	// case "job1":
-// 	//     // Call an actual function
-// 	//     err = func()
-// 	// case "job1":
-// 	//     err = func()
-// 	// default:
-// 	//     msg.Reject(false)
-// 	//     return
-// 	// }
-
-// 	if err != nil {
-// 		logAndNack(msg, l, startTime, err.Error())
-// 		return
-// 	}
-
-// 	l.Errorf("Took ms %d, succeeded %s", time.Since(startTime).Milliseconds(), evt.Job)
-// 	msg.Ack(false)
-// }
-
-// func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string, args ...interface{}) {
-// 	msg.Nack(false, false)
-// 	l.Errorf("Took ms %d, %e", time.Since(t).Milliseconds(), err)
-// }
+func (c *AccountClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
 
-func (c *AccountClient) Close() error {
-	if !c.connection.IsConnected {
+	var deliveries []<-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		if err := ch.Qos(1, 0, false); err != nil {
+			return err
+		}
+
+		for i := 1; i <= c.threads; i++ {
+			msgs, err := ch.Consume(
+				constants.AuthServiceQueue,
+				consumerName(i), // Consumer
+				false,           // Auto-Ack
+				false,           // Exclusive
+				false,           // No-local
+				false,           // No-Wait
+				nil,             // Args
+			)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, msgs)
+		}
 		return nil
+	})
+	if err != nil {
+		return err
 	}
-	c.connection.Alive = false
-	c.logger.Info("Waiting for current messages to be processed...")
-	c.wg.Wait()
-	for i := 1; i <= c.threads; i++ {
-		fmt.Println("Closing consumer: ", i)
-		err := c.connection.Channel.Cancel(consumerName(i), false)
-		if err != nil {
-			return fmt.Errorf("error canceling consumer %s: %v", consumerName(i), err)
-		}
+
+	// The consumers only stop early when the channel closes
+	var wg sync.WaitGroup
+	for _, msgs := range deliveries {
+		wg.Add(1)
+		go func(msgs <-chan amqp.Delivery) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-msgs:
+					if !ok {
+						return
+					}
+					metrics.Consume(constants.AuthServiceQueue, msg, c.parseEvent)
+				}
+			}
+		}(msgs)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
 	}
+	return rabbitmq.ErrDisconnected
+}
+
+func (c *AccountClient) parseEvent(msg amqp.Delivery) {
+	l := c.logger.Named("parseEvent")
+	startTime := time.Now()
 
-	err := c.connection.Close()
+	// The replicas' queries are traced as part of processing the message
+	ctx, span := tracing.StartConsume(context.Background(), msg)
+	defer span.End()
+
+	var evt event.BaseEvent
+	err := json.Unmarshal(msg.Body, &evt)
+	if err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
+		return
+	}
+
+	if evt.Payload == "" {
+		logAndNack(msg, l, startTime, "received event without data")
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			stack := make([]byte, 8096)
+			stack = stack[:runtime.Stack(stack, false)]
+			c.requeue(msg, l, startTime, fmt.Errorf("panic: %v\n%s", err, stack))
+		}
+	}()
+
+	// Malformed payloads are dropped, the replicas failing to store an event is requeued so they don't miss it
+	var replicaErr error
+	switch evt.Type {
+	case event.RoleGrantedType:
+		payload := &event.RoleGrantedEventData{}
+		if err = json.Unmarshal([]byte(evt.Payload), payload); err == nil {
+			replicaErr = c.roles.GrantRole(ctx, *payload)
+		}
+	case event.RoleRevokedType:
+		payload := &event.RoleRevokedEventData{}
+		if err = json.Unmarshal([]byte(evt.Payload), payload); err == nil {
+			replicaErr = c.roles.RevokeRole(ctx, *payload)
+		}
+	case event.UserSuspendedType:
+		payload := &event.UserSuspendedEventData{}
+		if err = json.Unmarshal([]byte(evt.Payload), payload); err == nil {
+			replicaErr = c.suspensions.SuspendUser(ctx, *payload)
+		}
+	case event.UserUnsuspendedType:
+		payload := &event.UserUnsuspendedEventData{}
+		if err = json.Unmarshal([]byte(evt.Payload), payload); err == nil {
+			replicaErr = c.suspensions.UnsuspendUser(ctx, *payload)
+		}
+	default:
+		msg.Reject(false)
+		return
+	}
 
 	if err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling payload: %s - %s", evt.Payload, err.Error())
+		return
+	}
+
+	if replicaErr != nil {
+		c.requeue(msg, l, startTime, replicaErr)
+		return
+	}
+
+	atomic.StoreInt32(&c.failures, 0)
+	l.Infof("Took ms %d, succeeded %s", time.Since(startTime).Milliseconds(), evt.Type)
+	msg.Ack(false)
+}
+
+func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string, args ...interface{}) {
+	msg.Nack(false, false)
+	l.Errorf("Took ms %d, %s", time.Since(t).Milliseconds(), fmt.Sprintf(err, args...))
+}
+
+// requeue puts the message back on the queue once the backoff is over, a database that stays down isn't hammered
+func (c *AccountClient) requeue(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err error) {
+	delay := c.backoff(atomic.AddInt32(&c.failures, 1))
+	l.Errorf("Took ms %d, requeueing in %v: %v", time.Since(t).Milliseconds(), delay, err)
+
+	time.Sleep(delay)
+	msg.Nack(false, true)
+}
+
+// backoff is how long to wait after the replicas failed failures times in a row
+func (c *AccountClient) backoff(failures int32) time.Duration {
+	delay := c.retryDelay
+	for i := int32(1); i < failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+func (c *AccountClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	c.logger.Info("Waiting for current messages to be processed...")
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	if err := c.connection.Close(ctx); err != nil {
 		return err
 	}
 
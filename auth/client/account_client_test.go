@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEvents(t *testing.T) {
+	type parseTest struct {
+		description string
+		body        string
+		expect      func(ack *amqpmock.MockAcknowledger)
+		granted     int
+		revoked     int
+		suspended   int
+	}
+
+	for _, scenario := range []parseTest{
+		{
+			description: "role granted",
+			body:        `{"type":"role_granted","payload":"{\"user_id\":5,\"role\":\"moderator\",\"channel_id\":2}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Ack(gomock.Any(), false) },
+			granted:     1,
+		},
+		{
+			description: "role revoked",
+			body:        `{"type":"role_revoked","payload":"{\"user_id\":5,\"role\":\"admin\"}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Ack(gomock.Any(), false) },
+			revoked:     1,
+		},
+		{
+			description: "user suspended",
+			body:        `{"type":"user_suspended","payload":"{\"user_id\":5,\"actor_id\":1,\"reason\":\"tos\"}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Ack(gomock.Any(), false) },
+			suspended:   1,
+		},
+		{
+			description: "role replica fails",
+			body:        `{"type":"role_granted","payload":"{\"user_id\":500,\"role\":\"vip\",\"channel_id\":2}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Nack(gomock.Any(), false, true) },
+		},
+		{
+			description: "suspension replica fails",
+			body:        `{"type":"user_suspended","payload":"{\"user_id\":500,\"actor_id\":1}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Nack(gomock.Any(), false, true) },
+		},
+		{
+			description: "malformed payload",
+			body:        `{"type":"user_suspended","payload":"{\"user_id\":\"five\"}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Nack(gomock.Any(), false, false) },
+		},
+		{
+			description: "no payload",
+			body:        `{"type":"role_granted"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Nack(gomock.Any(), false, false) },
+		},
+		{
+			description: "unknown event",
+			body:        `{"type":"account_created","payload":"{}"}`,
+			expect:      func(ack *amqpmock.MockAcknowledger) { ack.EXPECT().Reject(gomock.Any(), false) },
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			ctl := gomock.NewController(t)
+			defer ctl.Finish()
+
+			roles := &mock.RoleServiceMock{}
+			suspensions := &mock.SuspensionServiceMock{}
+			client := &AccountClient{
+				logger:      zap.L().Sugar().Named("test"),
+				roles:       roles,
+				suspensions: suspensions,
+			}
+
+			ack := amqpmock.NewMockAcknowledger(ctl)
+			scenario.expect(ack)
+
+			client.parseEvent(amqp091.Delivery{
+				Acknowledger: ack,
+				ContentType:  "application/json",
+				Body:         []byte(scenario.body),
+			})
+
+			if len(roles.Granted) != scenario.granted || len(roles.Revoked) != scenario.revoked {
+				t.Fatalf("Expected %d grants and %d revocations, got %d and %d", scenario.granted, scenario.revoked, len(roles.Granted), len(roles.Revoked))
+			}
+
+			if len(suspensions.Suspended) != scenario.suspended {
+				t.Fatalf("Expected %d suspensions, got %d", scenario.suspended, len(suspensions.Suspended))
+			}
+		})
+	}
+}
+
+func TestRequeueBackoff(t *testing.T) {
+	client := &AccountClient{retryDelay: time.Second}
+
+	for failures, want := range map[int32]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: maxRetryDelay, 100: maxRetryDelay} {
+		if got := client.backoff(failures); got != want {
+			t.Fatalf("expected a backoff of %v after %d failures, instead got: %v", want, failures, got)
+		}
+	}
+}
+
+func TestPublishAccountCreatedEvent(t *testing.T) {
+	broker := rabbitmq.NewMemoryBroker()
+	connection := rabbitmq.NewClientConnection(zap.NewNop().Sugar()).WithDialer(broker.Dial)
+	client := New("amqp://test", zap.NewNop().Sugar(), connection)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := connection.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	if err := client.PublishAccountCreatedEvent(ctx, event.AccountCreatedEventData{ID: 5, Email: "test@gmail.com", Username: "test"}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	// The account service consumes the queue auth declares, the event waits there until it's up
+	if want, got := 1, broker.Messages(constants.AccountsQueue); want != got {
+		t.Fatalf("expected %d message in the accounts queue, instead got: %d", want, got)
+	}
+	if want, got := 0, broker.Messages(constants.AuthServiceQueue); want != got {
+		t.Fatalf("expected %d messages in the auth queue, instead got: %d", want, got)
+	}
+}
@@ -5,6 +5,7 @@
 package mock_client
 
 import (
+	context "context"
 	event "nikolamilovic/twitchy/common/event"
 	reflect "reflect"
 
@@ -35,15 +36,15 @@ func (m *MockIAccountClient) EXPECT() *MockIAccountClientMockRecorder {
 }
 
 // PublishAccountCreatedEvent mocks base method.
-func (m *MockIAccountClient) PublishAccountCreatedEvent(event event.AccountCreatedEventData) error {
+func (m *MockIAccountClient) PublishAccountCreatedEvent(ctx context.Context, event event.AccountCreatedEventData) error {
         m.ctrl.T.Helper()
-        ret := m.ctrl.Call(m, "PublishAccountCreatedEvent", event)
+        ret := m.ctrl.Call(m, "PublishAccountCreatedEvent", ctx, event)
         ret0, _ := ret[0].(error)
         return ret0
 }
 
 // PublishAccountCreatedEvent indicates an expected call of PublishAccountCreatedEvent.
-func (mr *MockIAccountClientMockRecorder) PublishAccountCreatedEvent(event interface{}) *gomock.Call {
+func (mr *MockIAccountClientMockRecorder) PublishAccountCreatedEvent(ctx, event interface{}) *gomock.Call {
         mr.mock.ctrl.T.Helper()
-        return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishAccountCreatedEvent", reflect.TypeOf((*MockIAccountClient)(nil).PublishAccountCreatedEvent), event)
+        return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishAccountCreatedEvent", reflect.TypeOf((*MockIAccountClient)(nil).PublishAccountCreatedEvent), ctx, event)
 }
\ No newline at end of file
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"nikolamilovic/twitchy/common/config"
+)
+
+// Config is read from the environment, or the YAML file at CONFIG_FILE
+type Config struct {
+	Port      int    `env:"PORT" yaml:"port" default:"80"`
+	JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+	// TrustGateway takes the user the gateway verified from its headers, for services only reachable through it
+	TrustGateway bool            `env:"TRUST_GATEWAY" yaml:"trust_gateway"`
+	Postgres     config.Postgres `yaml:"postgres"`
+	RabbitMQ     config.RabbitMQ `yaml:"rabbitmq"`
+}
@@ -0,0 +1,8 @@
+-- Replica of the roles granted in the account service, kept in sync through the role events and embedded in the tokens.
+-- channel_id is 0 for platform roles.
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id integer NOT NULL,
+  role varchar(20) NOT NULL,
+  channel_id integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (user_id, role, channel_id)
+);
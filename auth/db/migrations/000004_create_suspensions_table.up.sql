@@ -0,0 +1,5 @@
+-- Replica of the suspensions in the account service, suspended users can't log in or refresh their tokens
+CREATE TABLE IF NOT EXISTS suspensions (
+  user_id integer PRIMARY KEY,
+  expires_at timestamptz
+);
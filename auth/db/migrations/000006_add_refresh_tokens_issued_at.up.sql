@@ -0,0 +1,2 @@
+-- Logging in and refreshing replace the token, issued_at is when the session was last used to get one
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS issued_at timestamptz NOT NULL DEFAULT NOW();
@@ -0,0 +1,17 @@
+-- Rate limits shared by all instances of the service, allowed is whether the last request took a token
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+  key varchar(255) PRIMARY KEY,
+  tokens double precision NOT NULL,
+  allowed boolean NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS rate_limit_windows (
+  key varchar(255) NOT NULL,
+  window_start timestamptz NOT NULL,
+  hits integer NOT NULL DEFAULT 0,
+  PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+CREATE INDEX IF NOT EXISTS rate_limit_windows_window_start_idx ON rate_limit_windows (window_start);
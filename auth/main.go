@@ -7,8 +7,14 @@ import (
 	"net/http"
 	"nikolamilovic/twitchy/auth/api"
 	"nikolamilovic/twitchy/auth/client"
+	"nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/common/config"
 	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/health"
 	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/tracing"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,6 +23,13 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// rateLimitRetention is how long the rate limits are kept, longer than the longest window
+	rateLimitRetention = 2 * time.Hour
+	// drainDelay is how long the server keeps serving once it's not ready, until the probes took it out of rotation
+	drainDelay = 5 * time.Second
+)
+
 var (
 	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
 	shutdowns []func() error
@@ -28,41 +41,69 @@ func main() {
 		ctx      = context.Background()
 		sigint   = make(chan os.Signal, 1)
 	)
+
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+	token.TrustGateway(cfg.TrustGateway)
+
 	rand.Seed(time.Now().UnixNano())
 
-	dbConn, dbCleanup, err := db.InitDb(ctx, logger.Sugar().Named("db"))
+	stopTracing, err := tracing.Init(ctx, "auth")
+	if err != nil {
+		logger.Fatal("failed to init tracing", zap.Error(err))
+	}
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
 	if err != nil {
 		logger.Fatal("failed to init the db", zap.Error(err))
 	}
+	// The probes aren't traced, they would bury the traces of the requests
+	probeConn := dbConn
+	dbConn = tracing.DB(dbConn)
 
-	amqpServerURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		os.Getenv("RABBITMQ_USER"),
-		os.Getenv("RABBITMQ_PASSWORD"),
-		os.Getenv("RABBITMQ_HOST"),
-		os.Getenv("RABBITMQ_PORT"),
-	)
+	migration, err := db.LatestVersion(cfg.Postgres.MigrationPath)
+	if err != nil {
+		logger.Fatal("failed to read the migrations", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
 
-	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"), sigint)
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
 	client := client.New(amqpServerURL, logger.Sugar().Named("accounts_rabbitmq_client"), clientConnection)
+	client.Consume(ctx, service.NewRoleService(dbConn), service.NewSuspensionService(dbConn))
 
-	srv, err := api.NewServer(dbConn, client)
+	probes := health.New().
+		Add("postgres", health.Postgres(probeConn)).
+		Add("migrations", health.Migrations(probeConn, migration)).
+		Add("broker", health.Broker(clientConnection))
+
+	rateLimits := ratelimit.NewPostgresStore(dbConn)
+	rateLimitCtx, stopRateLimits := context.WithCancel(ctx)
+	go rateLimits.Run(rateLimitCtx, rateLimitRetention, logger.Sugar().Named("rate_limits"))
+
+	srv, err := api.NewServer(dbConn, client, rateLimits, probes, []byte(cfg.JWTSecret), logger.Sugar().Named("server"))
 
 	if err != nil {
 		logger.Fatal("Unable to initialize the server", zap.Error(err))
 		os.Exit(1)
 	}
 
-	port := fmt.Sprintf(":%s", os.Getenv("PORT"))
+	port := fmt.Sprintf(":%d", cfg.Port)
 	server := http.Server{
 		Addr:    port,
 		Handler: srv,
 	}
 
-	shutdowns = append(shutdowns, dbCleanup, client.Close)
+	shutdowns = append(shutdowns, func() error {
+		stopRateLimits()
+		return nil
+	}, dbCleanup, client.Close, stopTracing)
 
 	defer logger.Sync()
 
-	go gracefulShutdown(&server, shutdown, ctx, sigint)
+	go gracefulShutdown(&server, probes, shutdown, ctx, sigint)
 
 	logger.Info("Server starting and listening at port " + server.Addr)
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
@@ -70,12 +111,16 @@ func main() {
 	}
 }
 
-func gracefulShutdown(server *http.Server, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
+func gracefulShutdown(server *http.Server, probes *health.Health, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 	<-sigint
 
 	logger.Info("shutting down server gracefully")
 
+	// fail the readiness so the traffic drains before the server stops.
+	probes.Drain()
+	time.Sleep(drainDelay)
+
 	// stop receiving any request.
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Fatal("shutdown error", zap.Error(err))
@@ -3,3 +3,5 @@ package model
 import "errors"
 
 var WrongPasswordError = errors.New("Wrong password")
+
+var UserSuspendedError = errors.New("The account is suspended")
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// Session is the refresh token of a user, logging in again replaces it so a user has at most one
+type Session struct {
+	UserID    int       `json:"user_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -15,8 +15,8 @@ import (
 // another layer on top of this simple example is just a bit overkill for the time being.
 
 type IAuthService interface {
-	Register(email, password, username string) (string, string, int, error)
-	Login(email, password string) (string, string, int, error)
+	Register(ctx context.Context, email, password, username string) (string, string, int, error)
+	Login(ctx context.Context, email, password string) (string, string, int, error)
 }
 
 type AuthService struct {
@@ -36,8 +36,8 @@ func checkPasswordHash(password, hash string) bool {
 }
 
 //Return JWT, refresh token and the user ID
-func (s *AuthService) Register(email, password, username string) (string, string, int, error) {
-	id, err := s.createUser(email, password, username)
+func (s *AuthService) Register(ctx context.Context, email, password, username string) (string, string, int, error) {
+	id, err := s.createUser(ctx, email, password, username)
 
 	if err != nil {
 		return "", "", -1, fmt.Errorf("Register create user %w", err)
@@ -49,7 +49,7 @@ func (s *AuthService) Register(email, password, username string) (string, string
 	// currently this poses an issue if the event emittion fails but we successfuly created a user
 	// so the user and event should be saved to the DB in a transaction
 
-	err = s.AccountRabbitClient.PublishAccountCreatedEvent(
+	err = s.AccountRabbitClient.PublishAccountCreatedEvent(ctx,
 		event.AccountCreatedEventData{ID: id, Email: email, Username: username})
 
 	if err != nil {
@@ -68,13 +68,19 @@ func (s *AuthService) Register(email, password, username string) (string, string
 }
 
 //Check checkLogin first, then if it's ok, generate tokens and return JWT, refresh token and the user ID
-func (a *AuthService) Login(email, password string) (string, string, int, error) {
-	id, err := a.checkLogin(email, password)
+func (a *AuthService) Login(ctx context.Context, email, password string) (string, string, int, error) {
+	id, err := a.checkLogin(ctx, email, password)
 
 	if err != nil {
 		return "", "", -1, fmt.Errorf("Login check %w", err)
 	}
 
+	err = checkSuspended(a.DB, id)
+
+	if err != nil {
+		return "", "", -1, fmt.Errorf("Login suspension check %w", err)
+	}
+
 	jwt, refresh, err := a.TokenService.GenerateNewTokensForUser(id)
 
 	if err != nil {
@@ -84,8 +90,8 @@ func (a *AuthService) Login(email, password string) (string, string, int, error)
 	return jwt, refresh, id, nil
 }
 
-func (a *AuthService) checkLogin(email, password string) (int, error) {
-	rows, err := a.DB.Query(context.Background(), "SELECT id, password FROM users WHERE email=$1", email)
+func (a *AuthService) checkLogin(ctx context.Context, email, password string) (int, error) {
+	rows, err := a.DB.Query(ctx, "SELECT id, password FROM users WHERE email=$1", email)
 
 	if err != nil {
 		fmt.Printf("Error getting user %s", err.Error())
@@ -112,7 +118,7 @@ func (a *AuthService) checkLogin(email, password string) (int, error) {
 	}
 }
 
-func (s *AuthService) createUser(email, password, username string) (int, error) {
+func (s *AuthService) createUser(ctx context.Context, email, password, username string) (int, error) {
 	fmt.Printf("Creating user with email %s, password: %s and username: %s\n", email, password, username)
 
 	hashedPassword, err := hashPassword(password)
@@ -122,7 +128,7 @@ func (s *AuthService) createUser(email, password, username string) (int, error)
 		return -1, err
 	}
 
-	rows, err := s.DB.Query(context.Background(), "INSERT INTO users (email, password, username) VALUES ($1,$2,$3) RETURNING id", email, hashedPassword, username)
+	rows, err := s.DB.Query(ctx, "INSERT INTO users (email, password, username) VALUES ($1,$2,$3) RETURNING id", email, hashedPassword, username)
 
 	if err != nil {
 		fmt.Printf("Error creating user %s", err.Error())
@@ -36,12 +36,12 @@ func TestRegistration(t *testing.T) {
 
 	mock.ExpectQuery("INSERT INTO users").WillReturnRows(rows)
 
-	clientMock.EXPECT().PublishAccountCreatedEvent(
+	clientMock.EXPECT().PublishAccountCreatedEvent(gomock.Any(),
 		event.AccountCreatedEventData{ID: 1, Email: "test@gmail.com", Username: "username"},
 	).Return(nil)
 
 	//WHEN
-	jwt, refresh, id, err := sut.Register("test@gmail.com", "123qwe", "username")
+	jwt, refresh, id, err := sut.Register(context.Background(), "test@gmail.com", "123qwe", "username")
 
 	//SHOULD
 	if jwt != "JWT" {
@@ -80,7 +80,7 @@ func TestLoginCheck(t *testing.T) {
 
 	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
 
-	id, err := sut.checkLogin("test@gmail.com", "password")
+	id, err := sut.checkLogin(context.Background(), "test@gmail.com", "password")
 
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when creating auth", err)
@@ -109,7 +109,7 @@ func TestLoginCheckWrongPassword(t *testing.T) {
 
 	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
 
-	id, err := sut.checkLogin("test@gmail.com", "wrongpassword")
+	id, err := sut.checkLogin(context.Background(), "test@gmail.com", "wrongpassword")
 
 	if id != -1 {
 		t.Fatalf("Expected id to be %d got %d", -1, id)
@@ -119,3 +119,31 @@ func TestLoginCheckWrongPassword(t *testing.T) {
 		t.Fatalf("wrong error , expected %e, got %e", model.WrongPasswordError, err)
 	}
 }
+
+func TestLoginSuspended(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+
+	sut := &AuthService{
+		DB:           mock,
+		TokenService: &serviceMock.TokenServiceMock{},
+	}
+
+	hashedPassword, _ := hashPassword("password")
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(pgxmock.NewRows([]string{"id", "password"}).AddRow(1, hashedPassword))
+	mock.ExpectQuery("SELECT 1 FROM suspensions").WithArgs(1).WillReturnRows(pgxmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	_, _, id, err := sut.Login(context.Background(), "test@gmail.com", "password")
+
+	if !errors.Is(err, model.UserSuspendedError) {
+		t.Fatalf("Expected error to be %v, got %v", model.UserSuspendedError, err)
+	}
+
+	if id != -1 {
+		t.Fatalf("Expected id to be %d got %d", -1, id)
+	}
+}
@@ -1,12 +1,14 @@
 package mock
 
+import "context"
+
 type AuthServiceMock struct {
 }
 
-func (a *AuthServiceMock) Register(email, password, username string) (string, string, int, error) {
+func (a *AuthServiceMock) Register(ctx context.Context, email, password, username string) (string, string, int, error) {
 	return "JWT", "REFRESH", 1, nil
 }
 
-func (a *AuthServiceMock) Login(email, password string) (string, string, int, error) {
+func (a *AuthServiceMock) Login(ctx context.Context, email, password string) (string, string, int, error) {
 	return "JWT", "REFRESH", 1, nil
 }
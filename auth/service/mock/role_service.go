@@ -0,0 +1,26 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/common/event"
+)
+
+// RoleServiceMock records the replicated roles, grants for user 500 fail
+type RoleServiceMock struct {
+	Granted []event.RoleGrantedEventData
+	Revoked []event.RoleRevokedEventData
+}
+
+func (r *RoleServiceMock) GrantRole(ctx context.Context, data event.RoleGrantedEventData) error {
+	if data.UserID == 500 {
+		return errors.New("db down")
+	}
+	r.Granted = append(r.Granted, data)
+	return nil
+}
+
+func (r *RoleServiceMock) RevokeRole(ctx context.Context, data event.RoleRevokedEventData) error {
+	r.Revoked = append(r.Revoked, data)
+	return nil
+}
@@ -0,0 +1,19 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/auth/model"
+	"time"
+)
+
+type SessionServiceMock struct {
+	Revoked []int
+}
+
+func (s *SessionServiceMock) GetSessions(userId int) ([]model.Session, error) {
+	return []model.Session{{UserID: userId, IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}}, nil
+}
+
+func (s *SessionServiceMock) RevokeSessions(userId int) (int, error) {
+	s.Revoked = append(s.Revoked, userId)
+	return 1, nil
+}
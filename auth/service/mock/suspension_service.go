@@ -0,0 +1,26 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/common/event"
+)
+
+// SuspensionServiceMock records the replicated suspensions, suspending user 500 fails
+type SuspensionServiceMock struct {
+	Suspended   []event.UserSuspendedEventData
+	Unsuspended []event.UserUnsuspendedEventData
+}
+
+func (s *SuspensionServiceMock) SuspendUser(ctx context.Context, data event.UserSuspendedEventData) error {
+	if data.UserID == 500 {
+		return errors.New("db down")
+	}
+	s.Suspended = append(s.Suspended, data)
+	return nil
+}
+
+func (s *SuspensionServiceMock) UnsuspendUser(ctx context.Context, data event.UserUnsuspendedEventData) error {
+	s.Unsuspended = append(s.Unsuspended, data)
+	return nil
+}
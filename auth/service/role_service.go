@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/event"
+)
+
+// RoleService keeps the replica of the roles the account service grants, the token service reads it to fill the scopes
+type RoleService struct {
+	DB db.PgxIface
+}
+
+func NewRoleService(db db.PgxIface) *RoleService {
+	return &RoleService{
+		DB: db,
+	}
+}
+
+// GrantRole stores the role, redelivered grants are ignored
+func (s *RoleService) GrantRole(ctx context.Context, data event.RoleGrantedEventData) error {
+	_, err := s.DB.Exec(ctx,
+		"INSERT INTO user_roles (user_id, role, channel_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		data.UserID, data.Role, data.ChannelID)
+
+	if err != nil {
+		return fmt.Errorf("GrantRole: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RoleService) RevokeRole(ctx context.Context, data event.RoleRevokedEventData) error {
+	_, err := s.DB.Exec(ctx,
+		"DELETE FROM user_roles WHERE user_id = $1 AND role = $2 AND channel_id = $3",
+		data.UserID, data.Role, data.ChannelID)
+
+	if err != nil {
+		return fmt.Errorf("RevokeRole: %w", err)
+	}
+
+	return nil
+}
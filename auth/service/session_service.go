@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/auth/model"
+	db "nikolamilovic/twitchy/common/db"
+	"time"
+)
+
+type ISessionService interface {
+	GetSessions(userId int) ([]model.Session, error)
+	// RevokeSessions drops the refresh tokens of the user, the JWTs already handed out stay valid until they expire
+	RevokeSessions(userId int) (int, error)
+}
+
+type SessionService struct {
+	DB db.PgxIface
+}
+
+func NewSessionService(db db.PgxIface) *SessionService {
+	return &SessionService{
+		DB: db,
+	}
+}
+
+func (s *SessionService) GetSessions(userId int) ([]model.Session, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT user_id, issued_at, expires FROM refresh_tokens WHERE user_id = $1 AND expires > $2", userId, time.Now().Unix())
+
+	if err != nil {
+		return nil, fmt.Errorf("GetSessions: %w", err)
+	}
+
+	defer rows.Close()
+
+	sessions := []model.Session{}
+	for rows.Next() {
+		var session model.Session
+		var expires int64
+		if err := rows.Scan(&session.UserID, &session.IssuedAt, &expires); err != nil {
+			return nil, fmt.Errorf("GetSessions: %w", err)
+		}
+
+		session.ExpiresAt = time.Unix(expires, 0)
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeSessions(userId int) (int, error) {
+	res, err := s.DB.Exec(context.Background(), "DELETE FROM refresh_tokens WHERE user_id = $1", userId)
+
+	if err != nil {
+		return 0, fmt.Errorf("RevokeSessions: %w", err)
+	}
+
+	return int(res.RowsAffected()), nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestGetSessions(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+
+	sut := NewSessionService(mock)
+
+	issuedAt := time.Now()
+	expires := issuedAt.Add(time.Hour).Unix()
+
+	mock.ExpectQuery("SELECT user_id, issued_at, expires FROM refresh_tokens").WithArgs(1, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "issued_at", "expires"}).AddRow(1, issuedAt, expires))
+
+	sessions, err := sut.GetSessions(1)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(sessions))
+	}
+
+	if got := sessions[0].ExpiresAt.Unix(); got != expires {
+		t.Fatalf("Expected the session to expire at %d, got %d", expires, got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+
+	sut := NewSessionService(mock)
+
+	mock.ExpectExec("DELETE FROM refresh_tokens").WithArgs(1).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	revoked, err := sut.RevokeSessions(1)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if revoked != 1 {
+		t.Fatalf("Expected 1 revoked session, got %d", revoked)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/auth/model"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/event"
+)
+
+// SuspensionService keeps the replica of the suspensions the account service hands out
+type SuspensionService struct {
+	DB db.PgxIface
+}
+
+func NewSuspensionService(db db.PgxIface) *SuspensionService {
+	return &SuspensionService{
+		DB: db,
+	}
+}
+
+// SuspendUser stores the suspension and drops the refresh token, so the user is logged out once the current JWT expires
+func (s *SuspensionService) SuspendUser(ctx context.Context, data event.UserSuspendedEventData) error {
+	_, err := s.DB.Exec(ctx, `
+		WITH logged_out AS (
+			DELETE FROM refresh_tokens WHERE user_id = $1
+		)
+		INSERT INTO suspensions (user_id, expires_at) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		data.UserID, data.ExpiresAt)
+
+	if err != nil {
+		return fmt.Errorf("SuspendUser: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SuspensionService) UnsuspendUser(ctx context.Context, data event.UserUnsuspendedEventData) error {
+	_, err := s.DB.Exec(ctx, "DELETE FROM suspensions WHERE user_id = $1", data.UserID)
+
+	if err != nil {
+		return fmt.Errorf("UnsuspendUser: %w", err)
+	}
+
+	return nil
+}
+
+// checkSuspended returns UserSuspendedError while the user has a suspension in effect
+func checkSuspended(db db.PgxIface, userId int) error {
+	rows, err := db.Query(context.Background(),
+		"SELECT 1 FROM suspensions WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())", userId)
+
+	if err != nil {
+		return fmt.Errorf("checkSuspended: %w", err)
+	}
+
+	defer rows.Close()
+
+	if rows.Next() {
+		return model.UserSuspendedError
+	}
+
+	return rows.Err()
+}
@@ -8,7 +8,6 @@ import (
 	"nikolamilovic/twitchy/auth/model"
 	db "nikolamilovic/twitchy/common/db"
 	tok "nikolamilovic/twitchy/common/token"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -23,6 +22,8 @@ type ITokenService interface {
 
 type TokenService struct {
 	DB db.PgxIface
+	// Secret signs the JWTs
+	Secret []byte
 }
 
 func (s *TokenService) RefreshToken(refreshTokenString string) (string, string, error) {
@@ -38,7 +39,17 @@ func (s *TokenService) RefreshToken(refreshTokenString string) (string, string,
 		return "", "", fmt.Errorf("RefreshToken: %w", errors.New("Refresh token is not valid"))
 	}
 
-	jwt, refresh, err := generateTokens(refreshToken.UserId)
+	err = checkSuspended(s.DB, refreshToken.UserId)
+	if err != nil {
+		return "", "", fmt.Errorf("RefreshToken: %w", err)
+	}
+
+	scopes, err := s.fetchScopes(refreshToken.UserId)
+	if err != nil {
+		return "", "", fmt.Errorf("RefreshToken: %w", err)
+	}
+
+	jwt, refresh, err := generateTokens(s.Secret, refreshToken.UserId, scopes...)
 
 	if err != nil {
 		return "", "", fmt.Errorf("RefreshToken: %w", err)
@@ -55,7 +66,12 @@ func (s *TokenService) RefreshToken(refreshTokenString string) (string, string,
 
 //Returns JWT, RefreshToken, error
 func (s *TokenService) GenerateNewTokensForUser(userId int) (string, string, error) {
-	jwt, refresh, err := generateTokens(userId)
+	scopes, err := s.fetchScopes(userId)
+	if err != nil {
+		return "", "", fmt.Errorf("GenerateNewTokensForUser: %w", err)
+	}
+
+	jwt, refresh, err := generateTokens(s.Secret, userId, scopes...)
 
 	if err != nil {
 		return "", "", err
@@ -92,10 +108,38 @@ func (s *TokenService) fetchRefreshToken(refreshTokenString string) (model.Refre
 	return refreshToken, nil
 }
 
+// fetchScopes returns the roles of the user as token scopes, every user is the broadcaster of their own channel
+func (s *TokenService) fetchScopes(userId int) ([]string, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT role, channel_id FROM user_roles WHERE user_id = $1", userId)
+
+	if err != nil {
+		return nil, fmt.Errorf("fetchScopes: %w", err)
+	}
+
+	defer rows.Close()
+
+	scopes := []string{tok.ChannelScope(tok.RoleBroadcaster, userId)}
+	for rows.Next() {
+		var role string
+		var channelId int
+		if err := rows.Scan(&role, &channelId); err != nil {
+			return nil, fmt.Errorf("fetchScopes: %w", err)
+		}
+
+		if channelId == 0 {
+			scopes = append(scopes, role)
+		} else {
+			scopes = append(scopes, tok.ChannelScope(role, channelId))
+		}
+	}
+
+	return scopes, nil
+}
+
 func (s *TokenService) saveRefreshToken(token string, userId int) error {
 	expiresAt := time.Now().Add(time.Hour * 24 * 7).Unix()
 	//INSERT if refresh token for given user doesnt exist already, otherwise update
-	res, err := s.DB.Exec(context.Background(), "INSERT INTO refresh_tokens (user_id, token, expires) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET token = $2, expires = $3", userId, token, expiresAt)
+	res, err := s.DB.Exec(context.Background(), "INSERT INTO refresh_tokens (user_id, token, expires) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET token = $2, expires = $3, issued_at = NOW()", userId, token, expiresAt)
 
 	if err != nil {
 		return fmt.Errorf("saveRefreshToken: %w", err)
@@ -107,9 +151,10 @@ func (s *TokenService) saveRefreshToken(token string, userId int) error {
 	}
 }
 
-func generateTokens(userId int) (string, string, error) {
+func generateTokens(secret []byte, userId int, scopes ...string) (string, string, error) {
 	claims := tok.UserClaims{
 		UserId: userId,
+		Scopes: scopes,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Minute * 5).Unix(),
 			Issuer:    "twitchy", //TODO
@@ -119,8 +164,7 @@ func generateTokens(userId int) (string, string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secret := os.Getenv("JWT_SECRET")
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString(secret)
 
 	b := make([]rune, 128)
 	for i := range b {
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"nikolamilovic/twitchy/auth/model"
 	"nikolamilovic/twitchy/common/token"
 	tok "nikolamilovic/twitchy/common/token"
 	"testing"
@@ -14,7 +15,6 @@ import (
 
 func TestExpiredToken(t *testing.T) {
 	secret := []byte("test secret")
-	t.Setenv("JWT_SECRET", string(secret))
 
 	claims := tok.UserClaims{
 		UserId: 1,
@@ -43,9 +43,8 @@ func TestExpiredToken(t *testing.T) {
 
 func TestGenarateNewTokens(t *testing.T) {
 	secret := []byte("test secret")
-	t.Setenv("JWT_SECRET", string(secret))
 
-	jwt, refresh, err := generateTokens(1)
+	jwt, refresh, err := generateTokens(secret, 1)
 	if err != nil {
 		t.Fatalf("Expected error to be nil, got %v", err.Error())
 	}
@@ -78,7 +77,6 @@ func TestGenarateNewTokens(t *testing.T) {
 func TestRefreshToken(t *testing.T) {
 	//Setup
 	secret := []byte("test secret")
-	t.Setenv("JWT_SECRET", string(secret))
 
 	mock, err := pgxmock.NewConn()
 	if err != nil {
@@ -86,7 +84,7 @@ func TestRefreshToken(t *testing.T) {
 	}
 	defer mock.Close(context.Background())
 
-	_, jwt, err := generateTokens(1)
+	_, jwt, err := generateTokens(secret, 1)
 
 	if err != nil {
 		t.Fatalf("Expected error to be nil, got %v", err.Error())
@@ -96,6 +94,10 @@ func TestRefreshToken(t *testing.T) {
 
 	mock.ExpectQuery("SELECT user_id, token, expires FROM refresh_tokens").WithArgs("correct_token").
 		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT 1 FROM suspensions").WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"?column?"}))
+	mock.ExpectQuery("SELECT role, channel_id FROM user_roles").WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"role", "channel_id"}).AddRow("admin", 0).AddRow("moderator", 42))
 	mock.ExpectExec("INSERT INTO refresh_tokens ").WithArgs(1, pgxmock.AnyArg(), pgxmock.AnyArg()).WillReturnResult(
 		pgxmock.NewResult("INSERT", 1),
 	)
@@ -103,7 +105,8 @@ func TestRefreshToken(t *testing.T) {
 		WillReturnRows(pgxmock.NewRows([]string{"user_id", "token", "expires"}))
 
 	s := &TokenService{
-		DB: mock,
+		DB:     mock,
+		Secret: secret,
 	}
 	correctJwt, correctRefresh, err := s.RefreshToken("correct_token")
 	if err != nil {
@@ -119,6 +122,15 @@ func TestRefreshToken(t *testing.T) {
 		t.Fatalf("Expected JWT to be valid, got invalid")
 	}
 
+	claims, err := token.ParseUserClaims(correctJwt, secret)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err.Error())
+	}
+
+	if !claims.HasRole("admin") || !claims.HasChannelRole("moderator", 42) || !claims.HasChannelRole("broadcaster", 1) {
+		t.Fatalf("Expected the roles to be embedded as scopes, got %v", claims.Scopes)
+	}
+
 	if len(correctRefresh) != 128 {
 		t.Errorf("TokenService.RefreshToken() refresh token length not 128, got %d", len(correctRefresh))
 	}
@@ -130,3 +142,29 @@ func TestRefreshToken(t *testing.T) {
 	}
 
 }
+
+func TestRefreshTokenSuspended(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mock.Close(context.Background())
+
+	mock.ExpectQuery("SELECT user_id, token, expires FROM refresh_tokens").WithArgs("token").
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "token", "expires"}).AddRow(1, "token", time.Now().Add(time.Minute*5).Unix()))
+	mock.ExpectQuery("SELECT 1 FROM suspensions").WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	s := &TokenService{
+		DB: mock,
+	}
+
+	_, _, err = s.RefreshToken("token")
+	if !errors.Is(err, model.UserSuspendedError) {
+		t.Fatalf("Expected error to be %v, got %v", model.UserSuspendedError, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,67 @@
+defmodule Chat.AMQP.MessagePublisher do
+  use GenServer
+  use AMQP
+  require Logger
+
+  @exchange "chat_topic"
+  @message_sent_key "chat.message_sent"
+
+  def start_link(_args) do
+    GenServer.start_link(__MODULE__, [], name: __MODULE__)
+  end
+
+  @impl GenServer
+  def init(_opts) do
+    Chat.AMQP.Connection.request_channel(__MODULE__)
+    Logger.log(:info, "Started AMQP message publisher")
+    {:ok, nil}
+  end
+
+  def channel_available(chan) do
+    GenServer.cast(__MODULE__, {:channel_available, chan})
+  end
+
+  # Publishes a chat_message_sent event for the analytics, fire and forget so chatting never waits on the broker
+  def publish_message_sent(message) do
+    GenServer.cast(__MODULE__, {:message_sent, message})
+  end
+
+  @impl GenServer
+  def handle_cast({:channel_available, chan}, _state) do
+    Logger.log(:info, "Received channel, declaring the chat exchange")
+    :ok = Exchange.topic(chan, @exchange, durable: true)
+    {:noreply, chan}
+  end
+
+  @impl GenServer
+  def handle_cast({:message_sent, message}, nil) do
+    Logger.warn("No AMQP channel yet, dropping the event of message #{message.id}")
+    {:noreply, nil}
+  end
+
+  @impl GenServer
+  def handle_cast({:message_sent, message}, chan) do
+    payload =
+      Poison.encode!(%{
+        message_id: message.id,
+        channel: message.channel,
+        user_id: message.user_id,
+        sent_at: message.inserted_at |> DateTime.from_naive!("Etc/UTC") |> DateTime.to_iso8601()
+      })
+
+    event = Poison.encode!(%{type: "chat_message_sent", payload: payload})
+
+    case Basic.publish(chan, @exchange, @message_sent_key, event,
+           content_type: "application/json",
+           persistent: true
+         ) do
+      :ok ->
+        :ok
+
+      {:error, reason} ->
+        Logger.error("Error publishing the event of message #{message.id}: #{inspect(reason)}")
+    end
+
+    {:noreply, chan}
+  end
+end
@@ -11,7 +11,8 @@ defmodule Chat.AMQP.Supervisor do
   def init(:ok) do
     children = [
       Chat.AMQP.Connection,
-      Chat.AMQP.AccountConsumer
+      Chat.AMQP.AccountConsumer,
+      Chat.AMQP.MessagePublisher
     ]
 
     opts = [strategy: :one_for_one, name: __MODULE__]
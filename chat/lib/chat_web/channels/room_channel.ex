@@ -42,6 +42,7 @@ defmodule ChatWeb.RoomChannel do
         case Chat.Messages.create_and_populate_message(message) do
           {:ok, message} ->
             broadcast(socket, "receive_message", message)
+            Chat.AMQP.MessagePublisher.publish_message_sent(message)
 
           {:error, reason} ->
             {:reply, {:error, %{reason: reason}}}
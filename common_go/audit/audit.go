@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/token"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Actions recorded by the services, prefixed with the area they belong to
+const (
+	ActionRegister       = "auth.register"
+	ActionLogin          = "auth.login"
+	ActionLoginFailed    = "auth.login_failed"
+	ActionLoginRefused   = "auth.login_refused"
+	ActionRefreshRefused = "auth.refresh_refused"
+
+	ActionRoleGranted = "role.granted"
+	ActionRoleRevoked = "role.revoked"
+
+	ActionBan       = "moderation.ban"
+	ActionTimeout   = "moderation.timeout"
+	ActionUnban     = "moderation.unban"
+	ActionSuspend   = "moderation.suspend"
+	ActionUnsuspend = "moderation.unsuspend"
+
+	ActionUserSearch     = "admin.user_search"
+	ActionSessionsViewed = "admin.sessions_viewed"
+	ActionForceLogout    = "admin.force_logout"
+)
+
+// Entry is a row of the audit log, ActorID is nil when nobody is logged in, e.g. a failed login
+type Entry struct {
+	ID        int64                  `json:"id"`
+	ActorID   *int                   `json:"actor_id"`
+	Action    string                 `json:"action"`
+	TargetID  *int                   `json:"target_id"`
+	IP        string                 `json:"ip"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Filter narrows the log down, the zero value lists the latest entries. Before pages through older entries by ID.
+type Filter struct {
+	ActorID  *int
+	TargetID *int
+	Action   string
+	Before   int64
+	Limit    int
+}
+
+// ParseFilter reads the filter from the actor, target, action, before and limit query parameters, get returns a parameter
+func ParseFilter(get func(key string) string) (Filter, error) {
+	var filter Filter
+
+	optionalID := func(key string) (*int, error) {
+		value := get(key)
+		if value == "" {
+			return nil, nil
+		}
+
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", key)
+		}
+
+		return &id, nil
+	}
+
+	var err error
+	if filter.ActorID, err = optionalID("actor"); err != nil {
+		return Filter{}, err
+	}
+	if filter.TargetID, err = optionalID("target"); err != nil {
+		return Filter{}, err
+	}
+
+	if before := get("before"); before != "" {
+		if filter.Before, err = strconv.ParseInt(before, 10, 64); err != nil {
+			return Filter{}, errors.New("invalid before")
+		}
+	}
+
+	if limit := get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return Filter{}, errors.New("invalid limit")
+		}
+	}
+
+	filter.Action = get("action")
+
+	return filter, nil
+}
+
+type ILog interface {
+	Record(entry Entry) error
+	List(filter Filter) ([]Entry, error)
+}
+
+// Log writes to the audit_log table, the table rejects updates and deletes so entries can only be appended
+type Log struct {
+	DB db.PgxIface
+}
+
+func NewLog(db db.PgxIface) *Log {
+	return &Log{
+		DB: db,
+	}
+}
+
+func (l *Log) Record(entry Entry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("Record: %w", err)
+	}
+	if entry.Details == nil {
+		details = []byte("{}")
+	}
+
+	_, err = l.DB.Exec(context.Background(),
+		"INSERT INTO audit_log (actor_id, action, target_id, ip, details) VALUES ($1, $2, $3, $4, $5)",
+		entry.ActorID, entry.Action, entry.TargetID, entry.IP, details)
+
+	if err != nil {
+		return fmt.Errorf("Record: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Log) List(filter Filter) ([]Entry, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		where("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		where("target_id = $%d", *filter.TargetID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.Before > 0 {
+		where("id < $%d", filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit < 1 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+	args = append(args, limit)
+
+	query := "SELECT id, actor_id, action, target_id, ip, details, created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := l.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("List: %w", err)
+	}
+
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var details []byte
+		err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetID, &entry.IP, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("List: %w", err)
+		}
+
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, fmt.Errorf("List: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// IP returns the address of the client. Behind a trusted gateway that's the X-Real-IP header it sets to the address
+// it was connected from, otherwise the address of the connection: a client reaching the service directly could set
+// the header to anything. X-Forwarded-For is always ignored for the same reason.
+func IP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); token.GatewayTrusted() && ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// FiberIP is IP for fiber handlers
+func FiberIP(ctx *fiber.Ctx) string {
+	if ip := ctx.Get("X-Real-IP"); token.GatewayTrusted() && ip != "" {
+		return ip
+	}
+
+	return ctx.IP()
+}
+
+// ID is a shorthand for the optional IDs of an entry
+func ID(id int) *int {
+	return &id
+}
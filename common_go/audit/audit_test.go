@@ -0,0 +1,39 @@
+package audit_test
+
+import (
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/token"
+	"testing"
+)
+
+func TestIP(t *testing.T) {
+	tests := []struct {
+		description string
+		trust       bool
+		realIP      string
+		expected    string
+	}{
+		{description: "the connection when the gateway isn't trusted", realIP: "1.1.1.1", expected: "192.0.2.1"},
+		{description: "the header of a trusted gateway", trust: true, realIP: "1.1.1.1", expected: "1.1.1.1"},
+		{description: "the connection when the gateway didn't set the header", trust: true, expected: "192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			token.TrustGateway(test.trust)
+			defer token.TrustGateway(false)
+
+			// httptest requests come from 192.0.2.1:1234
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Forwarded-For", "2.2.2.2")
+			if test.realIP != "" {
+				req.Header.Set("X-Real-IP", test.realIP)
+			}
+
+			if ip := audit.IP(req); ip != test.expected {
+				t.Fatalf("expected %s, instead got: %s", test.expected, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package authz
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/token"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const claimsLocal = "authz_claims"
+
+// FiberChannelResolver returns the channel the request is about, usually read from the route params
+type FiberChannelResolver func(ctx *fiber.Ctx) (int, error)
+
+// ChannelParam resolves the channel from an integer route param
+func ChannelParam(name string) FiberChannelResolver {
+	return func(ctx *fiber.Ctx) (int, error) {
+		return ctx.ParamsInt(name)
+	}
+}
+
+// Fiber is Middleware for fiber handlers, the claims of the accepted requests are available through FiberClaims
+func Fiber(secret []byte, policy Policy, channel FiberChannelResolver) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, err := token.FromHeaders(FiberHeader(ctx), secret)
+		if err != nil {
+			return fiber.NewError(http.StatusUnauthorized, "invalid token")
+		}
+
+		var channelID int
+		if channel != nil {
+			channelID, err = channel(ctx)
+			if err != nil {
+				return fiber.NewError(http.StatusBadRequest, "invalid channel")
+			}
+		}
+
+		if !policy(claims, channelID) {
+			return fiber.NewError(http.StatusForbidden, "forbidden")
+		}
+
+		ctx.Locals(claimsLocal, claims)
+
+		return ctx.Next()
+	}
+}
+
+// FiberHeader reads the request headers for token.FromHeaders
+func FiberHeader(ctx *fiber.Ctx) func(key string) string {
+	return func(key string) string {
+		return ctx.Get(key)
+	}
+}
+
+func FiberClaims(ctx *fiber.Ctx) (*token.UserClaims, bool) {
+	claims, ok := ctx.Locals(claimsLocal).(*token.UserClaims)
+	return claims, ok
+}
@@ -0,0 +1,47 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"nikolamilovic/twitchy/common/token"
+)
+
+type contextKey struct{}
+
+// ChannelResolver returns the channel the request is about, usually read from the URL
+type ChannelResolver func(r *http.Request) (int, error)
+
+// Middleware rejects requests without a valid token with 401 and those the policy doesn't let through with 403.
+// The claims of the accepted requests are available through ClaimsFromContext. A nil resolver means the request isn't about a channel.
+func Middleware(secret []byte, policy Policy, channel ChannelResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := token.FromHeaders(r.Header.Get, secret)
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			var channelID int
+			if channel != nil {
+				channelID, err = channel(r)
+				if err != nil {
+					http.Error(w, "invalid channel", http.StatusBadRequest)
+					return
+				}
+			}
+
+			if !policy(claims, channelID) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, claims)))
+		})
+	}
+}
+
+func ClaimsFromContext(ctx context.Context) (*token.UserClaims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*token.UserClaims)
+	return claims, ok
+}
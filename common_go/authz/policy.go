@@ -0,0 +1,62 @@
+package authz
+
+import "nikolamilovic/twitchy/common/token"
+
+// Policy decides whether the caller may go ahead, channelID is the channel the request is about or 0 if there is none.
+// Policies only look at the token, a role revoked after the token was issued keeps working until the token expires.
+type Policy func(claims *token.UserClaims, channelID int) bool
+
+// PlatformRole lets through users holding the platform role
+func PlatformRole(role string) Policy {
+	return func(claims *token.UserClaims, channelID int) bool {
+		return claims.HasRole(role)
+	}
+}
+
+// ChannelRole lets through users holding the role in the channel of the request
+func ChannelRole(role string) Policy {
+	return func(claims *token.UserClaims, channelID int) bool {
+		return channelID != 0 && claims.HasChannelRole(role, channelID)
+	}
+}
+
+// Any lets the request through if one of the policies does
+func Any(policies ...Policy) Policy {
+	return func(claims *token.UserClaims, channelID int) bool {
+		for _, p := range policies {
+			if p(claims, channelID) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+var (
+	Authenticated Policy = func(claims *token.UserClaims, channelID int) bool { return true }
+
+	Admin = PlatformRole(token.RoleAdmin)
+	Staff = Any(Admin, PlatformRole(token.RoleStaff))
+
+	// Admins can act as the broadcaster of any channel, staff can moderate any channel
+	BroadcasterOf = Any(Admin, ChannelRole(token.RoleBroadcaster))
+	ModeratorOf   = Any(Staff, ChannelRole(token.RoleBroadcaster), ChannelRole(token.RoleModerator))
+	EditorOf      = Any(BroadcasterOf, ChannelRole(token.RoleEditor))
+	VIPOf         = Any(ModeratorOf, ChannelRole(token.RoleVIP))
+)
+
+var named = map[string]Policy{
+	token.RoleAdmin:       Admin,
+	token.RoleStaff:       Staff,
+	token.RoleBroadcaster: BroadcasterOf,
+	token.RoleModerator:   ModeratorOf,
+	token.RoleEditor:      EditorOf,
+	token.RoleVIP:         VIPOf,
+}
+
+// Lookup returns the policy checking for the role, so "moderator" also lets through the broadcaster and staff
+func Lookup(role string) (Policy, bool) {
+	p, ok := named[role]
+	return p, ok
+}
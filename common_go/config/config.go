@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the optional YAML file read before the environment
+const FileEnv = "CONFIG_FILE"
+
+// Error lists every problem with the config, so they can all be fixed at once
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid config:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validator is implemented by the configs with rules across fields, it's called once the fields are loaded
+type Validator interface {
+	Validate() error
+}
+
+// Load fills cfg, a pointer to a struct, from the default tags of its fields, then the YAML file at CONFIG_FILE and
+// then the variables in the env tags. A variable with the _FILE suffix names a file to read the value from instead,
+// for secrets mounted by Docker. Fields tagged required must end up set.
+//
+//	type Config struct {
+//		Port      int    `env:"PORT" yaml:"port" default:"80"`
+//		JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+//	}
+func Load(cfg interface{}) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Load: expected a pointer to a struct, got %T", cfg)
+	}
+
+	l := &loader{}
+
+	l.fields(v.Elem(), l.setDefault)
+
+	if path := os.Getenv(FileEnv); path != "" {
+		if err := readFile(path, cfg); err != nil {
+			l.problems = append(l.problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	l.fields(v.Elem(), l.setEnv)
+	l.fields(v.Elem(), l.checkRequired)
+	l.validate(v.Elem())
+
+	if len(l.problems) > 0 {
+		return &Error{Problems: l.problems}
+	}
+
+	return nil
+}
+
+func readFile(path string, cfg interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, cfg)
+}
+
+type loader struct {
+	problems []string
+}
+
+// fields calls fn with every field, the fields of nested structs included
+func (l *loader) fields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if value.Kind() == reflect.Struct {
+			l.fields(value, fn)
+			continue
+		}
+
+		fn(field, value)
+	}
+}
+
+func (l *loader) setDefault(field reflect.StructField, value reflect.Value) {
+	raw, ok := field.Tag.Lookup("default")
+	if !ok {
+		return
+	}
+
+	if err := set(value, raw); err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("default of %s: %v", name(field), err))
+	}
+}
+
+func (l *loader) setEnv(field reflect.StructField, value reflect.Value) {
+	env := field.Tag.Get("env")
+	if env == "" {
+		return
+	}
+
+	raw := os.Getenv(env)
+	if path := os.Getenv(env + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.problems = append(l.problems, fmt.Sprintf("%s_FILE: %v", env, err))
+			return
+		}
+		raw = strings.TrimRight(string(data), "\r\n")
+	}
+
+	// Empty variables count as unset, compose passes them for every name in the file
+	if raw == "" {
+		return
+	}
+
+	if err := set(value, raw); err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s: %v", env, err))
+	}
+}
+
+func (l *loader) checkRequired(field reflect.StructField, value reflect.Value) {
+	if field.Tag.Get("required") == "true" && value.IsZero() {
+		l.problems = append(l.problems, fmt.Sprintf("%s is required", name(field)))
+	}
+}
+
+// validate runs the rules of cfg and its nested structs
+func (l *loader) validate(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() && v.Field(i).Kind() == reflect.Struct {
+			l.validate(v.Field(i))
+		}
+	}
+
+	if validator, ok := v.Addr().Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			l.problems = append(l.problems, err.Error())
+		}
+	}
+}
+
+// name is how a field is called in the problems, by its variable if it has one
+func name(field reflect.StructField) string {
+	if env := field.Tag.Get("env"); env != "" {
+		return env
+	}
+	if key := strings.Split(field.Tag.Get("yaml"), ",")[0]; key != "" {
+		return key
+	}
+	return field.Name
+}
+
+var errUnsupported = errors.New("unsupported type")
+
+func set(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration, got %q", raw)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", raw)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", raw)
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a positive integer, got %q", raw)
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", raw)
+		}
+		value.SetFloat(n)
+	default:
+		return fmt.Errorf("%w %s", errUnsupported, value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name    string        `env:"NAME" yaml:"name" required:"true"`
+	Port    int           `env:"PORT" yaml:"port" default:"80"`
+	Debug   bool          `env:"DEBUG" yaml:"debug"`
+	Timeout time.Duration `env:"TIMEOUT" yaml:"timeout" default:"5s"`
+	Backend testBackend   `yaml:"backend"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Port > 65535 {
+		return fmt.Errorf("PORT must be below 65536, got %d", c.Port)
+	}
+	return nil
+}
+
+// testBackend is validated on its own, like the nested configs shared between the services
+type testBackend struct {
+	Host string `yaml:"host" required:"true"`
+	Port int    `env:"BACKEND_PORT" yaml:"port" default:"5432"`
+}
+
+func (b *testBackend) Validate() error {
+	if b.Port <= 0 {
+		return fmt.Errorf("BACKEND_PORT must be positive, got %d", b.Port)
+	}
+	return nil
+}
+
+// testEnv is every variable the test config reads, they're all reset so the environment of the test run can't leak in
+var testEnv = []string{FileEnv, "NAME", "NAME_FILE", "PORT", "PORT_FILE", "DEBUG", "DEBUG_FILE", "TIMEOUT", "TIMEOUT_FILE", "BACKEND_PORT", "BACKEND_PORT_FILE"}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	secret := filepath.Join(dir, "name")
+	if err := os.WriteFile(secret, []byte("from file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "config.yaml")
+	backend := "backend:\n  host: db\n"
+
+	tests := []struct {
+		description string
+		file        string
+		env         map[string]string
+		expected    testConfig
+		// problems are expected to start with these, in order
+		problems []string
+	}{
+		{
+			description: "defaults",
+			file:        backend,
+			env:         map[string]string{"NAME": "app"},
+			expected:    testConfig{Name: "app", Port: 80, Timeout: 5 * time.Second, Backend: testBackend{Host: "db", Port: 5432}},
+		},
+		{
+			description: "file over the defaults",
+			file:        backend + "name: app\nport: 8080\ndebug: true\ntimeout: 1m\n",
+			expected:    testConfig{Name: "app", Port: 8080, Debug: true, Timeout: time.Minute, Backend: testBackend{Host: "db", Port: 5432}},
+		},
+		{
+			description: "environment over the file",
+			file:        backend + "name: app\nport: 8080\n",
+			env:         map[string]string{"PORT": "9090", "TIMEOUT": "10s", "BACKEND_PORT": "6432"},
+			expected:    testConfig{Name: "app", Port: 9090, Timeout: 10 * time.Second, Backend: testBackend{Host: "db", Port: 6432}},
+		},
+		{
+			description: "empty variables are unset",
+			file:        backend + "name: app\nport: 8080\n",
+			env:         map[string]string{"PORT": ""},
+			expected:    testConfig{Name: "app", Port: 8080, Timeout: 5 * time.Second, Backend: testBackend{Host: "db", Port: 5432}},
+		},
+		{
+			description: "secret from a file",
+			file:        backend,
+			env:         map[string]string{"NAME": "from env", "NAME_FILE": secret},
+			expected:    testConfig{Name: "from file", Port: 80, Timeout: 5 * time.Second, Backend: testBackend{Host: "db", Port: 5432}},
+		},
+		{
+			description: "missing secret file",
+			file:        backend,
+			env:         map[string]string{"NAME_FILE": filepath.Join(dir, "missing")},
+			problems:    []string{"NAME_FILE: open " + filepath.Join(dir, "missing"), "NAME is required"},
+		},
+		{
+			description: "required fields",
+			problems:    []string{"NAME is required", "host is required"},
+		},
+		{
+			description: "invalid values",
+			file:        backend,
+			env:         map[string]string{"NAME": "app", "PORT": "eighty", "DEBUG": "maybe", "TIMEOUT": "soon"},
+			problems: []string{
+				`PORT: expected an integer, got "eighty"`,
+				`DEBUG: expected true or false, got "maybe"`,
+				`TIMEOUT: expected a duration, got "soon"`,
+			},
+		},
+		{
+			description: "validators",
+			file:        backend + "name: app\nport: 70000\n",
+			env:         map[string]string{"BACKEND_PORT": "-1"},
+			problems:    []string{"BACKEND_PORT must be positive, got -1", "PORT must be below 65536, got 70000"},
+		},
+		{
+			description: "all problems at once",
+			file:        "port: [",
+			env:         map[string]string{"PORT": "eighty", "BACKEND_PORT": "0"},
+			problems: []string{
+				file + ": yaml:",
+				`PORT: expected an integer, got "eighty"`,
+				"NAME is required",
+				"host is required",
+				"BACKEND_PORT must be positive, got 0",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			for _, env := range testEnv {
+				t.Setenv(env, "")
+			}
+
+			if test.file != "" {
+				if err := os.WriteFile(file, []byte(test.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv(FileEnv, file)
+			}
+
+			for env, value := range test.env {
+				t.Setenv(env, value)
+			}
+
+			var cfg testConfig
+			err := Load(&cfg)
+
+			if test.problems == nil {
+				if err != nil {
+					t.Fatalf("expected no error, instead got: %v", err)
+				}
+				if !reflect.DeepEqual(cfg, test.expected) {
+					t.Fatalf("expected %+v, instead got: %+v", test.expected, cfg)
+				}
+				return
+			}
+
+			var problems *Error
+			if !errors.As(err, &problems) {
+				t.Fatalf("expected a config error, instead got: %v", err)
+			}
+
+			if len(problems.Problems) != len(test.problems) {
+				t.Fatalf("expected the problems %q, instead got: %q", test.problems, problems.Problems)
+			}
+			for i, problem := range problems.Problems {
+				if !strings.HasPrefix(problem, test.problems[i]) {
+					t.Fatalf("expected the problems %q, instead got: %q", test.problems, problems.Problems)
+				}
+			}
+
+			// The error lists every problem, so they can all be fixed at once
+			for _, problem := range problems.Problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Fatalf("expected the error to list %q, instead got: %v", problem, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadNotAStruct(t *testing.T) {
+	var port int
+	err := Load(&port)
+
+	var problems *Error
+	if err == nil || errors.As(err, &problems) {
+		t.Fatalf("expected Load to refuse a pointer to an int, instead got: %v", err)
+	}
+}
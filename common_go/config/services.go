@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Postgres is the database of a service, migrated from MigrationPath on startup
+type Postgres struct {
+	User          string `env:"POSTGRES_USER" yaml:"user" required:"true"`
+	Password      string `env:"POSTGRES_PASSWORD" yaml:"password" required:"true"`
+	Host          string `env:"POSTGRES_HOST" yaml:"host" required:"true"`
+	Port          int    `env:"POSTGRES_PORT" yaml:"port" default:"5432"`
+	DB            string `env:"POSTGRES_DB" yaml:"db" required:"true"`
+	MigrationPath string `env:"MIGRATION_PATH" yaml:"migration_path" required:"true"`
+}
+
+func (p Postgres) URL() string {
+	return fmt.Sprintf("postgres://%s@%s:%d/%s?sslmode=disable",
+		url.UserPassword(p.User, p.Password).String(), p.Host, p.Port, p.DB)
+}
+
+// RabbitMQ is the broker the services exchange their events through
+type RabbitMQ struct {
+	User     string `env:"RABBITMQ_USER" yaml:"user" required:"true"`
+	Password string `env:"RABBITMQ_PASSWORD" yaml:"password" required:"true"`
+	Host     string `env:"RABBITMQ_HOST" yaml:"host" required:"true"`
+	Port     int    `env:"RABBITMQ_PORT" yaml:"port" default:"5672"`
+}
+
+func (r RabbitMQ) URL() string {
+	return fmt.Sprintf("amqp://%s@%s:%d/", url.UserPassword(r.User, r.Password).String(), r.Host, r.Port)
+}
@@ -5,4 +5,45 @@ var (
 	AccountsQueue     = "accounts_queue"
 	AccountsExchange  = "accounts_topic"
 	AccountCreatedKey = "account.created"
+	AccountUpdatedKey = "account.updated"
+	UserFollowedKey   = "user.followed"
+	UserUnfollowedKey = "user.unfollowed"
+
+	RoleGrantedKey = "role.granted"
+	RoleRevokedKey = "role.revoked"
+
+	UserBannedKey      = "user.banned"
+	UserTimedOutKey    = "user.timed_out"
+	UserUnbannedKey    = "user.unbanned"
+	UserSuspendedKey   = "user.suspended"
+	UserUnsuspendedKey = "user.unsuspended"
+	UserBlockedKey     = "user.blocked"
+	UserUnblockedKey   = "user.unblocked"
+
+	SubscriptionStartedKey = "subscription.started"
+	SubscriptionEndedKey   = "subscription.ended"
+
+	StreamsQueue           = "streams_queue"
+	StreamsExchange        = "streams_topic"
+	StreamStartedKey       = "stream.started"
+	StreamEndedKey         = "stream.ended"
+	StreamStatusChangedKey = "stream.status_changed"
+	StreamInfoUpdatedKey   = "stream.info_updated"
+	ViewerCountUpdatedKey  = "stream.viewer_count_updated"
+	ClipCreatedKey         = "clip.created"
+
+	NotificationsQueue     = "notifications_queue"
+	NotificationsExchange  = "notifications_topic"
+	NotificationCreatedKey = "notification.created"
+
+	SearchQueue = "search_queue"
+
+	ChatExchange       = "chat_topic"
+	ChatMessageSentKey = "chat.message_sent"
+
+	AnalyticsQueue = "analytics_queue"
+
+	WebhooksQueue = "webhooks_queue"
+
+	VideoQueue = "video_queue"
 )
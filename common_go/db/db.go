@@ -6,6 +6,9 @@ import (
 	"os"
 	"time"
 
+	"nikolamilovic/twitchy/common/config"
+	"nikolamilovic/twitchy/common/metrics"
+
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -17,20 +20,10 @@ type PgxIface interface {
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
 }
 
-func InitDb(ctx context.Context, logger *zap.SugaredLogger) (PgxIface, func() error, error) {
-	dbUrl := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_DB"))
+func InitDb(ctx context.Context, cfg config.Postgres, logger *zap.SugaredLogger) (PgxIface, func() error, error) {
+	dbUrl := cfg.URL()
 
-	// fmt.Println(os.Getenv("POSTGRES_USER"))
-	// fmt.Println(os.Getenv("POSTGRES_PASSWORD"))
-	// fmt.Println(os.Getenv("POSTGRES_HOST"))
-	// fmt.Println(os.Getenv("POSTGRES_PORT"))
-	// fmt.Println(os.Getenv("POSTGRES_DB"))
-	fmt.Println(dbUrl)
+	logger.Infof("connecting to %s:%d/%s", cfg.Host, cfg.Port, cfg.DB)
 
 	conn, err := pgxpool.Connect(context.Background(), dbUrl)
 
@@ -55,7 +48,12 @@ func InitDb(ctx context.Context, logger *zap.SugaredLogger) (PgxIface, func() er
 		return nil, nil, err
 	}
 
-	err = MigrateDb(dbUrl, logger)
+	err = metrics.RegisterPool(conn)
+	if err != nil {
+		logger.Errorf("failed to register the pool metrics: %v", err)
+	}
+
+	err = MigrateDb(dbUrl, cfg.MigrationPath, logger)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to migrate the: %v\n", err)
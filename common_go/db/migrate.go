@@ -1,19 +1,21 @@
 package db
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"go.uber.org/zap"
 )
 
-func MigrateDb(dburl string, l *zap.SugaredLogger) error {
+func MigrateDb(dburl, path string, l *zap.SugaredLogger) error {
 	m, err := migrate.New(
-		"file:///"+os.Getenv("MIGRATION_PATH"),
+		"file:///"+path,
 		dburl,
 	)
 	if err != nil {
@@ -28,3 +30,28 @@ func MigrateDb(dburl string, l *zap.SugaredLogger) error {
 	l.Info("Migrated DB")
 	return err
 }
+
+// LatestVersion is the version of the newest migration in path, the one MigrateDb migrates to
+func LatestVersion(path string) (uint, error) {
+	src, err := source.Open("file:///" + path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
@@ -3,6 +3,7 @@ package event
 const (
 	AccountCreatedType    = "account_created"
 	AccountCreatedAckType = "account_created_ack"
+	AccountUpdatedType    = "account_updated"
 )
 
 type AccountCreatedEventData struct {
@@ -15,3 +16,11 @@ type AccountCreatedAckData struct {
 	ID      int64  `json:"id"`
 	Service string `json:"service"`
 }
+
+// AccountUpdatedEventData carries the public profile of the user after the change
+type AccountUpdatedEventData struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+}
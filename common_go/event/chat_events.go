@@ -0,0 +1,15 @@
+package event
+
+import "time"
+
+const (
+	ChatMessageSentType = "chat_message_sent"
+)
+
+// ChatMessageSentEventData is published by the chat service for every message, Channel is the name of the channel
+type ChatMessageSentEventData struct {
+	MessageID int       `json:"message_id"`
+	Channel   string    `json:"channel"`
+	UserID    int       `json:"user_id"`
+	SentAt    time.Time `json:"sent_at"`
+}
@@ -0,0 +1,18 @@
+package event
+
+import "time"
+
+const (
+	ClipCreatedType = "clip_created"
+)
+
+type ClipCreatedEventData struct {
+	ID        string    `json:"id"`
+	ChannelID int       `json:"channel_id"`
+	Channel   string    `json:"channel"`
+	SessionID string    `json:"session_id"`
+	CreatorID int       `json:"creator_id"`
+	Title     string    `json:"title"`
+	Duration  float64   `json:"duration"`
+	CreatedAt time.Time `json:"created_at"`
+}
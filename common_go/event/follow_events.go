@@ -0,0 +1,19 @@
+package event
+
+import "time"
+
+const (
+	UserFollowedType   = "user_followed"
+	UserUnfollowedType = "user_unfollowed"
+)
+
+type UserFollowedEventData struct {
+	FollowerID int       `json:"follower_id"`
+	FollowedID int       `json:"followed_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type UserUnfollowedEventData struct {
+	FollowerID int `json:"follower_id"`
+	FollowedID int `json:"followed_id"`
+}
@@ -0,0 +1,59 @@
+package event
+
+import "time"
+
+const (
+	UserBannedType      = "user_banned"
+	UserTimedOutType    = "user_timed_out"
+	UserUnbannedType    = "user_unbanned"
+	UserSuspendedType   = "user_suspended"
+	UserUnsuspendedType = "user_unsuspended"
+	UserBlockedType     = "user_blocked"
+	UserUnblockedType   = "user_unblocked"
+)
+
+type UserBannedEventData struct {
+	ChannelID   int       `json:"channel_id"`
+	UserID      int       `json:"user_id"`
+	ModeratorID int       `json:"moderator_id"`
+	Reason      string    `json:"reason"`
+	BannedAt    time.Time `json:"banned_at"`
+}
+
+type UserTimedOutEventData struct {
+	ChannelID   int       `json:"channel_id"`
+	UserID      int       `json:"user_id"`
+	ModeratorID int       `json:"moderator_id"`
+	Reason      string    `json:"reason"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// UserUnbannedEventData lifts a ban or a timeout before it expires
+type UserUnbannedEventData struct {
+	ChannelID   int `json:"channel_id"`
+	UserID      int `json:"user_id"`
+	ModeratorID int `json:"moderator_id"`
+}
+
+type UserSuspendedEventData struct {
+	UserID  int    `json:"user_id"`
+	ActorID int    `json:"actor_id"`
+	Reason  string `json:"reason"`
+	// ExpiresAt is nil for suspensions that last until they're lifted
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type UserUnsuspendedEventData struct {
+	UserID  int `json:"user_id"`
+	ActorID int `json:"actor_id"`
+}
+
+type UserBlockedEventData struct {
+	BlockerID int `json:"blocker_id"`
+	BlockedID int `json:"blocked_id"`
+}
+
+type UserUnblockedEventData struct {
+	BlockerID int `json:"blocker_id"`
+	BlockedID int `json:"blocked_id"`
+}
@@ -0,0 +1,17 @@
+package event
+
+import "time"
+
+const (
+	NotificationCreatedType = "notification_created"
+)
+
+type NotificationCreatedEventData struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id"`
+	Type      string    `json:"type"`
+	Channel   string    `json:"channel"`
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package event
+
+const (
+	RoleGrantedType = "role_granted"
+	RoleRevokedType = "role_revoked"
+)
+
+// RoleGrantedEventData carries a platform role when ChannelID is 0 and a channel role otherwise
+type RoleGrantedEventData struct {
+	UserID    int    `json:"user_id"`
+	Role      string `json:"role"`
+	ChannelID int    `json:"channel_id"`
+	GrantedBy int    `json:"granted_by"`
+}
+
+type RoleRevokedEventData struct {
+	UserID    int    `json:"user_id"`
+	Role      string `json:"role"`
+	ChannelID int    `json:"channel_id"`
+	RevokedBy int    `json:"revoked_by"`
+}
@@ -0,0 +1,68 @@
+package event
+
+import "time"
+
+const (
+	StreamStartedType       = "stream_started"
+	StreamEndedType         = "stream_ended"
+	StreamStatusChangedType = "stream_status_changed"
+	StreamInfoUpdatedType   = "stream_info_updated"
+	ViewerCountUpdatedType  = "viewer_count_updated"
+)
+
+// Stream session statuses, a session goes offline -> live -> ended
+const (
+	StreamStatusOffline = "offline"
+	StreamStatusLive    = "live"
+	StreamStatusEnded   = "ended"
+)
+
+type StreamStartedEventData struct {
+	SessionID string    `json:"session_id"`
+	ChannelID int       `json:"channel_id"`
+	Channel   string    `json:"channel"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type StreamEndedEventData struct {
+	SessionID string    `json:"session_id"`
+	ChannelID int       `json:"channel_id"`
+	Channel   string    `json:"channel"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+type StreamStatusChangedEventData struct {
+	SessionID   string     `json:"session_id"`
+	ChannelID   int        `json:"channel_id"`
+	Channel     string     `json:"channel"`
+	Status      string     `json:"status"`
+	Title       string     `json:"title"`
+	Category    string     `json:"category"`
+	Tags        []string   `json:"tags,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	PeakViewers int        `json:"peak_viewers"`
+}
+
+// StreamInfoUpdatedEventData is what the channel streams, it applies to the live session and the ones after it
+type StreamInfoUpdatedEventData struct {
+	ChannelID int       `json:"channel_id"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ViewerCountUpdatedEventData is the audience of a live session, published every few seconds while it changes.
+// UniqueViewers is an estimate.
+type ViewerCountUpdatedEventData struct {
+	SessionID     string    `json:"session_id"`
+	ChannelID     int       `json:"channel_id"`
+	Channel       string    `json:"channel"`
+	Viewers       int       `json:"viewers"`
+	UniqueViewers int       `json:"unique_viewers"`
+	PeakViewers   int       `json:"peak_viewers"`
+	CountedAt     time.Time `json:"counted_at"`
+}
@@ -0,0 +1,24 @@
+package event
+
+import "time"
+
+const (
+	SubscriptionStartedType = "subscription_started"
+	SubscriptionEndedType   = "subscription_ended"
+)
+
+type SubscriptionStartedEventData struct {
+	SubscriptionID int       `json:"subscription_id"`
+	SubscriberID   int       `json:"subscriber_id"`
+	ChannelID      int       `json:"channel_id"`
+	Tier           int       `json:"tier"`
+	PeriodEnd      time.Time `json:"period_end"`
+}
+
+type SubscriptionEndedEventData struct {
+	SubscriptionID int       `json:"subscription_id"`
+	SubscriberID   int       `json:"subscriber_id"`
+	ChannelID      int       `json:"channel_id"`
+	Tier           int       `json:"tier"`
+	EndedAt        time.Time `json:"ended_at"`
+}
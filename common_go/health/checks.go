@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/rabbitmq"
+)
+
+// Postgres checks the database answers a query
+func Postgres(conn db.PgxIface) Check {
+	return func(ctx context.Context) error {
+		rows, err := conn.Query(ctx, "SELECT 1")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+		}
+		return rows.Err()
+	}
+}
+
+// Connection is a connection to the broker, like a rabbitmq.ClientConnection
+type Connection interface {
+	IsConnected() bool
+}
+
+// Broker checks the connection to rabbitmq is up, it's down while reconnecting
+func Broker(connection Connection) Check {
+	return func(ctx context.Context) error {
+		if !connection.IsConnected() {
+			return rabbitmq.ErrDisconnected
+		}
+		return nil
+	}
+}
+
+// Migrations checks the database is migrated to at least version, and that no migration failed halfway
+func Migrations(conn db.PgxIface, version uint) Check {
+	return func(ctx context.Context) error {
+		rows, err := conn.Query(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			return errors.New("not migrated")
+		}
+
+		var (
+			current int64
+			dirty   bool
+		)
+		if err := rows.Scan(&current, &dirty); err != nil {
+			return err
+		}
+
+		if dirty {
+			return fmt.Errorf("migration %d is dirty", current)
+		}
+		if current < int64(version) {
+			return fmt.Errorf("at migration %d, expected %d", current, version)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,19 @@
+package health
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// FiberLive answers /healthz for fiber apps
+func (h *Health) FiberLive(ctx *fiber.Ctx) error {
+	return ctx.JSON(Report{Status: "ok"})
+}
+
+// FiberReadiness answers /readyz for fiber apps
+func (h *Health) FiberReadiness(ctx *fiber.Ctx) error {
+	report, ready := h.Ready(ctx.UserContext())
+	if !ready {
+		ctx.Status(fiber.StatusServiceUnavailable)
+	}
+	return ctx.JSON(report)
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds every check, a probe shouldn't hang on a dependency that doesn't answer
+const checkTimeout = 2 * time.Second
+
+var ErrDraining = errors.New("shutting down")
+
+// Check reports whether a dependency can be used, nil if it can
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Health answers the liveness and readiness probes. The service is live as long as it serves, it's ready while every
+// check passes and it isn't draining.
+type Health struct {
+	checks   []namedCheck
+	draining int32
+}
+
+// Report is the body of the probes, the error of every failing check by its name
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func New() *Health {
+	return &Health{}
+}
+
+// Add makes readiness depend on check
+func (h *Health) Add(name string, check Check) *Health {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+	return h
+}
+
+// Drain fails the readiness from now on so the traffic moves to other instances before the server shuts down
+func (h *Health) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// Ready runs the checks, it's not ready if any of them failed
+func (h *Health) Ready(ctx context.Context) (Report, bool) {
+	report := Report{Status: "ok", Checks: map[string]string{}}
+
+	if atomic.LoadInt32(&h.draining) == 1 {
+		report.Status = ErrDraining.Error()
+		return report, false
+	}
+
+	ready := true
+	for _, c := range h.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.check(checkCtx)
+		cancel()
+
+		if err != nil {
+			ready = false
+			report.Checks[c.name] = err.Error()
+			continue
+		}
+		report.Checks[c.name] = "ok"
+	}
+
+	if !ready {
+		report.Status = "unavailable"
+	}
+
+	return report, ready
+}
+
+// Live answers /healthz
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, Report{Status: "ok"}, true)
+}
+
+// Readiness answers /readyz
+func (h *Health) Readiness(w http.ResponseWriter, r *http.Request) {
+	report, ready := h.Ready(r.Context())
+	writeReport(w, report, ready)
+}
+
+func writeReport(w http.ResponseWriter, report Report, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
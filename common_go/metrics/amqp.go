@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Reasons a publish attempt failed
+const (
+	PublishDisconnected = "disconnected"
+	PublishError        = "error"
+	PublishNack         = "nack"
+	PublishTimeout      = "timeout"
+)
+
+// Outcomes of consuming a message, unsettled when the handler neither acked nor rejected it
+const (
+	outcomeAck       = "ack"
+	outcomeNack      = "nack"
+	outcomeReject    = "reject"
+	outcomeUnsettled = "unsettled"
+)
+
+var (
+	publishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rabbitmq_publish_confirm_duration_seconds",
+		Help:    "Time from publishing a message until the broker confirmed it, resends included.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"exchange", "routing_key"})
+
+	publishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_publish_failures_total",
+		Help: "Publish attempts that weren't confirmed by the broker.",
+	}, []string{"exchange", "routing_key", "reason"})
+
+	consumeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rabbitmq_consume_duration_seconds",
+		Help:    "Time spent processing a message by queue and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"queue", "outcome"})
+
+	consumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_consumed_messages_total",
+		Help: "Messages processed by queue and outcome.",
+	}, []string{"queue", "outcome"})
+
+	reconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rabbitmq_reconnects_total",
+		Help: "Connections established to rabbitmq after the connection was lost.",
+	})
+
+	connectFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rabbitmq_connect_failures_total",
+		Help: "Failed attempts to connect to rabbitmq.",
+	})
+)
+
+// Publish measures publishing a message until the broker confirms it
+type Publish struct {
+	exchange string
+	key      string
+	start    time.Time
+}
+
+// StartPublish starts measuring the publish of a message to exchange with key
+func StartPublish(exchange, key string) *Publish {
+	return &Publish{exchange: exchange, key: key, start: time.Now()}
+}
+
+// Confirmed observes the confirm latency of the message
+func (p *Publish) Confirmed() {
+	publishDuration.WithLabelValues(p.exchange, p.key).Observe(time.Since(p.start).Seconds())
+}
+
+// Failed counts a failed attempt, the message may still be resent
+func (p *Publish) Failed(reason string) {
+	publishFailures.WithLabelValues(p.exchange, p.key, reason).Inc()
+}
+
+// Consume hands the message to handle and records how long it took and whether it was acked, nacked or rejected
+func Consume(queue string, msg amqp.Delivery, handle func(amqp.Delivery)) {
+	ack := &outcomeAcknowledger{acknowledger: msg.Acknowledger, outcome: outcomeUnsettled}
+	msg.Acknowledger = ack
+
+	start := time.Now()
+	handle(msg)
+
+	consumeDuration.WithLabelValues(queue, ack.outcome).Observe(time.Since(start).Seconds())
+	consumed.WithLabelValues(queue, ack.outcome).Inc()
+}
+
+// Reconnected counts a connection established after the previous one was lost
+func Reconnected() {
+	reconnects.Inc()
+}
+
+// ConnectFailed counts a failed attempt to connect
+func ConnectFailed() {
+	connectFailures.Inc()
+}
+
+var errNoAcknowledger = errors.New("delivery has no acknowledger")
+
+// outcomeAcknowledger remembers how the handler settled the message
+type outcomeAcknowledger struct {
+	acknowledger amqp.Acknowledger
+	outcome      string
+}
+
+func (a *outcomeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.outcome = outcomeAck
+	if a.acknowledger == nil {
+		return errNoAcknowledger
+	}
+	return a.acknowledger.Ack(tag, multiple)
+}
+
+func (a *outcomeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.outcome = outcomeNack
+	if a.acknowledger == nil {
+		return errNoAcknowledger
+	}
+	return a.acknowledger.Nack(tag, multiple, requeue)
+}
+
+func (a *outcomeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.outcome = outcomeReject
+	if a.acknowledger == nil {
+		return errNoAcknowledger
+	}
+	return a.acknowledger.Reject(tag, requeue)
+}
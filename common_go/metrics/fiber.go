@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Fiber is Middleware for fiber apps
+func Fiber() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		self := ctx.Route()
+
+		err := ctx.Next()
+
+		// The error handler writes the response after the middleware, the status is taken from the error
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			status = http.StatusInternalServerError
+
+			var e *fiber.Error
+			if errors.As(err, &e) {
+				status = e.Code
+			}
+		}
+
+		// The route stays the one of the middleware when no handler matched the request
+		route := ctx.Route().Path
+		if ctx.Route() == self {
+			route = unmatchedRoute
+		}
+
+		observeRequest(ctx.Method(), route, status, start)
+
+		return err
+	}
+}
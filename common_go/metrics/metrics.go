@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// The metrics are registered with the default registry, next to the go runtime and process metrics
+
+// Handler serves the metrics for prometheus to scrape
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// FiberHandler is Handler for fiber apps
+func FiberHandler() fiber.Handler {
+	handler := fasthttpadaptor.NewFastHTTPHandler(Handler())
+	return func(ctx *fiber.Ctx) error {
+		handler(ctx.Context())
+		return nil
+	}
+}
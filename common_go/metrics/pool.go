@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads the stats of the pool when it's scraped
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	constructing    *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	acquires        *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	canceled        *prometheus.Desc
+	empty           *prometheus.Desc
+}
+
+// RegisterPool exposes the stats of the database pool
+func RegisterPool(pool *pgxpool.Pool) error {
+	return prometheus.Register(&poolCollector{
+		pool: pool,
+
+		acquired:        prometheus.NewDesc("pgxpool_acquired_connections", "Connections currently acquired from the pool.", nil, nil),
+		idle:            prometheus.NewDesc("pgxpool_idle_connections", "Idle connections in the pool.", nil, nil),
+		constructing:    prometheus.NewDesc("pgxpool_constructing_connections", "Connections being opened.", nil, nil),
+		total:           prometheus.NewDesc("pgxpool_total_connections", "Connections in the pool.", nil, nil),
+		max:             prometheus.NewDesc("pgxpool_max_connections", "Maximum size of the pool.", nil, nil),
+		acquires:        prometheus.NewDesc("pgxpool_acquires_total", "Connections acquired from the pool.", nil, nil),
+		acquireDuration: prometheus.NewDesc("pgxpool_acquire_duration_seconds_total", "Time spent acquiring connections from the pool.", nil, nil),
+		canceled:        prometheus.NewDesc("pgxpool_canceled_acquires_total", "Acquires canceled by their context.", nil, nil),
+		empty:           prometheus.NewDesc("pgxpool_empty_acquires_total", "Acquires that waited for a connection because the pool was empty.", nil, nil),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.constructing
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquires
+	ch <- c.acquireDuration
+	ch <- c.canceled
+	ch <- c.empty
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.empty, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+}
@@ -0,0 +1,50 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Channel is the part of an amqp.Channel the clients use, the connection opens it through a Dialer
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Connection is a connection to the broker the channels are opened on
+type Connection interface {
+	Channel() (Channel, error)
+	Close() error
+}
+
+// Dialer connects to the broker at addr
+type Dialer func(addr string) (Connection, error)
+
+// DialAMQP connects to a rabbitmq server, it's the Dialer of every ClientConnection unless replaced
+func DialAMQP(addr string) (Connection, error) {
+	conn, err := amqp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnection{conn}, nil
+}
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
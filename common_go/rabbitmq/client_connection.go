@@ -1,120 +1,402 @@
 package rabbitmq
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
+	"sync"
 	"time"
 
+	"nikolamilovic/twitchy/common/metrics"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 )
 
-var ErrDisconnected = errors.New("disconnected from rabbitmq, trying to reconnect")
+var (
+	ErrDisconnected = errors.New("disconnected from rabbitmq, trying to reconnect")
+	ErrClosed       = errors.New("rabbitmq connection closed")
+)
 
 const (
 	// When reconnecting to the server after connection failure
 	reconnectDelay = 5 * time.Second
+	// How many confirms the broker can send ahead of them being handed to the publishers
+	confirmBuffer = 64
+	// When resending messages the server didn't confirm
+	resendDelay = 5 * time.Second
+)
+
+type state int
+
+const (
+	disconnected state = iota
+	connected
+	closed
 )
 
-//Maybe manage a pool of channels
+// Maybe manage a pool of channels
 type ClientConnection struct {
-	logger        *zap.SugaredLogger
-	connection    *amqp.Connection
-	Channel       *amqp.Channel
-	Done          chan os.Signal
-	NotifyClose   chan *amqp.Error
-	NotifyConfirm chan amqp.Confirmation
-	IsConnected   bool
-	Alive         bool
+	logger *zap.SugaredLogger
+	dial   Dialer
+
+	mu      sync.RWMutex
+	state   state
+	session *session
+	// connected is closed once connected, and replaced when the connection drops
+	connected chan struct{}
+	// done is closed by Close and stops HandleReconnect, stopped is closed once it returned
+	done    chan struct{}
+	stopped chan struct{}
+	started bool
 }
 
 // NewClientConnection creates a new ClientConnection
-func NewClientConnection(logger *zap.SugaredLogger, done chan os.Signal) *ClientConnection {
+func NewClientConnection(logger *zap.SugaredLogger) *ClientConnection {
 	return &ClientConnection{
-		logger: logger,
-		Done:   done,
-		Alive:  true,
+		logger:    logger,
+		dial:      DialAMQP,
+		connected: make(chan struct{}),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
 	}
 }
 
-// handleReconnect will wait for a connection error on
-// notifyClose, and then continuously attempt to reconnect.
-func (c *ClientConnection) HandleReconnect(addr string, clientConnect func(*amqp.Channel) bool) {
-	for c.Alive {
-		c.IsConnected = false
+// WithDialer replaces how the connection reaches the broker, it has to be called before HandleReconnect
+func (c *ClientConnection) WithDialer(dial Dialer) *ClientConnection {
+	c.dial = dial
+	return c
+}
+
+// IsConnected reports whether there's a channel to the broker, it's false while reconnecting
+func (c *ClientConnection) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state == connected
+}
+
+// NotifyConnected returns a channel that's closed once connected, right away if already connected
+func (c *ClientConnection) NotifyConnected() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
+}
+
+// WaitConnected blocks until connected, ErrClosed once the connection is closed
+func (c *ClientConnection) WaitConnected(ctx context.Context) error {
+	c.mu.RLock()
+	if c.state == closed {
+		c.mu.RUnlock()
+		return ErrClosed
+	}
+	connected, done := c.connected, c.done
+	c.mu.RUnlock()
+
+	select {
+	case <-connected:
+		return nil
+	case <-done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithChannel calls fn with the current channel, ErrDisconnected if there's none or it closed while in use
+func (c *ClientConnection) WithChannel(fn func(Channel) error) error {
+	s, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	err = fn(s.channel)
+	if errors.Is(err, amqp.ErrClosed) {
+		c.lost(s)
+		return ErrDisconnected
+	}
+	return err
+}
+
+// Publish sends msg on the current channel, the returned channel receives whether the broker confirmed it. A
+// channel closing before confirming counts as a nack, the message has to be resent once reconnected.
+func (c *ClientConnection) Publish(exchange, key string, msg amqp.Publishing) (<-chan bool, error) {
+	s, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed, err := s.publish(exchange, key, msg)
+	if errors.Is(err, amqp.ErrClosed) {
+		c.lost(s)
+		return nil, ErrDisconnected
+	}
+	return confirmed, err
+}
+
+// PublishConfirmed sends msg and waits for the broker to confirm it. Messages the broker nacks, doesn't confirm in time
+// or that were in flight when the connection dropped are resent, on the new channel once reconnected. It fails right
+// away when not connected, and when ctx is done while waiting to reconnect.
+func (c *ClientConnection) PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	published := metrics.StartPublish(exchange, key)
+	if !c.IsConnected() {
+		published.Failed(metrics.PublishDisconnected)
+		return errors.New("failed to push push: not connected")
+	}
+
+	for {
+		confirmed, err := c.Publish(exchange, key, msg)
+		if err != nil {
+			if err == ErrDisconnected {
+				if err := c.WaitConnected(ctx); err != nil {
+					published.Failed(metrics.PublishDisconnected)
+					return err
+				}
+				continue
+			}
+			published.Failed(metrics.PublishError)
+			return err
+		}
+		select {
+		case ack := <-confirmed:
+			if ack {
+				published.Confirmed()
+				return nil
+			}
+			published.Failed(metrics.PublishNack)
+		case <-time.After(resendDelay):
+			published.Failed(metrics.PublishTimeout)
+		}
+	}
+}
+
+// HandleReconnect connects and waits for the channel to close, then continuously attempts to reconnect until Close
+func (c *ClientConnection) HandleReconnect(addr string, clientConnect func(Channel) bool) {
+	c.mu.Lock()
+	if c.started || c.state == closed {
+		c.mu.Unlock()
+		return
+	}
+	c.started = true
+	c.mu.Unlock()
+	defer close(c.stopped)
+
+	var reconnecting bool
+	for {
 		t := time.Now()
-		fmt.Printf("Attempting to connect to rabbitMQ: %s\n", addr)
+		c.logger.Infof("Attempting to connect to rabbitMQ: %s", addr)
 		var retryCount int
-		for !c.connect(addr, clientConnect) {
-			if !c.Alive {
-				return
+		for {
+			s, ok := c.connect(addr, clientConnect)
+			if ok {
+				c.logger.Infof("Connected to rabbitMQ in: %vms", time.Since(t).Milliseconds())
+				if reconnecting {
+					metrics.Reconnected()
+				}
+				reconnecting = true
+
+				select {
+				case <-c.done:
+					return
+				case <-s.notifyClose:
+				}
+				c.lost(s)
+				s.close()
+				break
 			}
+
+			metrics.ConnectFailed()
 			select {
-			case <-c.Done:
+			case <-c.done:
 				return
 			case <-time.After(reconnectDelay + time.Duration(retryCount)*time.Second):
 				c.logger.Info("disconnected from rabbitMQ and failed to connect")
 				retryCount++
 			}
 		}
-		c.logger.Infof("Connected to rabbitMQ in: %vms", time.Since(t).Milliseconds())
-		select {
-		case <-c.Done:
-			return
-		case <-c.NotifyClose:
-		}
 	}
 }
 
 // connect will make a single attempt to connect to
-// RabbitMq. It returns the success of the attempt.
-func (c *ClientConnection) connect(addr string, clientConnect func(*amqp.Channel) bool) bool {
-	conn, err := amqp.Dial(addr)
+// RabbitMq. It returns the session and the success of the attempt.
+func (c *ClientConnection) connect(addr string, clientConnect func(Channel) bool) (*session, bool) {
+	conn, err := c.dial(addr)
 	if err != nil {
 		c.logger.Errorf("failed to dial rabbitMQ server: %v", err)
-		return false
+		return nil, false
 	}
 
 	ch, err := conn.Channel()
 	if err != nil {
 		c.logger.Errorf("failed connecting to channel: %v", err)
-		return false
+		conn.Close()
+		return nil, false
+	}
+
+	s := &session{
+		connection:  conn,
+		channel:     ch,
+		notifyClose: ch.NotifyClose(make(chan *amqp.Error, 1)),
+		pending:     map[uint64]chan bool{},
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		c.logger.Errorf("failed to put the channel in confirm mode: %v", err)
+		s.close()
+		return nil, false
 	}
-	ch.Confirm(false)
+	go s.dispatchConfirms(ch.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer)))
 
-	connectedClient := clientConnect(ch)
+	if !clientConnect(ch) {
+		c.logger.Error("failed to connect the client to rabbitmq")
+		s.close()
+		return nil, false
+	}
 
-	if !connectedClient {
-		c.logger.Errorf("failed to connect the client to rabbitmq: %v", err)
-		return false
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == closed {
+		s.close()
+		return nil, false
 	}
-	c.changeConnection(conn, ch)
-	c.IsConnected = true
-	return true
+	c.state = connected
+	c.session = s
+	close(c.connected)
+	return s, true
 }
 
-// changeConnection takes a new connection to the queue,
-// and updates the channel listeners to reflect this.
-func (c *ClientConnection) changeConnection(connection *amqp.Connection, channel *amqp.Channel) {
-	c.connection = connection
-	c.Channel = channel
-	c.NotifyClose = make(chan *amqp.Error)
-	c.NotifyConfirm = make(chan amqp.Confirmation)
-	c.Channel.NotifyClose(c.NotifyClose)
-	c.Channel.NotifyPublish(c.NotifyConfirm)
+// current returns the session publishers and consumers use
+func (c *ClientConnection) current() (*session, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch c.state {
+	case closed:
+		return nil, ErrClosed
+	case disconnected:
+		return nil, ErrDisconnected
+	}
+	return c.session, nil
 }
 
-func (c *ClientConnection) Close() error {
-	err := c.Channel.Close()
-	if err != nil {
-		c.logger.Errorf("failed to close channel: %v", err)
+// lost marks the connection as disconnected once s stopped working, HandleReconnect replaces it
+func (c *ClientConnection) lost(s *session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != connected || c.session != s {
+		return
 	}
-	err = c.connection.Close()
-	if err != nil {
-		return err
+	c.state = disconnected
+	c.session = nil
+	c.connected = make(chan struct{})
+}
+
+// Close stops reconnecting and closes the connection, waiting for HandleReconnect to return until ctx is done
+func (c *ClientConnection) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.state == closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.state = closed
+	s, started := c.session, c.started
+	c.session = nil
+	close(c.done)
+	c.mu.Unlock()
+
+	var err error
+	if s != nil {
+		err = s.close()
 	}
-	c.IsConnected = false
 
+	if started {
+		select {
+		case <-c.stopped:
+		case <-ctx.Done():
+			return fmt.Errorf("Close: %w", ctx.Err())
+		}
+	}
+	return err
+}
+
+// session is a single channel to the broker and the publishes on it waiting for a confirm
+type session struct {
+	connection  Connection
+	channel     Channel
+	notifyClose chan *amqp.Error
+
+	// publishMu keeps the publishes in the order of the delivery tags the broker confirms them with
+	publishMu sync.Mutex
+	nextTag   uint64
+
+	mu      sync.Mutex
+	pending map[uint64]chan bool
+}
+
+func (s *session) publish(exchange, key string, msg amqp.Publishing) (<-chan bool, error) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	// The confirm may arrive before Publish returns, so it's waited for up front
+	tag := s.nextTag + 1
+	confirmed := make(chan bool, 1)
+	s.mu.Lock()
+	if s.pending == nil {
+		s.mu.Unlock()
+		return nil, amqp.ErrClosed
+	}
+	s.pending[tag] = confirmed
+	s.mu.Unlock()
+
+	if err := s.channel.Publish(exchange, key, false, false, msg); err != nil {
+		s.mu.Lock()
+		delete(s.pending, tag)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.nextTag = tag
+	return confirmed, nil
+}
+
+// dispatchConfirms hands the confirms to their publishers until the channel closes, the rest are nacked
+func (s *session) dispatchConfirms(confirms <-chan amqp.Confirmation) {
+	for confirm := range confirms {
+		s.mu.Lock()
+		if confirmed, ok := s.pending[confirm.DeliveryTag]; ok {
+			confirmed <- confirm.Ack
+			delete(s.pending, confirm.DeliveryTag)
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, confirmed := range s.pending {
+		confirmed <- false
+	}
+	s.pending = nil
+}
+
+func (s *session) close() error {
+	if err := s.channel.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return err
+	}
+	if err := s.connection.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return err
+	}
 	return nil
 }
+
+// Wait waits for wg until ctx is done
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestPublishConfirmed(t *testing.T) {
+	broker := NewMemoryBroker()
+	connection := NewClientConnection(zap.NewNop().Sugar()).WithDialer(broker.Dial)
+	defer connection.Close(context.Background())
+
+	msg := amqp.Publishing{Body: []byte("body")}
+
+	if err := connection.PublishConfirmed(context.Background(), "", "queue", msg); err == nil {
+		t.Fatal("expected the publish to fail before connecting")
+	}
+
+	go connection.HandleReconnect("amqp://memory", func(ch Channel) bool {
+		_, err := ch.QueueDeclare("queue", true, false, false, false, nil)
+		return err == nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	if err := connection.WaitConnected(ctx); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+
+	if err := connection.PublishConfirmed(ctx, "", "queue", msg); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+
+	if messages := broker.Messages("queue"); messages != 1 {
+		t.Fatalf("expected the confirmed message in the queue, instead got: %d", messages)
+	}
+}
@@ -0,0 +1,708 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// MemoryBroker is a broker in memory the tests dial instead of rabbitmq. It has topic, direct and fanout exchanges,
+// queues with bindings, acks and nacks, publisher confirms and forced disconnects. Unroutable messages are dropped.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	exchanges   map[string]*memoryExchange
+	queues      map[string]*memoryQueue
+	connections map[*memoryConnection]struct{}
+	// names counts the queues named by the broker
+	names int
+}
+
+type memoryExchange struct {
+	kind     string
+	bindings []memoryBinding
+}
+
+type memoryBinding struct {
+	queue string
+	key   string
+}
+
+type memoryQueue struct {
+	name       string
+	autoDelete bool
+	owner      *memoryConnection
+	ready      []amqp.Delivery
+	consumers  []*memoryConsumer
+	next       int
+	deleted    bool
+}
+
+// NewMemoryBroker creates a MemoryBroker with just the default exchange
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		exchanges: map[string]*memoryExchange{
+			"": {kind: amqp.ExchangeDirect},
+		},
+		queues:      map[string]*memoryQueue{},
+		connections: map[*memoryConnection]struct{}{},
+	}
+}
+
+// Dial connects to the broker, addr is ignored. It's a Dialer for ClientConnection.WithDialer.
+func (b *MemoryBroker) Dial(addr string) (Connection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	conn := &memoryConnection{broker: b, channels: map[*memoryChannel]struct{}{}}
+	b.connections[conn] = struct{}{}
+	return conn, nil
+}
+
+// Disconnect forcibly closes every connection, like the broker restarting would
+func (b *MemoryBroker) Disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.connections {
+		b.closeConnection(conn, &amqp.Error{
+			Code:   amqp.ConnectionForced,
+			Reason: "CONNECTION_FORCED - broker forced connection closure",
+			Server: true,
+		})
+	}
+}
+
+// Messages returns how many messages in queue are waiting to be delivered
+func (b *MemoryBroker) Messages(queue string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if q, ok := b.queues[queue]; ok {
+		return len(q.ready)
+	}
+	return 0
+}
+
+// route returns the queues a message published to exchange with key goes to
+func (b *MemoryBroker) route(exchange *memoryExchange, name, key string) []*memoryQueue {
+	// Every queue is bound to the default exchange by its name
+	if name == "" {
+		if q, ok := b.queues[key]; ok {
+			return []*memoryQueue{q}
+		}
+		return nil
+	}
+
+	var queues []*memoryQueue
+	routed := map[string]bool{}
+	for _, binding := range exchange.bindings {
+		if routed[binding.queue] {
+			continue
+		}
+
+		var matches bool
+		switch exchange.kind {
+		case amqp.ExchangeFanout:
+			matches = true
+		case amqp.ExchangeDirect:
+			matches = binding.key == key
+		case amqp.ExchangeTopic:
+			matches = matchTopic(strings.Split(binding.key, "."), strings.Split(key, "."))
+		}
+
+		if matches {
+			routed[binding.queue] = true
+			queues = append(queues, b.queues[binding.queue])
+		}
+	}
+	return queues
+}
+
+// matchTopic matches the words of a routing key against a binding, * matches a single word and # any number of them
+func matchTopic(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchTopic(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+	}
+}
+
+// dispatch hands the ready messages of q to its consumers in turn, as long as their prefetch allows
+func (b *MemoryBroker) dispatch(q *memoryQueue) {
+	for len(q.ready) > 0 && len(q.consumers) > 0 {
+		var consumer *memoryConsumer
+		for i := range q.consumers {
+			c := q.consumers[(q.next+i)%len(q.consumers)]
+			if c.autoAck || c.prefetch == 0 || c.unacked < c.prefetch {
+				consumer = c
+				q.next = (q.next + i + 1) % len(q.consumers)
+				break
+			}
+		}
+		if consumer == nil {
+			return
+		}
+
+		msg := q.ready[0]
+		q.ready = q.ready[1:]
+
+		ch := consumer.channel
+		ch.deliveryTag++
+		msg.Acknowledger = ch
+		msg.DeliveryTag = ch.deliveryTag
+		msg.ConsumerTag = consumer.tag
+		if !consumer.autoAck {
+			ch.unacked[msg.DeliveryTag] = memoryUnacked{consumer: consumer, queue: q, msg: msg}
+			consumer.unacked++
+		}
+		consumer.push(msg)
+	}
+}
+
+// requeue puts msg back in front of its queue, to be delivered again
+func (b *MemoryBroker) requeue(q *memoryQueue, msg amqp.Delivery) {
+	if q.deleted {
+		return
+	}
+	msg.Redelivered = true
+	q.ready = append([]amqp.Delivery{msg}, q.ready...)
+	b.dispatch(q)
+}
+
+func (b *MemoryBroker) deleteQueue(q *memoryQueue) {
+	q.deleted = true
+	delete(b.queues, q.name)
+	for _, exchange := range b.exchanges {
+		bindings := exchange.bindings[:0]
+		for _, binding := range exchange.bindings {
+			if binding.queue != q.name {
+				bindings = append(bindings, binding)
+			}
+		}
+		exchange.bindings = bindings
+	}
+}
+
+// cancel stops consumer, the messages it was handed but didn't receive yet go back to the queue
+func (b *MemoryBroker) cancel(consumer *memoryConsumer) {
+	q, ch := consumer.queue, consumer.channel
+
+	for i, c := range q.consumers {
+		if c == consumer {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+	delete(ch.consumers, consumer.tag)
+
+	consumer.stopped = true
+	close(consumer.stop)
+	for _, msg := range consumer.buffer {
+		b.unsent(consumer, msg)
+	}
+	consumer.buffer = nil
+
+	if q.autoDelete && len(q.consumers) == 0 {
+		b.deleteQueue(q)
+	} else {
+		b.dispatch(q)
+	}
+}
+
+// unsent requeues a message that was handed to consumer but never made it to the deliveries
+func (b *MemoryBroker) unsent(consumer *memoryConsumer, msg amqp.Delivery) {
+	if !consumer.autoAck {
+		if _, ok := consumer.channel.unacked[msg.DeliveryTag]; !ok {
+			return
+		}
+		delete(consumer.channel.unacked, msg.DeliveryTag)
+		consumer.unacked--
+	}
+	b.requeue(consumer.queue, msg)
+}
+
+// closeChannel closes ch with err, nil when closed by the client. What it didn't ack goes back to the queues.
+func (b *MemoryBroker) closeChannel(ch *memoryChannel, err *amqp.Error) {
+	if ch.closed {
+		return
+	}
+	ch.closed = true
+	delete(ch.connection.channels, ch)
+
+	for _, consumer := range ch.consumers {
+		b.cancel(consumer)
+	}
+
+	tags := make([]uint64, 0, len(ch.unacked))
+	for tag := range ch.unacked {
+		tags = append(tags, tag)
+	}
+	// Requeued in front of the queue one by one, so the last delivered goes first
+	sort.Slice(tags, func(i, j int) bool { return tags[i] > tags[j] })
+	for _, tag := range tags {
+		unacked := ch.unacked[tag]
+		delete(ch.unacked, tag)
+		b.requeue(unacked.queue, unacked.msg)
+	}
+
+	closes, confirms := ch.closes, ch.confirms
+	ch.closes, ch.confirms = nil, nil
+	// The listeners are notified without holding the broker, they may be using it
+	go func() {
+		for _, c := range closes {
+			if err != nil {
+				c <- err
+			}
+			close(c)
+		}
+		for _, c := range confirms {
+			close(c)
+		}
+	}()
+}
+
+// fail closes ch with a channel exception, like rabbitmq does when a method fails
+func (b *MemoryBroker) fail(ch *memoryChannel, code int, format string, args ...interface{}) *amqp.Error {
+	err := &amqp.Error{Code: code, Reason: fmt.Sprintf(format, args...), Server: true}
+	b.closeChannel(ch, err)
+	return err
+}
+
+func (b *MemoryBroker) closeConnection(conn *memoryConnection, err *amqp.Error) {
+	if conn.closed {
+		return
+	}
+	conn.closed = true
+	delete(b.connections, conn)
+
+	for ch := range conn.channels {
+		b.closeChannel(ch, err)
+	}
+
+	// Exclusive queues are gone with the connection that declared them
+	for _, q := range b.queues {
+		if q.owner == conn {
+			b.deleteQueue(q)
+		}
+	}
+}
+
+type memoryConnection struct {
+	broker   *MemoryBroker
+	channels map[*memoryChannel]struct{}
+	closed   bool
+}
+
+func (c *memoryConnection) Channel() (Channel, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	ch := &memoryChannel{
+		broker:     c.broker,
+		connection: c,
+		consumers:  map[string]*memoryConsumer{},
+		unacked:    map[uint64]memoryUnacked{},
+	}
+	c.channels[ch] = struct{}{}
+	return ch, nil
+}
+
+func (c *memoryConnection) Close() error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	c.broker.closeConnection(c, nil)
+	return nil
+}
+
+// memoryChannel is a Channel of the MemoryBroker, it acknowledges the deliveries it consumed
+type memoryChannel struct {
+	broker     *MemoryBroker
+	connection *memoryConnection
+	closed     bool
+
+	prefetch   int
+	confirming bool
+	published  uint64
+
+	consumers   map[string]*memoryConsumer
+	deliveryTag uint64
+	unacked     map[uint64]memoryUnacked
+
+	closes   []chan *amqp.Error
+	confirms []chan amqp.Confirmation
+}
+
+type memoryUnacked struct {
+	consumer *memoryConsumer
+	queue    *memoryQueue
+	msg      amqp.Delivery
+}
+
+func (ch *memoryChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	switch kind {
+	case amqp.ExchangeTopic, amqp.ExchangeDirect, amqp.ExchangeFanout:
+	default:
+		return ch.broker.fail(ch, amqp.CommandInvalid, "COMMAND_INVALID - unknown exchange type '%s'", kind)
+	}
+
+	if exchange, ok := ch.broker.exchanges[name]; ok {
+		if exchange.kind != kind {
+			return ch.broker.fail(ch, amqp.PreconditionFailed, "PRECONDITION_FAILED - inequivalent arg 'type' for exchange '%s'", name)
+		}
+		return nil
+	}
+
+	ch.broker.exchanges[name] = &memoryExchange{kind: kind}
+	return nil
+}
+
+func (ch *memoryChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+
+	if name == "" {
+		ch.broker.names++
+		name = fmt.Sprintf("amq.gen-%d", ch.broker.names)
+	}
+
+	q, ok := ch.broker.queues[name]
+	if !ok {
+		q = &memoryQueue{name: name, autoDelete: autoDelete}
+		if exclusive {
+			q.owner = ch.connection
+		}
+		ch.broker.queues[name] = q
+	} else if q.owner != nil && q.owner != ch.connection {
+		return amqp.Queue{}, ch.broker.fail(ch, amqp.ResourceLocked, "RESOURCE_LOCKED - cannot obtain exclusive access to locked queue '%s'", name)
+	}
+
+	return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}, nil
+}
+
+func (ch *memoryChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	e, ok := ch.broker.exchanges[exchange]
+	if !ok {
+		return ch.broker.fail(ch, amqp.NotFound, "NOT_FOUND - no exchange '%s'", exchange)
+	}
+	if _, ok := ch.broker.queues[name]; !ok {
+		return ch.broker.fail(ch, amqp.NotFound, "NOT_FOUND - no queue '%s'", name)
+	}
+
+	binding := memoryBinding{queue: name, key: key}
+	for _, b := range e.bindings {
+		if b == binding {
+			return nil
+		}
+	}
+	e.bindings = append(e.bindings, binding)
+	return nil
+}
+
+// Qos sets the prefetch of the consumers started afterwards, the size and global are ignored
+func (ch *memoryChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.prefetch = prefetchCount
+	return nil
+}
+
+func (ch *memoryChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	q, ok := ch.broker.queues[queue]
+	if !ok {
+		return nil, ch.broker.fail(ch, amqp.NotFound, "NOT_FOUND - no queue '%s'", queue)
+	}
+	if q.owner != nil && q.owner != ch.connection {
+		return nil, ch.broker.fail(ch, amqp.ResourceLocked, "RESOURCE_LOCKED - cannot obtain exclusive access to locked queue '%s'", queue)
+	}
+
+	if consumer == "" {
+		consumer = fmt.Sprintf("ctag-%d", len(ch.consumers)+1)
+	}
+	if _, ok := ch.consumers[consumer]; ok {
+		return nil, ch.broker.fail(ch, amqp.NotAllowed, "NOT_ALLOWED - attempt to reuse consumer tag '%s'", consumer)
+	}
+
+	c := &memoryConsumer{
+		broker:     ch.broker,
+		tag:        consumer,
+		queue:      q,
+		channel:    ch,
+		autoAck:    autoAck,
+		prefetch:   ch.prefetch,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		deliveries: make(chan amqp.Delivery),
+	}
+	ch.consumers[consumer] = c
+	q.consumers = append(q.consumers, c)
+	go c.run()
+
+	ch.broker.dispatch(q)
+	return c.deliveries, nil
+}
+
+func (ch *memoryChannel) Cancel(consumer string, noWait bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	if c, ok := ch.consumers[consumer]; ok {
+		ch.broker.cancel(c)
+	}
+	return nil
+}
+
+// Publish routes msg to the queues bound to exchange, a confirm is sent once it's in all of them
+func (ch *memoryChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	e, ok := ch.broker.exchanges[exchange]
+	if !ok {
+		// Like rabbitmq the publish itself succeeds, the channel is closed right after
+		ch.broker.fail(ch, amqp.NotFound, "NOT_FOUND - no exchange '%s'", exchange)
+		return nil
+	}
+
+	for _, q := range ch.broker.route(e, exchange, key) {
+		q.ready = append(q.ready, amqp.Delivery{
+			Headers:         msg.Headers,
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			DeliveryMode:    msg.DeliveryMode,
+			Priority:        msg.Priority,
+			CorrelationId:   msg.CorrelationId,
+			ReplyTo:         msg.ReplyTo,
+			Expiration:      msg.Expiration,
+			MessageId:       msg.MessageId,
+			Timestamp:       msg.Timestamp,
+			Type:            msg.Type,
+			UserId:          msg.UserId,
+			AppId:           msg.AppId,
+			Exchange:        exchange,
+			RoutingKey:      key,
+			Body:            msg.Body,
+		})
+		ch.broker.dispatch(q)
+	}
+
+	if ch.confirming {
+		ch.published++
+		// The listeners have to keep reading the confirms, like with amqp
+		for _, c := range ch.confirms {
+			c <- amqp.Confirmation{DeliveryTag: ch.published, Ack: true}
+		}
+	}
+	return nil
+}
+
+func (ch *memoryChannel) Confirm(noWait bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.confirming = true
+	return nil
+}
+
+func (ch *memoryChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		close(confirm)
+	} else {
+		ch.confirms = append(ch.confirms, confirm)
+	}
+	return confirm
+}
+
+func (ch *memoryChannel) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+	} else {
+		ch.closes = append(ch.closes, c)
+	}
+	return c
+}
+
+func (ch *memoryChannel) Close() error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.broker.closeChannel(ch, nil)
+	return nil
+}
+
+func (ch *memoryChannel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, multiple, func(q *memoryQueue, msg amqp.Delivery) {})
+}
+
+func (ch *memoryChannel) Nack(tag uint64, multiple, requeue bool) error {
+	return ch.settle(tag, multiple, func(q *memoryQueue, msg amqp.Delivery) {
+		if requeue {
+			ch.broker.requeue(q, msg)
+		}
+	})
+}
+
+func (ch *memoryChannel) Reject(tag uint64, requeue bool) error {
+	return ch.Nack(tag, false, requeue)
+}
+
+// settle removes the delivery with tag, or all of them up to tag when multiple, and calls done with each
+func (ch *memoryChannel) settle(tag uint64, multiple bool, done func(*memoryQueue, amqp.Delivery)) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	tags := []uint64{tag}
+	if multiple {
+		tags = tags[:0]
+		for t := range ch.unacked {
+			if t <= tag {
+				tags = append(tags, t)
+			}
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i] > tags[j] })
+	}
+
+	for _, t := range tags {
+		unacked, ok := ch.unacked[t]
+		if !ok {
+			ch.broker.fail(ch, amqp.PreconditionFailed, "PRECONDITION_FAILED - unknown delivery tag %d", t)
+			return nil
+		}
+		delete(ch.unacked, t)
+		unacked.consumer.unacked--
+		done(unacked.queue, unacked.msg)
+		ch.broker.dispatch(unacked.queue)
+	}
+	return nil
+}
+
+// memoryConsumer hands the messages dispatched to it to the deliveries, so dispatching never waits on a consumer
+type memoryConsumer struct {
+	broker   *MemoryBroker
+	tag      string
+	queue    *memoryQueue
+	channel  *memoryChannel
+	autoAck  bool
+	prefetch int
+	unacked  int
+
+	buffer     []amqp.Delivery
+	wake       chan struct{}
+	stop       chan struct{}
+	stopped    bool
+	deliveries chan amqp.Delivery
+}
+
+func (c *memoryConsumer) push(msg amqp.Delivery) {
+	c.buffer = append(c.buffer, msg)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *memoryConsumer) run() {
+	defer close(c.deliveries)
+
+	for {
+		c.broker.mu.Lock()
+		for len(c.buffer) == 0 && !c.stopped {
+			c.broker.mu.Unlock()
+			select {
+			case <-c.wake:
+			case <-c.stop:
+			}
+			c.broker.mu.Lock()
+		}
+		if c.stopped {
+			c.broker.mu.Unlock()
+			return
+		}
+		msg := c.buffer[0]
+		c.buffer = c.buffer[1:]
+		c.broker.mu.Unlock()
+
+		select {
+		case c.deliveries <- msg:
+		case <-c.stop:
+			c.broker.mu.Lock()
+			c.broker.unsent(c, msg)
+			c.broker.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package rabbitmq
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// How long a test waits for a delivery before failing
+const deliveryTimeout = time.Second
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{pattern: "account.created", key: "account.created", expected: true},
+		{pattern: "account.created", key: "account.updated"},
+		{pattern: "account.*", key: "account.created", expected: true},
+		{pattern: "account.*", key: "account"},
+		{pattern: "account.*", key: "account.created.twice"},
+		{pattern: "*.created", key: "account.created", expected: true},
+		{pattern: "account.#", key: "account", expected: true},
+		{pattern: "account.#", key: "account.created.twice", expected: true},
+		{pattern: "#", key: "account.created", expected: true},
+		{pattern: "#.created", key: "created", expected: true},
+		{pattern: "#.created", key: "account.user.created", expected: true},
+		{pattern: "#.created", key: "account.updated"},
+		{pattern: "account.#.created", key: "account.created", expected: true},
+		{pattern: "*.*", key: "account"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.key, func(t *testing.T) {
+			matches := matchTopic(strings.Split(test.pattern, "."), strings.Split(test.key, "."))
+			if matches != test.expected {
+				t.Fatalf("expected %s to match %s: %v, instead got: %v", test.pattern, test.key, test.expected, matches)
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerRouting(t *testing.T) {
+	type binding struct {
+		queue string
+		key   string
+	}
+
+	tests := []struct {
+		description string
+		kind        string
+		exchange    string
+		bindings    []binding
+		key         string
+		// expected is how many messages each queue holds after the publish
+		expected map[string]int
+	}{
+		{
+			description: "direct to the queue bound with the key",
+			kind:        amqp.ExchangeDirect,
+			exchange:    "direct",
+			bindings:    []binding{{"a", "created"}, {"b", "updated"}},
+			key:         "created",
+			expected:    map[string]int{"a": 1, "b": 0},
+		},
+		{
+			description: "fanout to every bound queue",
+			kind:        amqp.ExchangeFanout,
+			exchange:    "fanout",
+			bindings:    []binding{{"a", "created"}, {"b", "updated"}},
+			key:         "anything",
+			expected:    map[string]int{"a": 1, "b": 1},
+		},
+		{
+			description: "topic through the wildcards",
+			kind:        amqp.ExchangeTopic,
+			exchange:    "topic",
+			bindings:    []binding{{"a", "account.*"}, {"b", "#.followed"}, {"c", "stream.#"}},
+			key:         "account.followed",
+			expected:    map[string]int{"a": 1, "b": 1, "c": 0},
+		},
+		{
+			description: "once to a queue bound more than once",
+			kind:        amqp.ExchangeTopic,
+			exchange:    "topic",
+			bindings:    []binding{{"a", "account.*"}, {"a", "#"}},
+			key:         "account.created",
+			expected:    map[string]int{"a": 1},
+		},
+		{
+			description: "unroutable messages are dropped",
+			kind:        amqp.ExchangeDirect,
+			exchange:    "direct",
+			bindings:    []binding{{"a", "created"}},
+			key:         "deleted",
+			expected:    map[string]int{"a": 0},
+		},
+		{
+			description: "the default exchange by queue name",
+			bindings:    []binding{{"a", ""}, {"b", ""}},
+			key:         "b",
+			expected:    map[string]int{"a": 0, "b": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			broker := NewMemoryBroker()
+			ch := channel(t, broker)
+
+			if test.exchange != "" {
+				if err := ch.ExchangeDeclare(test.exchange, test.kind, true, false, false, false, nil); err != nil {
+					t.Fatalf("expected no error, instead got: %v", err)
+				}
+			}
+
+			for _, b := range test.bindings {
+				if _, err := ch.QueueDeclare(b.queue, true, false, false, false, nil); err != nil {
+					t.Fatalf("expected no error, instead got: %v", err)
+				}
+				if test.exchange == "" {
+					continue
+				}
+				if err := ch.QueueBind(b.queue, b.key, test.exchange, false, nil); err != nil {
+					t.Fatalf("expected no error, instead got: %v", err)
+				}
+			}
+
+			if err := ch.Publish(test.exchange, test.key, false, false, amqp.Publishing{Body: []byte("body")}); err != nil {
+				t.Fatalf("expected no error, instead got: %v", err)
+			}
+
+			for queue, expected := range test.expected {
+				if messages := broker.Messages(queue); messages != expected {
+					t.Fatalf("expected %d messages in %s, instead got: %d", expected, queue, messages)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerSettlement(t *testing.T) {
+	tests := []struct {
+		description string
+		settle      func(msg amqp.Delivery) error
+		// redelivered tells whether the message comes back to the consumer
+		redelivered bool
+	}{
+		{description: "ack", settle: func(msg amqp.Delivery) error { return msg.Ack(false) }},
+		{description: "nack", settle: func(msg amqp.Delivery) error { return msg.Nack(false, false) }},
+		{description: "nack and requeue", settle: func(msg amqp.Delivery) error { return msg.Nack(false, true) }, redelivered: true},
+		{description: "reject", settle: func(msg amqp.Delivery) error { return msg.Reject(false) }},
+		{description: "reject and requeue", settle: func(msg amqp.Delivery) error { return msg.Reject(true) }, redelivered: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			broker := NewMemoryBroker()
+			ch := channel(t, broker)
+			deliveries := consume(t, ch, "queue", 1)
+
+			for _, body := range []string{"first", "second"} {
+				if err := ch.Publish("", "queue", false, false, amqp.Publishing{Body: []byte(body)}); err != nil {
+					t.Fatalf("expected no error, instead got: %v", err)
+				}
+			}
+
+			// The prefetch holds the second message back until the first is settled
+			msg := receive(t, deliveries)
+			if string(msg.Body) != "first" || msg.Redelivered || broker.Messages("queue") != 1 {
+				t.Fatalf("expected the first message with the second waiting, instead got: %s with %d waiting", msg.Body, broker.Messages("queue"))
+			}
+
+			if err := test.settle(msg); err != nil {
+				t.Fatalf("expected no error, instead got: %v", err)
+			}
+
+			msg = receive(t, deliveries)
+			if test.redelivered {
+				if string(msg.Body) != "first" || !msg.Redelivered {
+					t.Fatalf("expected the first message to be redelivered, instead got: %s, redelivered %v", msg.Body, msg.Redelivered)
+				}
+				msg.Ack(false)
+				msg = receive(t, deliveries)
+			}
+
+			if string(msg.Body) != "second" {
+				t.Fatalf("expected the second message, instead got: %s", msg.Body)
+			}
+			msg.Ack(false)
+		})
+	}
+}
+
+func TestMemoryBrokerRequeuesUnackedOnClose(t *testing.T) {
+	broker := NewMemoryBroker()
+	publisher := channel(t, broker)
+	consumer := channel(t, broker)
+	deliveries := consume(t, consumer, "queue", 0)
+
+	for _, body := range []string{"first", "second"} {
+		if err := publisher.Publish("", "queue", false, false, amqp.Publishing{Body: []byte(body)}); err != nil {
+			t.Fatalf("expected no error, instead got: %v", err)
+		}
+	}
+
+	receive(t, deliveries)
+	receive(t, deliveries)
+
+	if err := consumer.Close(); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+
+	if messages := broker.Messages("queue"); messages != 2 {
+		t.Fatalf("expected the 2 unacked messages back in the queue, instead got: %d", messages)
+	}
+
+	msg := receive(t, consume(t, channel(t, broker), "queue", 0))
+	if string(msg.Body) != "first" || !msg.Redelivered {
+		t.Fatalf("expected the first message to be redelivered first, instead got: %s, redelivered %v", msg.Body, msg.Redelivered)
+	}
+}
+
+func TestMemoryBrokerUnknownDeliveryTag(t *testing.T) {
+	broker := NewMemoryBroker()
+	ch := channel(t, broker)
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err := ch.(*memoryChannel).Ack(42, false); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+
+	select {
+	case err := <-closed:
+		if err == nil || err.Code != amqp.PreconditionFailed {
+			t.Fatalf("expected the channel to be closed with %d, instead got: %v", amqp.PreconditionFailed, err)
+		}
+	case <-time.After(deliveryTimeout):
+		t.Fatal("expected the channel to be closed")
+	}
+}
+
+func channel(t *testing.T, broker *MemoryBroker) Channel {
+	conn, err := broker.Dial("")
+	if err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ch, err := conn.Channel()
+	if err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+	return ch
+}
+
+// consume declares queue and consumes it with the prefetch, 0 being unlimited
+func consume(t *testing.T, ch Channel, queue string, prefetch int) <-chan amqp.Delivery {
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+
+	deliveries, err := ch.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+	return deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	select {
+	case msg := <-deliveries:
+		return msg
+	case <-time.After(deliveryTimeout):
+		t.Fatal("expected a delivery")
+		return amqp.Delivery{}
+	}
+}
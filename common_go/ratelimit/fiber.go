@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/token"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// FiberKeyFunc returns who the request counts against
+type FiberKeyFunc func(ctx *fiber.Ctx) string
+
+// FiberByIP is ByIP for fiber handlers
+func FiberByIP(ctx *fiber.Ctx) string {
+	return "ip:" + audit.FiberIP(ctx)
+}
+
+// FiberByUser is ByUser for fiber handlers
+func FiberByUser(secret []byte) FiberKeyFunc {
+	return func(ctx *fiber.Ctx) string {
+		if claims, err := token.FromHeaders(authz.FiberHeader(ctx), secret); err == nil {
+			return "user:" + strconv.Itoa(claims.UserId)
+		}
+
+		return FiberByIP(ctx)
+	}
+}
+
+// Fiber is Middleware for fiber handlers
+func Fiber(limiter Limiter, key FiberKeyFunc, logger *zap.SugaredLogger) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		res, err := limiter.Allow(ctx.Context(), key(ctx))
+		if err != nil {
+			logger.Errorf("failed to check the rate limit, letting the request through: %v", err)
+			return ctx.Next()
+		}
+
+		setHeaders(ctx.Set, res)
+
+		if !res.Allowed {
+			return fiber.NewError(http.StatusTooManyRequests, "too many requests")
+		}
+
+		return ctx.Next()
+	}
+}
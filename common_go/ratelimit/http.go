@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/token"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// KeyFunc returns who the request counts against
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts the requests against the IP of the client
+func ByIP(r *http.Request) string {
+	return "ip:" + audit.IP(r)
+}
+
+// ByUser counts the requests against the user of the access token, or the IP without a valid one
+func ByUser(secret []byte) KeyFunc {
+	return func(r *http.Request) string {
+		if claims, err := token.FromHeaders(r.Header.Get, secret); err == nil {
+			return "user:" + strconv.Itoa(claims.UserId)
+		}
+
+		return ByIP(r)
+	}
+}
+
+// Middleware rejects the requests over the limit with 429, every response carries the RateLimit headers.
+// Requests are let through if the limit can't be checked, the store being down shouldn't take the service with it.
+func Middleware(limiter Limiter, key KeyFunc, logger *zap.SugaredLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), key(r))
+			if err != nil {
+				logger.Errorf("failed to check the rate limit, letting the request through: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setHeaders(w.Header().Set, res)
+
+			if !res.Allowed {
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// downStore fails every check
+type downStore struct{}
+
+func (downStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func (downStore) Hit(ctx context.Context, key string, window time.Duration) (int, int, time.Duration, error) {
+	return 0, 0, 0, errors.New("connection refused")
+}
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	srv := Middleware(NewTokenBucket(NewMemoryStore(), "test", 1, 1), ByIP, zap.NewNop().Sugar())(ok)
+
+	type requestTest struct {
+		status     int
+		remaining  string
+		retryAfter string
+	}
+
+	for _, want := range []requestTest{
+		{status: http.StatusOK, remaining: "0"},
+		{status: http.StatusTooManyRequests, remaining: "0", retryAfter: "1"},
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != want.status || w.Header().Get("RateLimit-Remaining") != want.remaining || w.Header().Get("Retry-After") != want.retryAfter {
+			t.Fatalf("expected a %d with %s remaining and retry after %q, instead got: %d, %v", want.status, want.remaining, want.retryAfter, w.Code, w.Header())
+		}
+
+		if want, got := "1", w.Header().Get("RateLimit-Limit"); want != got {
+			t.Fatalf("expected a limit of %s, instead got: %s", want, got)
+		}
+	}
+
+	// The service keeps running when the limits can't be checked
+	w := httptest.NewRecorder()
+	Middleware(NewSlidingWindow(downStore{}, "test", 1, time.Minute), ByIP, zap.NewNop().Sugar())(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("expected the request through without limits, instead got: %d, %v", w.Code, w.Header())
+	}
+}
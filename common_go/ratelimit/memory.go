@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops the limits that are back to full, they're no different from new ones
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the limits in the process, every instance of a service limits on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Duration
+}
+
+type window struct {
+	start    time.Time
+	length   time.Duration
+	current  int
+	previous int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		windows: map[string]*window{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now, full: seconds(float64(burst) / rate)}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+func (s *MemoryStore) Hit(ctx context.Context, key string, length time.Duration) (int, int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	start := now.Truncate(length)
+
+	w, ok := s.windows[key]
+	switch {
+	case !ok:
+		w = &window{start: start, length: length}
+		s.windows[key] = w
+	case w.start.Equal(start):
+	case w.start.Add(length).Equal(start):
+		w.start, w.previous, w.current = start, w.current, 0
+	default:
+		w.start, w.previous, w.current = start, 0, 0
+	}
+
+	w.current++
+
+	return w.current, w.previous, now.Sub(start), nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.full {
+			delete(s.buckets, key)
+		}
+	}
+
+	for key, w := range s.windows {
+		if now.Sub(w.start) >= 2*w.length {
+			delete(s.windows, key)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+// PostgresStore keeps the limits in the rate_limit_buckets and rate_limit_windows tables, created by the migrations of
+// the services using it, so all instances of a service share them. The database clock is used so the instances agree on the windows.
+type PostgresStore struct {
+	DB db.PgxIface
+}
+
+func NewPostgresStore(db db.PgxIface) *PostgresStore {
+	return &PostgresStore{
+		DB: db,
+	}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	// SET sees the row before the update, the refill is computed the same way for both columns
+	rows, err := s.DB.Query(ctx, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $3::float8 - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $2::float8) >= 1
+				THEN LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $2::float8) - 1
+				ELSE LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $2::float8)
+			END,
+			allowed = LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $2::float8) >= 1,
+			updated_at = now()
+		RETURNING allowed, tokens`,
+		key, rate, burst)
+	if err != nil {
+		return false, 0, fmt.Errorf("Take: %w", err)
+	}
+
+	defer rows.Close()
+
+	var (
+		allowed bool
+		tokens  float64
+	)
+	if !rows.Next() {
+		return false, 0, fmt.Errorf("Take: %w", rowsErr(rows))
+	}
+	if err := rows.Scan(&allowed, &tokens); err != nil {
+		return false, 0, fmt.Errorf("Take: %w", err)
+	}
+
+	return allowed, tokens, nil
+}
+
+func (s *PostgresStore) Hit(ctx context.Context, key string, window time.Duration) (int, int, time.Duration, error) {
+	rows, err := s.DB.Query(ctx, `
+		WITH clock AS (
+			SELECT now() AS now, to_timestamp(floor(EXTRACT(EPOCH FROM now()) / $2::float8) * $2::float8) AS window_start
+		), hit AS (
+			INSERT INTO rate_limit_windows (key, window_start, hits)
+			SELECT $1, window_start, 1 FROM clock
+			ON CONFLICT (key, window_start) DO UPDATE SET hits = rate_limit_windows.hits + 1
+			RETURNING hits
+		)
+		SELECT
+			(SELECT hits FROM hit),
+			COALESCE((
+				SELECT w.hits FROM rate_limit_windows w, clock
+				WHERE w.key = $1 AND w.window_start = clock.window_start - make_interval(secs => $2::float8)
+			), 0),
+			EXTRACT(EPOCH FROM clock.now - clock.window_start)::float8
+		FROM clock`,
+		key, window.Seconds())
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("Hit: %w", err)
+	}
+
+	defer rows.Close()
+
+	var (
+		current, previous int
+		elapsed           float64
+	)
+	if !rows.Next() {
+		return 0, 0, 0, fmt.Errorf("Hit: %w", rowsErr(rows))
+	}
+	if err := rows.Scan(&current, &previous, &elapsed); err != nil {
+		return 0, 0, 0, fmt.Errorf("Hit: %w", err)
+	}
+
+	return current, previous, seconds(elapsed), nil
+}
+
+// rowsErr is why a query that always returns a row didn't
+func rowsErr(rows pgx.Rows) error {
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return pgx.ErrNoRows
+}
+
+// Sweep deletes the limits untouched for longer than retention, it should be longer than the longest limit
+func (s *PostgresStore) Sweep(ctx context.Context, retention time.Duration) error {
+	_, err := s.DB.Exec(ctx, `
+		WITH buckets AS (
+			DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1::float8)
+		)
+		DELETE FROM rate_limit_windows WHERE window_start < now() - make_interval(secs => $1::float8)`,
+		retention.Seconds())
+	if err != nil {
+		return fmt.Errorf("Sweep: %w", err)
+	}
+
+	return nil
+}
+
+// Run sweeps the limits every retention until ctx is cancelled, a failed sweep is retried on the next tick
+func (s *PostgresStore) Run(ctx context.Context, retention time.Duration, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(retention)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sweep(ctx, retention); err != nil {
+				logger.Errorf("failed to sweep the rate limits: %v", err)
+			}
+		}
+	}
+}
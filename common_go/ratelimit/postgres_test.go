@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pashagolub/pgxmock"
+)
+
+func newPostgresStore(t *testing.T) (*PostgresStore, pgxmock.PgxConnIface) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { mockDB.Close(context.Background()) })
+
+	return NewPostgresStore(mockDB), mockDB
+}
+
+func TestPostgresTake(t *testing.T) {
+	sut, mockDB := newPostgresStore(t)
+	ctx := context.Background()
+
+	mockDB.ExpectQuery("INSERT INTO rate_limit_buckets").WithArgs("login:ip:1.2.3.4", 0.5, 5).
+		WillReturnRows(pgxmock.NewRows([]string{"allowed", "tokens"}).AddRow(true, 3.5))
+	mockDB.ExpectQuery("INSERT INTO rate_limit_buckets").WithArgs("login:ip:1.2.3.4", 0.5, 5).
+		WillReturnRows(pgxmock.NewRows([]string{"allowed", "tokens"}).AddRow(false, 0.25))
+	mockDB.ExpectQuery("INSERT INTO rate_limit_buckets").WithArgs("login:ip:1.2.3.4", 0.5, 5).
+		WillReturnRows(pgxmock.NewRows([]string{"allowed", "tokens"}))
+	mockDB.ExpectQuery("INSERT INTO rate_limit_buckets").WithArgs("login:ip:1.2.3.4", 0.5, 5).
+		WillReturnError(errors.New("connection reset"))
+
+	if allowed, tokens, err := sut.Take(ctx, "login:ip:1.2.3.4", 0.5, 5); err != nil || !allowed || tokens != 3.5 {
+		t.Fatalf("expected a token to be taken with 3.5 left, instead got: %v, %v, %v", allowed, tokens, err)
+	}
+
+	if allowed, tokens, err := sut.Take(ctx, "login:ip:1.2.3.4", 0.5, 5); err != nil || allowed || tokens != 0.25 {
+		t.Fatalf("expected no token with 0.25 left, instead got: %v, %v, %v", allowed, tokens, err)
+	}
+
+	if _, _, err := sut.Take(ctx, "login:ip:1.2.3.4", 0.5, 5); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected %v when no row is returned, instead got: %v", pgx.ErrNoRows, err)
+	}
+
+	if _, _, err := sut.Take(ctx, "login:ip:1.2.3.4", 0.5, 5); err == nil {
+		t.Fatal("expected the query error to be returned")
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresHit(t *testing.T) {
+	sut, mockDB := newPostgresStore(t)
+	ctx := context.Background()
+
+	// The window is passed in seconds, the database clock decides which window the hit is in
+	mockDB.ExpectQuery("INSERT INTO rate_limit_windows").WithArgs("register:ip:1.2.3.4", 3600.0).
+		WillReturnRows(pgxmock.NewRows([]string{"hits", "previous", "elapsed"}).AddRow(3, 7, 90.5))
+	mockDB.ExpectQuery("INSERT INTO rate_limit_windows").WithArgs("register:ip:1.2.3.4", 3600.0).
+		WillReturnRows(pgxmock.NewRows([]string{"hits", "previous", "elapsed"}))
+
+	current, previous, elapsed, err := sut.Hit(ctx, "register:ip:1.2.3.4", time.Hour)
+	if err != nil || current != 3 || previous != 7 || elapsed != 90500*time.Millisecond {
+		t.Fatalf("expected 3 hits and 7 in the previous window 90.5s in, instead got: %d, %d, %v, %v", current, previous, elapsed, err)
+	}
+
+	if _, _, _, err := sut.Hit(ctx, "register:ip:1.2.3.4", time.Hour); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("expected %v when no row is returned, instead got: %v", pgx.ErrNoRows, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSweep(t *testing.T) {
+	sut, mockDB := newPostgresStore(t)
+
+	mockDB.ExpectExec("DELETE FROM rate_limit_buckets").WithArgs(7200.0).WillReturnResult(pgxmock.NewResult("DELETE", 3))
+
+	if err := sut.Sweep(context.Background(), 2*time.Hour); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
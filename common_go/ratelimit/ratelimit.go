@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Result is the decision on a request and the state of its limit, it's sent to the client in the RateLimit headers
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the quota resets
+	Reset time.Duration
+	// RetryAfter is how long a rejected client should wait before the next request
+	RetryAfter time.Duration
+	// Policy describes the limit as the quota and window in seconds, e.g. 10;w=60
+	Policy string
+}
+
+type Limiter interface {
+	// Allow counts a request of the client behind key against the limit
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Store keeps the state of the limits, the Postgres store shares it between all instances of a service
+type Store interface {
+	// Take refills the bucket of key at rate tokens per second up to burst and takes a token if there is one.
+	// It returns whether it took one and the tokens left.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error)
+	// Hit counts a request in the current window of key. It returns the requests counted in the current
+	// and the previous window and how far into the current window the request is.
+	Hit(ctx context.Context, key string, window time.Duration) (int, int, time.Duration, error)
+}
+
+// TokenBucket lets bursts of up to burst requests through, refilled at rate requests per second
+type TokenBucket struct {
+	store Store
+	name  string
+	rate  float64
+	burst int
+}
+
+// NewTokenBucket limits to rate requests per second with bursts of up to burst, name separates the keys of
+// different limits sharing a store
+func NewTokenBucket(store Store, name string, rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		store: store,
+		name:  name,
+		rate:  rate,
+		burst: burst,
+	}
+}
+
+func (l *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	ok, tokens, err := l.store.Take(ctx, l.name+":"+key, l.rate, l.burst)
+	if err != nil {
+		return Result{}, fmt.Errorf("Allow: %w", err)
+	}
+
+	res := Result{
+		Allowed:   ok,
+		Limit:     l.burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(l.burst) - tokens) / l.rate),
+		Policy:    fmt.Sprintf("%d;w=%d", l.burst, int(math.Ceil(float64(l.burst)/l.rate))),
+	}
+
+	if !ok {
+		res.RetryAfter = seconds((1 - tokens) / l.rate)
+	}
+
+	return res, nil
+}
+
+// SlidingWindow lets limit requests through in any window long period. It counts the requests in fixed windows and
+// weighs the previous window by how much of it still overlaps the sliding one. Rejected requests are counted as
+// well, a client that keeps retrying stays limited.
+type SlidingWindow struct {
+	store  Store
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// NewSlidingWindow limits to limit requests per window, name separates the keys of different limits sharing a store
+func NewSlidingWindow(store Store, name string, limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{
+		store:  store,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (l *SlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	current, previous, elapsed, err := l.store.Hit(ctx, l.name+":"+key, l.window)
+	if err != nil {
+		return Result{}, fmt.Errorf("Allow: %w", err)
+	}
+
+	window := l.window.Seconds()
+	overlap := 1 - elapsed.Seconds()/window
+	count := float64(previous)*overlap + float64(current)
+
+	res := Result{
+		Allowed:   count <= float64(l.limit),
+		Limit:     l.limit,
+		Remaining: int(math.Max(0, float64(l.limit)-math.Ceil(count))),
+		Reset:     l.window - elapsed,
+		Policy:    fmt.Sprintf("%d;w=%d", l.limit, int(math.Ceil(window))),
+	}
+
+	if res.Allowed {
+		return res, nil
+	}
+
+	// The next request is let through once the weighted count drops below the limit, as the previous window
+	// slides out or, when the current one alone is over the limit, after it becomes the previous one
+	if current < l.limit {
+		res.RetryAfter = seconds(window*(1-float64(l.limit-current-1)/float64(previous)) - elapsed.Seconds())
+	} else {
+		res.RetryAfter = seconds(window - elapsed.Seconds() + window*(1-float64(l.limit-1)/float64(current)))
+	}
+
+	return res, nil
+}
+
+// setHeaders sends the limit to the client in the RateLimit headers, and a Retry-After if it was rejected
+func setHeaders(set func(key, value string), res Result) {
+	set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	set("RateLimit-Policy", res.Policy)
+
+	if !res.Allowed {
+		set("Retry-After", strconv.Itoa(int(math.Max(1, float64(ceilSeconds(res.RetryAfter))))))
+	}
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// hitStore returns the same window counts for every hit
+type hitStore struct {
+	current, previous int
+	elapsed           time.Duration
+}
+
+func (s *hitStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	return false, 0, nil
+}
+
+func (s *hitStore) Hit(ctx context.Context, key string, window time.Duration) (int, int, time.Duration, error) {
+	return s.current, s.previous, s.elapsed, nil
+}
+
+// clock is a memory store whose time only moves when told to
+func clock() (*MemoryStore, func(time.Duration)) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func roughly(a, b time.Duration) bool {
+	d := a - b
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func TestSlidingWindow(t *testing.T) {
+	type windowTest struct {
+		description       string
+		current, previous int
+		elapsed           time.Duration
+		allowed           bool
+		remaining         int
+		retryAfter        time.Duration
+	}
+
+	for _, scenario := range []windowTest{
+		{
+			description: "first request",
+			current:     1,
+			elapsed:     10 * time.Second,
+			allowed:     true,
+			remaining:   9,
+		},
+		{
+			description: "half of the previous window still counts",
+			current:     5,
+			previous:    10,
+			elapsed:     30 * time.Second,
+			allowed:     true,
+			remaining:   0,
+		},
+		{
+			// 10*0.3 + 7 is back to the limit 42s into the window
+			description: "over the limit with the previous window",
+			current:     6,
+			previous:    10,
+			elapsed:     30 * time.Second,
+			retryAfter:  12 * time.Second,
+		},
+		{
+			// 12*0.75 + 1 is back to the limit 15s into the next window
+			description: "over the limit with the current window alone",
+			current:     12,
+			elapsed:     30 * time.Second,
+			retryAfter:  45 * time.Second,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			store := &hitStore{current: scenario.current, previous: scenario.previous, elapsed: scenario.elapsed}
+
+			res, err := NewSlidingWindow(store, "test", 10, time.Minute).Allow(context.Background(), "key")
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+
+			if res.Allowed != scenario.allowed || res.Remaining != scenario.remaining {
+				t.Fatalf("expected allowed %v with %d remaining, instead got: %+v", scenario.allowed, scenario.remaining, res)
+			}
+
+			if !roughly(res.RetryAfter, scenario.retryAfter) {
+				t.Fatalf("expected to retry after %v, instead got: %v", scenario.retryAfter, res.RetryAfter)
+			}
+
+			if want, got := time.Minute-scenario.elapsed, res.Reset; want != got {
+				t.Fatalf("expected a reset in %v, instead got: %v", want, got)
+			}
+
+			if want, got := "10;w=60", res.Policy; want != got {
+				t.Fatalf("expected the policy %s, instead got: %s", want, got)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowRetryAfterIsEnough(t *testing.T) {
+	for _, spread := range []time.Duration{0, time.Second, 7 * time.Second, 10 * time.Second} {
+		store, advance := clock()
+		limiter := NewSlidingWindow(store, "test", 5, time.Minute)
+		ctx := context.Background()
+
+		// Requests every spread until one is rejected
+		var res Result
+		for i := 0; ; i++ {
+			var err error
+			if res, err = limiter.Allow(ctx, "key"); err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if !res.Allowed {
+				break
+			}
+			if i > 100 {
+				t.Fatalf("expected a request every %v to be limited", spread)
+			}
+			advance(spread)
+		}
+
+		if res.RetryAfter <= 0 {
+			t.Fatalf("expected the rejected request to wait, instead got: %+v", res)
+		}
+
+		// The rejected request counts as well, retrying right after waiting is let through
+		advance(res.RetryAfter + time.Millisecond)
+		if res, err := limiter.Allow(ctx, "key"); err != nil || !res.Allowed {
+			t.Fatalf("expected the request after %v to be allowed with requests every %v, instead got: %+v, %v",
+				res.RetryAfter, spread, res, err)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	store, advance := clock()
+	limiter := NewTokenBucket(store, "test", 0.5, 2)
+	ctx := context.Background()
+
+	for i := 1; i >= 0; i-- {
+		res, err := limiter.Allow(ctx, "key")
+		if err != nil || !res.Allowed || res.Remaining != i {
+			t.Fatalf("expected the burst to be allowed with %d remaining, instead got: %+v, %v", i, res, err)
+		}
+	}
+
+	res, _ := limiter.Allow(ctx, "key")
+	if res.Allowed || res.RetryAfter != 2*time.Second || res.Reset != 4*time.Second || res.Policy != "2;w=4" {
+		t.Fatalf("expected to retry in 2s once the bucket is empty, instead got: %+v", res)
+	}
+
+	// Other keys have buckets of their own
+	if res, _ := limiter.Allow(ctx, "other"); !res.Allowed {
+		t.Fatalf("expected another key to be allowed, instead got: %+v", res)
+	}
+
+	advance(2 * time.Second)
+	if res, _ := limiter.Allow(ctx, "key"); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("expected a refilled token to be taken, instead got: %+v", res)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store, advance := clock()
+	ctx := context.Background()
+
+	store.Take(ctx, "bucket", 1, 5)
+	store.Hit(ctx, "window", time.Minute)
+
+	// Back to full and two windows ago, they're no different from new ones
+	advance(2 * time.Minute)
+	store.Take(ctx, "other", 1, 5)
+
+	if _, ok := store.buckets["bucket"]; ok {
+		t.Fatal("expected the full bucket to be swept")
+	}
+	if _, ok := store.windows["window"]; ok {
+		t.Fatal("expected the old window to be swept")
+	}
+}
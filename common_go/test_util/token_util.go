@@ -8,9 +8,10 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-func GenerateTokens(userId int, secret string) (string, error) {
+func GenerateTokens(userId int, secret string, scopes ...string) (string, error) {
 	claims := token.UserClaims{
 		UserId: userId,
+		Scopes: scopes,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Minute * 5).Unix(),
 			Issuer:    "twitchy", //TODO
@@ -21,7 +22,7 @@ func GenerateTokens(userId int, secret string) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString([]byte(secret))
 
 	if err != nil {
 		return "", fmt.Errorf("GenerateTokens: %w", err)
@@ -0,0 +1,47 @@
+package token
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Headers the gateway forwards the user it verified the token of in, it drops whatever the client sent in them
+const (
+	HeaderUserID     = "X-User-Id"
+	HeaderUserScopes = "X-User-Scopes"
+)
+
+var trustGateway bool
+
+// TrustGateway makes FromHeaders take the user the gateway forwarded instead of verifying the token again. It's called
+// once on startup, and only by services that can't be reached but through the gateway: anyone reaching the service
+// directly could set the headers.
+func TrustGateway(trust bool) {
+	trustGateway = trust
+}
+
+// GatewayTrusted tells whether the headers the gateway sets can be trusted, see TrustGateway
+func GatewayTrusted() bool {
+	return trustGateway
+}
+
+// FromHeaders returns the claims of the caller, read with header. That's the user the gateway forwarded if it's
+// trusted and verified one, or else the bearer token in the Authorization header.
+func FromHeaders(header func(key string) string, secret []byte) (*UserClaims, error) {
+	if id := header(HeaderUserID); trustGateway && id != "" {
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("FromHeaders: %w: invalid user id %q", InvalidJWTError, id)
+		}
+
+		return &UserClaims{UserId: userID, Scopes: strings.Fields(header(HeaderUserScopes))}, nil
+	}
+
+	claims, err := ParseUserClaims(strings.TrimPrefix(header("Authorization"), "Bearer "), secret)
+	if err != nil {
+		return nil, fmt.Errorf("FromHeaders: %w", err)
+	}
+
+	return claims, nil
+}
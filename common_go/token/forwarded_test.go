@@ -0,0 +1,71 @@
+package token_test
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"reflect"
+	"testing"
+)
+
+const secret = "secret"
+
+func TestFromHeaders(t *testing.T) {
+	jwt, err := test_util.GenerateTokens(1, secret, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forwarded := http.Header{}
+	forwarded.Set(token.HeaderUserID, "7")
+	forwarded.Set(token.HeaderUserScopes, "channel:moderator:3 staff")
+
+	both := forwarded.Clone()
+	both.Set("Authorization", "Bearer "+jwt)
+
+	bearer := http.Header{}
+	bearer.Set("Authorization", "Bearer "+jwt)
+
+	invalid := http.Header{}
+	invalid.Set(token.HeaderUserID, "seven")
+
+	tests := []struct {
+		description string
+		trust       bool
+		header      http.Header
+		userID      int
+		scopes      []string
+		fail        bool
+	}{
+		{description: "the token when the gateway isn't trusted", header: both, userID: 1, scopes: []string{"admin"}},
+		{description: "no user when the gateway isn't trusted and there is no token", header: forwarded, fail: true},
+		{description: "the forwarded user when the gateway is trusted", trust: true, header: both, userID: 7, scopes: []string{"channel:moderator:3", "staff"}},
+		{description: "the token when the gateway didn't forward a user", trust: true, header: bearer, userID: 1, scopes: []string{"admin"}},
+		{description: "no user without a token or a forwarded user", trust: true, header: http.Header{}, fail: true},
+		{description: "no user when the forwarded id isn't a number", trust: true, header: invalid, fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			token.TrustGateway(test.trust)
+			defer token.TrustGateway(false)
+
+			claims, err := token.FromHeaders(test.header.Get, []byte(secret))
+
+			if test.fail {
+				if !errors.Is(err, token.InvalidJWTError) {
+					t.Fatalf("expected an invalid token error, instead got: %v, %+v", err, claims)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, instead got: %v", err)
+			}
+			if claims.UserId != test.userID || !reflect.DeepEqual(claims.Scopes, test.scopes) {
+				t.Fatalf("expected user %d with %v, instead got: %d with %v", test.userID, test.scopes, claims.UserId, claims.Scopes)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package token
+
+import "fmt"
+
+// Platform roles apply everywhere
+const (
+	RoleAdmin = "admin"
+	RoleStaff = "staff"
+)
+
+// Channel roles only apply to the channel they were granted in
+const (
+	RoleBroadcaster = "broadcaster"
+	RoleModerator   = "moderator"
+	RoleEditor      = "editor"
+	RoleVIP         = "vip"
+)
+
+func IsPlatformRole(role string) bool {
+	return role == RoleAdmin || role == RoleStaff
+}
+
+// IsChannelRole reports whether the role can be granted in a channel, the broadcaster role comes with the channel
+func IsChannelRole(role string) bool {
+	return role == RoleModerator || role == RoleEditor || role == RoleVIP
+}
+
+// ChannelScope is how a channel role is written in the token scopes, e.g. moderator:42
+func ChannelScope(role string, channelID int) string {
+	return fmt.Sprintf("%s:%d", role, channelID)
+}
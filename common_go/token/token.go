@@ -10,6 +10,32 @@ import (
 
 var InvalidJWTError = errors.New("Invalid JWT Token")
 
+func keyFunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return secret, nil
+	}
+}
+
+// ParseUserClaims validates the token and returns its claims, used by services that need to know who the caller is
+func ParseUserClaims(tokenString string, secret []byte) (*UserClaims, error) {
+	claims := &UserClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc(secret))
+	if err != nil {
+		return nil, fmt.Errorf("ParseUserClaims: %w: %v", InvalidJWTError, err)
+	}
+
+	if !token.Valid {
+		return nil, InvalidJWTError
+	}
+
+	return claims, nil
+}
+
 func CheckJWTToken(tokenString string, secret []byte) (bool, error) {
 	// Parse takes the token string and a function for looking up the key. The latter is especially
 	// useful if you use multiple keys for your application.  The standard is to use 'kid' in the
@@ -24,7 +50,7 @@ func CheckJWTToken(tokenString string, secret []byte) (bool, error) {
 		return secret, nil
 	})
 
-	if !token.Valid {
+	if token == nil || !token.Valid {
 		return false, InvalidJWTError
 	}
 
@@ -4,5 +4,31 @@ import "github.com/golang-jwt/jwt"
 
 type UserClaims struct {
 	UserId int `json:"uid"`
+	// Scopes are the roles of the user when the token was issued, see ChannelScope for the channel scoped ones
+	Scopes []string `json:"scp,omitempty"`
 	jwt.StandardClaims
 }
+
+// HasRole reports whether the user holds the platform role
+func (c *UserClaims) HasRole(role string) bool {
+	return c.hasScope(role)
+}
+
+// HasChannelRole reports whether the user holds the role in the channel. Every user is the broadcaster of their own channel.
+func (c *UserClaims) HasChannelRole(role string, channelID int) bool {
+	if role == RoleBroadcaster && channelID != 0 && c.UserId == channelID {
+		return true
+	}
+
+	return c.hasScope(ChannelScope(role, channelID))
+}
+
+func (c *UserClaims) hasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier reads and writes the trace context in the headers of a message
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartPublish starts the span of publishing a message to exchange with key. The returned headers carry the trace to
+// the consumers and go into the message, the span ends once the broker confirmed it.
+func StartPublish(ctx context.Context, exchange, key string) (trace.Span, amqp.Table) {
+	ctx, span := tracer().Start(ctx, key+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(exchange, key)...))
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	return span, headers
+}
+
+// StartConsume continues the trace of the message in the span of processing it
+func StartConsume(ctx context.Context, msg amqp.Delivery) (context.Context, trace.Span) {
+	headers := msg.Headers
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(headers))
+
+	return tracer().Start(ctx, msg.RoutingKey+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messagingAttributes(msg.Exchange, msg.RoutingKey)...))
+}
+
+func messagingAttributes(exchange, key string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination", exchange),
+		attribute.String("messaging.rabbitmq.routing_key", key),
+	}
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	db "nikolamilovic/twitchy/common/db"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedDB traces the queries, the span of a query ends once Postgres answered, reading the rows isn't part of it
+type tracedDB struct {
+	db db.PgxIface
+}
+
+// DB traces the queries made through db as children of the span in their context
+func DB(db db.PgxIface) db.PgxIface {
+	return &tracedDB{db: db}
+}
+
+func (t *tracedDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startQuery(ctx, sql)
+	defer span.End()
+
+	rows, err := t.db.Query(ctx, sql, args...)
+	recordError(span, err)
+
+	return rows, err
+}
+
+func (t *tracedDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startQuery(ctx, sql)
+	defer span.End()
+
+	tag, err := t.db.Exec(ctx, sql, args...)
+	recordError(span, err)
+
+	return tag, err
+}
+
+func startQuery(ctx context.Context, sql string) (context.Context, trace.Span) {
+	operation := "QUERY"
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return tracer().Start(ctx, "postgres "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", sql),
+		))
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fiber is Middleware for fiber apps, the context of the span is the user context of the request
+func Fiber() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		carrier := propagation.MapCarrier{}
+		for _, key := range otel.GetTextMapPropagator().Fields() {
+			if value := ctx.Get(key); value != "" {
+				carrier.Set(key, value)
+			}
+		}
+
+		c := otel.GetTextMapPropagator().Extract(ctx.UserContext(), carrier)
+		c, span := tracer().Start(c, ctx.Method()+" "+ctx.Path(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", ctx.Method()),
+				attribute.String("http.target", ctx.Path()),
+			))
+		defer span.End()
+
+		ctx.SetUserContext(c)
+
+		err := ctx.Next()
+
+		span.SetName(ctx.Method() + " " + ctx.Route().Path)
+		span.SetAttributes(attribute.String("http.route", ctx.Route().Path))
+
+		// The error handler writes the response after the middleware, the status is taken from the error
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			status = http.StatusInternalServerError
+
+			var e *fiber.Error
+			if errors.As(err, &e) {
+				status = e.Code
+			}
+		}
+
+		setStatus(span, status)
+
+		return err
+	}
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Where the spans are exported to, picked with the OTEL_TRACES_EXPORTER variable
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	instrumentationName = "nikolamilovic/twitchy/common/tracing"
+	shutdownTimeout     = 5 * time.Second
+)
+
+// Init sets up tracing for the service and returns a func that flushes the spans left and stops it. Spans are exported
+// to OTEL_TRACES_EXPORTER, none by default, the OTLP exporter is set up with the standard OTEL_EXPORTER_OTLP_* variables.
+// The W3C trace context is propagated even when nothing is exported, so the services after this one can continue the trace.
+func Init(ctx context.Context, service string) (func() error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", ExporterNone:
+		return func() error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("Init: unknown exporter %q", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Init: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		return nil, fmt.Errorf("Init: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
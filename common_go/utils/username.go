@@ -0,0 +1,7 @@
+package utils
+
+import "regexp"
+
+// UsernamePattern is what a username may look like. Usernames double as channel names in URLs so they're kept to
+// characters that need no escaping, and to the 50 characters the services store.
+var UsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
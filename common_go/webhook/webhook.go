@@ -0,0 +1,96 @@
+// Package webhook sends the requests to the URLs users hand in, such as notification webhooks and event callbacks
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNotHTTPS       = errors.New("webhooks must be https")
+	ErrPrivateAddress = errors.New("webhooks can't reach private addresses")
+)
+
+// Shared address space of carrier-grade NATs, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewClient returns the client for the URLs users hand in. It only connects to public addresses, checked when dialing
+// the resolved address so a DNS name can't point it at the internal services, and doesn't follow redirects, a redirect
+// isn't an answer.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: publicOnly,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy, the address checked has to be the one the request goes to
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckURL returns ErrNotHTTPS unless the URL is an absolute https one
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("CheckURL: %w", err)
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return ErrNotHTTPS
+	}
+
+	return nil
+}
+
+// Public reports whether the address is reachable from the internet. Loopback, private, link-local, which the cloud
+// metadata endpoints are on, shared, unspecified and multicast addresses aren't.
+func Public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) ||
+		ip.Equal(net.IPv4bcast) || (ip.To4() != nil && ip.To4()[0] == 0))
+}
+
+// publicOnly refuses connections to addresses that aren't public, it's called with every address dialed
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// Signature is how the receivers check a request came from us, "sha256=" and the hex HMAC-SHA256 of the message id,
+// timestamp and body keyed with their secret
+func Signature(secret, messageID, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(messageID))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.0.0.5":        false,
+		"172.18.0.3":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::":              false,
+		"::ffff:10.0.0.5": false,
+		"224.0.0.1":       false,
+	} {
+		if got := Public(net.ParseIP(address)); got != public {
+			t.Fatalf("expected %s to be public: %v, instead got: %v", address, public, got)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the request not to reach the server")
+	}))
+	defer srv.Close()
+
+	// localhost is refused once resolved as well, the check is on the address dialed
+	for _, url := range []string{srv.URL, "http://localhost:" + srv.URL[len("http://127.0.0.1:"):]} {
+		_, err := NewClient(time.Second).Post(url, "application/json", nil)
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Fatalf("expected %s to be refused, instead got: %v", url, err)
+		}
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	// Only the redirect policy is under test, the server is on loopback
+	client.Transport = http.DefaultTransport
+
+	res, err := client.Post(srv.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("expected no error, instead got: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("expected the redirect itself, instead got: %d", res.StatusCode)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for url, valid := range map[string]bool{
+		"https://example.com/hook": true,
+		"http://example.com/hook":  false,
+		"ftp://example.com":        false,
+		"https:///hook":            false,
+		"example.com":              false,
+	} {
+		if err := CheckURL(url); (err == nil) != valid {
+			t.Fatalf("expected %s to be valid: %v, instead got: %v", url, valid, err)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	// printf 'message2022-10-01T12:00:00Z{}' | openssl dgst -sha256 -hmac s3cr3t-s3cr3t
+	want := "sha256=f04433b7e3d19e32c114363c34c15072fee4138fb372a55052b963edcd50d148"
+
+	if got := Signature("s3cr3t-s3cr3t", "message", "2022-10-01T12:00:00Z", []byte("{}")); got != want {
+		t.Fatalf("expected the signature %s, instead got: %s", want, got)
+	}
+}
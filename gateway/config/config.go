@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Auth modes of a route
+const (
+	// AuthNone passes the request on without looking at the token
+	AuthNone = "none"
+	// AuthOptional forwards the user of a valid token and rejects invalid ones, requests without a token pass anonymously
+	AuthOptional = "optional"
+	// AuthRequired rejects requests without a valid token
+	AuthRequired = "required"
+)
+
+// Config is the routing table of the gateway, loaded from a YAML file
+type Config struct {
+	CORS   CORS    `yaml:"cors"`
+	Routes []Route `yaml:"routes"`
+}
+
+type CORS struct {
+	// AllowedOrigins may contain "*" to allow any origin
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	// MaxAge is how many seconds browsers may cache a preflight response
+	MaxAge int `yaml:"max_age"`
+}
+
+// Route sends the requests whose path starts with Prefix to Upstream, the longest matching prefix wins
+type Route struct {
+	Prefix   string `yaml:"prefix"`
+	Upstream string `yaml:"upstream"`
+	// StripPrefix removes the prefix before forwarding, for services that don't serve under it
+	StripPrefix bool       `yaml:"strip_prefix"`
+	Auth        string     `yaml:"auth"`
+	RateLimit   *RateLimit `yaml:"rate_limit"`
+}
+
+// RateLimit is a token bucket per user, or per client IP for anonymous requests
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// Load reads and validates the config file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	for i := range cfg.Routes {
+		if cfg.Routes[i].Auth == "" {
+			cfg.Routes[i].Auth = AuthOptional
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Validate reports every problem of the config at once
+func (c *Config) Validate() error {
+	var problems []string
+	prefixes := map[string]bool{}
+
+	if len(c.Routes) == 0 {
+		problems = append(problems, "no routes")
+	}
+
+	for i, r := range c.Routes {
+		if !strings.HasPrefix(r.Prefix, "/") {
+			problems = append(problems, fmt.Sprintf("route %d: prefix %q must start with /", i, r.Prefix))
+		}
+		if prefixes[r.Prefix] {
+			problems = append(problems, fmt.Sprintf("route %d: prefix %q is routed twice", i, r.Prefix))
+		}
+		prefixes[r.Prefix] = true
+
+		u, err := url.Parse(r.Upstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("route %d: upstream %q must be an http(s) URL", i, r.Upstream))
+		}
+
+		switch r.Auth {
+		case AuthNone, AuthOptional, AuthRequired:
+		default:
+			problems = append(problems, fmt.Sprintf("route %d: unknown auth %q", i, r.Auth))
+		}
+
+		if r.RateLimit != nil && (r.RateLimit.RequestsPerSecond <= 0 || r.RateLimit.Burst < 1) {
+			problems = append(problems, fmt.Sprintf("route %d: rate limit needs a positive rate and burst", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
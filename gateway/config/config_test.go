@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+cors:
+  allowed_origins: ["*"]
+routes:
+  - prefix: /v1/auth
+    upstream: http://auth-service
+    auth: none
+    rate_limit:
+      requests_per_second: 1
+      burst: 10
+  - prefix: /api/accounts
+    upstream: http://account-service
+`))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(cfg.Routes) != 2 || cfg.Routes[0].RateLimit.Burst != 10 || cfg.CORS.AllowedOrigins[0] != "*" {
+		t.Fatalf("Expected both routes to be parsed, got %+v", cfg)
+	}
+
+	if cfg.Routes[1].Auth != AuthOptional || cfg.Routes[1].RateLimit != nil {
+		t.Fatalf("Expected the token to be optional and no rate limit by default, got %+v", cfg.Routes[1])
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte(`
+routes:
+  - prefix: v1/auth
+    upstream: auth-service
+    auth: sometimes
+  - prefix: /api/accounts
+    upstream: http://account-service
+    rate_limit:
+      requests_per_second: 0
+  - prefix: /api/accounts
+    upstream: http://account-service
+`))
+	if err == nil {
+		t.Fatalf("Expected the config to be rejected")
+	}
+
+	// Every problem is reported at once
+	for _, problem := range []string{"must start with /", "must be an http(s) URL", "unknown auth", "rate limit", "routed twice"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("Expected %q to be reported, got %v", problem, err)
+		}
+	}
+}
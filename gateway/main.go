@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/gateway/config"
+	"nikolamilovic/twitchy/gateway/proxy"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const reloadInterval = 5 * time.Second
+
+var (
+	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
+	shutdowns []func() error
+)
+
+func main() {
+	var (
+		shutdown = make(chan struct{})
+		ctx      = context.Background()
+		sigint   = make(chan os.Signal, 1)
+	)
+
+	settings, err := loadSettings()
+	if err != nil {
+		logger.Fatal("failed to load the settings", zap.Error(err))
+	}
+	configPath := settings.Routes
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+
+	gateway, err := proxy.New(cfg, []byte(settings.JWTSecret), logger.Sugar().Named("gateway"))
+	if err != nil {
+		logger.Fatal("Unable to initialize the gateway", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// The routes are reloaded when the file changes, or right away on SIGHUP
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	go gateway.Watch(watchCtx, configPath, reloadInterval)
+	go reloadOnHangup(gateway, configPath)
+
+	port := fmt.Sprintf(":%d", settings.Port)
+	server := http.Server{
+		Addr:    port,
+		Handler: gateway,
+	}
+
+	shutdowns = append(shutdowns, func() error {
+		stopWatching()
+		return nil
+	})
+
+	// The metrics are served on their own port, everything on the public one is proxied
+	if settings.MetricsPort != 0 {
+		metricsServer := &http.Server{
+			Addr:    fmt.Sprintf(":%d", settings.MetricsPort),
+			Handler: metrics.Handler(),
+		}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Error("metrics server error", zap.Error(err))
+			}
+		}()
+		shutdowns = append(shutdowns, func() error {
+			return metricsServer.Shutdown(ctx)
+		})
+	}
+
+	defer logger.Sync()
+
+	go gracefulShutdown(&server, shutdown, ctx, sigint)
+
+	logger.Info("Server starting and listening at port " + server.Addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal("server error", zap.Error(err))
+	}
+}
+
+func reloadOnHangup(gateway *proxy.Gateway, configPath string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		if err := gateway.ReloadFile(configPath); err != nil {
+			logger.Error("failed to reload the config, keeping the previous one", zap.Error(err))
+			continue
+		}
+		logger.Info("reloaded the config from " + configPath)
+	}
+}
+
+func gracefulShutdown(server *http.Server, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+
+	logger.Info("shutting down server gracefully")
+
+	// stop receiving any request.
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("shutdown error", zap.Error(err))
+	}
+
+	// close any other modules.
+	for i := range shutdowns {
+		shutdowns[i]()
+	}
+
+	close(shutdown)
+}
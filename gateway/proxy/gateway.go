@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/gateway/config"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Headers the gateway sets on every forwarded request, whatever the client sent in them is dropped. The services
+// started with TRUST_GATEWAY read the user from the X-User headers through token.FromHeaders.
+const (
+	HeaderRequestID  = "X-Request-Id"
+	HeaderUserID     = token.HeaderUserID
+	HeaderUserScopes = token.HeaderUserScopes
+)
+
+// A request id the client sent is kept if it looks like one
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type route struct {
+	config.Route
+	// upstream proxies the requests that passed the rate limit
+	upstream http.Handler
+	// handler forwards the requests of the route, measured under its prefix
+	handler http.Handler
+}
+
+// table is a config ready to serve, the routes are sorted longest prefix first
+type table struct {
+	cors   config.CORS
+	routes []*route
+}
+
+// Gateway reverse-proxies the API to the services. It authenticates the requests once, forwarding the verified user
+// in the X-User headers, applies CORS and the rate limits of the routes and tags every request with an id.
+type Gateway struct {
+	table     atomic.Value
+	jwtSecret []byte
+	// limits outlive the reloads, a new config doesn't hand out fresh bursts
+	limits *ratelimit.MemoryStore
+	logger *zap.SugaredLogger
+}
+
+func New(cfg *config.Config, jwtSecret []byte, logger *zap.SugaredLogger) (*Gateway, error) {
+	g := &Gateway{
+		jwtSecret: jwtSecret,
+		limits:    ratelimit.NewMemoryStore(),
+		logger:    logger,
+	}
+
+	if err := g.Reload(cfg); err != nil {
+		return nil, fmt.Errorf("New: %w", err)
+	}
+
+	return g, nil
+}
+
+// Reload swaps in the config, requests already being served finish with the previous one
+func (g *Gateway) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("Reload: %w", err)
+	}
+
+	t := &table{cors: cfg.CORS}
+	for _, r := range cfg.Routes {
+		rt, err := g.newRoute(r)
+		if err != nil {
+			return fmt.Errorf("Reload: %w", err)
+		}
+		t.routes = append(t.routes, rt)
+	}
+
+	sort.SliceStable(t.routes, func(i, j int) bool {
+		return len(t.routes[i].Prefix) > len(t.routes[j].Prefix)
+	})
+
+	g.table.Store(t)
+
+	return nil
+}
+
+// ReloadFile loads the config file and swaps it in, a config that doesn't load leaves the current one in place
+func (g *Gateway) ReloadFile(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("ReloadFile: %w", err)
+	}
+
+	if err := g.Reload(cfg); err != nil {
+		return fmt.Errorf("ReloadFile: %w", err)
+	}
+
+	return nil
+}
+
+// Watch reloads the config file whenever it changes, checking every interval until ctx is cancelled
+func (g *Gateway) Watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			g.logger.Errorf("failed to check the config: %v", err)
+			continue
+		}
+
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		if err := g.ReloadFile(path); err != nil {
+			g.logger.Errorf("failed to reload the config, keeping the previous one: %v", err)
+			continue
+		}
+
+		g.logger.Infof("reloaded the config from %s", path)
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := g.table.Load().(*table)
+
+	id := r.Header.Get(HeaderRequestID)
+	if !requestIDPattern.MatchString(id) {
+		id = newRequestID()
+	}
+	r.Header.Set(HeaderRequestID, id)
+	w.Header().Set(HeaderRequestID, id)
+
+	if t.applyCORS(w, r) {
+		return
+	}
+
+	rt := t.match(r.URL.Path)
+	if rt == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	rt.handler.ServeHTTP(w, r)
+}
+
+// forward authenticates and rate limits the request before proxying it to the upstream of the route
+func (g *Gateway) forward(w http.ResponseWriter, r *http.Request, rt *route) {
+	if !g.authenticate(w, r, rt.Auth) {
+		return
+	}
+
+	rt.upstream.ServeHTTP(w, r)
+}
+
+func (g *Gateway) newRoute(r config.Route) (*route, error) {
+	target, err := url.Parse(r.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &route{Route: r}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		host, proto := req.Host, "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+
+		if r.StripPrefix {
+			req.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(req.URL.Path, r.Prefix), "/")
+			req.URL.RawPath = ""
+		}
+
+		director(req)
+
+		req.Header.Set("X-Real-IP", clientIP(req))
+		req.Header.Set("X-Forwarded-Host", host)
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
+	// The gateway answers CORS for every service, the services' own headers would be duplicates. So would the
+	// RateLimit headers of the routes the gateway limits, a service's 429 still carries its Retry-After.
+	proxy.ModifyResponse = func(res *http.Response) error {
+		for header := range res.Header {
+			if strings.HasPrefix(header, "Access-Control-") || (r.RateLimit != nil && strings.HasPrefix(header, "Ratelimit-")) {
+				res.Header.Del(header)
+			}
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		g.logger.Warnf("request %s to %s failed: %v", req.Header.Get(HeaderRequestID), r.Upstream, err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}
+	// Live streams, server-sent events and ingest are streamed, nothing is held back
+	proxy.FlushInterval = -1
+
+	rt.upstream = proxy
+	if r.RateLimit != nil {
+		limiter := ratelimit.NewTokenBucket(g.limits, r.Prefix, r.RateLimit.RequestsPerSecond, r.RateLimit.Burst)
+		rt.upstream = ratelimit.Middleware(limiter, clientKey, g.logger)(proxy)
+	}
+	rt.handler = metrics.Instrument(r.Prefix, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		g.forward(w, req, rt)
+	}))
+
+	return rt, nil
+}
+
+// authenticate forwards the user of the token in the X-User headers. The token is read from the Authorization header,
+// or the access_token query parameter for the clients that can't set headers.
+func (g *Gateway) authenticate(w http.ResponseWriter, r *http.Request, mode string) bool {
+	r.Header.Del(HeaderUserID)
+	r.Header.Del(HeaderUserScopes)
+
+	if mode == config.AuthNone {
+		return true
+	}
+
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if bearer == "" {
+		bearer = r.URL.Query().Get("access_token")
+	}
+
+	if bearer == "" {
+		if mode == config.AuthRequired {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	claims, err := token.ParseUserClaims(bearer, g.jwtSecret)
+	if err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	}
+
+	r.Header.Set(HeaderUserID, strconv.Itoa(claims.UserId))
+	r.Header.Set(HeaderUserScopes, strings.Join(claims.Scopes, " "))
+
+	return true
+}
+
+func (t *table) match(path string) *route {
+	for _, rt := range t.routes {
+		if path == rt.Prefix || strings.HasPrefix(path, strings.TrimSuffix(rt.Prefix, "/")+"/") {
+			return rt
+		}
+	}
+
+	return nil
+}
+
+// applyCORS sets the CORS headers for allowed origins and answers preflight requests, returning true if it did
+func (t *table) applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	w.Header().Add("Vary", "Origin")
+
+	if !t.allowedOrigin(origin) {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	if t.cors.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		if len(t.cors.ExposedHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(t.cors.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	h.Set("Access-Control-Allow-Methods", strings.Join(t.cors.AllowedMethods, ", "))
+	h.Set("Access-Control-Allow-Headers", strings.Join(t.cors.AllowedHeaders, ", "))
+	if t.cors.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(t.cors.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+func (t *table) allowedOrigin(origin string) bool {
+	for _, o := range t.cors.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientKey is who the rate limits count against, the user authenticate verified or the IP of anonymous clients
+func clientKey(r *http.Request) string {
+	if id := r.Header.Get(HeaderUserID); id != "" {
+		return "user:" + id
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// clientIP is the address the client connected from, the services read it from X-Real-IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,281 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/gateway/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const secret = "secret"
+
+// upstream echoes back the path and the headers set by the gateway
+func upstream(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Got-User-Id", r.Header.Get(HeaderUserID))
+		w.Header().Set("X-Got-User-Scopes", r.Header.Get(HeaderUserScopes))
+		w.Header().Set("X-Got-Request-Id", r.Header.Get(HeaderRequestID))
+		w.Header().Set("X-Got-Real-IP", r.Header.Get("X-Real-IP"))
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("RateLimit-Limit", "100")
+	}))
+}
+
+func newGateway(t *testing.T, cfg *config.Config) *Gateway {
+	g, err := New(cfg, []byte(secret), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+	return g
+}
+
+func serve(g *Gateway, r *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	g.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestRouting(t *testing.T) {
+	streams, chat := upstream("streams"), upstream("chat")
+	defer streams.Close()
+	defer chat.Close()
+
+	g := newGateway(t, &config.Config{Routes: []config.Route{
+		{Prefix: "/api", Upstream: chat.URL, Auth: config.AuthNone},
+		{Prefix: "/api/streams", Upstream: streams.URL, Auth: config.AuthNone},
+		{Prefix: "/v1/chat", Upstream: chat.URL, StripPrefix: true, Auth: config.AuthNone},
+	}})
+
+	rr := serve(g, httptest.NewRequest(http.MethodGet, "/api/streams/1", nil))
+	if rr.Header().Get("X-Upstream") != "streams" || rr.Header().Get("X-Path") != "/api/streams/1" {
+		t.Fatalf("Expected the longest prefix to win, got %v %v", rr.Header().Get("X-Upstream"), rr.Header().Get("X-Path"))
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/api/streamsx", nil))
+	if rr.Header().Get("X-Upstream") != "chat" {
+		t.Fatalf("Expected prefixes to match whole segments, got %v", rr.Header().Get("X-Upstream"))
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/v1/chat/socket/websocket", nil))
+	if rr.Header().Get("X-Path") != "/socket/websocket" {
+		t.Fatalf("Expected the prefix to be stripped, got %v", rr.Header().Get("X-Path"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/streams", nil)
+	req.Header.Set("X-Real-IP", "1.1.1.1")
+	rr = serve(g, req)
+	if rr.Header().Get("X-Got-Real-IP") != "192.0.2.1" {
+		t.Fatalf("Expected the address of the client to be forwarded, got %v", rr.Header().Get("X-Got-Real-IP"))
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code to be %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	up := upstream("streams")
+	defer up.Close()
+
+	g := newGateway(t, &config.Config{Routes: []config.Route{
+		{Prefix: "/optional", Upstream: up.URL, Auth: config.AuthOptional},
+		{Prefix: "/required", Upstream: up.URL, Auth: config.AuthRequired},
+	}})
+
+	access, err := test_util.GenerateTokens(7, secret, "channel:broadcaster:7", "admin")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	// Whatever the client claims to be is dropped
+	req := httptest.NewRequest(http.MethodGet, "/optional", nil)
+	req.Header.Set(HeaderUserID, "1")
+	rr := serve(g, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Got-User-Id") != "" {
+		t.Fatalf("Expected an anonymous request, got %d %v", rr.Code, rr.Header().Get("X-Got-User-Id"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/optional", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	rr = serve(g, req)
+	if rr.Header().Get("X-Got-User-Id") != "7" || rr.Header().Get("X-Got-User-Scopes") != "channel:broadcaster:7 admin" {
+		t.Fatalf("Expected the user to be forwarded, got %v %v", rr.Header().Get("X-Got-User-Id"), rr.Header().Get("X-Got-User-Scopes"))
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/required?access_token="+access, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Got-User-Id") != "7" {
+		t.Fatalf("Expected the query token to be accepted, got %d", rr.Code)
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/required", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status code to be %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/optional", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rr = serve(g, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected an invalid token to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	up := upstream("streams")
+	defer up.Close()
+
+	g := newGateway(t, &config.Config{Routes: []config.Route{{Prefix: "/", Upstream: up.URL, Auth: config.AuthNone}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "abc-123")
+	rr := serve(g, req)
+	if rr.Header().Get(HeaderRequestID) != "abc-123" || rr.Header().Get("X-Got-Request-Id") != "abc-123" {
+		t.Fatalf("Expected the request id to be kept, got %v", rr.Header().Get(HeaderRequestID))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "not a request id")
+	rr = serve(g, req)
+	id := rr.Header().Get(HeaderRequestID)
+	if len(id) != 32 || rr.Header().Get("X-Got-Request-Id") != id {
+		t.Fatalf("Expected a new request id, got %v", id)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	up := upstream("streams")
+	defer up.Close()
+
+	g := newGateway(t, &config.Config{
+		CORS: config.CORS{
+			AllowedOrigins: []string{"http://localhost:8080"},
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Authorization"},
+			MaxAge:         600,
+		},
+		Routes: []config.Route{{Prefix: "/", Upstream: up.URL, Auth: config.AuthRequired}},
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/streams", nil)
+	req.Header.Set("Origin", "http://localhost:8080")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := serve(g, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST" || rr.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("Expected the preflight to be answered without a token, got %d %v", rr.Code, rr.Header())
+	}
+
+	access, _ := test_util.GenerateTokens(1, secret)
+	req = httptest.NewRequest(http.MethodGet, "/streams", nil)
+	req.Header.Set("Origin", "http://localhost:8080")
+	req.Header.Set("Authorization", "Bearer "+access)
+	rr = serve(g, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "http://localhost:8080" || len(rr.Header().Values("Access-Control-Allow-Origin")) != 1 {
+		t.Fatalf("Expected only the gateway's CORS headers, got %v", rr.Header().Values("Access-Control-Allow-Origin"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/streams", nil)
+	req.Header.Set("Origin", "http://evil.com")
+	req.Header.Set("Authorization", "Bearer "+access)
+	rr = serve(g, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected the origin to be refused, got %v", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	up := upstream("auth")
+	defer up.Close()
+
+	cfg := &config.Config{Routes: []config.Route{
+		{Prefix: "/v1/auth", Upstream: up.URL, Auth: config.AuthOptional, RateLimit: &config.RateLimit{RequestsPerSecond: 0.5, Burst: 1}},
+		{Prefix: "/v1/chat", Upstream: up.URL, Auth: config.AuthNone},
+	}}
+	g := newGateway(t, cfg)
+
+	rr := serve(g, httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code to be %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Values("RateLimit-Limit")[0] != "1" || len(rr.Header().Values("RateLimit-Limit")) != 1 ||
+		rr.Header().Get("RateLimit-Remaining") != "0" || rr.Header().Get("RateLimit-Policy") != "1;w=2" {
+		t.Fatalf("Expected only the RateLimit headers of the gateway, got %v", rr.Header())
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "2" {
+		t.Fatalf("Expected to be limited for 2 seconds, got %d %v", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	// Users are limited on their own, not by the IP they share
+	access, _ := test_util.GenerateTokens(1, secret)
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil)
+	req.Header.Set("Authorization", "Bearer "+access)
+	if rr = serve(g, req); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the user to pass, got %d", rr.Code)
+	}
+
+	// Routes without a limit pass the service's headers through
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/v1/chat", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "100" {
+		t.Fatalf("Expected the RateLimit headers of the service, got %d %v", rr.Code, rr.Header())
+	}
+
+	// Reloading doesn't hand out a new burst
+	if err := g.Reload(cfg); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+	rr = serve(g, httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected to still be limited after a reload, got %d", rr.Code)
+	}
+}
+
+func TestReload(t *testing.T) {
+	first, second := upstream("first"), upstream("second")
+	defer first.Close()
+	defer second.Close()
+
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	write := func(upstream string) {
+		routes := "routes:\n  - prefix: /\n    upstream: " + upstream + "\n    auth: none\n"
+		if err := os.WriteFile(path, []byte(routes), 0o644); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	write(first.URL)
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+	g := newGateway(t, cfg)
+
+	write(second.URL)
+	if err := g.ReloadFile(path); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	rr := serve(g, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Header().Get("X-Upstream") != "second" {
+		t.Fatalf("Expected the new routes to be served, got %v", rr.Header().Get("X-Upstream"))
+	}
+
+	// A broken config keeps the routes being served
+	write("second-service")
+	if err := g.ReloadFile(path); err == nil {
+		t.Fatalf("Expected the config to be rejected")
+	}
+
+	rr = serve(g, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Header().Get("X-Upstream") != "second" {
+		t.Fatalf("Expected the previous routes to be kept, got %v", rr.Header().Get("X-Upstream"))
+	}
+}
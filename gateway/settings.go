@@ -0,0 +1,21 @@
+package main
+
+import (
+	envconfig "nikolamilovic/twitchy/common/config"
+)
+
+// Settings are read from the environment, or the YAML file at CONFIG_FILE. The routes are in their own file, they're
+// reloaded while the gateway runs.
+type Settings struct {
+	Port      int    `env:"PORT" yaml:"port" default:"80"`
+	JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+	// MetricsPort serves the metrics apart from the proxied routes, they aren't served when it isn't set
+	MetricsPort int    `env:"METRICS_PORT" yaml:"metrics_port"`
+	Routes      string `env:"GATEWAY_CONFIG" yaml:"routes" default:"routes.yaml"`
+}
+
+func loadSettings() (Settings, error) {
+	var settings Settings
+	err := envconfig.Load(&settings)
+	return settings, err
+}
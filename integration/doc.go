@@ -0,0 +1,9 @@
+// Package integration runs the auth and account services together in process, against a throwaway Postgres and the
+// in-memory broker, to test the flows that cross them: registering, logging in, refreshing the tokens and the account
+// provisioned from the account_created event.
+//
+// TestRegisterProvisionsAccount runs the register flow on mocked databases with every go test. The other tests are
+// built with the integration tag. They start a Postgres cluster with the initdb and postgres binaries found
+// in POSTGRES_BIN, on the PATH or in the usual install locations, or use the server at TEST_DATABASE_URL, and fail when
+// there's neither.
+package integration
@@ -0,0 +1,213 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"nikolamilovic/twitchy/common/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// How long a started cluster has to accept connections
+	postgresStartTimeout = 30 * time.Second
+)
+
+// pg is the server every test creates its databases on
+var pg postgresServer
+
+// postgresServer is where the tests create their databases
+type postgresServer struct {
+	host     string
+	port     int
+	user     string
+	password string
+}
+
+func TestMain(m *testing.M) {
+	server, stop, err := startPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start postgres: %v\n", err)
+		os.Exit(1)
+	}
+	pg = server
+
+	code := m.Run()
+	stop(code != 0)
+	os.Exit(code)
+}
+
+// startPostgres returns the server at TEST_DATABASE_URL, or starts a cluster that's removed once stopped
+func startPostgres() (postgresServer, func(failed bool), error) {
+	if dbURL := os.Getenv("TEST_DATABASE_URL"); dbURL != "" {
+		server, err := parsePostgresURL(dbURL)
+		return server, func(bool) {}, err
+	}
+
+	bin, ok := postgresBin()
+	if !ok {
+		return postgresServer{}, nil, errors.New("no Postgres to test against, set TEST_DATABASE_URL or POSTGRES_BIN, or put initdb and postgres on the PATH")
+	}
+
+	dir, err := os.MkdirTemp("", "twitchy-integration")
+	if err != nil {
+		return postgresServer{}, nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "-N").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return postgresServer{}, nil, fmt.Errorf("failed to init the cluster: %v\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return postgresServer{}, nil, err
+	}
+
+	var logs bytes.Buffer
+	// fsync is off, the cluster is thrown away
+	cmd := exec.Command(filepath.Join(bin, "postgres"), "-D", data, "-p", strconv.Itoa(port), "-k", dir,
+		"-c", "listen_addresses=127.0.0.1", "-F")
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return postgresServer{}, nil, err
+	}
+
+	stop := func(failed bool) {
+		// SIGINT is the fast shutdown, the sessions still open are closed
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+		if failed {
+			fmt.Fprintf(os.Stderr, "postgres logs:\n%s", logs.String())
+		}
+		os.RemoveAll(dir)
+	}
+
+	return postgresServer{host: "127.0.0.1", port: port, user: "postgres"}, stop, nil
+}
+
+// postgresBin returns the directory with the initdb and postgres binaries
+func postgresBin() (string, bool) {
+	candidates := []string{os.Getenv("POSTGRES_BIN")}
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		candidates = append(candidates, filepath.Dir(initdb))
+	}
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin", "/usr/local/opt/postgresql*/bin", "/opt/homebrew/opt/postgresql*/bin"} {
+		dirs, _ := filepath.Glob(pattern)
+		candidates = append(candidates, dirs...)
+	}
+
+	for _, dir := range candidates {
+		if dir == "" {
+			continue
+		}
+		if isFile(filepath.Join(dir, "initdb")) && isFile(filepath.Join(dir, "postgres")) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func parsePostgresURL(dbURL string) (postgresServer, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return postgresServer{}, fmt.Errorf("invalid TEST_DATABASE_URL: %w", err)
+	}
+
+	port := 5432
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return postgresServer{}, fmt.Errorf("invalid TEST_DATABASE_URL port: %w", err)
+		}
+	}
+
+	password, _ := u.User.Password()
+	return postgresServer{host: u.Hostname(), port: port, user: u.User.Username(), password: password}, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// createDatabase creates a database of its own for a service, dropped at the end of the test
+func (s postgresServer) createDatabase(t *testing.T, name, migrations string) config.Postgres {
+	name = fmt.Sprintf("%s_%d", name, time.Now().UnixNano())
+
+	admin := s.connect(t)
+	defer admin.Close(context.Background())
+
+	if _, err := admin.Exec(context.Background(), "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		t.Fatalf("failed to create the %s database: %v", name, err)
+	}
+
+	t.Cleanup(func() {
+		admin := s.connect(t)
+		defer admin.Close(context.Background())
+
+		if _, err := admin.Exec(context.Background(), "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			t.Logf("failed to drop the %s database: %v", name, err)
+		}
+	})
+
+	path, err := filepath.Abs(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Postgres{
+		User:     s.user,
+		Password: s.password,
+		Host:     s.host,
+		Port:     s.port,
+		DB:       name,
+		// The migrations are read from file:/// followed by the path
+		MigrationPath: path[1:],
+	}
+}
+
+// connect connects to the postgres database, waiting for a cluster that's still starting
+func (s postgresServer) connect(t *testing.T) *pgx.Conn {
+	cfg := config.Postgres{User: s.user, Password: s.password, Host: s.host, Port: s.port, DB: "postgres"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postgresStartTimeout)
+	defer cancel()
+
+	for {
+		conn, err := pgx.Connect(ctx, cfg.URL())
+		if err == nil {
+			return conn
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("failed to connect to postgres: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"context"
+	accountclient "nikolamilovic/twitchy/accounts/client"
+	accountservice "nikolamilovic/twitchy/accounts/service"
+	authclient "nikolamilovic/twitchy/auth/client"
+	authservice "nikolamilovic/twitchy/auth/service"
+	authmock "nikolamilovic/twitchy/auth/service/mock"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long the account service has to provision an account once registered
+	provisionTimeout = 10 * time.Second
+)
+
+// provisioning reports the accounts the account service created
+type provisioning struct {
+	accountservice.IAccountService
+	created chan event.AccountCreatedEventData
+}
+
+func (p *provisioning) CreateUser(ctx context.Context, ev event.AccountCreatedEventData) error {
+	if err := p.IAccountService.CreateUser(ctx, ev); err != nil {
+		return err
+	}
+	p.created <- ev
+	return nil
+}
+
+// TestRegisterProvisionsAccount runs the register flow through the in-memory broker with both databases mocked, so
+// unlike the rest of the package it needs neither Postgres nor the integration tag
+func TestRegisterProvisionsAccount(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := rabbitmq.NewMemoryBroker()
+
+	authDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer authDB.Close(ctx)
+
+	accountDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer accountDB.Close(ctx)
+
+	authDB.ExpectQuery("INSERT INTO users").
+		WithArgs("integration@gmail.com", pgxmock.AnyArg(), "integration").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(7))
+	accountDB.ExpectQuery("INSERT INTO users").
+		WithArgs(7, "integration@gmail.com", "integration").
+		WillReturnRows(pgxmock.NewRows([]string{}))
+
+	accounts := &provisioning{
+		IAccountService: accountservice.NewAccountService(accountDB),
+		created:         make(chan event.AccountCreatedEventData, 1),
+	}
+	account := accountclient.New("amqp://memory", logger, accounts, rabbitmq.NewClientConnection(logger).WithDialer(broker.Dial))
+	account.Consume(ctx)
+	defer account.Close()
+
+	auth := authclient.New("amqp://memory", logger, rabbitmq.NewClientConnection(logger).WithDialer(broker.Dial))
+	defer auth.Close()
+
+	sut := &authservice.AuthService{DB: authDB, TokenService: &authmock.TokenServiceMock{}, AccountRabbitClient: auth}
+
+	_, _, id, err := sut.Register(ctx, "integration@gmail.com", "password", "integration")
+	if err != nil || id != 7 {
+		t.Fatalf("expected user 7 to be registered, instead got: %d, %v", id, err)
+	}
+
+	select {
+	case created := <-accounts.created:
+		if created.ID != 7 || created.Username != "integration" {
+			t.Fatalf("expected the account of user 7, instead got: %+v", created)
+		}
+	case <-time.After(provisionTimeout):
+		t.Fatal("expected the account to be provisioned from the event")
+	}
+
+	if err := authDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+	if err := accountDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,263 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	accountapi "nikolamilovic/twitchy/accounts/api"
+	accountclient "nikolamilovic/twitchy/accounts/client"
+	"nikolamilovic/twitchy/accounts/model"
+	"nikolamilovic/twitchy/accounts/payment"
+	accountservice "nikolamilovic/twitchy/accounts/service"
+	authapi "nikolamilovic/twitchy/auth/api"
+	authclient "nikolamilovic/twitchy/auth/client"
+	"nikolamilovic/twitchy/auth/model/response"
+	authservice "nikolamilovic/twitchy/auth/service"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/health"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/ratelimit"
+	"nikolamilovic/twitchy/common/token"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var jwtSecret = []byte("integration-secret")
+
+// startAuth runs the auth service on a database of its own and returns its URL
+func startAuth(t *testing.T, broker *rabbitmq.MemoryBroker) string {
+	logger := zap.NewNop().Sugar()
+	ctx := context.Background()
+
+	conn, cleanup, err := db.InitDb(ctx, pg.createDatabase(t, "auth", "../auth/db/migrations"), logger)
+	if err != nil {
+		t.Fatalf("failed to init the auth database: %v", err)
+	}
+	t.Cleanup(func() { cleanup() })
+
+	connection := rabbitmq.NewClientConnection(logger).WithDialer(broker.Dial)
+	client := authclient.New("amqp://integration", logger, connection)
+	client.Consume(ctx, authservice.NewRoleService(conn), authservice.NewSuspensionService(conn))
+	t.Cleanup(func() { client.Close() })
+
+	srv, err := authapi.NewServer(conn, client, ratelimit.NewMemoryStore(), health.New(), jwtSecret, logger)
+	if err != nil {
+		t.Fatalf("failed to create the auth server: %v", err)
+	}
+
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// startAccount runs the account service on a database of its own and returns its URL
+func startAccount(t *testing.T, broker *rabbitmq.MemoryBroker) string {
+	logger := zap.NewNop().Sugar()
+	ctx := context.Background()
+
+	conn, cleanup, err := db.InitDb(ctx, pg.createDatabase(t, "account", "../account/db/migrations"), logger)
+	if err != nil {
+		t.Fatalf("failed to init the account database: %v", err)
+	}
+	t.Cleanup(func() { cleanup() })
+
+	accounts := accountservice.NewAccountService(conn)
+
+	connection := rabbitmq.NewClientConnection(logger).WithDialer(broker.Dial)
+	client := accountclient.New("amqp://integration", logger, accounts, connection)
+	client.Consume(ctx)
+	t.Cleanup(func() { client.Close() })
+
+	app := accountapi.NewServer(accounts,
+		accountservice.NewFollowService(conn, client),
+		accountservice.NewSubscriptionService(conn, payment.NewFakeProvider(), client, logger),
+		accountservice.NewRoleService(conn, client),
+		accountservice.NewModerationService(conn, client),
+		accountservice.NewProfileService(conn, client),
+		audit.NewLog(conn), ratelimit.NewMemoryStore(), health.New(), jwtSecret, logger)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	return "http://" + listener.Addr().String()
+}
+
+// services are the URLs of the auth and account services sharing a broker
+type services struct {
+	auth    string
+	account string
+}
+
+func startServices(t *testing.T) services {
+	broker := rabbitmq.NewMemoryBroker()
+	return services{auth: startAuth(t, broker), account: startAccount(t, broker)}
+}
+
+// request sends body to url with the bearer jwt if there's one, and decodes the response into out on a 200
+func request(t *testing.T, method, url, jwt, body string, out interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+jwt)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode the response of %s %s: %v", method, url, err)
+		}
+	} else {
+		io.Copy(io.Discard, res.Body)
+	}
+	return res.StatusCode
+}
+
+const credentials = `{"email":"integration@gmail.com","password":"password"}`
+
+// register signs up the integration user
+func (s services) register(t *testing.T) response.AuthResponse {
+	var registered response.AuthResponse
+	code := request(t, http.MethodPost, s.auth+"/v1/auth/register", "",
+		`{"email":"integration@gmail.com","password":"password","username":"integration"}`, &registered)
+	if code != http.StatusOK {
+		t.Fatalf("expected the registration to succeed, instead got: %d", code)
+	}
+	return registered
+}
+
+func (s services) userURL(id int) string {
+	return s.account + "/api/accounts/" + strconv.Itoa(id)
+}
+
+// waitForAccount returns the account once the account service provisioned it from the account_created event
+func (s services) waitForAccount(t *testing.T, id int) model.User {
+	var user model.User
+	deadline := time.Now().Add(provisionTimeout)
+	for request(t, http.MethodGet, s.userURL(id), "", "", &user) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the account of user %d to be provisioned", id)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return user
+}
+
+func TestAccountProvisionedFromEvent(t *testing.T) {
+	s := startServices(t)
+
+	// Auth owns the users, the account service only learns about them from the event
+	if code := request(t, http.MethodGet, s.userURL(1), "", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected a %d before registering, instead got: %d", http.StatusNotFound, code)
+	}
+
+	registered := s.register(t)
+
+	user := s.waitForAccount(t, registered.ID)
+	if user.ID != registered.ID || user.Username != "integration" {
+		t.Fatalf("expected the account of integration, instead got: %+v", user)
+	}
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	s := startServices(t)
+	registered := s.register(t)
+	s.waitForAccount(t, registered.ID)
+
+	var loggedIn response.AuthResponse
+	code := request(t, http.MethodPost, s.auth+"/v1/auth/login", "", credentials, &loggedIn)
+	if code != http.StatusOK || loggedIn.ID != registered.ID {
+		t.Fatalf("expected user %d to log in, instead got: %d, %+v", registered.ID, code, loggedIn)
+	}
+
+	var refreshed response.RefreshResponse
+	code = request(t, http.MethodPost, s.auth+"/v1/auth/refresh", "", `{"refresh_token":"`+loggedIn.RefreshToken+`"}`, &refreshed)
+	if code != http.StatusOK || refreshed.JWT == "" || refreshed.RefreshToken == "" {
+		t.Fatalf("expected the tokens to be refreshed, instead got: %d, %+v", code, refreshed)
+	}
+
+	claims, err := token.ParseUserClaims(refreshed.JWT, jwtSecret)
+	if err != nil || claims.UserId != registered.ID {
+		t.Fatalf("expected a token of user %d, instead got: %+v, %v", registered.ID, claims, err)
+	}
+
+	// A refresh token is replaced once used
+	if code := request(t, http.MethodPost, s.auth+"/v1/auth/refresh", "", `{"refresh_token":"`+loggedIn.RefreshToken+`"}`, nil); code == http.StatusOK {
+		t.Fatal("expected the used refresh token to be refused")
+	}
+
+	// The account service accepts the tokens auth issued
+	code = request(t, http.MethodPut, s.userURL(registered.ID)+"/profile", refreshed.JWT, `{"display_name":"Integration","bio":"bio"}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected the profile to be updated, instead got: %d", code)
+	}
+	if code := request(t, http.MethodPut, s.userURL(registered.ID)+"/profile", "", `{"display_name":"Anonymous"}`, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected a %d without a token, instead got: %d", http.StatusUnauthorized, code)
+	}
+
+	var user model.User
+	if request(t, http.MethodGet, s.userURL(registered.ID), "", "", &user) != http.StatusOK || user.DisplayName != "Integration" {
+		t.Fatalf("expected the updated profile, instead got: %+v", user)
+	}
+}
+
+func TestLoginWithWrongPassword(t *testing.T) {
+	s := startServices(t)
+	s.register(t)
+
+	for description, body := range map[string]string{
+		"wrong password": `{"email":"integration@gmail.com","password":"wrong password"}`,
+		"unknown email":  `{"email":"unknown@gmail.com","password":"password"}`,
+	} {
+		var res response.AuthResponse
+		if code := request(t, http.MethodPost, s.auth+"/v1/auth/login", "", body, &res); code == http.StatusOK || res.JWT != "" {
+			t.Fatalf("expected the login with an %s to fail, instead got: %d, %+v", description, code, res)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	s := startServices(t)
+	registered := s.register(t)
+	s.waitForAccount(t, registered.ID)
+
+	for description, body := range map[string]string{
+		"same email":    `{"email":"integration@gmail.com","password":"password","username":"other"}`,
+		"same username": `{"email":"other@gmail.com","password":"password","username":"integration"}`,
+	} {
+		var res response.AuthResponse
+		if code := request(t, http.MethodPost, s.auth+"/v1/auth/register", "", body, &res); code == http.StatusOK {
+			t.Fatalf("expected registering with the %s to fail, instead got: %d, %+v", description, code, res)
+		}
+	}
+
+	// Nothing was provisioned for the refused registrations, the first account is untouched
+	var loggedIn response.AuthResponse
+	if code := request(t, http.MethodPost, s.auth+"/v1/auth/login", "", credentials, &loggedIn); code != http.StatusOK || loggedIn.ID != registered.ID {
+		t.Fatalf("expected user %d to log in, instead got: %d, %+v", registered.ID, code, loggedIn)
+	}
+	if code := request(t, http.MethodGet, s.userURL(registered.ID+1), "", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected no other account, instead got: %d", code)
+	}
+}
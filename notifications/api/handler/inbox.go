@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/model/response"
+	"nikolamilovic/twitchy/notifications/service"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	// Comments keep proxies from closing an idle stream
+	heartbeatInterval = 15 * time.Second
+)
+
+type InboxHandler struct {
+	router       *chi.Mux
+	inboxService service.IInboxService
+	hub          service.INotificationHub
+	jwtSecret    []byte
+	logger       *zap.SugaredLogger
+}
+
+func NewInboxHandler(inbox service.IInboxService, hub service.INotificationHub, jwtSecret []byte, logger *zap.SugaredLogger) *InboxHandler {
+	h := &InboxHandler{}
+
+	h.inboxService = inbox
+	h.hub = hub
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *InboxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *InboxHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Get("/", h.handleList())
+	r.Get("/stream", h.handleStream())
+	r.Post("/read", h.handleMarkAllRead())
+	r.Post("/{id}/read", h.handleMarkRead())
+}
+
+func (h *InboxHandler) handleList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		limit := defaultLimit
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit < 1 || limit > maxLimit {
+				http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+				return
+			}
+		}
+
+		notifications, next, err := h.inboxService.ListNotifications(userID, r.URL.Query().Get("cursor"), limit)
+		if errors.Is(err, model.InvalidCursorError) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("failed to list the notifications of user %d: %v", userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		unread, err := h.inboxService.UnreadCount(userID)
+		if err != nil {
+			h.logger.Errorf("failed to count the unread notifications of user %d: %v", userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, response.NotificationsResponse{
+			Notifications: notifications,
+			NextCursor:    next,
+			Unread:        unread,
+		})
+	}
+}
+
+func (h *InboxHandler) handleMarkRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		err = h.inboxService.MarkRead(userID, id)
+		if errors.Is(err, model.NotificationNotFoundError) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.logger.Errorf("failed to mark notification %d of user %d read: %v", id, userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *InboxHandler) handleMarkAllRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		if err := h.inboxService.MarkAllRead(userID); err != nil {
+			h.logger.Errorf("failed to mark the notifications of user %d read: %v", userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleStream pushes new notifications as server-sent events. A reconnecting EventSource sends the id of the last event
+// it received and gets what it missed first.
+func (h *InboxHandler) handleStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// EventSource can't set headers, browsers pass the token in the query instead
+		if r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+r.URL.Query().Get("access_token"))
+		}
+
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribe before catching up so nothing stored in between is lost, duplicates are skipped by id
+		notifications, unsubscribe := h.hub.Subscribe(userID)
+		defer unsubscribe()
+
+		var missed []model.Notification
+		lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+		if err == nil {
+			missed, err = h.inboxService.ListNotificationsAfter(userID, lastID, maxLimit)
+			if err != nil {
+				h.logger.Errorf("failed to list the notifications user %d missed: %v", userID, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// Stop nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		for _, n := range missed {
+			if err := writeEvent(w, n); err != nil {
+				return
+			}
+			lastID = n.ID
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case n := <-notifications:
+				if n.ID <= lastID {
+					continue
+				}
+				if err := writeEvent(w, n); err != nil {
+					return
+				}
+				lastID = n.ID
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, n model.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", n.ID, data)
+	return err
+}
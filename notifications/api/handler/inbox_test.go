@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/model/response"
+	"nikolamilovic/twitchy/notifications/service"
+	"nikolamilovic/twitchy/notifications/service/mock"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestListNotifications(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	srv := NewInboxHandler(&mock.InboxServiceMock{}, service.NewNotificationHub(), []byte(secret), zap.NewNop().Sugar())
+
+	req := httptest.NewRequest(http.MethodGet, "/?limit=10", nil)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.NotificationsResponse
+	json.NewDecoder(w.Result().Body).Decode(&responseData)
+
+	if len(responseData.Notifications) != 1 || responseData.NextCursor != "next" || responseData.Unread != 1 {
+		t.Fatalf("expected a page of notifications, instead got: %+v", responseData)
+	}
+
+	for query, status := range map[string]int{
+		"?limit=101":      http.StatusBadRequest,
+		"?cursor=invalid": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+jwt)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("expected a %d for %q, instead got: %d", want, query, got)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if want, got := http.StatusUnauthorized, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d without a token, instead got: %d", want, got)
+	}
+}
+
+func TestMarkRead(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	inbox := &mock.InboxServiceMock{}
+	srv := NewInboxHandler(inbox, service.NewNotificationHub(), []byte(secret), zap.NewNop().Sugar())
+
+	for path, status := range map[string]int{
+		"/2/read":   http.StatusNoContent,
+		"/3/read":   http.StatusNotFound,
+		"/abc/read": http.StatusNotFound,
+		"/read":     http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer "+jwt)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("expected a %d for %s, instead got: %d", want, path, got)
+		}
+	}
+
+	if len(inbox.Read) != 1 || len(inbox.AllRead) != 1 || inbox.AllRead[0] != 7 {
+		t.Fatalf("expected the notifications of the token owner to be read, instead got: %+v", inbox)
+	}
+}
+
+func TestStream(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	hub := service.NewNotificationHub()
+	ts := httptest.NewServer(NewInboxHandler(&mock.InboxServiceMock{}, hub, []byte(secret), zap.NewNop().Sugar()))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/stream?access_token="+jwt, nil)
+	req.Header.Set("Last-Event-ID", "4")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	defer res.Body.Close()
+
+	if want, got := "text/event-stream", res.Header.Get("Content-Type"); want != got {
+		t.Fatalf("expected a %s, instead got: %s", want, got)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	readEvent := func() model.Notification {
+		var n model.Notification
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "data: ") {
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &n)
+			}
+			if line == "" && n.ID != 0 {
+				return n
+			}
+		}
+		t.Fatalf("expected an event, instead got: %v", scanner.Err())
+		return n
+	}
+
+	// What was missed since the last event comes first
+	if n := readEvent(); n.ID != 5 {
+		t.Fatalf("expected the missed notification 5, instead got: %+v", n)
+	}
+
+	// Already sent while catching up
+	hub.Publish(model.Notification{ID: 5, UserID: 7})
+	hub.Publish(model.Notification{ID: 6, UserID: 7, Channel: "channel"})
+
+	if n := readEvent(); n.ID != 6 || n.Channel != "channel" {
+		t.Fatalf("expected the published notification 6, instead got: %+v", n)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/service"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+type PreferenceHandler struct {
+	router            *chi.Mux
+	validator         *validator.Validate
+	preferenceService service.IPreferenceService
+	jwtSecret         []byte
+	logger            *zap.SugaredLogger
+}
+
+func NewPreferenceHandler(validator *validator.Validate, preferences service.IPreferenceService, jwtSecret []byte, logger *zap.SugaredLogger) *PreferenceHandler {
+	h := &PreferenceHandler{}
+
+	h.validator = validator
+	h.preferenceService = preferences
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *PreferenceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *PreferenceHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Get("/", h.handleGetPreferences())
+	r.Put("/", h.handleUpdatePreferences())
+}
+
+func (h *PreferenceHandler) handleGetPreferences() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		prefs, err := h.preferenceService.GetPreferences(userID)
+		if err != nil {
+			h.logger.Errorf("failed to get the preferences of user %d: %v", userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, prefs)
+	}
+}
+
+func (h *PreferenceHandler) handleUpdatePreferences() http.HandlerFunc {
+	type PreferencesRequest struct {
+		InApp      bool   `json:"in_app"`
+		Email      bool   `json:"email"`
+		WebhookURL string `json:"webhook_url" validate:"omitempty,url,startswith=https://,max=2048"`
+		// WebhookSecret keys the signature of the webhooks
+		WebhookSecret string `json:"webhook_secret" validate:"required_with=WebhookURL,omitempty,min=10,max=100"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authenticate(w, r, h.jwtSecret)
+		if !ok {
+			return
+		}
+
+		var req PreferencesRequest
+
+		if err := utils.DecodeJSONBody(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		prefs := model.Preferences{
+			UserID:     userID,
+			InApp:      req.InApp,
+			Email:      req.Email,
+			WebhookURL: req.WebhookURL,
+		}
+		if req.WebhookURL != "" {
+			prefs.WebhookSecret = req.WebhookSecret
+		}
+
+		if err := h.preferenceService.UpdatePreferences(prefs); err != nil {
+			h.logger.Errorf("failed to update the preferences of user %d: %v", userID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, prefs)
+	}
+}
+
+// authenticate returns the id of the user, forwarded by the gateway or from the bearer token, responding with 401 if
+// it's missing or invalid
+func authenticate(w http.ResponseWriter, r *http.Request, secret []byte) (int, bool) {
+	claims, err := token.FromHeaders(r.Header.Get, secret)
+	if err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	return claims.UserId, true
+}
+
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("failed to write the response: %v", err)
+	}
+}
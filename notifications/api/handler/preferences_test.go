@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/service/mock"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+func TestUpdatePreferences(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type updateTest struct {
+		description    string
+		token          string
+		body           string
+		expectedStatus int
+	}
+
+	for _, scenario := range []updateTest{
+		{
+			description:    "webhook",
+			token:          "Bearer " + jwt,
+			body:           `{"in_app":true,"email":true,"webhook_url":"https://example.com/hook","webhook_secret":"s3cr3t-s3cr3t"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "webhook without a secret",
+			token:          "Bearer " + jwt,
+			body:           `{"in_app":true,"webhook_url":"https://example.com/hook"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "plain http webhook",
+			token:          "Bearer " + jwt,
+			body:           `{"in_app":true,"webhook_url":"http://auth-service/v1/auth/login","webhook_secret":"s3cr3t-s3cr3t"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "missing token",
+			body:           `{"in_app":true}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "invalid webhook",
+			token:          "Bearer " + jwt,
+			body:           `{"in_app":true,"webhook_url":"ftp://example.com"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", scenario.token)
+			w := httptest.NewRecorder()
+
+			preferences := &mock.PreferenceServiceMock{}
+			srv := NewPreferenceHandler(validator.New(), preferences, []byte(secret), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if scenario.expectedStatus != http.StatusOK {
+				return
+			}
+
+			body, _ := io.ReadAll(w.Result().Body)
+			var prefs model.Preferences
+			json.Unmarshal(body, &prefs)
+
+			if len(preferences.Updated) != 1 || preferences.Updated[0].UserID != 7 || !prefs.Email {
+				t.Fatalf("expected the preferences of the token owner to be updated, instead got: %+v", preferences.Updated)
+			}
+
+			// The secret is stored, but never sent back
+			if preferences.Updated[0].WebhookSecret != "s3cr3t-s3cr3t" || strings.Contains(string(body), "s3cr3t") {
+				t.Fatalf("expected the secret to be stored and not returned, instead got: %+v, %s", preferences.Updated[0], body)
+			}
+		})
+	}
+}
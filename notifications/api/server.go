@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/notifications/api/handler"
+	"nikolamilovic/twitchy/notifications/service"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	mux               *chi.Mux
+	validator         *validator.Validate
+	preferenceService service.IPreferenceService
+	inboxService      service.IInboxService
+	hub               service.INotificationHub
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func NewServer(preferences service.IPreferenceService, inbox service.IInboxService, hub service.INotificationHub, jwtSecret []byte, logger *zap.SugaredLogger) (*Server, error) {
+	s := &Server{
+		mux:               chi.NewMux(),
+		validator:         validator.New(),
+		preferenceService: preferences,
+		inboxService:      inbox,
+		hub:               hub,
+	}
+
+	s.mux.Use(metrics.Middleware)
+
+	//Routing
+	preferencesHandler := handler.NewPreferenceHandler(s.validator, s.preferenceService, jwtSecret, logger.Named("preference_handler"))
+	inboxHandler := handler.NewInboxHandler(s.inboxService, s.hub, jwtSecret, logger.Named("inbox_handler"))
+
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/notifications/preferences", preferencesHandler)
+	s.mux.Mount("/v1/notifications", inboxHandler)
+	return s, nil
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/service"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const inboxConsumer = "go-inbox-consumer"
+
+// InboxClient receives every stored notification and hands it to the users connected to this instance.
+// Each instance consumes from its own queue which is deleted with the connection, nothing is kept for instances that are gone
+// as reconnecting users catch up from the inbox.
+type InboxClient struct {
+	hub        service.INotificationHub
+	logger     *zap.SugaredLogger
+	connection *rabbitmq.ClientConnection
+	wg         *sync.WaitGroup
+	stop       context.CancelFunc
+
+	// queue is named again on every reconnect
+	mu    sync.Mutex
+	queue string
+}
+
+func NewInboxClient(addr string, l *zap.SugaredLogger, hub service.INotificationHub, connection *rabbitmq.ClientConnection) *InboxClient {
+	client := InboxClient{
+		logger:     l,
+		hub:        hub,
+		connection: connection,
+		wg:         &sync.WaitGroup{},
+	}
+
+	go client.connection.HandleReconnect(addr, client.connect)
+	return &client
+}
+
+func (c *InboxClient) Consume(cancelCtx context.Context) {
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			err := c.stream(ctx)
+			if errors.Is(err, rabbitmq.ErrDisconnected) {
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
+			break
+		}
+	}()
+}
+
+func (c *InboxClient) connect(ch rabbitmq.Channel) bool {
+	err := ch.ExchangeDeclare(constants.NotificationsExchange, "topic", true, false, false, false, nil)
+	if err != nil {
+		c.logger.Errorf("failed to declare exchange %s: %v", constants.NotificationsExchange, err)
+		return false
+	}
+
+	q, err := ch.QueueDeclare(
+		"",    // Named by the server
+		false, // Durable
+		true,  // Delete when unused
+		true,  // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare the inbox queue: %v", err)
+		return false
+	}
+
+	err = ch.QueueBind(q.Name, constants.NotificationCreatedKey, constants.NotificationsExchange, false, nil)
+	if err != nil {
+		c.logger.Errorf("failed to bind %s to the inbox queue: %v", constants.NotificationCreatedKey, err)
+		return false
+	}
+
+	c.mu.Lock()
+	c.queue = q.Name
+	c.mu.Unlock()
+
+	return true
+}
+
+// stream uses a single consumer so the notifications of a user are pushed in order
+func (c *InboxClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
+
+	var msgs <-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		c.mu.Lock()
+		queue := c.queue
+		c.mu.Unlock()
+
+		var err error
+		msgs, err = ch.Consume(
+			queue,
+			inboxConsumer, // Consumer
+			false,         // Auto-Ack
+			true,          // Exclusive
+			false,         // No-local
+			false,         // No-Wait
+			nil,           // Args
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return rabbitmq.ErrDisconnected
+			}
+			// The queue is named by the broker for each instance, the metrics use one label for all of them
+			metrics.Consume("inbox", msg, c.parseEvent)
+		}
+	}
+}
+
+func (c *InboxClient) parseEvent(msg amqp.Delivery) {
+	l := c.logger.Named("parseEvent")
+	startTime := time.Now()
+
+	var evt event.BaseEvent
+	err := json.Unmarshal(msg.Body, &evt)
+	if err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
+		return
+	}
+
+	if evt.Type != event.NotificationCreatedType {
+		msg.Reject(false)
+		return
+	}
+
+	payload := &event.NotificationCreatedEventData{}
+	if err := json.Unmarshal([]byte(evt.Payload), payload); err != nil {
+		logAndNack(msg, l, startTime, "%s", err.Error())
+		return
+	}
+
+	c.hub.Publish(model.Notification{
+		ID:        payload.ID,
+		UserID:    payload.UserID,
+		Type:      payload.Type,
+		Channel:   payload.Channel,
+		SessionID: payload.SessionID,
+		Title:     payload.Title,
+		CreatedAt: payload.CreatedAt,
+	})
+
+	msg.Ack(false)
+}
+
+func (c *InboxClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	if err := c.connection.Close(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("gracefully stopped the inbox rabbitMQ connection")
+	return nil
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"nikolamilovic/twitchy/notifications/service"
+	"nikolamilovic/twitchy/notifications/service/mock"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEventFollowed(t *testing.T) {
+	//Set up test
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.NotificationServiceMock{}
+	client := &NotificationClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	//WHEN
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"user_followed",
+ 	  "payload":"{\"follower_id\":2,\"followed_id\":1}"
+		}`),
+		},
+	)
+
+	//SHOULD
+	if len(service.Follows) != 1 || service.Follows[0].FollowerID != 2 || service.Follows[0].FollowedID != 1 {
+		t.Fatalf("Expected the follow to be stored, got %+v", service.Follows)
+	}
+}
+
+func TestParseEventStatusChanged(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.NotificationServiceMock{}
+	client := &NotificationClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"stream_status_changed",
+ 	  "payload":"{\"session_id\":\"abc\",\"channel_id\":1,\"channel\":\"channel\",\"status\":\"live\"}"
+		}`),
+		},
+	)
+
+	if len(service.Statuses) != 1 || service.Statuses[0].SessionID != "abc" || service.Statuses[0].Status != event.StreamStatusLive {
+		t.Fatalf("Expected the status change to be handled, got %+v", service.Statuses)
+	}
+}
+
+func TestParseEventRejectUnknown(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &NotificationClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.NotificationServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Reject(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body:         []byte(`{"type":"stream_started","payload":"{}"}`),
+		},
+	)
+}
+
+func TestInboxParseEvent(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	hub := service.NewNotificationHub()
+	notifications, unsubscribe := hub.Subscribe(3)
+	defer unsubscribe()
+
+	client := &InboxClient{
+		logger: zap.L().Sugar().Named("test"),
+		hub:    hub,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"notification_created",
+ 	  "payload":"{\"id\":10,\"user_id\":3,\"type\":\"stream_live\",\"channel\":\"channel\"}"
+		}`),
+		},
+	)
+
+	select {
+	case n := <-notifications:
+		if n.ID != 10 || n.Channel != "channel" {
+			t.Fatalf("Expected notification 10, got %+v", n)
+		}
+	default:
+		t.Fatalf("Expected the notification to reach the user")
+	}
+}
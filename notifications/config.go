@@ -0,0 +1,25 @@
+package main
+
+import (
+	"nikolamilovic/twitchy/common/config"
+)
+
+// Config is read from the environment, or the YAML file at CONFIG_FILE
+type Config struct {
+	Port      int    `env:"PORT" yaml:"port" default:"80"`
+	JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+	// TrustGateway takes the user the gateway verified from its headers, for services only reachable through it
+	TrustGateway bool `env:"TRUST_GATEWAY" yaml:"trust_gateway"`
+	// SMTP sends the emails when its host is set, otherwise they're only logged
+	SMTP     SMTP            `yaml:"smtp"`
+	Postgres config.Postgres `yaml:"postgres"`
+	RabbitMQ config.RabbitMQ `yaml:"rabbitmq"`
+}
+
+type SMTP struct {
+	Host     string `env:"SMTP_HOST" yaml:"host"`
+	Port     string `env:"SMTP_PORT" yaml:"port"`
+	User     string `env:"SMTP_USER" yaml:"user"`
+	Password string `env:"SMTP_PASSWORD" yaml:"password"`
+	From     string `env:"SMTP_FROM" yaml:"from"`
+}
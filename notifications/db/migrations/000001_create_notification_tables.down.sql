@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS fanouts;
+DROP TABLE IF EXISTS preferences;
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS users;
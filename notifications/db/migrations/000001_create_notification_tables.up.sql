@@ -0,0 +1,55 @@
+-- Users and follows are replicated from the account events
+CREATE TABLE IF NOT EXISTS users(
+   id INTEGER PRIMARY KEY,
+   username VARCHAR(50),
+   email VARCHAR(300) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS follows(
+   followed_id INTEGER NOT NULL,
+   follower_id INTEGER NOT NULL,
+   PRIMARY KEY (followed_id, follower_id)
+);
+
+CREATE TABLE IF NOT EXISTS preferences(
+   user_id INTEGER PRIMARY KEY,
+   in_app BOOLEAN NOT NULL DEFAULT TRUE,
+   email BOOLEAN NOT NULL DEFAULT FALSE,
+   webhook_url VARCHAR(2048) NOT NULL DEFAULT ''
+);
+
+-- A fan-out walks the followers of a channel that went live in batches, last_follower_id is where the next batch starts
+CREATE TABLE IF NOT EXISTS fanouts(
+   session_id VARCHAR(16) PRIMARY KEY,
+   channel_id INTEGER NOT NULL,
+   channel VARCHAR(50) NOT NULL,
+   title VARCHAR(140) NOT NULL DEFAULT '',
+   category VARCHAR(100) NOT NULL DEFAULT '',
+   started_at TIMESTAMPTZ NOT NULL,
+   last_follower_id INTEGER NOT NULL DEFAULT 0,
+   done BOOLEAN NOT NULL DEFAULT FALSE,
+   leased_until TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS fanouts_pending_idx ON fanouts (created_at) WHERE NOT done;
+
+-- Every follower is notified at most once per stream session
+CREATE TABLE IF NOT EXISTS deliveries(
+   session_id VARCHAR(16) NOT NULL,
+   user_id INTEGER NOT NULL,
+   PRIMARY KEY (session_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications(
+   id BIGSERIAL PRIMARY KEY,
+   user_id INTEGER NOT NULL,
+   type VARCHAR(50) NOT NULL,
+   channel VARCHAR(50) NOT NULL,
+   session_id VARCHAR(16) NOT NULL,
+   title VARCHAR(140) NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   read_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE preferences DROP COLUMN IF EXISTS webhook_secret;
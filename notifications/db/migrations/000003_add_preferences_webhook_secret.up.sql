@@ -0,0 +1,4 @@
+ALTER TABLE preferences ADD COLUMN IF NOT EXISTS webhook_secret VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Webhooks are signed with the secret and must be https now, the ones set up before have neither and are set up again
+UPDATE preferences SET webhook_url = '' WHERE webhook_secret = '';
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"nikolamilovic/twitchy/common/config"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/notifications/api"
+	"nikolamilovic/twitchy/notifications/client"
+	"nikolamilovic/twitchy/notifications/notifier"
+	"nikolamilovic/twitchy/notifications/service"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Keep within what the SMTP relay and the users' endpoints tolerate
+	emailsPerSecond   = 10
+	webhooksPerSecond = 50
+	dispatchIdle      = 2 * time.Second
+	// How long shutdown waits for the queued webhooks to be posted
+	webhookDrainTimeout = 10 * time.Second
+)
+
+var (
+	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
+	shutdowns []func() error
+)
+
+func main() {
+	var (
+		shutdown = make(chan struct{})
+		ctx      = context.Background()
+		sigint   = make(chan os.Signal, 1)
+	)
+
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+	token.TrustGateway(cfg.TrustGateway)
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
+	if err != nil {
+		logger.Fatal("failed to init the db", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
+
+	notificationService := service.NewNotificationService(dbConn)
+
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
+	notificationClient := client.New(amqpServerURL, logger.Sugar().Named("notifications_rabbitmq_client"), notificationService, clientConnection)
+	notificationClient.Consume(ctx)
+
+	// Stored notifications reach the users connected to any instance through the broker
+	hub := service.NewNotificationHub()
+	inboxConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("inbox_client_connection"))
+	inboxClient := client.NewInboxClient(amqpServerURL, logger.Sugar().Named("inbox_rabbitmq_client"), hub, inboxConnection)
+	inboxClient.Consume(ctx)
+
+	webhookNotifier := notifier.NewWebhookNotifier(notifier.NewLimiter(webhooksPerSecond, webhooksPerSecond),
+		notifier.DefaultWebhookWorkers, logger.Sugar().Named("webhook_notifier"))
+	notifiers := []notifier.Notifier{
+		notifier.NewInboxNotifier(dbConn, notificationClient),
+		webhookNotifier,
+		notifier.NewEmailNotifier(initMailer(cfg.SMTP), notifier.NewLimiter(emailsPerSecond, emailsPerSecond), logger.Sugar().Named("email_notifier")),
+	}
+
+	dispatcher := service.NewDispatcher(dbConn, notifiers, logger.Sugar().Named("dispatcher"))
+
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	go dispatcher.Run(dispatchCtx, dispatchIdle)
+
+	srv, err := api.NewServer(service.NewPreferenceService(dbConn), service.NewInboxService(dbConn), hub, []byte(cfg.JWTSecret), logger.Sugar().Named("server"))
+	if err != nil {
+		logger.Fatal("Unable to initialize the server", zap.Error(err))
+		os.Exit(1)
+	}
+
+	port := fmt.Sprintf(":%d", cfg.Port)
+	// Notification streams never go idle on their own, they're cancelled when the server shuts down
+	requestCtx, cancelRequests := context.WithCancel(ctx)
+	server := http.Server{
+		Addr:    port,
+		Handler: srv,
+		BaseContext: func(net.Listener) context.Context {
+			return requestCtx
+		},
+	}
+	server.RegisterOnShutdown(cancelRequests)
+
+	shutdowns = append(shutdowns, func() error {
+		stopDispatch()
+		return nil
+	}, func() error {
+		drainCtx, cancel := context.WithTimeout(context.Background(), webhookDrainTimeout)
+		defer cancel()
+		return webhookNotifier.Close(drainCtx)
+	}, inboxClient.Close, notificationClient.Close, dbCleanup)
+
+	defer logger.Sync()
+
+	go gracefulShutdown(&server, shutdown, ctx, sigint)
+
+	logger.Info("Server starting and listening at port " + server.Addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal("server error", zap.Error(err))
+	}
+}
+
+// initMailer sends emails through SMTP_HOST when it's set, otherwise they're only logged
+func initMailer(smtp SMTP) notifier.Mailer {
+	if smtp.Host == "" {
+		return notifier.NewLogMailer(logger.Sugar().Named("mailer"))
+	}
+
+	return notifier.NewSMTPMailer(smtp.Host, smtp.Port, smtp.User, smtp.Password, smtp.From)
+}
+
+func gracefulShutdown(server *http.Server, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+
+	logger.Info("shutting down server gracefully")
+
+	// stop receiving any request.
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("shutdown error", zap.Error(err))
+	}
+
+	// close any other modules.
+	for i := range shutdowns {
+		shutdowns[i]()
+	}
+
+	close(shutdown)
+}
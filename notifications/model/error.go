@@ -0,0 +1,8 @@
+package model
+
+import "errors"
+
+var (
+	NotificationNotFoundError = errors.New("notification not found")
+	InvalidCursorError        = errors.New("invalid cursor")
+)
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const NotificationTypeLive = "stream_live"
+
+// Notification is an entry of a user's in-app inbox
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int        `json:"-"`
+	Type      string     `json:"type"`
+	Channel   string     `json:"channel"`
+	SessionID string     `json:"session_id"`
+	Title     string     `json:"title"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// FanOut is the job of notifying the followers of a channel that went live
+type FanOut struct {
+	SessionID      string
+	ChannelID      int
+	Channel        string
+	Title          string
+	Category       string
+	StartedAt      time.Time
+	LastFollowerID int
+}
+
+// Recipient is a follower to notify along with their preferences
+type Recipient struct {
+	Preferences
+	EmailAddress string
+}
@@ -0,0 +1,19 @@
+package model
+
+// Preferences decide how a user is notified, users without stored preferences get the in-app inbox only
+type Preferences struct {
+	UserID int  `json:"-"`
+	InApp  bool `json:"in_app"`
+	Email  bool `json:"email"`
+	// WebhookURL receives a POST for every notification, empty disables the webhook
+	WebhookURL string `json:"webhook_url"`
+	// WebhookSecret signs the webhooks, it's never sent back
+	WebhookSecret string `json:"-"`
+}
+
+func DefaultPreferences(userID int) Preferences {
+	return Preferences{
+		UserID: userID,
+		InApp:  true,
+	}
+}
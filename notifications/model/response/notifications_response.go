@@ -0,0 +1,10 @@
+package response
+
+import "nikolamilovic/twitchy/notifications/model"
+
+type NotificationsResponse struct {
+	Notifications []model.Notification `json:"notifications"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor"`
+	Unread     int    `json:"unread"`
+}
@@ -0,0 +1,97 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"nikolamilovic/twitchy/notifications/model"
+
+	"go.uber.org/zap"
+)
+
+// Mailer sends a plain text email
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// EmailNotifier mails every recipient that opted into emails
+type EmailNotifier struct {
+	Mailer  Mailer
+	Limiter *Limiter
+	logger  *zap.SugaredLogger
+}
+
+func NewEmailNotifier(mailer Mailer, limiter *Limiter, logger *zap.SugaredLogger) *EmailNotifier {
+	return &EmailNotifier{
+		Mailer:  mailer,
+		Limiter: limiter,
+		logger:  logger,
+	}
+}
+
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, fanOut model.FanOut, recipients []model.Recipient) error {
+	subject := fmt.Sprintf("%s is live", fanOut.Channel)
+	body := fmt.Sprintf("%s just went live: %s", fanOut.Channel, fanOut.Title)
+
+	for _, r := range recipients {
+		if !r.Email || r.EmailAddress == "" {
+			continue
+		}
+
+		if err := n.Limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("Notify: %w", err)
+		}
+
+		if err := n.Mailer.Send(ctx, r.EmailAddress, subject, body); err != nil {
+			n.logger.Warnf("email to user %d failed: %v", r.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// LogMailer only logs the emails, for development
+type LogMailer struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogMailer(logger *zap.SugaredLogger) *LogMailer {
+	return &LogMailer{
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.logger.Infof("email to %s: %s - %s", to, subject, body)
+	return nil
+}
+
+// SMTPMailer sends the emails through an SMTP relay
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func NewSMTPMailer(host, port, user, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &SMTPMailer{
+		Addr: host + ":" + port,
+		From: from,
+		Auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", m.From, to, subject, body)
+
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/notifications/model"
+)
+
+// IInboxPublisher is implemented by the rabbitMQ client, the stored notifications are pushed to the connected users through it
+type IInboxPublisher interface {
+	PublishNotificationCreatedEvent(data event.NotificationCreatedEventData) error
+}
+
+// InboxNotifier stores the notifications in the in-app inbox, a whole batch is a single insert
+type InboxNotifier struct {
+	DB        db.PgxIface
+	Publisher IInboxPublisher
+}
+
+func NewInboxNotifier(db db.PgxIface, publisher IInboxPublisher) *InboxNotifier {
+	return &InboxNotifier{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+func (n *InboxNotifier) Name() string {
+	return "inbox"
+}
+
+func (n *InboxNotifier) Notify(ctx context.Context, fanOut model.FanOut, recipients []model.Recipient) error {
+	var users []int32
+	for _, r := range recipients {
+		if r.InApp {
+			users = append(users, int32(r.UserID))
+		}
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	rows, err := n.DB.Query(ctx,
+		"INSERT INTO notifications (user_id, type, channel, session_id, title) SELECT unnest($1::integer[]), $2, $3, $4, $5 RETURNING id, user_id, created_at",
+		users, model.NotificationTypeLive, fanOut.Channel, fanOut.SessionID, fanOut.Title)
+
+	if err != nil {
+		return fmt.Errorf("Notify: %w", err)
+	}
+
+	var created []event.NotificationCreatedEventData
+	for rows.Next() {
+		data := event.NotificationCreatedEventData{
+			Type:      model.NotificationTypeLive,
+			Channel:   fanOut.Channel,
+			SessionID: fanOut.SessionID,
+			Title:     fanOut.Title,
+		}
+		if err := rows.Scan(&data.ID, &data.UserID, &data.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("Notify: %w", err)
+		}
+		created = append(created, data)
+	}
+	rows.Close()
+
+	// The notifications are stored, users that miss the push see them in their inbox
+	for _, data := range created {
+		if err := n.Publisher.PublishNotificationCreatedEvent(data); err != nil {
+			return fmt.Errorf("Notify: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package notifier
+
+import (
+	"context"
+	"nikolamilovic/twitchy/notifications/model"
+)
+
+// Notifier delivers a go-live notification over one channel, it picks the recipients that opted into it
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, fanOut model.FanOut, recipients []model.Recipient) error
+}
@@ -0,0 +1,215 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/webhook"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/service/mock"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(10, 2)
+	l.now = func() time.Time { return now }
+
+	if l.reserve() != 0 || l.reserve() != 0 {
+		t.Fatalf("Expected the burst to pass without waiting")
+	}
+
+	if want, got := 100*time.Millisecond, l.reserve(); want != got {
+		t.Fatalf("Expected to wait %v, got %v", want, got)
+	}
+
+	// The debt is paid and one more token refilled
+	now = now.Add(200 * time.Millisecond)
+	if got := l.reserve(); got != 0 {
+		t.Fatalf("Expected no wait after the refill, got %v", got)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var received []webhookPayload
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := webhook.Signature("s3cr3t-s3cr3t", r.Header.Get(HeaderMessageID), r.Header.Get(HeaderMessageTimestamp), body)
+		if r.Header.Get(HeaderMessageSignature) != signature || r.Header.Get(HeaderMessageID) != "session:1" && r.Header.Get(HeaderMessageID) != "session:4" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var payload webhookPayload
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(NewLimiter(1000, 1000), 2, zap.L().Sugar().Named("test"))
+	// The server is on loopback, which the default client refuses
+	n.Client = srv.Client()
+
+	recipients := []model.Recipient{
+		{Preferences: model.Preferences{UserID: 1, WebhookURL: srv.URL, WebhookSecret: "s3cr3t-s3cr3t"}},
+		{Preferences: model.Preferences{UserID: 2}},
+		// An unreachable webhook doesn't stop the others
+		{Preferences: model.Preferences{UserID: 3, WebhookURL: "https://127.0.0.1:1", WebhookSecret: "s3cr3t-s3cr3t"}},
+		// Plain http isn't sent at all
+		{Preferences: model.Preferences{UserID: 5, WebhookURL: "http" + srv.URL[len("https"):], WebhookSecret: "s3cr3t-s3cr3t"}},
+		{Preferences: model.Preferences{UserID: 4, WebhookURL: srv.URL, WebhookSecret: "s3cr3t-s3cr3t"}},
+	}
+
+	err := n.Notify(context.Background(), model.FanOut{SessionID: "session", Channel: "channel", Title: "title"}, recipients)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when notifying", err)
+	}
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatalf("an error '%s' was not expected when closing", err)
+	}
+
+	if len(received) != 2 || received[0].Channel != "channel" || received[0].Type != model.NotificationTypeLive {
+		t.Fatalf("Expected 2 signed webhooks for the channel, got %+v", received)
+	}
+}
+
+func TestWebhookNotifierRefusesPrivateAddresses(t *testing.T) {
+	var received int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(NewLimiter(1000, 1000), 2, zap.L().Sugar().Named("test"))
+
+	recipients := []model.Recipient{{Preferences: model.Preferences{UserID: 1, WebhookURL: srv.URL, WebhookSecret: "s3cr3t-s3cr3t"}}}
+	if err := n.Notify(context.Background(), model.FanOut{SessionID: "session"}, recipients); err != nil {
+		t.Fatalf("an error '%s' was not expected when notifying", err)
+	}
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatalf("an error '%s' was not expected when closing", err)
+	}
+
+	if received != 0 {
+		t.Fatalf("Expected the webhook on loopback to be refused, got %d requests", received)
+	}
+}
+
+func TestWebhookNotifierDoesNotWaitForSlowEndpoints(t *testing.T) {
+	release := make(chan struct{})
+	var received int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&received, 1)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(NewLimiter(1000, 1000), 4, zap.L().Sugar().Named("test"))
+	n.Client = srv.Client()
+
+	recipients := make([]model.Recipient, 50)
+	for i := range recipients {
+		recipients[i] = model.Recipient{Preferences: model.Preferences{UserID: i, WebhookURL: srv.URL, WebhookSecret: "s3cr3t-s3cr3t"}}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- n.Notify(context.Background(), model.FanOut{SessionID: "session"}, recipients)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when notifying", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Notify to return while the endpoint is still answering")
+	}
+
+	// The queued webhooks time out once the workers are stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := n.Close(ctx); err == nil {
+		t.Fatal("Expected Close to report the webhooks it dropped")
+	}
+	close(release)
+
+	if got := atomic.LoadInt32(&received); got >= 50 {
+		t.Fatalf("Expected the webhooks queued past the timeout to be dropped, got %d", got)
+	}
+}
+
+type mailerMock struct {
+	sent []string
+}
+
+func (m *mailerMock) Send(ctx context.Context, to, subject, body string) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func TestEmailNotifier(t *testing.T) {
+	mailer := &mailerMock{}
+	n := NewEmailNotifier(mailer, NewLimiter(1000, 1000), zap.L().Sugar().Named("test"))
+
+	recipients := []model.Recipient{
+		{Preferences: model.Preferences{UserID: 1, Email: true}, EmailAddress: "one@test.com"},
+		{Preferences: model.Preferences{UserID: 2}, EmailAddress: "two@test.com"},
+	}
+
+	if err := n.Notify(context.Background(), model.FanOut{Channel: "channel"}, recipients); err != nil {
+		t.Fatalf("an error '%s' was not expected when notifying", err)
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0] != "one@test.com" {
+		t.Fatalf("Expected only the opted in user to be mailed, got %v", mailer.sent)
+	}
+}
+
+func TestInboxNotifier(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.InboxPublisherMock{}
+	n := NewInboxNotifier(mockDB, publisher)
+
+	now := time.Now()
+
+	mockDB.ExpectQuery("INSERT INTO notifications").
+		WithArgs([]int32{1, 3}, model.NotificationTypeLive, "channel", "session", "title").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "user_id", "created_at"}).AddRow(int64(10), 1, now).AddRow(int64(11), 3, now))
+
+	recipients := []model.Recipient{
+		{Preferences: model.Preferences{UserID: 1, InApp: true}},
+		{Preferences: model.Preferences{UserID: 2}},
+		{Preferences: model.Preferences{UserID: 3, InApp: true}},
+	}
+
+	err = n.Notify(context.Background(), model.FanOut{SessionID: "session", Channel: "channel", Title: "title"}, recipients)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when notifying", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(publisher.Published) != 2 || publisher.Published[1].ID != 11 || publisher.Published[1].UserID != 3 {
+		t.Fatalf("Expected the stored notifications to be published, got %+v", publisher.Published)
+	}
+}
@@ -0,0 +1,181 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/webhook"
+	"nikolamilovic/twitchy/notifications/model"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	webhookTimeout = 5 * time.Second
+	// Webhooks are posted by a pool of workers, the dispatcher only queues them so slow endpoints don't hold it up
+	DefaultWebhookWorkers = 20
+	// Webhooks queued for the workers, Notify waits for room beyond that
+	webhookQueueSize = 10000
+)
+
+// The headers of every webhook, the signature is computed like the one of the webhooks service: the HMAC-SHA256 of the
+// message id, timestamp and body keyed with the secret of the user
+const (
+	HeaderMessageID        = "Twitchy-Notification-Id"
+	HeaderMessageTimestamp = "Twitchy-Notification-Timestamp"
+	HeaderMessageSignature = "Twitchy-Notification-Signature"
+)
+
+type webhookPayload struct {
+	Type      string    `json:"type"`
+	Channel   string    `json:"channel"`
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// WebhookNotifier POSTs the signed notification to the webhook of every recipient that set one up. The webhooks are
+// only sent to public https addresses, they can't reach the internal services. A failing webhook is logged and
+// skipped, it's the user's endpoint and shouldn't hold up the rest.
+type WebhookNotifier struct {
+	Client  *http.Client
+	Limiter *Limiter
+	jobs    chan webhookJob
+	// pending counts the queued webhooks and the ones being posted
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	stop    context.CancelFunc
+	logger  *zap.SugaredLogger
+}
+
+type webhookJob struct {
+	recipient model.Recipient
+	id        string
+	body      []byte
+}
+
+// NewWebhookNotifier starts the workers posting the webhooks, Close stops them
+func NewWebhookNotifier(limiter *Limiter, workers int, logger *zap.SugaredLogger) *WebhookNotifier {
+	ctx, stop := context.WithCancel(context.Background())
+
+	n := &WebhookNotifier{
+		Client:  webhook.NewClient(webhookTimeout),
+		Limiter: limiter,
+		jobs:    make(chan webhookJob, webhookQueueSize),
+		stop:    stop,
+		logger:  logger,
+	}
+
+	for i := 0; i < workers; i++ {
+		n.workers.Add(1)
+		go n.work(ctx)
+	}
+
+	return n
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify queues the webhooks of the recipients, it returns once they're queued without waiting for them to be posted
+func (n *WebhookNotifier) Notify(ctx context.Context, fanOut model.FanOut, recipients []model.Recipient) error {
+	body, err := json.Marshal(webhookPayload{
+		Type:      model.NotificationTypeLive,
+		Channel:   fanOut.Channel,
+		SessionID: fanOut.SessionID,
+		Title:     fanOut.Title,
+		Category:  fanOut.Category,
+		StartedAt: fanOut.StartedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("Notify: %w", err)
+	}
+
+	for _, r := range recipients {
+		if r.WebhookURL == "" {
+			continue
+		}
+
+		// The same notification has the same id on every attempt, receivers can tell it apart from a new one
+		job := webhookJob{recipient: r, id: fanOut.SessionID + ":" + strconv.Itoa(r.UserID), body: body}
+
+		n.pending.Add(1)
+		select {
+		case n.jobs <- job:
+		case <-ctx.Done():
+			n.pending.Done()
+			return fmt.Errorf("Notify: %w", ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// Close waits for the queued webhooks to be posted until ctx is done, the ones left then are dropped
+func (n *WebhookNotifier) Close(ctx context.Context) error {
+	err := rabbitmq.Wait(ctx, &n.pending)
+
+	n.stop()
+	n.workers.Wait()
+
+	if err != nil {
+		return fmt.Errorf("Close: dropped %d webhooks: %w", len(n.jobs), err)
+	}
+
+	return nil
+}
+
+func (n *WebhookNotifier) work(ctx context.Context) {
+	defer n.workers.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-n.jobs:
+			if err := n.Limiter.Wait(ctx); err == nil {
+				if err := n.post(ctx, job.recipient, job.id, job.body); err != nil {
+					n.logger.Warnf("webhook of user %d failed: %v", job.recipient.UserID, err)
+				}
+			}
+			n.pending.Done()
+		}
+	}
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, r model.Recipient, id string, body []byte) error {
+	if err := webhook.CheckURL(r.WebhookURL); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, id)
+	req.Header.Set(HeaderMessageTimestamp, timestamp)
+	req.Header.Set(HeaderMessageSignature, webhook.Signature(r.WebhookSecret, id, timestamp, body))
+
+	res, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
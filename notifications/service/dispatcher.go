@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/notifications/model"
+	"nikolamilovic/twitchy/notifications/notifier"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultBatchSize = 500
+	// How many fan-outs a round works on, each gets one batch so small channels aren't stuck behind big ones
+	DefaultConcurrentFanOuts = 10
+	// A claimed fan-out is handed to another dispatcher if the batch isn't finished in time
+	DefaultLease = time.Minute
+)
+
+// Dispatcher works through the queued fan-outs, notifying the followers a batch at a time
+type Dispatcher struct {
+	DB        db.PgxIface
+	Notifiers []notifier.Notifier
+	BatchSize int
+	FanOuts   int
+	Lease     time.Duration
+	logger    *zap.SugaredLogger
+}
+
+func NewDispatcher(db db.PgxIface, notifiers []notifier.Notifier, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		DB:        db,
+		Notifiers: notifiers,
+		BatchSize: DefaultBatchSize,
+		FanOuts:   DefaultConcurrentFanOuts,
+		Lease:     DefaultLease,
+		logger:    logger,
+	}
+}
+
+// Run dispatches rounds until ctx is cancelled, sleeping for idle whenever there is nothing to do
+func (d *Dispatcher) Run(ctx context.Context, idle time.Duration) {
+	for {
+		n, err := d.DispatchRound(ctx)
+		if err != nil {
+			d.logger.Errorf("failed to dispatch notifications: %v", err)
+		}
+
+		if n > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(idle):
+		}
+	}
+}
+
+// DispatchRound claims the oldest pending fan-outs and sends one batch of each, returning how many it worked on
+func (d *Dispatcher) DispatchRound(ctx context.Context) (int, error) {
+	fanOuts, err := d.claim(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("DispatchRound: %w", err)
+	}
+
+	for _, fanOut := range fanOuts {
+		if err := d.dispatchBatch(ctx, fanOut); err != nil {
+			return 0, fmt.Errorf("DispatchRound: %w", err)
+		}
+	}
+
+	return len(fanOuts), nil
+}
+
+func (d *Dispatcher) claim(ctx context.Context) ([]model.FanOut, error) {
+	rows, err := d.DB.Query(ctx, `
+		UPDATE fanouts SET leased_until = NOW() + make_interval(secs => $2)
+		WHERE session_id IN (
+			SELECT session_id FROM fanouts WHERE NOT done AND leased_until < NOW() ORDER BY created_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING session_id, channel_id, channel, title, category, started_at, last_follower_id`,
+		d.FanOuts, d.Lease.Seconds())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var fanOuts []model.FanOut
+	for rows.Next() {
+		var f model.FanOut
+		err := rows.Scan(&f.SessionID, &f.ChannelID, &f.Channel, &f.Title, &f.Category, &f.StartedAt, &f.LastFollowerID)
+		if err != nil {
+			return nil, err
+		}
+		fanOuts = append(fanOuts, f)
+	}
+
+	return fanOuts, nil
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context, fanOut model.FanOut) error {
+	recipients, err := d.recipients(ctx, fanOut)
+	if err != nil {
+		return err
+	}
+
+	// A short batch is the last one
+	done := len(recipients) < d.BatchSize
+	lastFollowerID := fanOut.LastFollowerID
+	if len(recipients) > 0 {
+		lastFollowerID = recipients[len(recipients)-1].UserID
+	}
+
+	recipients, err = d.claimDeliveries(ctx, fanOut.SessionID, recipients)
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) > 0 {
+		for _, n := range d.Notifiers {
+			// Deliveries are already claimed, a failing channel only loses its own notifications
+			if err := n.Notify(ctx, fanOut, recipients); err != nil {
+				d.logger.Errorf("%s notifications for session %s failed: %v", n.Name(), fanOut.SessionID, err)
+			}
+		}
+	}
+
+	// The stream may have ended in the meantime, don't undo that
+	_, err = d.DB.Exec(ctx,
+		"UPDATE fanouts SET last_follower_id = $2, done = done OR $3, leased_until = 'epoch' WHERE session_id = $1",
+		fanOut.SessionID, lastFollowerID, done)
+
+	return err
+}
+
+// recipients returns the next batch of followers with their preferences, ordered by id so the batch can resume
+func (d *Dispatcher) recipients(ctx context.Context, fanOut model.FanOut) ([]model.Recipient, error) {
+	rows, err := d.DB.Query(ctx, `
+		SELECT f.follower_id, COALESCE(u.email, ''), COALESCE(p.in_app, true), COALESCE(p.email, false), COALESCE(p.webhook_url, ''), COALESCE(p.webhook_secret, '')
+		FROM follows f
+		LEFT JOIN users u ON u.id = f.follower_id
+		LEFT JOIN preferences p ON p.user_id = f.follower_id
+		WHERE f.followed_id = $1 AND f.follower_id > $2
+		ORDER BY f.follower_id
+		LIMIT $3`,
+		fanOut.ChannelID, fanOut.LastFollowerID, d.BatchSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var recipients []model.Recipient
+	for rows.Next() {
+		var r model.Recipient
+		err := rows.Scan(&r.UserID, &r.EmailAddress, &r.InApp, &r.Email, &r.WebhookURL, &r.WebhookSecret)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, nil
+}
+
+// claimDeliveries records the deliveries of the session and returns only the recipients that weren't notified yet
+func (d *Dispatcher) claimDeliveries(ctx context.Context, session string, recipients []model.Recipient) ([]model.Recipient, error) {
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	users := make([]int32, len(recipients))
+	for i, r := range recipients {
+		users[i] = int32(r.UserID)
+	}
+
+	rows, err := d.DB.Query(ctx,
+		"INSERT INTO deliveries (session_id, user_id) SELECT $1, unnest($2::integer[]) ON CONFLICT DO NOTHING RETURNING user_id",
+		session, users)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	claimed := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		claimed[id] = true
+	}
+
+	fresh := recipients[:0]
+	for _, r := range recipients {
+		if claimed[r.UserID] {
+			fresh = append(fresh, r)
+		}
+	}
+
+	return fresh, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/notifications/notifier"
+	"nikolamilovic/twitchy/notifications/service/mock"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+var (
+	fanOutColumns    = []string{"session_id", "channel_id", "channel", "title", "category", "started_at", "last_follower_id"}
+	recipientColumns = []string{"follower_id", "email", "in_app", "email", "webhook_url", "webhook_secret"}
+)
+
+func TestDispatchRound(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	notifierMock := &mock.NotifierMock{}
+	sut := &Dispatcher{
+		DB:        mockDB,
+		Notifiers: []notifier.Notifier{notifierMock},
+		BatchSize: 2,
+		FanOuts:   10,
+		Lease:     time.Minute,
+		logger:    zap.L().Sugar().Named("test"),
+	}
+
+	startedAt := time.Now()
+
+	mockDB.ExpectQuery("UPDATE fanouts SET leased_until").
+		WithArgs(10, time.Minute.Seconds()).
+		WillReturnRows(pgxmock.NewRows(fanOutColumns).AddRow("session", 1, "channel", "title", "category", startedAt, 4))
+	mockDB.ExpectQuery("SELECT (.+) FROM follows").
+		WithArgs(1, 4, 2).
+		WillReturnRows(pgxmock.NewRows(recipientColumns).
+			AddRow(5, "five@test.com", true, false, "", "").
+			AddRow(6, "six@test.com", true, true, "", ""))
+	// 5 was notified before the previous dispatcher lost its lease
+	mockDB.ExpectQuery("INSERT INTO deliveries").
+		WithArgs("session", []int32{5, 6}).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(6))
+	// A full batch, there may be more followers
+	mockDB.ExpectExec("UPDATE fanouts SET last_follower_id").
+		WithArgs("session", 6, false).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	n, err := sut.DispatchRound(context.Background())
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when dispatching", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if n != 1 {
+		t.Fatalf("Expected %d fan-outs to be dispatched, got %d", 1, n)
+	}
+
+	if len(notifierMock.Batches) != 1 || len(notifierMock.Batches[0]) != 1 || notifierMock.Batches[0][0].UserID != 6 {
+		t.Fatalf("Expected only user 6 to be notified, got %+v", notifierMock.Batches)
+	}
+}
+
+func TestDispatchRoundLastBatch(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	notifierMock := &mock.NotifierMock{}
+	sut := &Dispatcher{
+		DB:        mockDB,
+		Notifiers: []notifier.Notifier{notifierMock},
+		BatchSize: 2,
+		FanOuts:   10,
+		Lease:     time.Minute,
+		logger:    zap.L().Sugar().Named("test"),
+	}
+
+	mockDB.ExpectQuery("UPDATE fanouts SET leased_until").
+		WithArgs(10, time.Minute.Seconds()).
+		WillReturnRows(pgxmock.NewRows(fanOutColumns).AddRow("session", 1, "channel", "title", "category", time.Now(), 6))
+	mockDB.ExpectQuery("SELECT (.+) FROM follows").
+		WithArgs(1, 6, 2).
+		WillReturnRows(pgxmock.NewRows(recipientColumns))
+	mockDB.ExpectExec("UPDATE fanouts SET last_follower_id").
+		WithArgs("session", 6, true).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if _, err := sut.DispatchRound(context.Background()); err != nil {
+		t.Fatalf("an error '%s' was not expected when dispatching", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(notifierMock.Batches) != 0 {
+		t.Fatalf("Expected nobody to be notified, got %+v", notifierMock.Batches)
+	}
+}
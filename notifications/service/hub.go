@@ -0,0 +1,63 @@
+package service
+
+import (
+	"nikolamilovic/twitchy/notifications/model"
+	"sync"
+)
+
+// A subscriber that falls this far behind starts losing notifications, it can still catch up from the inbox
+const subscriberBuffer = 32
+
+// INotificationHub hands the notifications to the users connected to this instance
+type INotificationHub interface {
+	// Subscribe returns the notifications of the user until the returned function is called
+	Subscribe(userID int) (<-chan model.Notification, func())
+	Publish(n model.Notification)
+}
+
+type NotificationHub struct {
+	mu          sync.RWMutex
+	subscribers map[int]map[chan model.Notification]struct{}
+}
+
+func NewNotificationHub() *NotificationHub {
+	return &NotificationHub{
+		subscribers: make(map[int]map[chan model.Notification]struct{}),
+	}
+}
+
+func (h *NotificationHub) Subscribe(userID int) (<-chan model.Notification, func()) {
+	ch := make(chan model.Notification, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan model.Notification]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userID], ch)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish never blocks, a slow subscriber doesn't hold up the notifications of everyone else
+func (h *NotificationHub) Publish(n model.Notification) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[n.UserID] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/notifications/model"
+	"strconv"
+)
+
+const notificationColumns = "id, user_id, type, channel, session_id, title, created_at, read_at"
+
+type IInboxService interface {
+	// ListNotifications returns a page of the user's notifications, newest first, and the cursor of the next page
+	ListNotifications(userID int, cursor string, limit int) ([]model.Notification, string, error)
+	// ListNotificationsAfter returns the notifications newer than after, oldest first, for clients catching up
+	ListNotificationsAfter(userID int, after int64, limit int) ([]model.Notification, error)
+	UnreadCount(userID int) (int, error)
+	MarkRead(userID int, id int64) error
+	MarkAllRead(userID int) error
+}
+
+type InboxService struct {
+	DB db.PgxIface
+}
+
+func NewInboxService(db db.PgxIface) IInboxService {
+	return &InboxService{
+		DB: db,
+	}
+}
+
+func (s *InboxService) ListNotifications(userID int, cursor string, limit int) ([]model.Notification, string, error) {
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = $1"
+	args := []interface{}{userID}
+
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("ListNotifications: %w", err)
+		}
+		query += " AND id < $2"
+		args = append(args, id)
+	}
+
+	// One extra row tells us whether there is a next page
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args)+1)
+	args = append(args, limit+1)
+
+	notifications, err := s.query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("ListNotifications: %w", err)
+	}
+
+	if len(notifications) <= limit {
+		return notifications, "", nil
+	}
+
+	notifications = notifications[:limit]
+
+	return notifications, encodeCursor(notifications[limit-1].ID), nil
+}
+
+func (s *InboxService) ListNotificationsAfter(userID int, after int64, limit int) ([]model.Notification, error) {
+	notifications, err := s.query(
+		"SELECT "+notificationColumns+" FROM notifications WHERE user_id = $1 AND id > $2 ORDER BY id LIMIT $3",
+		userID, after, limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("ListNotificationsAfter: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (s *InboxService) UnreadCount(userID int) (int, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID)
+	if err != nil {
+		return 0, fmt.Errorf("UnreadCount: %w", err)
+	}
+
+	defer rows.Close()
+
+	var unread int
+	if rows.Next() {
+		err = rows.Scan(&unread)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("UnreadCount: %w", err)
+	}
+
+	return unread, nil
+}
+
+// MarkRead keeps the time the notification was first read, marking it again is a no-op
+func (s *InboxService) MarkRead(userID int, id int64) error {
+	tag, err := s.DB.Exec(context.Background(),
+		"UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2", id, userID)
+
+	if err != nil {
+		return fmt.Errorf("MarkRead: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return model.NotificationNotFoundError
+	}
+
+	return nil
+}
+
+func (s *InboxService) MarkAllRead(userID int) error {
+	_, err := s.DB.Exec(context.Background(),
+		"UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL", userID)
+
+	if err != nil {
+		return fmt.Errorf("MarkAllRead: %w", err)
+	}
+
+	return nil
+}
+
+func (s *InboxService) query(query string, args ...interface{}) ([]model.Notification, error) {
+	rows, err := s.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Channel, &n.SessionID, &n.Title, &n.CreatedAt, &n.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, model.InvalidCursorError
+	}
+
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, model.InvalidCursorError
+	}
+
+	return id, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/notifications/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+var notificationRows = []string{"id", "user_id", "type", "channel", "session_id", "title", "created_at", "read_at"}
+
+func TestListNotifications(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &InboxService{
+		DB: mockDB,
+	}
+
+	now := time.Now()
+
+	mockDB.ExpectQuery("SELECT (.+) FROM notifications WHERE user_id = (.+) AND id <").
+		WithArgs(1, int64(10), 3).
+		WillReturnRows(pgxmock.NewRows(notificationRows).
+			AddRow(int64(9), 1, model.NotificationTypeLive, "channel", "session", "title", now, nil).
+			AddRow(int64(8), 1, model.NotificationTypeLive, "channel", "session", "title", now, &now).
+			AddRow(int64(7), 1, model.NotificationTypeLive, "channel", "session", "title", now, nil))
+
+	notifications, next, err := sut.ListNotifications(1, encodeCursor(10), 2)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listing notifications", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(notifications) != 2 || notifications[1].ReadAt == nil {
+		t.Fatalf("Expected a page of 2 notifications, got %+v", notifications)
+	}
+
+	if id, err := decodeCursor(next); err != nil || id != 8 {
+		t.Fatalf("Expected the next page to start after 8, got %d", id)
+	}
+
+	if _, _, err := sut.ListNotifications(1, "!!", 2); !errors.Is(err, model.InvalidCursorError) {
+		t.Fatalf("Expected %v, got %v", model.InvalidCursorError, err)
+	}
+}
+
+func TestMarkRead(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &InboxService{
+		DB: mockDB,
+	}
+
+	mockDB.ExpectExec("UPDATE notifications SET read_at").WithArgs(int64(5), 1).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	// Somebody else's notification
+	mockDB.ExpectExec("UPDATE notifications SET read_at").WithArgs(int64(6), 1).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if err := sut.MarkRead(1, 5); err != nil {
+		t.Fatalf("an error '%s' was not expected when marking a notification read", err)
+	}
+
+	if err := sut.MarkRead(1, 6); err != model.NotificationNotFoundError {
+		t.Fatalf("Expected %v, got %v", model.NotificationNotFoundError, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNotificationHub(t *testing.T) {
+	hub := NewNotificationHub()
+
+	first, unsubscribe := hub.Subscribe(1)
+	second, _ := hub.Subscribe(1)
+	other, _ := hub.Subscribe(2)
+
+	hub.Publish(model.Notification{ID: 1, UserID: 1})
+
+	for _, ch := range []<-chan model.Notification{first, second} {
+		select {
+		case n := <-ch:
+			if n.ID != 1 {
+				t.Fatalf("Expected notification 1, got %+v", n)
+			}
+		default:
+			t.Fatalf("Expected every subscriber of the user to get the notification")
+		}
+	}
+
+	select {
+	case n := <-other:
+		t.Fatalf("Expected other users not to get the notification, got %+v", n)
+	default:
+	}
+
+	unsubscribe()
+	hub.Publish(model.Notification{ID: 2, UserID: 1})
+
+	if len(first) != 0 || len(second) != 1 {
+		t.Fatalf("Expected only the remaining subscriber to get the notification")
+	}
+
+	// A subscriber that stopped reading doesn't block the publisher
+	for i := 0; i < subscriberBuffer*2; i++ {
+		hub.Publish(model.Notification{ID: int64(i), UserID: 2})
+	}
+}
@@ -0,0 +1,48 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/notifications/model"
+)
+
+type InboxServiceMock struct {
+	Read    []int64
+	AllRead []int
+}
+
+func (s *InboxServiceMock) ListNotifications(userID int, cursor string, limit int) ([]model.Notification, string, error) {
+	if cursor == "invalid" {
+		return nil, "", model.InvalidCursorError
+	}
+	return []model.Notification{{ID: 2, UserID: userID, Type: model.NotificationTypeLive, Channel: "channel"}}, "next", nil
+}
+
+func (s *InboxServiceMock) ListNotificationsAfter(userID int, after int64, limit int) ([]model.Notification, error) {
+	return []model.Notification{{ID: after + 1, UserID: userID, Type: model.NotificationTypeLive, Channel: "channel"}}, nil
+}
+
+func (s *InboxServiceMock) UnreadCount(userID int) (int, error) {
+	return 1, nil
+}
+
+func (s *InboxServiceMock) MarkRead(userID int, id int64) error {
+	if id != 2 {
+		return model.NotificationNotFoundError
+	}
+	s.Read = append(s.Read, id)
+	return nil
+}
+
+func (s *InboxServiceMock) MarkAllRead(userID int) error {
+	s.AllRead = append(s.AllRead, userID)
+	return nil
+}
+
+type InboxPublisherMock struct {
+	Published []event.NotificationCreatedEventData
+}
+
+func (p *InboxPublisherMock) PublishNotificationCreatedEvent(data event.NotificationCreatedEventData) error {
+	p.Published = append(p.Published, data)
+	return nil
+}
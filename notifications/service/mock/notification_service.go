@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/notifications/model"
+)
+
+type NotificationServiceMock struct {
+	Users     []event.AccountCreatedEventData
+	Follows   []event.UserFollowedEventData
+	Unfollows []event.UserUnfollowedEventData
+	Statuses  []event.StreamStatusChangedEventData
+}
+
+func (s *NotificationServiceMock) CreateUser(ev event.AccountCreatedEventData) error {
+	s.Users = append(s.Users, ev)
+	return nil
+}
+
+func (s *NotificationServiceMock) Follow(ev event.UserFollowedEventData) error {
+	s.Follows = append(s.Follows, ev)
+	return nil
+}
+
+func (s *NotificationServiceMock) Unfollow(ev event.UserUnfollowedEventData) error {
+	s.Unfollows = append(s.Unfollows, ev)
+	return nil
+}
+
+func (s *NotificationServiceMock) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	s.Statuses = append(s.Statuses, ev)
+	return nil
+}
+
+// NotifierMock records every batch it was handed
+type NotifierMock struct {
+	Batches [][]model.Recipient
+}
+
+func (n *NotifierMock) Name() string {
+	return "mock"
+}
+
+func (n *NotifierMock) Notify(ctx context.Context, fanOut model.FanOut, recipients []model.Recipient) error {
+	batch := make([]model.Recipient, len(recipients))
+	copy(batch, recipients)
+	n.Batches = append(n.Batches, batch)
+	return nil
+}
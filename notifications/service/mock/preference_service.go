@@ -0,0 +1,16 @@
+package mock
+
+import "nikolamilovic/twitchy/notifications/model"
+
+type PreferenceServiceMock struct {
+	Updated []model.Preferences
+}
+
+func (s *PreferenceServiceMock) GetPreferences(userID int) (model.Preferences, error) {
+	return model.DefaultPreferences(userID), nil
+}
+
+func (s *PreferenceServiceMock) UpdatePreferences(prefs model.Preferences) error {
+	s.Updated = append(s.Updated, prefs)
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+)
+
+// INotificationService keeps the local copy of users and follows up to date and queues a fan-out when a channel goes live
+type INotificationService interface {
+	CreateUser(ev event.AccountCreatedEventData) error
+	Follow(ev event.UserFollowedEventData) error
+	Unfollow(ev event.UserUnfollowedEventData) error
+	StreamStatusChanged(ev event.StreamStatusChangedEventData) error
+}
+
+type NotificationService struct {
+	DB db.PgxIface
+}
+
+func NewNotificationService(db db.PgxIface) INotificationService {
+	return &NotificationService{
+		DB: db,
+	}
+}
+
+func (s *NotificationService) CreateUser(ev event.AccountCreatedEventData) error {
+	_, err := s.DB.Exec(context.Background(),
+		"INSERT INTO users (id, username, email) VALUES ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET username = $2, email = $3",
+		ev.ID, ev.Username, ev.Email)
+
+	if err != nil {
+		return fmt.Errorf("CreateUser: %w", err)
+	}
+
+	return nil
+}
+
+func (s *NotificationService) Follow(ev event.UserFollowedEventData) error {
+	_, err := s.DB.Exec(context.Background(),
+		"INSERT INTO follows (followed_id, follower_id) VALUES ($1,$2) ON CONFLICT DO NOTHING", ev.FollowedID, ev.FollowerID)
+
+	if err != nil {
+		return fmt.Errorf("Follow: %w", err)
+	}
+
+	return nil
+}
+
+func (s *NotificationService) Unfollow(ev event.UserUnfollowedEventData) error {
+	_, err := s.DB.Exec(context.Background(),
+		"DELETE FROM follows WHERE followed_id = $1 AND follower_id = $2", ev.FollowedID, ev.FollowerID)
+
+	if err != nil {
+		return fmt.Errorf("Unfollow: %w", err)
+	}
+
+	return nil
+}
+
+// StreamStatusChanged only queues the fan-out, the dispatcher walks the followers so a huge channel doesn't hold up the queue.
+// A session is queued once no matter how often the event is delivered, and once it ends its fan-out is stopped.
+func (s *NotificationService) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	var err error
+
+	switch ev.Status {
+	case event.StreamStatusLive:
+		_, err = s.DB.Exec(context.Background(),
+			"INSERT INTO fanouts (session_id, channel_id, channel, title, category, started_at) VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT DO NOTHING",
+			ev.SessionID, ev.ChannelID, ev.Channel, ev.Title, ev.Category, ev.StartedAt)
+	case event.StreamStatusEnded:
+		_, err = s.DB.Exec(context.Background(), "UPDATE fanouts SET done = true WHERE session_id = $1", ev.SessionID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("StreamStatusChanged: %w", err)
+	}
+
+	return nil
+}
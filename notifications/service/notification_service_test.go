@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestStreamStatusChanged(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &NotificationService{
+		DB: mockDB,
+	}
+
+	startedAt := time.Now()
+
+	mockDB.ExpectExec("INSERT INTO fanouts").
+		WithArgs("session", 1, "channel", "title", "category", startedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectExec("UPDATE fanouts SET done").
+		WithArgs("session").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = sut.StreamStatusChanged(event.StreamStatusChangedEventData{
+		SessionID: "session",
+		ChannelID: 1,
+		Channel:   "channel",
+		Status:    event.StreamStatusLive,
+		Title:     "title",
+		Category:  "category",
+		StartedAt: startedAt,
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when the stream went live", err)
+	}
+
+	err = sut.StreamStatusChanged(event.StreamStatusChangedEventData{
+		SessionID: "session",
+		ChannelID: 1,
+		Channel:   "channel",
+		Status:    event.StreamStatusEnded,
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when the stream ended", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetPreferencesDefault(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &PreferenceService{
+		DB: mockDB,
+	}
+
+	mockDB.ExpectQuery("SELECT (.+) FROM preferences").WithArgs(1).
+		WillReturnRows(pgxmock.NewRows([]string{"in_app", "email", "webhook_url", "webhook_secret"}))
+
+	prefs, err := sut.GetPreferences(1)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting preferences", err)
+	}
+
+	if !prefs.InApp || prefs.Email || prefs.WebhookURL != "" {
+		t.Fatalf("Expected the default preferences, got %+v", prefs)
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/notifications/model"
+)
+
+type IPreferenceService interface {
+	GetPreferences(userID int) (model.Preferences, error)
+	UpdatePreferences(prefs model.Preferences) error
+}
+
+type PreferenceService struct {
+	DB db.PgxIface
+}
+
+func NewPreferenceService(db db.PgxIface) IPreferenceService {
+	return &PreferenceService{
+		DB: db,
+	}
+}
+
+func (s *PreferenceService) GetPreferences(userID int) (model.Preferences, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT in_app, email, webhook_url, webhook_secret FROM preferences WHERE user_id = $1", userID)
+	if err != nil {
+		return model.Preferences{}, fmt.Errorf("GetPreferences: %w", err)
+	}
+
+	defer rows.Close()
+
+	prefs := model.DefaultPreferences(userID)
+	if !rows.Next() {
+		return prefs, nil
+	}
+
+	if err := rows.Scan(&prefs.InApp, &prefs.Email, &prefs.WebhookURL, &prefs.WebhookSecret); err != nil {
+		return model.Preferences{}, fmt.Errorf("GetPreferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+func (s *PreferenceService) UpdatePreferences(prefs model.Preferences) error {
+	_, err := s.DB.Exec(context.Background(), `
+		INSERT INTO preferences (user_id, in_app, email, webhook_url, webhook_secret) VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT (user_id) DO UPDATE SET in_app = $2, email = $3, webhook_url = $4, webhook_secret = $5`,
+		prefs.UserID, prefs.InApp, prefs.Email, prefs.WebhookURL, prefs.WebhookSecret)
+
+	if err != nil {
+		return fmt.Errorf("UpdatePreferences: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"nikolamilovic/twitchy/search/model/response"
+	"nikolamilovic/twitchy/search/service"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit        = 20
+	maxLimit            = 100
+	maxQueryLength      = 100
+	defaultSuggestLimit = 10
+)
+
+type SearchHandler struct {
+	router        *chi.Mux
+	searchService service.ISearchService
+	logger        *zap.SugaredLogger
+}
+
+func NewSearchHandler(search service.ISearchService, logger *zap.SugaredLogger) *SearchHandler {
+	h := &SearchHandler{}
+
+	h.searchService = search
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *SearchHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Get("/", h.handleSearch())
+	r.Get("/autocomplete", h.handleAutocomplete())
+}
+
+// handleSearch serves ?q= with optional live=true to only find channels that are live, paged with page and limit
+func (h *SearchHandler) handleSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, ok := searchQuery(w, r)
+		if !ok {
+			return
+		}
+
+		page, pageErr := strconv.Atoi(queryParam(r, "page", "1"))
+		limit, limitErr := strconv.Atoi(queryParam(r, "limit", strconv.Itoa(defaultLimit)))
+
+		if pageErr != nil || limitErr != nil || page < 1 || limit < 1 || limit > maxLimit {
+			http.Error(w, "page must be positive and limit between 1 and 100", http.StatusBadRequest)
+			return
+		}
+
+		liveOnly := r.URL.Query().Get("live") == "true"
+
+		results, total, err := h.searchService.Search(query, liveOnly, page, limit)
+		if err != nil {
+			h.logger.Errorf("failed to search for %q: %v", query, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, response.SearchResponse{
+			Results: results,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		})
+	}
+}
+
+func (h *SearchHandler) handleAutocomplete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefix, ok := searchQuery(w, r)
+		if !ok {
+			return
+		}
+
+		limit, err := strconv.Atoi(queryParam(r, "limit", strconv.Itoa(defaultSuggestLimit)))
+		if err != nil || limit < 1 || limit > maxLimit {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+
+		results, err := h.searchService.Autocomplete(prefix, limit)
+		if err != nil {
+			h.logger.Errorf("failed to autocomplete %q: %v", prefix, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, results)
+	}
+}
+
+// searchQuery reads ?q=, it's required and at most 100 characters long
+func searchQuery(w http.ResponseWriter, r *http.Request) (string, bool) {
+	query := r.URL.Query().Get("q")
+
+	if query == "" || utf8.RuneCountInString(query) > maxQueryLength {
+		http.Error(w, "q must be between 1 and 100 characters", http.StatusBadRequest)
+		return "", false
+	}
+
+	return query, true
+}
+
+func queryParam(r *http.Request, key, fallback string) string {
+	if value := r.URL.Query().Get(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("failed to write the response: %v", err)
+	}
+}
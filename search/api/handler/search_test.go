@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/search/model/response"
+	"nikolamilovic/twitchy/search/service/mock"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSearch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?q=nikola&live=true&page=2&limit=5", nil)
+	w := httptest.NewRecorder()
+
+	search := &mock.SearchServiceMock{}
+	NewSearchHandler(search, zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.SearchResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if responseData.Page != 2 || responseData.Limit != 5 || responseData.Total != 1 || len(responseData.Results) != 1 {
+		t.Fatalf("expected the second page of results, instead got: %+v", responseData)
+	}
+
+	if len(search.LiveOnly) != 1 || !search.LiveOnly[0] {
+		t.Fatalf("expected the search to be limited to live channels, instead got: %v", search.LiveOnly)
+	}
+}
+
+func TestSearchInvalid(t *testing.T) {
+	for _, path := range []string{
+		"/",
+		"/?q=" + strings.Repeat("a", 101),
+		"/?q=nikola&page=0",
+		"/?q=nikola&limit=101",
+		"/autocomplete",
+		"/autocomplete?q=nik&limit=0",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			NewSearchHandler(&mock.SearchServiceMock{}, zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+			if want, got := http.StatusBadRequest, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/autocomplete?q=nik", nil)
+	w := httptest.NewRecorder()
+
+	NewSearchHandler(&mock.SearchServiceMock{}, zap.NewNop().Sugar()).ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var results []struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&results); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Username != "nikola" {
+		t.Fatalf("expected nikola to be suggested, instead got: %+v", results)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/search/api/handler"
+	"nikolamilovic/twitchy/search/service"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	mux           *chi.Mux
+	searchService service.ISearchService
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func NewServer(search service.ISearchService, logger *zap.SugaredLogger) (*Server, error) {
+	s := &Server{
+		mux:           chi.NewMux(),
+		searchService: search,
+	}
+
+	s.mux.Use(metrics.Middleware)
+
+	//Routing
+	searchHandler := handler.NewSearchHandler(s.searchService, logger.Named("search_handler"))
+
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/search", searchHandler)
+	return s, nil
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/search/service"
+	"runtime"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long closing waits for the messages being processed
+	closeTimeout = 10 * time.Second
+)
+
+// SearchClient consumes the account events and the stream status changes to keep the search documents fresh
+type SearchClient struct {
+	service    service.IIndexService
+	logger     *zap.SugaredLogger
+	connection *rabbitmq.ClientConnection
+	threads    int
+	wg         *sync.WaitGroup
+	stop       context.CancelFunc
+}
+
+func New(addr string, l *zap.SugaredLogger, service service.IIndexService, connection *rabbitmq.ClientConnection) *SearchClient {
+	threads := runtime.GOMAXPROCS(0)
+	if numCPU := runtime.NumCPU(); numCPU > threads {
+		threads = numCPU
+	}
+
+	client := SearchClient{
+		logger:     l,
+		service:    service,
+		threads:    threads,
+		connection: connection,
+		wg:         &sync.WaitGroup{},
+	}
+
+	go client.connection.HandleReconnect(addr, client.connect)
+	return &client
+}
+
+func (c *SearchClient) Consume(cancelCtx context.Context) {
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			err := c.stream(ctx)
+			if errors.Is(err, rabbitmq.ErrDisconnected) {
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
+			break
+		}
+	}()
+}
+
+func (c *SearchClient) connect(ch rabbitmq.Channel) bool {
+	bindings := map[string][]string{
+		constants.AccountsExchange: {constants.AccountCreatedKey, constants.AccountUpdatedKey},
+		constants.StreamsExchange:  {constants.StreamStatusChangedKey, constants.StreamInfoUpdatedKey},
+	}
+
+	_, err := ch.QueueDeclare(
+		constants.SearchQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.SearchQueue, err)
+		return false
+	}
+
+	for exchange, keys := range bindings {
+		err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil)
+		if err != nil {
+			c.logger.Errorf("failed to declare exchange %s: %v", exchange, err)
+			return false
+		}
+
+		for _, key := range keys {
+			err = ch.QueueBind(constants.SearchQueue, key, exchange, false, nil)
+			if err != nil {
+				c.logger.Errorf("failed to bind %s to the search queue: %v", key, err)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (c *SearchClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
+
+	var deliveries []<-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		if err := ch.Qos(1, 0, false); err != nil {
+			return err
+		}
+
+		for i := 1; i <= c.threads; i++ {
+			msgs, err := ch.Consume(
+				constants.SearchQueue,
+				consumerName(i), // Consumer
+				false,           // Auto-Ack
+				false,           // Exclusive
+				false,           // No-local
+				false,           // No-Wait
+				nil,             // Args
+			)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, msgs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The consumers only stop early when the channel closes
+	var wg sync.WaitGroup
+	for _, msgs := range deliveries {
+		wg.Add(1)
+		go func(msgs <-chan amqp.Delivery) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-msgs:
+					if !ok {
+						return
+					}
+					metrics.Consume(constants.SearchQueue, msg, c.parseEvent)
+				}
+			}
+		}(msgs)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return rabbitmq.ErrDisconnected
+}
+
+func (c *SearchClient) parseEvent(msg amqp.Delivery) {
+	l := c.logger.Named("parseEvent")
+	startTime := time.Now()
+
+	var evt event.BaseEvent
+	err := json.Unmarshal(msg.Body, &evt)
+	if err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
+		return
+	}
+
+	if evt.Payload == "" {
+		logAndNack(msg, l, startTime, "received event without data")
+		return
+	}
+
+	defer func(e event.BaseEvent, m amqp.Delivery, logger *zap.SugaredLogger) {
+		if err := recover(); err != nil {
+			stack := make([]byte, 8096)
+			stack = stack[:runtime.Stack(stack, false)]
+			logger.Error("panic recovery for rabbitMQ message")
+			msg.Nack(false, false)
+		}
+	}(evt, msg, l)
+
+	c.logger.Infof("Received event %v", evt)
+
+	switch evt.Type {
+	case event.AccountCreatedType:
+		payload := &event.AccountCreatedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.AddUser(*payload)
+		}
+	case event.AccountUpdatedType:
+		payload := &event.AccountUpdatedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.UpdateUser(*payload)
+		}
+	case event.StreamStatusChangedType:
+		payload := &event.StreamStatusChangedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.UpdateStream(*payload)
+		}
+	case event.StreamInfoUpdatedType:
+		payload := &event.StreamInfoUpdatedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.UpdateStreamInfo(*payload)
+		}
+	default:
+		msg.Reject(false)
+		return
+	}
+
+	if err != nil {
+		logAndNack(msg, l, startTime, "%s", err.Error())
+		return
+	}
+
+	l.Infof("Took ms %d, succeeded %s", time.Since(startTime).Milliseconds(), evt.Type)
+	msg.Ack(false)
+}
+
+func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string, args ...interface{}) {
+	msg.Nack(false, false)
+	l.Errorf("Took ms %d, %s", time.Since(t).Milliseconds(), fmt.Sprintf(err, args...))
+}
+
+func (c *SearchClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	c.logger.Info("Waiting for current messages to be processed...")
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	if err := c.connection.Close(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("gracefully stopped rabbitMQ connection")
+	return nil
+}
+
+func consumerName(i int) string {
+	return fmt.Sprintf("go-consumer-%v", i)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"nikolamilovic/twitchy/search/service/mock"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEventAccountUpdated(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.IndexServiceMock{}
+	client := &SearchClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"account_updated",
+ 	  "payload":"{\"id\":1,\"username\":\"nik\",\"display_name\":\"Nikola\",\"bio\":\"I stream Go\"}"
+		}`),
+		},
+	)
+
+	if len(service.Updated) != 1 || service.Updated[0].DisplayName != "Nikola" || service.Updated[0].Bio != "I stream Go" {
+		t.Fatalf("Expected the profile to be indexed, got %+v", service.Updated)
+	}
+}
+
+func TestParseEventStatusChanged(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.IndexServiceMock{}
+	client := &SearchClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"stream_status_changed",
+ 	  "payload":"{\"session_id\":\"abc\",\"channel_id\":1,\"channel\":\"nik\",\"status\":\"live\",\"title\":\"Writing a search service\"}"
+		}`),
+		},
+	)
+
+	if len(service.Statuses) != 1 || service.Statuses[0].Title != "Writing a search service" || service.Statuses[0].Status != event.StreamStatusLive {
+		t.Fatalf("Expected the stream to be indexed, got %+v", service.Statuses)
+	}
+}
+
+func TestParseEventStreamInfoUpdated(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.IndexServiceMock{}
+	client := &SearchClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"stream_info_updated",
+ 	  "payload":"{\"channel_id\":1,\"title\":\"Speedrunning\",\"category\":\"chess\",\"tags\":[\"english\"]}"
+		}`),
+		},
+	)
+
+	if len(service.Infos) != 1 || service.Infos[0].Category != "chess" || len(service.Infos[0].Tags) != 1 {
+		t.Fatalf("Expected the stream info to be indexed, got %+v", service.Infos)
+	}
+}
+
+func TestParseEventRejectUnknown(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &SearchClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.IndexServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Reject(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body:         []byte(`{"type":"user_followed","payload":"{}"}`),
+		},
+	)
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"nikolamilovic/twitchy/common/config"
+)
+
+// Config is read from the environment, or the YAML file at CONFIG_FILE
+type Config struct {
+	Port     int             `env:"PORT" yaml:"port" default:"80"`
+	Postgres config.Postgres `yaml:"postgres"`
+	RabbitMQ config.RabbitMQ `yaml:"rabbitmq"`
+}
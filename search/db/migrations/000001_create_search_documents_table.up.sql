@@ -0,0 +1,26 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- One document per user, built from the account and stream events. Usernames are indexed as they are,
+-- the bio and the stream title are stemmed as English.
+CREATE TABLE IF NOT EXISTS search_documents (
+  user_id integer PRIMARY KEY,
+  username varchar(50) NOT NULL DEFAULT '',
+  display_name varchar(50) NOT NULL DEFAULT '',
+  bio varchar(300) NOT NULL DEFAULT '',
+  stream_title text NOT NULL DEFAULT '',
+  live boolean NOT NULL DEFAULT false,
+  -- Time of the last stream event applied, older ones arriving late are ignored
+  stream_updated_at timestamptz,
+  document tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', username), 'A') ||
+    setweight(to_tsvector('simple', display_name), 'A') ||
+    setweight(to_tsvector('english', stream_title), 'B') ||
+    setweight(to_tsvector('english', bio), 'C')
+  ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS search_documents_document_idx ON search_documents USING GIN (document);
+CREATE INDEX IF NOT EXISTS search_documents_username_trgm_idx ON search_documents USING GIN (username gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS search_documents_display_name_trgm_idx ON search_documents USING GIN (display_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS search_documents_username_prefix_idx ON search_documents (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS search_documents_display_name_prefix_idx ON search_documents (lower(display_name) text_pattern_ops);
@@ -0,0 +1,12 @@
+ALTER TABLE search_documents DROP COLUMN document;
+ALTER TABLE search_documents DROP COLUMN IF EXISTS stream_tags;
+ALTER TABLE search_documents DROP COLUMN IF EXISTS stream_category;
+
+ALTER TABLE search_documents ADD COLUMN document tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', username), 'A') ||
+  setweight(to_tsvector('simple', display_name), 'A') ||
+  setweight(to_tsvector('english', stream_title), 'B') ||
+  setweight(to_tsvector('english', bio), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS search_documents_document_idx ON search_documents USING GIN (document);
@@ -0,0 +1,15 @@
+-- Tags are stored space separated, array_to_string isn't immutable so it can't be used by the generated document
+ALTER TABLE search_documents ADD COLUMN IF NOT EXISTS stream_category varchar(100) NOT NULL DEFAULT '';
+ALTER TABLE search_documents ADD COLUMN IF NOT EXISTS stream_tags text NOT NULL DEFAULT '';
+
+ALTER TABLE search_documents DROP COLUMN document;
+ALTER TABLE search_documents ADD COLUMN document tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', username), 'A') ||
+  setweight(to_tsvector('simple', display_name), 'A') ||
+  setweight(to_tsvector('english', stream_title), 'B') ||
+  setweight(to_tsvector('simple', replace(stream_category, '-', ' ')), 'B') ||
+  setweight(to_tsvector('simple', stream_tags), 'B') ||
+  setweight(to_tsvector('english', bio), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS search_documents_document_idx ON search_documents USING GIN (document);
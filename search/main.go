@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nikolamilovic/twitchy/common/config"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/search/api"
+	"nikolamilovic/twitchy/search/client"
+	"nikolamilovic/twitchy/search/service"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+var (
+	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
+	shutdowns []func() error
+)
+
+func main() {
+	var (
+		shutdown = make(chan struct{})
+		ctx      = context.Background()
+		sigint   = make(chan os.Signal, 1)
+	)
+
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
+	if err != nil {
+		logger.Fatal("failed to init the db", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
+
+	searchService := service.NewSearchService(dbConn)
+
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
+	searchClient := client.New(amqpServerURL, logger.Sugar().Named("search_rabbitmq_client"), searchService, clientConnection)
+	searchClient.Consume(ctx)
+
+	srv, err := api.NewServer(searchService, logger.Sugar().Named("server"))
+	if err != nil {
+		logger.Fatal("Unable to initialize the server", zap.Error(err))
+		os.Exit(1)
+	}
+
+	port := fmt.Sprintf(":%d", cfg.Port)
+	server := http.Server{
+		Addr:    port,
+		Handler: srv,
+	}
+
+	shutdowns = append(shutdowns, searchClient.Close, dbCleanup)
+
+	defer logger.Sync()
+
+	go gracefulShutdown(&server, shutdown, ctx, sigint)
+
+	logger.Info("Server starting and listening at port " + server.Addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal("server error", zap.Error(err))
+	}
+}
+
+func gracefulShutdown(server *http.Server, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+
+	logger.Info("shutting down server gracefully")
+
+	// stop receiving any request.
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("shutdown error", zap.Error(err))
+	}
+
+	// close any other modules.
+	for i := range shutdowns {
+		shutdowns[i]()
+	}
+
+	close(shutdown)
+}
@@ -0,0 +1,10 @@
+package response
+
+import "nikolamilovic/twitchy/search/model"
+
+type SearchResponse struct {
+	Results []model.Result `json:"results"`
+	Page    int            `json:"page"`
+	Limit   int            `json:"limit"`
+	Total   int            `json:"total"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// Result is a user matching the search, the stream fields describe the current stream while they are live
+type Result struct {
+	UserID         int      `json:"user_id"`
+	Username       string   `json:"username"`
+	DisplayName    string   `json:"display_name,omitempty"`
+	Bio            string   `json:"bio,omitempty"`
+	StreamTitle    string   `json:"stream_title,omitempty"`
+	StreamCategory string   `json:"stream_category,omitempty"`
+	StreamTags     []string `json:"stream_tags,omitempty"`
+	Live           bool     `json:"live"`
+}
@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/search/model"
+)
+
+type SearchServiceMock struct {
+	Queries  []string
+	LiveOnly []bool
+}
+
+func (s *SearchServiceMock) Search(query string, liveOnly bool, page, limit int) ([]model.Result, int, error) {
+	s.Queries = append(s.Queries, query)
+	s.LiveOnly = append(s.LiveOnly, liveOnly)
+	return []model.Result{{UserID: 1, Username: query, Live: liveOnly}}, 1, nil
+}
+
+func (s *SearchServiceMock) Autocomplete(prefix string, limit int) ([]model.Result, error) {
+	s.Queries = append(s.Queries, prefix)
+	return []model.Result{{UserID: 1, Username: prefix + "ola"}}, nil
+}
+
+type IndexServiceMock struct {
+	Created  []event.AccountCreatedEventData
+	Updated  []event.AccountUpdatedEventData
+	Statuses []event.StreamStatusChangedEventData
+	Infos    []event.StreamInfoUpdatedEventData
+}
+
+func (s *IndexServiceMock) AddUser(ev event.AccountCreatedEventData) error {
+	s.Created = append(s.Created, ev)
+	return nil
+}
+
+func (s *IndexServiceMock) UpdateUser(ev event.AccountUpdatedEventData) error {
+	s.Updated = append(s.Updated, ev)
+	return nil
+}
+
+func (s *IndexServiceMock) UpdateStream(ev event.StreamStatusChangedEventData) error {
+	s.Statuses = append(s.Statuses, ev)
+	return nil
+}
+
+func (s *IndexServiceMock) UpdateStreamInfo(ev event.StreamInfoUpdatedEventData) error {
+	s.Infos = append(s.Infos, ev)
+	return nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/search/model"
+	"strings"
+)
+
+const resultColumns = "user_id, username, display_name, bio, stream_title, stream_category, stream_tags, live"
+
+// searchMatch matches $1 against the full-text document, or by trigram similarity against the names so typos still
+// find the user. $2 restricts the matches to live channels.
+const searchMatch = `
+	FROM search_documents, (SELECT websearch_to_tsquery('simple', $1) || websearch_to_tsquery('english', $1) AS query) q
+	WHERE (document @@ q.query OR username % $1 OR display_name % $1) AND (live OR NOT $2)`
+
+// searchRank weighs the text match and the closest name, live channels come first among equally good matches
+const searchRank = `ts_rank(document, q.query) + GREATEST(similarity(username, $1), similarity(display_name, $1)) + CASE WHEN live THEN 0.1 ELSE 0 END`
+
+type ISearchService interface {
+	// Search returns a page of the users matching the query, best match first, and the total number of matches
+	Search(query string, liveOnly bool, page, limit int) ([]model.Result, int, error)
+	// Autocomplete returns the users whose username or display name starts with the prefix, live channels first
+	Autocomplete(prefix string, limit int) ([]model.Result, error)
+}
+
+// IIndexService keeps the documents up to date from the events of the other services
+type IIndexService interface {
+	AddUser(ev event.AccountCreatedEventData) error
+	UpdateUser(ev event.AccountUpdatedEventData) error
+	UpdateStream(ev event.StreamStatusChangedEventData) error
+	UpdateStreamInfo(ev event.StreamInfoUpdatedEventData) error
+}
+
+type SearchService struct {
+	DB db.PgxIface
+}
+
+func NewSearchService(db db.PgxIface) *SearchService {
+	return &SearchService{
+		DB: db,
+	}
+}
+
+func (s *SearchService) Search(query string, liveOnly bool, page, limit int) ([]model.Result, int, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT count(*)"+searchMatch, query, liveOnly)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Search: %w", err)
+	}
+
+	var total int
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+	rows.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("Search: %w", err)
+	}
+
+	rows, err = s.DB.Query(context.Background(),
+		"SELECT "+resultColumns+searchMatch+" ORDER BY "+searchRank+" DESC, user_id LIMIT $3 OFFSET $4",
+		query, liveOnly, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Search: %w", err)
+	}
+
+	results, err := scanResults(rows)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Search: %w", err)
+	}
+
+	return results, total, nil
+}
+
+func (s *SearchService) Autocomplete(prefix string, limit int) ([]model.Result, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+resultColumns+" FROM search_documents WHERE lower(username) LIKE $1 OR lower(display_name) LIKE $1 ORDER BY live DESC, length(username), username LIMIT $2",
+		likePrefix(strings.ToLower(prefix)), limit)
+	if err != nil {
+		return nil, fmt.Errorf("Autocomplete: %w", err)
+	}
+
+	results, err := scanResults(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Autocomplete: %w", err)
+	}
+
+	return results, nil
+}
+
+// AddUser indexes a new user, a stream event may have created the document already
+func (s *SearchService) AddUser(ev event.AccountCreatedEventData) error {
+	_, err := s.DB.Exec(context.Background(),
+		"INSERT INTO search_documents (user_id, username) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET username = EXCLUDED.username",
+		ev.ID, ev.Username)
+
+	if err != nil {
+		return fmt.Errorf("AddUser: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SearchService) UpdateUser(ev event.AccountUpdatedEventData) error {
+	_, err := s.DB.Exec(context.Background(), `
+		INSERT INTO search_documents (user_id, username, display_name, bio) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET username = EXCLUDED.username, display_name = EXCLUDED.display_name, bio = EXCLUDED.bio`,
+		ev.ID, ev.Username, ev.DisplayName, ev.Bio)
+
+	if err != nil {
+		return fmt.Errorf("UpdateUser: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateStream sets the title, category and tags while the channel is live and clears them once the stream ends.
+// Events older than the last one applied are ignored so a late end can't hide a newer stream.
+func (s *SearchService) UpdateStream(ev event.StreamStatusChangedEventData) error {
+	var (
+		title     string
+		category  string
+		tags      string
+		live      bool
+		changedAt = ev.StartedAt
+	)
+
+	switch ev.Status {
+	case event.StreamStatusLive:
+		title, category, tags, live = ev.Title, ev.Category, strings.Join(ev.Tags, " "), true
+	case event.StreamStatusEnded:
+		if ev.EndedAt != nil {
+			changedAt = *ev.EndedAt
+		}
+	default:
+		return nil
+	}
+
+	_, err := s.DB.Exec(context.Background(), `
+		INSERT INTO search_documents (user_id, username, stream_title, stream_category, stream_tags, live, stream_updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET stream_title = EXCLUDED.stream_title, stream_category = EXCLUDED.stream_category,
+		stream_tags = EXCLUDED.stream_tags, live = EXCLUDED.live, stream_updated_at = EXCLUDED.stream_updated_at
+		WHERE search_documents.stream_updated_at IS NULL OR search_documents.stream_updated_at <= EXCLUDED.stream_updated_at`,
+		ev.ChannelID, ev.Channel, title, category, tags, live, changedAt)
+
+	if err != nil {
+		return fmt.Errorf("UpdateStream: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateStreamInfo applies a title, category or tags change to the live stream of the channel. Offline channels are
+// left alone, the streams service copies the info onto the next session and it is indexed when that goes live.
+func (s *SearchService) UpdateStreamInfo(ev event.StreamInfoUpdatedEventData) error {
+	_, err := s.DB.Exec(context.Background(), `
+		UPDATE search_documents SET stream_title = $2, stream_category = $3, stream_tags = $4, stream_updated_at = $5
+		WHERE user_id = $1 AND live AND (stream_updated_at IS NULL OR stream_updated_at <= $5)`,
+		ev.ChannelID, ev.Title, ev.Category, strings.Join(ev.Tags, " "), ev.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("UpdateStreamInfo: %w", err)
+	}
+
+	return nil
+}
+
+// resultRows is the part of pgx.Rows scanResults needs
+type resultRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Close()
+}
+
+func scanResults(rows resultRows) ([]model.Result, error) {
+	defer rows.Close()
+
+	results := []model.Result{}
+	for rows.Next() {
+		var (
+			result model.Result
+			tags   string
+		)
+		err := rows.Scan(&result.UserID, &result.Username, &result.DisplayName, &result.Bio, &result.StreamTitle,
+			&result.StreamCategory, &tags, &result.Live)
+		if err != nil {
+			return nil, err
+		}
+		result.StreamTags = strings.Fields(tags)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// likePrefix escapes the wildcards of the prefix so it only matches the start of the name
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
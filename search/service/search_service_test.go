@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+var resultRowColumns = []string{"user_id", "username", "display_name", "bio", "stream_title", "stream_category", "stream_tags", "live"}
+
+func TestSearch(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSearchService(mockDB)
+
+	mockDB.ExpectQuery("SELECT count").WithArgs("go", true).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(7))
+	mockDB.ExpectQuery("SELECT user_id, (.+) FROM search_documents").WithArgs("go", true, 5, 5).
+		WillReturnRows(pgxmock.NewRows(resultRowColumns).AddRow(1, "nik", "Nikola", "", "Writing Go", "software-and-game-development", "english go", true))
+
+	results, total, err := sut.Search("go", true, 2, 5)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if total != 7 || len(results) != 1 || results[0].StreamTitle != "Writing Go" || len(results[0].StreamTags) != 2 {
+		t.Fatalf("Expected 1 of 7 results, got %d %+v", total, results)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSearchService(mockDB)
+
+	mockDB.ExpectQuery("SELECT (.+) FROM search_documents WHERE lower").WithArgs(`ni\_k%`, 10).
+		WillReturnRows(pgxmock.NewRows(resultRowColumns).AddRow(1, "ni_kola", "", "", "", "", "", false))
+
+	results, err := sut.Autocomplete("Ni_K", 10)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Username != "ni_kola" {
+		t.Fatalf("Expected ni_kola to be suggested, got %+v", results)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateStream(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSearchService(mockDB)
+
+	startedAt := time.Now()
+	endedAt := startedAt.Add(time.Hour)
+
+	mockDB.ExpectExec("INSERT INTO search_documents").WithArgs(1, "nik", "Writing Go", "chess", "english speed-run", true, startedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectExec("INSERT INTO search_documents").WithArgs(1, "nik", "", "", "", false, endedAt).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	err = sut.UpdateStream(event.StreamStatusChangedEventData{
+		ChannelID: 1,
+		Channel:   "nik",
+		Status:    event.StreamStatusLive,
+		Title:     "Writing Go",
+		Category:  "chess",
+		Tags:      []string{"english", "speed-run"},
+		StartedAt: startedAt,
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when the stream went live", err)
+	}
+
+	err = sut.UpdateStream(event.StreamStatusChangedEventData{
+		ChannelID: 1,
+		Channel:   "nik",
+		Status:    event.StreamStatusEnded,
+		Title:     "Writing Go",
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when the stream ended", err)
+	}
+
+	// Offline sessions haven't started, there is nothing to index
+	err = sut.UpdateStream(event.StreamStatusChangedEventData{ChannelID: 1, Status: event.StreamStatusOffline})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected for an offline session", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateStreamInfo(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSearchService(mockDB)
+
+	updatedAt := time.Now()
+
+	mockDB.ExpectExec("UPDATE search_documents (.+) WHERE user_id = (.+) AND live").
+		WithArgs(1, "Writing Go", "chess", "english speed-run", updatedAt).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = sut.UpdateStreamInfo(event.StreamInfoUpdatedEventData{
+		ChannelID: 1,
+		Title:     "Writing Go",
+		Category:  "chess",
+		Tags:      []string{"english", "speed-run"},
+		UpdatedAt: updatedAt,
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when updating the stream info", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
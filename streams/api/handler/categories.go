@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/model/response"
+	"nikolamilovic/twitchy/streams/service"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CategoryHandler struct {
+	Router          *fiber.App
+	categoryService service.ICategoryService
+	jwtSecret       []byte
+}
+
+func NewCategoryHandler(categories service.ICategoryService, jwtSecret []byte) *CategoryHandler {
+	h := &CategoryHandler{}
+
+	h.categoryService = categories
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *CategoryHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	staff := authz.Fiber(h.jwtSecret, authz.Staff, nil)
+
+	r.Get("/", h.handleGetCategories())
+	r.Get("/:slug", h.handleGetCategory())
+	r.Put("/:slug", staff, h.handleSaveCategory())
+	r.Get("/:slug/streams", h.handleCategoryStreams())
+}
+
+func (h *CategoryHandler) handleGetCategories() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		page, limit, err := pagination(ctx)
+		if err != nil {
+			return err
+		}
+
+		categories, total, err := h.categoryService.GetCategories(page, limit)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(response.CategoriesResponse{
+			Categories: categories,
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+		})
+	}
+}
+
+func (h *CategoryHandler) handleGetCategory() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		category, err := h.categoryService.GetCategory(ctx.Params("slug"))
+		if err != nil {
+			return categoryError(err)
+		}
+
+		return ctx.JSON(category)
+	}
+}
+
+// handleSaveCategory adds the category to the catalogue or edits it, the slug never changes as streams are filed under it
+func (h *CategoryHandler) handleSaveCategory() fiber.Handler {
+	type CategoryRequest struct {
+		Name      string `json:"name"`
+		BoxArtURL string `json:"box_art_url"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		var req CategoryRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if req.Name == "" || utf8.RuneCountInString(req.Name) > 100 {
+			return fiber.NewError(http.StatusBadRequest, "name must be between 1 and 100 characters")
+		}
+
+		category, err := h.categoryService.SaveCategory(model.Category{
+			Slug:      ctx.Params("slug"),
+			Name:      req.Name,
+			BoxArtURL: req.BoxArtURL,
+		})
+		if err != nil {
+			return categoryError(err)
+		}
+
+		return ctx.JSON(category)
+	}
+}
+
+// handleCategoryStreams lists the live streams of the category, the most watched first
+func (h *CategoryHandler) handleCategoryStreams() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		page, limit, err := pagination(ctx)
+		if err != nil {
+			return err
+		}
+
+		category, err := h.categoryService.GetCategory(ctx.Params("slug"))
+		if err != nil {
+			return categoryError(err)
+		}
+
+		streams, total, err := h.categoryService.GetCategoryStreams(category.Slug, page, limit)
+		if err != nil {
+			return categoryError(err)
+		}
+
+		return ctx.JSON(response.CategoryStreamsResponse{
+			Category: category,
+			Streams:  streams,
+			Page:     page,
+			Limit:    limit,
+			Total:    total,
+		})
+	}
+}
+
+func categoryError(err error) error {
+	switch {
+	case errors.Is(err, model.CategoryNotFoundError):
+		return fiber.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, model.InvalidSlugError), errors.Is(err, model.InvalidTagError), errors.Is(err, model.TooManyTagsError):
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	default:
+		return fiber.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
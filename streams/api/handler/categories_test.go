@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/streams/model/response"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"strings"
+	"testing"
+)
+
+func TestCategoryStreams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/chess/streams?limit=5", nil)
+
+	srv := NewCategoryHandler(&mock.CategoryServiceMock{}, []byte("secret"))
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.CategoryStreamsResponse
+	json.Unmarshal(data, &responseData)
+
+	if responseData.Category.Slug != "chess" || responseData.Limit != 5 || len(responseData.Streams) != 1 {
+		t.Fatalf("expected the live streams of the category, instead got: %+v", responseData)
+	}
+}
+
+func TestCategoryStreamsUnknownCategory(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/unknown/streams", nil)
+
+	srv := NewCategoryHandler(&mock.CategoryServiceMock{}, []byte("secret"))
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusNotFound, resp.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+}
+
+func TestSaveCategory(t *testing.T) {
+	secret := "secret"
+	staff, err := test_util.GenerateTokens(1, secret, token.RoleStaff)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	viewer, err := test_util.GenerateTokens(2, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type saveTest struct {
+		description    string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []saveTest{
+		{"staff", "/chess", `{"name":"Chess","box_art_url":"https://cdn.twitchy.dev/chess.png"}`, staff, http.StatusOK},
+		{"viewer", "/chess", `{"name":"Chess"}`, viewer, http.StatusForbidden},
+		{"without name", "/chess", `{}`, staff, http.StatusBadRequest},
+		{"invalid slug", "/Chess", `{"name":"Chess"}`, staff, http.StatusBadRequest},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewCategoryHandler(&mock.CategoryServiceMock{}, []byte(secret))
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/streams/service"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ChannelInfoHandler struct {
+	Router             *fiber.App
+	channelInfoService service.IChannelInfoService
+	jwtSecret          []byte
+}
+
+func NewChannelInfoHandler(info service.IChannelInfoService, jwtSecret []byte) *ChannelInfoHandler {
+	h := &ChannelInfoHandler{}
+
+	h.channelInfoService = info
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *ChannelInfoHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	editor := authz.Fiber(h.jwtSecret, authz.EditorOf, authz.ChannelParam("id"))
+
+	r.Get("/channels/:id/info", h.handleGetChannelInfo())
+	r.Put("/channels/:id/info", editor, h.handleUpdateChannelInfo())
+}
+
+func (h *ChannelInfoHandler) handleGetChannelInfo() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		channelID, err := ctx.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "invalid channel id")
+		}
+
+		info, err := h.channelInfoService.GetChannelInfo(channelID)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(info)
+	}
+}
+
+// handleUpdateChannelInfo replaces the title, category and tags of the channel, editors of the channel may change them too
+func (h *ChannelInfoHandler) handleUpdateChannelInfo() fiber.Handler {
+	type ChannelInfoRequest struct {
+		Title    string   `json:"title"`
+		Category string   `json:"category"`
+		Tags     []string `json:"tags"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		channelID, _ := ctx.ParamsInt("id")
+
+		var req ChannelInfoRequest
+
+		if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+			return fiber.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		if utf8.RuneCountInString(req.Title) > 140 {
+			return fiber.NewError(http.StatusBadRequest, "title must be at most 140 characters")
+		}
+
+		info, err := h.channelInfoService.UpdateChannelInfo(channelID, req.Title, req.Category, req.Tags)
+		if err != nil {
+			return categoryError(err)
+		}
+
+		return ctx.JSON(info)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"strings"
+	"testing"
+)
+
+func TestUpdateChannelInfo(t *testing.T) {
+	secret := "secret"
+	broadcaster, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	editor, err := test_util.GenerateTokens(2, secret, token.ChannelScope(token.RoleEditor, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type infoTest struct {
+		description    string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []infoTest{
+		{"broadcaster", "/channels/1/info", `{"title":"Speedrunning","category":"chess","tags":["English"]}`, broadcaster, http.StatusOK},
+		{"editor", "/channels/1/info", `{"title":"Speedrunning"}`, editor, http.StatusOK},
+		{"someone else's channel", "/channels/3/info", `{"title":"Speedrunning"}`, editor, http.StatusForbidden},
+		{"without token", "/channels/1/info", `{"title":"Speedrunning"}`, "", http.StatusUnauthorized},
+		{"title too long", "/channels/1/info", `{"title":"` + strings.Repeat("a", 141) + `"}`, broadcaster, http.StatusBadRequest},
+		{"invalid tag", "/channels/1/info", `{"tags":["c++"]}`, broadcaster, http.StatusBadRequest},
+		{"unknown category", "/channels/1/info", `{"category":"unknown"}`, broadcaster, http.StatusNotFound},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+
+			srv := NewChannelInfoHandler(&mock.ChannelInfoServiceMock{}, []byte(secret))
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
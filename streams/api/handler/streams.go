@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/streams/model/response"
+	"nikolamilovic/twitchy/streams/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type StreamHandler struct {
+	Router        *fiber.App
+	streamService service.IStreamService
+}
+
+func NewStreamHandler(streams service.IStreamService) *StreamHandler {
+	h := &StreamHandler{}
+
+	h.streamService = streams
+
+	h.Routes()
+
+	return h
+}
+
+func (h *StreamHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	r.Get("/live", h.handleLiveStreams())
+	r.Get("/:channel", h.handleChannelStream())
+}
+
+func (h *StreamHandler) handleLiveStreams() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		page, limit, err := pagination(ctx)
+		if err != nil {
+			return err
+		}
+
+		streams, total, err := h.streamService.GetLiveStreams(page, limit)
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(response.LiveStreamsResponse{
+			Streams: streams,
+			Page:    page,
+			Limit:   limit,
+			Total:   total,
+		})
+	}
+}
+
+func (h *StreamHandler) handleChannelStream() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		stream, err := h.streamService.GetChannelStream(ctx.Params("channel"))
+		if err != nil {
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(stream)
+	}
+}
+
+func pagination(ctx *fiber.Ctx) (int, int, error) {
+	page, pageErr := strconv.Atoi(ctx.Query("page", "1"))
+	limit, limitErr := strconv.Atoi(ctx.Query("limit", strconv.Itoa(defaultLimit)))
+
+	if pageErr != nil || limitErr != nil || page < 1 || limit < 1 || limit > maxLimit {
+		return 0, 0, fiber.NewError(http.StatusBadRequest, "page must be positive and limit between 1 and 100")
+	}
+
+	return page, limit, nil
+}
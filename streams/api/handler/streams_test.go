@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/model/response"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"testing"
+)
+
+func TestLiveStreams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/live?page=2&limit=5", nil)
+
+	srv := NewStreamHandler(&mock.StreamServiceMock{})
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.LiveStreamsResponse
+	json.Unmarshal(data, &responseData)
+
+	if responseData.Page != 2 || responseData.Limit != 5 || responseData.Total != 1 || len(responseData.Streams) != 1 {
+		t.Fatalf("expected the second page of live streams, instead got: %+v", responseData)
+	}
+}
+
+func TestLiveStreamsPagination(t *testing.T) {
+	for _, query := range []string{"page=0", "limit=0", "limit=101", "page=first"} {
+		req := httptest.NewRequest(http.MethodGet, "/live?"+query, nil)
+
+		srv := NewStreamHandler(&mock.StreamServiceMock{})
+
+		resp, err := srv.Router.Test(req)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+
+		if want, got := http.StatusBadRequest, resp.StatusCode; want != got {
+			t.Fatalf("expected a %d for %s, instead got: %d", want, query, got)
+		}
+	}
+}
+
+func TestChannelStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/channel", nil)
+
+	srv := NewStreamHandler(&mock.StreamServiceMock{})
+
+	resp, err := srv.Router.Test(req)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+
+	var stream model.Stream
+	json.Unmarshal(data, &stream)
+
+	if stream.Channel != "channel" || stream.Status != event.StreamStatusOffline {
+		t.Fatalf("expected an offline channel, instead got: %+v", stream)
+	}
+}
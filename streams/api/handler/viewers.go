@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"nikolamilovic/twitchy/common/audit"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/service"
+	"regexp"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Anonymous players make up an id and keep it for the whole session
+var viewerIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+type ViewerHandler struct {
+	Router        *fiber.App
+	viewerService service.IViewerService
+	jwtSecret     []byte
+}
+
+func NewViewerHandler(viewers service.IViewerService, jwtSecret []byte) *ViewerHandler {
+	h := &ViewerHandler{}
+
+	h.viewerService = viewers
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *ViewerHandler) Routes() {
+	r := fiber.New()
+	h.Router = r
+
+	r.Post("/:channel/heartbeat", h.handleHeartbeat())
+}
+
+// handleHeartbeat counts the player as watching the channel for the next minute. Logged in viewers are counted
+// by their user id so they are only counted once across devices, anonymous ones by the viewer_id of the player.
+// Anyone can make up viewer ids, so only so many anonymous viewers are counted per IP.
+func (h *ViewerHandler) handleHeartbeat() fiber.Handler {
+	type HeartbeatRequest struct {
+		ViewerID string `json:"viewer_id"`
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		var viewerID, ip string
+
+		if ctx.Get("Authorization") != "" || ctx.Get(token.HeaderUserID) != "" {
+			claims, err := token.FromHeaders(authz.FiberHeader(ctx), h.jwtSecret)
+			if err != nil {
+				return fiber.NewError(http.StatusUnauthorized, "unauthorized")
+			}
+			viewerID = "user:" + strconv.Itoa(claims.UserId)
+		} else {
+			var req HeartbeatRequest
+
+			if err := utils.DecodeJSONBodyFiber(ctx, &req); err != nil {
+				return fiber.NewError(http.StatusBadRequest, err.Error())
+			}
+
+			if !viewerIDPattern.MatchString(req.ViewerID) {
+				return fiber.NewError(http.StatusBadRequest, "viewer_id must be 8 to 64 letters, digits, dashes or underscores")
+			}
+			viewerID = "anonymous:" + req.ViewerID
+			ip = audit.FiberIP(ctx)
+		}
+
+		err := h.viewerService.Heartbeat(ctx.Params("channel"), viewerID, ip)
+
+		switch {
+		case err == nil:
+			return ctx.SendStatus(http.StatusNoContent)
+		case errors.Is(err, model.NotLiveError):
+			return fiber.NewError(http.StatusNotFound, err.Error())
+		case errors.Is(err, model.TooManyViewersError):
+			return fiber.NewError(http.StatusTooManyRequests, err.Error())
+		default:
+			return fiber.NewError(http.StatusInternalServerError, err.Error())
+		}
+	}
+}
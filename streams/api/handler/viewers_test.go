@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"strings"
+	"testing"
+)
+
+func TestHeartbeat(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type heartbeatTest struct {
+		description    string
+		path           string
+		body           string
+		token          string
+		expectedStatus int
+		expectedViewer string
+	}
+
+	for _, scenario := range []heartbeatTest{
+		{"anonymous", "/channel/heartbeat", `{"viewer_id":"0f8fad5b-d9cb-469f"}`, "", http.StatusNoContent, "anonymous:0f8fad5b-d9cb-469f"},
+		{"logged in", "/channel/heartbeat", `{"viewer_id":"0f8fad5b-d9cb-469f"}`, jwt, http.StatusNoContent, "user:1"},
+		{"invalid token", "/channel/heartbeat", `{"viewer_id":"0f8fad5b-d9cb-469f"}`, "invalid", http.StatusUnauthorized, ""},
+		{"without viewer id", "/channel/heartbeat", `{}`, "", http.StatusBadRequest, ""},
+		{"invalid viewer id", "/channel/heartbeat", `{"viewer_id":"../../etc"}`, "", http.StatusBadRequest, ""},
+		{"offline channel", "/offline/heartbeat", `{"viewer_id":"0f8fad5b-d9cb-469f"}`, "", http.StatusNotFound, ""},
+		{"too many viewers from the address", "/crowded/heartbeat", `{"viewer_id":"0f8fad5b-d9cb-469f"}`, "", http.StatusTooManyRequests, ""},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, scenario.path, strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			if scenario.token != "" {
+				req.Header.Set("Authorization", "Bearer "+scenario.token)
+			}
+
+			viewers := &mock.ViewerServiceMock{}
+			srv := NewViewerHandler(viewers, []byte(secret))
+
+			resp, err := srv.Router.Test(req)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+
+			if want, got := scenario.expectedStatus, resp.StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if scenario.expectedViewer != "" && (len(viewers.Heartbeats) != 1 || viewers.Heartbeats[0] != scenario.expectedViewer) {
+				t.Fatalf("expected %s to be counted, instead got: %v", scenario.expectedViewer, viewers.Heartbeats)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/streams/api/handler"
+	"nikolamilovic/twitchy/streams/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Server struct {
+	router        *fiber.App
+	streamService service.IStreamService
+	categories    service.ICategoryService
+	channelInfo   service.IChannelInfoService
+	viewers       service.IViewerService
+	jwtSecret     []byte
+}
+
+func NewServer(service service.IStreamService, categories service.ICategoryService, info service.IChannelInfoService, viewers service.IViewerService, jwtSecret []byte) *fiber.App {
+	s := &Server{
+		streamService: service,
+		categories:    categories,
+		channelInfo:   info,
+		viewers:       viewers,
+		jwtSecret:     jwtSecret,
+		router:        fiber.New(),
+	}
+	s.router.Use(metrics.Fiber())
+	s.routes()
+	return s.router
+}
+
+func (s *Server) routes() {
+	h := handler.NewStreamHandler(s.streamService)
+	ch := handler.NewCategoryHandler(s.categories, s.jwtSecret)
+	ih := handler.NewChannelInfoHandler(s.channelInfo, s.jwtSecret)
+	vh := handler.NewViewerHandler(s.viewers, s.jwtSecret)
+
+	s.router.Get("/metrics", metrics.FiberHandler())
+
+	// Mounted before the stream handler so /:channel doesn't swallow them
+	s.router.Mount("/api/streams/categories", ch.Router)
+	s.router.Mount("/api/streams", ih.Router)
+	s.router.Mount("/api/streams", vh.Router)
+	s.router.Mount("/api/streams", h.Router)
+}
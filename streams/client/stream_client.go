@@ -0,0 +1,291 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/streams/service"
+	"runtime"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long closing waits for the messages being processed
+	closeTimeout = 10 * time.Second
+)
+
+// StreamClient consumes the stream lifecycle events published by the video service
+// and publishes the resulting status changes for chat and notifications
+type StreamClient struct {
+	service    service.IStreamService
+	logger     *zap.SugaredLogger
+	connection *rabbitmq.ClientConnection
+	threads    int
+	wg         *sync.WaitGroup
+	stop       context.CancelFunc
+}
+
+func New(addr string, l *zap.SugaredLogger, connection *rabbitmq.ClientConnection) *StreamClient {
+	threads := runtime.GOMAXPROCS(0)
+	if numCPU := runtime.NumCPU(); numCPU > threads {
+		threads = numCPU
+	}
+
+	client := StreamClient{
+		logger:     l,
+		threads:    threads,
+		connection: connection,
+		wg:         &sync.WaitGroup{},
+	}
+
+	go client.connection.HandleReconnect(addr, client.connect)
+	return &client
+}
+
+// Consume starts feeding the stream events into the service, the service publishes through this client
+// so it can only be handed over once both exist
+func (c *StreamClient) Consume(cancelCtx context.Context, service service.IStreamService) {
+	c.service = service
+
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			err := c.stream(ctx)
+			if errors.Is(err, rabbitmq.ErrDisconnected) {
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
+			break
+		}
+	}()
+}
+
+func (c *StreamClient) PublishStreamStatusChangedEvent(data event.StreamStatusChangedEventData) error {
+	return c.publish(constants.StreamStatusChangedKey, event.StreamStatusChangedType, data)
+}
+
+func (c *StreamClient) PublishStreamInfoUpdatedEvent(data event.StreamInfoUpdatedEventData) error {
+	return c.publish(constants.StreamInfoUpdatedKey, event.StreamInfoUpdatedType, data)
+}
+
+func (c *StreamClient) PublishViewerCountUpdatedEvent(data event.ViewerCountUpdatedEventData) error {
+	return c.publish(constants.ViewerCountUpdatedKey, event.ViewerCountUpdatedType, data)
+}
+
+func (c *StreamClient) publish(key, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	baseEv := event.BaseEvent{
+		Type:    eventType,
+		Payload: string(payload),
+	}
+
+	ev, err := json.Marshal(baseEv)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	return c.push(key, ev)
+}
+
+func (c *StreamClient) push(key string, data []byte) error {
+	return c.connection.PublishConfirmed(context.Background(), constants.StreamsExchange, key, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        data,
+	})
+}
+
+func (c *StreamClient) connect(ch rabbitmq.Channel) bool {
+
+	err := ch.ExchangeDeclare(constants.StreamsExchange, "topic", true, false, false, false, nil)
+
+	if err != nil {
+		c.logger.Errorf("failed to declare exchange: %v", err)
+		return false
+	}
+
+	_, err = ch.QueueDeclare(
+		constants.StreamsQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.StreamsQueue, err)
+		return false
+	}
+
+	for _, key := range []string{constants.StreamStartedKey, constants.StreamEndedKey} {
+		err = ch.QueueBind(constants.StreamsQueue, key, constants.StreamsExchange, false, nil)
+		if err != nil {
+			c.logger.Errorf("failed to bind %s to the streams queue: %v", key, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *StreamClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
+
+	var deliveries []<-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		if err := ch.Qos(1, 0, false); err != nil {
+			return err
+		}
+
+		for i := 1; i <= c.threads; i++ {
+			msgs, err := ch.Consume(
+				constants.StreamsQueue,
+				consumerName(i), // Consumer
+				false,           // Auto-Ack
+				false,           // Exclusive
+				false,           // No-local
+				false,           // No-Wait
+				nil,             // Args
+			)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, msgs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The consumers only stop early when the channel closes
+	var wg sync.WaitGroup
+	for _, msgs := range deliveries {
+		wg.Add(1)
+		go func(msgs <-chan amqp.Delivery) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg, ok := <-msgs:
+					if !ok {
+						return
+					}
+					metrics.Consume(constants.StreamsQueue, msg, c.parseEvent)
+				}
+			}
+		}(msgs)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return rabbitmq.ErrDisconnected
+}
+
+func (c *StreamClient) parseEvent(msg amqp.Delivery) {
+	l := c.logger.Named("parseEvent")
+	startTime := time.Now()
+
+	var evt event.BaseEvent
+	err := json.Unmarshal(msg.Body, &evt)
+	if err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
+		return
+	}
+
+	if evt.Payload == "" {
+		logAndNack(msg, l, startTime, "received event without data")
+		return
+	}
+
+	defer func(e event.BaseEvent, m amqp.Delivery, logger *zap.SugaredLogger) {
+		if err := recover(); err != nil {
+			stack := make([]byte, 8096)
+			stack = stack[:runtime.Stack(stack, false)]
+			logger.Error("panic recovery for rabbitMQ message")
+			msg.Nack(false, false)
+		}
+	}(evt, msg, l)
+
+	c.logger.Infof("Received event %v", evt)
+
+	switch evt.Type {
+	case event.StreamStartedType:
+		payload := &event.StreamStartedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.StartStream(*payload)
+		}
+	case event.StreamEndedType:
+		payload := &event.StreamEndedEventData{}
+		err = json.Unmarshal([]byte(evt.Payload), payload)
+		if err == nil {
+			err = c.service.EndStream(*payload)
+		}
+	default:
+		msg.Reject(false)
+		return
+	}
+
+	if err != nil {
+		logAndNack(msg, l, startTime, err.Error())
+		return
+	}
+
+	l.Infof("Took ms %d, succeeded %s", time.Since(startTime).Milliseconds(), evt.Type)
+	msg.Ack(false)
+}
+
+func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string, args ...interface{}) {
+	msg.Nack(false, false)
+	l.Errorf("Took ms %d, %s", time.Since(t).Milliseconds(), fmt.Sprintf(err, args...))
+}
+
+func (c *StreamClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	c.logger.Info("Waiting for current messages to be processed...")
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	if err := c.connection.Close(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("gracefully stopped rabbitMQ connection")
+	return nil
+}
+
+func consumerName(i int) string {
+	return fmt.Sprintf("go-consumer-%v", i)
+}
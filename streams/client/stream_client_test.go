@@ -0,0 +1,96 @@
+package client
+
+import (
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEventStarted(t *testing.T) {
+	//Set up test
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.StreamServiceMock{}
+	client := &StreamClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	//WHEN
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"stream_started",
+ 	  "payload":"{\"session_id\":\"abc\",\"channel_id\":1,\"channel\":\"channel\",\"started_at\":\"2022-04-01T10:00:00Z\"}"
+		}`),
+		},
+	)
+
+	//SHOULD
+	if len(service.Started) != 1 || service.Started[0].SessionID != "abc" || service.Started[0].ChannelID != 1 {
+		t.Fatalf("Expected the stream to be started, got %+v", service.Started)
+	}
+}
+
+func TestParseEventEnded(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.StreamServiceMock{}
+	client := &StreamClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"stream_ended",
+ 	  "payload":"{\"session_id\":\"abc\",\"channel_id\":1,\"channel\":\"channel\"}"
+		}`),
+		},
+	)
+
+	if len(service.Ended) != 1 || service.Ended[0].SessionID != "abc" {
+		t.Fatalf("Expected the stream to be ended, got %+v", service.Ended)
+	}
+}
+
+func TestParseEventRejectUnknown(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &StreamClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.StreamServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Reject(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body:         []byte(`{"type":"account_created","payload":"{}"}`),
+		},
+	)
+}
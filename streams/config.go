@@ -0,0 +1,17 @@
+package main
+
+import (
+	"nikolamilovic/twitchy/common/config"
+)
+
+// Config is read from the environment, or the YAML file at CONFIG_FILE
+type Config struct {
+	Port      int    `env:"PORT" yaml:"port" default:"80"`
+	JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+	// TrustGateway takes the user the gateway verified from its headers, for services only reachable through it
+	TrustGateway bool `env:"TRUST_GATEWAY" yaml:"trust_gateway"`
+	// ViewersSnapshotPath keeps the viewer counts across restarts, they start from zero when it isn't set
+	ViewersSnapshotPath string          `env:"VIEWERS_SNAPSHOT_PATH" yaml:"viewers_snapshot_path"`
+	Postgres            config.Postgres `yaml:"postgres"`
+	RabbitMQ            config.RabbitMQ `yaml:"rabbitmq"`
+}
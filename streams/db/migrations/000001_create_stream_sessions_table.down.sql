@@ -0,0 +1 @@
+DROP TABLE IF EXISTS stream_sessions;
\ No newline at end of file
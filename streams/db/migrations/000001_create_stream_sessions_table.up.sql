@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS stream_sessions(
+   id VARCHAR (32) PRIMARY KEY,
+   channel_id integer NOT NULL,
+   channel VARCHAR (50) NOT NULL,
+   status VARCHAR (16) NOT NULL DEFAULT 'offline' CHECK (status IN ('offline', 'live', 'ended')),
+   title VARCHAR (140) NOT NULL DEFAULT '',
+   category VARCHAR (100) NOT NULL DEFAULT '',
+   started_at timestamptz,
+   ended_at timestamptz,
+   peak_viewers integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS stream_sessions_channel_idx ON stream_sessions (channel, started_at DESC);
+CREATE INDEX IF NOT EXISTS stream_sessions_live_idx ON stream_sessions (started_at DESC) WHERE status = 'live';
+-- A channel can only have a single live session at a time
+CREATE UNIQUE INDEX IF NOT EXISTS stream_sessions_one_live_idx ON stream_sessions (channel_id) WHERE status = 'live';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS stream_sessions_category_idx;
+ALTER TABLE stream_sessions DROP COLUMN IF EXISTS viewers;
+ALTER TABLE stream_sessions DROP COLUMN IF EXISTS tags;
+DROP TABLE IF EXISTS channel_info;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS categories(
+   slug VARCHAR (100) PRIMARY KEY,
+   name VARCHAR (100) NOT NULL,
+   box_art_url TEXT NOT NULL DEFAULT '',
+   created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- What the channel streams, copied onto every session it starts
+CREATE TABLE IF NOT EXISTS channel_info(
+   channel_id integer PRIMARY KEY,
+   title VARCHAR (140) NOT NULL DEFAULT '',
+   category VARCHAR (100) REFERENCES categories (slug) ON DELETE SET NULL,
+   tags VARCHAR (25)[] NOT NULL DEFAULT '{}',
+   updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS tags VARCHAR (25)[] NOT NULL DEFAULT '{}';
+-- Concurrent viewers of a live session
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS viewers integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS stream_sessions_category_idx ON stream_sessions (category, viewers DESC) WHERE status = 'live';
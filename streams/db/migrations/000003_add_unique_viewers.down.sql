@@ -0,0 +1 @@
+ALTER TABLE stream_sessions DROP COLUMN IF EXISTS unique_viewers;
@@ -0,0 +1,2 @@
+-- Estimated from the heartbeats of the players, see the presence package
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS unique_viewers integer NOT NULL DEFAULT 0;
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"nikolamilovic/twitchy/common/config"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/streams/api"
+	"nikolamilovic/twitchy/streams/client"
+	"nikolamilovic/twitchy/streams/presence"
+	"nikolamilovic/twitchy/streams/service"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+const (
+	viewerShards = 64
+	// Players send a heartbeat every 30 seconds, a viewer that missed two has left
+	viewerTTL           = time.Minute
+	viewerFlushInterval = 10 * time.Second
+	// Anonymous viewers counted per IP, enough for a household or an office behind a NAT
+	anonymousViewersPerIP = 20
+)
+
+var (
+	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
+	shutdowns []func() error
+)
+
+func main() {
+	var (
+		shutdown = make(chan struct{})
+		ctx      = context.Background()
+	)
+
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+	token.TrustGateway(cfg.TrustGateway)
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
+	if err != nil {
+		logger.Fatal("failed to init the db", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
+
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
+	client := client.New(amqpServerURL, logger.Sugar().Named("streams_rabbitmq_client"), clientConnection)
+
+	streamService := service.NewStreamService(dbConn, client)
+	categoryService := service.NewCategoryService(dbConn)
+	channelInfoService := service.NewChannelInfoService(dbConn, client)
+	client.Consume(ctx, streamService)
+
+	tracker := presence.NewTracker(viewerShards, viewerTTL, anonymousViewersPerIP)
+	viewerService := service.NewViewerService(dbConn, client, tracker, cfg.ViewersSnapshotPath, logger.Sugar().Named("viewer_service"))
+	if err := viewerService.Restore(); err != nil {
+		logger.Error("failed to restore the viewer counts", zap.Error(err))
+	}
+
+	countsCtx, stopCounts := context.WithCancel(ctx)
+	go viewerService.RunCounts(countsCtx, viewerFlushInterval)
+
+	srv := api.NewServer(streamService, categoryService, channelInfoService, viewerService, []byte(cfg.JWTSecret))
+
+	shutdowns = append(shutdowns, func() error {
+		stopCounts()
+		return viewerService.Snapshot()
+	}, dbCleanup, client.Close)
+
+	defer logger.Sync()
+
+	go gracefulShutdown(srv.Server(), shutdown)
+
+	port := fmt.Sprintf(":%d", cfg.Port)
+
+	err = srv.Listen(port)
+	if err != nil {
+		logger.Fatal("failed to start the server", zap.Error(err))
+		os.Exit(1)
+	}
+	logger.Info("Server starting and listening at port " + port)
+}
+
+func gracefulShutdown(server *fasthttp.Server, shutdown chan struct{}) {
+	var (
+		sigint = make(chan os.Signal, 1)
+	)
+
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+
+	logger.Info("shutting down server gracefully")
+
+	// stop receiving any request.
+	if err := server.Shutdown(); err != nil {
+		logger.Fatal("shutdown error", zap.Error(err))
+	}
+
+	// close any other modules.
+	for i := range shutdowns {
+		shutdowns[i]()
+	}
+
+	close(shutdown)
+}
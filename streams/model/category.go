@@ -0,0 +1,68 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxTags      = 10
+	MaxTagLength = 25
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Category is a game or topic that streams are browsed by
+type Category struct {
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	BoxArtURL string `json:"box_art_url"`
+}
+
+// ChannelInfo is what the channel streams, it is copied onto every session the channel starts
+type ChannelInfo struct {
+	ChannelID int        `json:"channel_id"`
+	Title     string     `json:"title"`
+	Category  string     `json:"category"`
+	Tags      []string   `json:"tags"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func ValidSlug(slug string) bool {
+	return len(slug) <= 100 && slugPattern.MatchString(slug)
+}
+
+// NormalizeTags lowercases the tags and joins their words with dashes, so "Speed Run" and "speed-run" are the same tag.
+// Duplicates are dropped, the order is kept.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, InvalidTagError
+		}
+		for _, r := range tag {
+			if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return nil, InvalidTagError
+			}
+		}
+
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxTags {
+		return nil, TooManyTagsError
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,14 @@
+package model
+
+import "errors"
+
+var (
+	StreamNotFoundError = errors.New("Stream not found")
+	NotLiveError        = errors.New("the channel isn't live")
+	TooManyViewersError = errors.New("too many viewers from the same address")
+
+	CategoryNotFoundError = errors.New("category not found")
+	InvalidSlugError      = errors.New("slugs are 1 to 100 lowercase letters, digits and dashes")
+	InvalidTagError       = errors.New("tags are 1 to 25 letters, digits and dashes")
+	TooManyTagsError      = errors.New("a stream can have at most 10 tags")
+)
@@ -0,0 +1,18 @@
+package response
+
+import "nikolamilovic/twitchy/streams/model"
+
+type CategoriesResponse struct {
+	Categories []model.Category `json:"categories"`
+	Page       int              `json:"page"`
+	Limit      int              `json:"limit"`
+	Total      int              `json:"total"`
+}
+
+type CategoryStreamsResponse struct {
+	Category model.Category `json:"category"`
+	Streams  []model.Stream `json:"streams"`
+	Page     int            `json:"page"`
+	Limit    int            `json:"limit"`
+	Total    int            `json:"total"`
+}
@@ -0,0 +1,10 @@
+package response
+
+import "nikolamilovic/twitchy/streams/model"
+
+type LiveStreamsResponse struct {
+	Streams []model.Stream `json:"streams"`
+	Page    int            `json:"page"`
+	Limit   int            `json:"limit"`
+	Total   int            `json:"total"`
+}
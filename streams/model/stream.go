@@ -0,0 +1,39 @@
+package model
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"time"
+)
+
+// Stream is a single broadcast session of a channel
+type Stream struct {
+	SessionID   string     `json:"session_id,omitempty"`
+	ChannelID   int        `json:"channel_id,omitempty"`
+	Channel     string     `json:"channel"`
+	Status      string     `json:"status"`
+	Title       string     `json:"title,omitempty"`
+	Category    string     `json:"category,omitempty"`
+	Tags        []string   `json:"tags"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	PeakViewers int        `json:"peak_viewers"`
+	Viewers     int        `json:"viewers"`
+	// UniqueViewers is an estimate of everyone who watched the session
+	UniqueViewers int `json:"unique_viewers"`
+}
+
+var transitions = map[string][]string{
+	event.StreamStatusOffline: {event.StreamStatusLive, event.StreamStatusEnded},
+	event.StreamStatusLive:    {event.StreamStatusEnded},
+}
+
+// CanTransition reports whether a session may move from one status to another, sessions go offline -> live -> ended.
+// Offline may go straight to ended when the end of a stream is seen before its start.
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package presence
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// 2^14 registers take 16KB per session and estimate within about 0.8%
+	precision = 14
+	registers = 1 << precision
+	alpha     = 0.7213 / (1 + 1.079/registers)
+)
+
+// HyperLogLog estimates the number of distinct values added to it in constant memory
+type HyperLogLog struct {
+	Registers []uint8 `json:"registers"`
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{Registers: make([]uint8, registers)}
+}
+
+func (h *HyperLogLog) Add(value string) {
+	x := hash(value)
+
+	// The first bits pick the register, it keeps the longest run of leading zeros seen in the rest
+	i := x >> (64 - precision)
+	rank := uint8(bits.LeadingZeros64(x<<precision|1<<(precision-1))) + 1
+
+	if rank > h.Registers[i] {
+		h.Registers[i] = rank
+	}
+}
+
+func (h *HyperLogLog) Count() int {
+	var (
+		sum   float64
+		empty int
+	)
+
+	for _, r := range h.Registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			empty++
+		}
+	}
+
+	estimate := alpha * registers * registers / sum
+
+	// Small counts leave most registers empty, linear counting is more accurate there
+	if estimate <= 2.5*registers && empty > 0 {
+		estimate = registers * math.Log(float64(registers)/float64(empty))
+	}
+
+	return int(estimate + 0.5)
+}
+
+// valid reports whether the registers survived a snapshot intact
+func (h *HyperLogLog) valid() bool {
+	return h != nil && len(h.Registers) == registers
+}
+
+// hash spreads FNV over all 64 bits with the splitmix64 finalizer, FNV alone is too weak in the high bits for short ids
+func hash(value string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+
+	x := f.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
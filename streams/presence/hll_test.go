@@ -0,0 +1,24 @@
+package presence
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	for _, distinct := range []int{0, 1, 100, 10000, 200000} {
+		h := NewHyperLogLog()
+
+		for i := 0; i < distinct; i++ {
+			h.Add(fmt.Sprintf("viewer-%d", i))
+			// Viewers send a heartbeat every 30 seconds, repeats must not count
+			h.Add(fmt.Sprintf("viewer-%d", i))
+		}
+
+		got := h.Count()
+		if diff := math.Abs(float64(got - distinct)); diff > 0.03*float64(distinct) {
+			t.Fatalf("Expected about %d distinct viewers, got %d", distinct, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package presence
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotLive        = errors.New("the channel isn't live")
+	ErrTooManyViewers = errors.New("too many viewers from the same source")
+)
+
+// Session is a live session viewers can watch
+type Session struct {
+	SessionID string `json:"session_id"`
+	ChannelID int    `json:"channel_id"`
+	Channel   string `json:"channel"`
+}
+
+// Count is the audience of a live session, Viewers are the ones watching right now
+type Count struct {
+	Session
+	Viewers       int `json:"viewers"`
+	UniqueViewers int `json:"unique_viewers"`
+	PeakViewers   int `json:"peak_viewers"`
+}
+
+// stream is the presence of a single live session, it is exported through the snapshot
+type stream struct {
+	Session
+	// LastSeen is the time of the last heartbeat of every viewer still watching
+	LastSeen map[string]time.Time `json:"last_seen"`
+	Uniques  *HyperLogLog         `json:"uniques"`
+	Peak     int                  `json:"peak"`
+	// sources holds the source of every viewer that has one and bySource how many viewers each source adds.
+	// They aren't snapshotted, the viewers restored without a source expire within the ttl anyway.
+	sources  map[string]string
+	bySource map[string]int
+}
+
+func newStream(session Session) *stream {
+	return &stream{Session: session, LastSeen: map[string]time.Time{}, Uniques: NewHyperLogLog(), sources: map[string]string{}, bySource: map[string]int{}}
+}
+
+// forget drops the viewer from the stream and from the count of its source
+func (st *stream) forget(viewerID string) {
+	delete(st.LastSeen, viewerID)
+
+	source, ok := st.sources[viewerID]
+	if !ok {
+		return
+	}
+	delete(st.sources, viewerID)
+
+	st.bySource[source]--
+	if st.bySource[source] <= 0 {
+		delete(st.bySource, source)
+	}
+}
+
+type shard struct {
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+// Tracker counts the viewers of the live sessions from the heartbeats of their players. Channels are spread over
+// shards so heartbeats for different channels rarely wait on the same lock.
+type Tracker struct {
+	shards []*shard
+	// Viewers that didn't send a heartbeat for this long stopped watching
+	ttl time.Duration
+	// perSource is how many viewers a single source can add to a channel at once, 0 doesn't limit them
+	perSource int
+}
+
+func NewTracker(shards int, ttl time.Duration, perSource int) *Tracker {
+	t := &Tracker{
+		shards:    make([]*shard, shards),
+		ttl:       ttl,
+		perSource: perSource,
+	}
+
+	for i := range t.shards {
+		t.shards[i] = &shard{streams: map[string]*stream{}}
+	}
+
+	return t
+}
+
+// Heartbeat marks the viewer as watching the channel, it fails with ErrNotLive when the channel has no live session.
+// Viewers with a source, like the IP of an anonymous player, fail with ErrTooManyViewers once the source already
+// adds as many viewers as the tracker allows, the ones already watching keep being counted.
+func (t *Tracker) Heartbeat(channel, viewerID, source string, now time.Time) error {
+	s := t.shard(channel)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.streams[channel]
+	if !ok {
+		return ErrNotLive
+	}
+
+	if _, watching := st.LastSeen[viewerID]; !watching && source != "" {
+		if t.perSource > 0 && st.bySource[source] >= t.perSource {
+			return ErrTooManyViewers
+		}
+		st.sources[viewerID] = source
+		st.bySource[source]++
+	}
+
+	st.LastSeen[viewerID] = now
+	st.Uniques.Add(viewerID)
+
+	return nil
+}
+
+// Sync replaces the tracked sessions with the live ones. Channels that went live again start counting from zero,
+// the ones that ended are dropped.
+func (t *Tracker) Sync(live []Session) {
+	byShard := make([]map[string]Session, len(t.shards))
+	for i := range byShard {
+		byShard[i] = map[string]Session{}
+	}
+	for _, session := range live {
+		byShard[t.index(session.Channel)][session.Channel] = session
+	}
+
+	for i, s := range t.shards {
+		s.mu.Lock()
+
+		for channel, st := range s.streams {
+			if session, ok := byShard[i][channel]; !ok || session.SessionID != st.SessionID {
+				delete(s.streams, channel)
+			}
+		}
+
+		for channel, session := range byShard[i] {
+			if _, ok := s.streams[channel]; !ok {
+				s.streams[channel] = newStream(session)
+			}
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// Counts drops the viewers whose heartbeats expired and returns the audience of every live session.
+// The peak is only taken here, so viewers that left but haven't expired yet don't inflate it.
+func (t *Tracker) Counts(now time.Time) []Count {
+	counts := []Count{}
+
+	for _, s := range t.shards {
+		s.mu.Lock()
+
+		for _, st := range s.streams {
+			for viewer, seen := range st.LastSeen {
+				if now.Sub(seen) > t.ttl {
+					st.forget(viewer)
+				}
+			}
+			if len(st.LastSeen) > st.Peak {
+				st.Peak = len(st.LastSeen)
+			}
+
+			counts = append(counts, Count{
+				Session:       st.Session,
+				Viewers:       len(st.LastSeen),
+				UniqueViewers: st.Uniques.Count(),
+				PeakViewers:   st.Peak,
+			})
+		}
+
+		s.mu.Unlock()
+	}
+
+	return counts
+}
+
+// Snapshot writes the state of every shard so a restarted tracker keeps the unique and peak counts
+func (t *Tracker) Snapshot(w io.Writer) error {
+	streams := []*stream{}
+
+	for _, s := range t.shards {
+		s.mu.Lock()
+		for _, st := range s.streams {
+			copied := *st
+			copied.LastSeen = make(map[string]time.Time, len(st.LastSeen))
+			for viewer, seen := range st.LastSeen {
+				copied.LastSeen[viewer] = seen
+			}
+			copied.Uniques = &HyperLogLog{Registers: append([]uint8(nil), st.Uniques.Registers...)}
+			streams = append(streams, &copied)
+		}
+		s.mu.Unlock()
+	}
+
+	return json.NewEncoder(w).Encode(streams)
+}
+
+// Restore loads a snapshot, the shard count may differ from the tracker that wrote it
+func (t *Tracker) Restore(r io.Reader) error {
+	var streams []*stream
+	if err := json.NewDecoder(r).Decode(&streams); err != nil {
+		return err
+	}
+
+	for _, st := range streams {
+		if st.LastSeen == nil {
+			st.LastSeen = map[string]time.Time{}
+		}
+		if !st.Uniques.valid() {
+			st.Uniques = NewHyperLogLog()
+		}
+		st.sources = map[string]string{}
+		st.bySource = map[string]int{}
+
+		s := t.shard(st.Channel)
+		s.mu.Lock()
+		s.streams[st.Channel] = st
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// SaveSnapshot writes the snapshot to a temporary file first so a crash mid-write keeps the previous one
+func (t *Tracker) SaveSnapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := t.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the snapshot at path, a missing snapshot leaves the tracker empty
+func (t *Tracker) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.Restore(f)
+}
+
+func (t *Tracker) shard(channel string) *shard {
+	return t.shards[t.index(channel)]
+}
+
+func (t *Tracker) index(channel string) int {
+	h := fnv.New32a()
+	h.Write([]byte(channel))
+
+	return int(h.Sum32() % uint32(len(t.shards)))
+}
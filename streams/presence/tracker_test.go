@@ -0,0 +1,133 @@
+package presence
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrackerHeartbeat(t *testing.T) {
+	tracker := NewTracker(4, time.Minute, 0)
+	tracker.Sync([]Session{{SessionID: "session", ChannelID: 1, Channel: "channel"}})
+
+	now := time.Now()
+
+	if err := tracker.Heartbeat("offline", "viewer", "", now); !errors.Is(err, ErrNotLive) {
+		t.Fatalf("Expected %v for an offline channel, got %v", ErrNotLive, err)
+	}
+
+	tracker.Heartbeat("channel", "first", "", now)
+	tracker.Heartbeat("channel", "second", "", now)
+	tracker.Heartbeat("channel", "first", "", now.Add(50*time.Second))
+
+	counts := tracker.Counts(now.Add(90 * time.Second))
+	if len(counts) != 1 {
+		t.Fatalf("Expected a single live session, got %+v", counts)
+	}
+
+	// The second viewer stopped sending heartbeats a minute and a half ago
+	if got := counts[0]; got.Viewers != 1 || got.UniqueViewers != 2 || got.PeakViewers != 1 {
+		t.Fatalf("Expected 1 viewer out of 2 unique, got %+v", got)
+	}
+}
+
+func TestTrackerLimitsViewersPerSource(t *testing.T) {
+	tracker := NewTracker(4, time.Minute, 2)
+	tracker.Sync([]Session{{SessionID: "session", ChannelID: 1, Channel: "channel"}})
+
+	now := time.Now()
+
+	for _, viewer := range []string{"first", "second", "first"} {
+		if err := tracker.Heartbeat("channel", viewer, "10.0.0.1", now); err != nil {
+			t.Fatalf("Expected error to be nil for %s, got %v", viewer, err)
+		}
+	}
+
+	if err := tracker.Heartbeat("channel", "third", "10.0.0.1", now); !errors.Is(err, ErrTooManyViewers) {
+		t.Fatalf("Expected %v for a third viewer from the same source, got %v", ErrTooManyViewers, err)
+	}
+	if err := tracker.Heartbeat("channel", "third", "10.0.0.2", now); err != nil {
+		t.Fatalf("Expected error to be nil for another source, got %v", err)
+	}
+	if err := tracker.Heartbeat("channel", "user", "", now); err != nil {
+		t.Fatalf("Expected error to be nil for a viewer without a source, got %v", err)
+	}
+
+	// The first two expired, so the source can add viewers again
+	tracker.Counts(now.Add(90 * time.Second))
+
+	if err := tracker.Heartbeat("channel", "fourth", "10.0.0.1", now.Add(90*time.Second)); err != nil {
+		t.Fatalf("Expected error to be nil once the viewers expired, got %v", err)
+	}
+}
+
+func TestTrackerSync(t *testing.T) {
+	tracker := NewTracker(4, time.Minute, 0)
+	tracker.Sync([]Session{{SessionID: "old", ChannelID: 1, Channel: "channel"}, {SessionID: "other", ChannelID: 2, Channel: "other"}})
+
+	now := time.Now()
+	tracker.Heartbeat("channel", "viewer", "", now)
+
+	// The channel went live again and the other one ended
+	tracker.Sync([]Session{{SessionID: "new", ChannelID: 1, Channel: "channel"}})
+
+	counts := tracker.Counts(now)
+	if len(counts) != 1 || counts[0].SessionID != "new" || counts[0].UniqueViewers != 0 {
+		t.Fatalf("Expected the new session to start from zero, got %+v", counts)
+	}
+}
+
+func TestTrackerSnapshot(t *testing.T) {
+	tracker := NewTracker(4, time.Minute, 0)
+	tracker.Sync([]Session{{SessionID: "session", ChannelID: 1, Channel: "channel"}})
+
+	now := time.Now()
+	tracker.Heartbeat("channel", "first", "", now)
+	tracker.Heartbeat("channel", "second", "", now)
+	tracker.Counts(now)
+
+	var buf bytes.Buffer
+	if err := tracker.Snapshot(&buf); err != nil {
+		t.Fatalf("an error '%s' was not expected when taking a snapshot", err)
+	}
+
+	restored := NewTracker(8, time.Minute, 0)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("an error '%s' was not expected when restoring a snapshot", err)
+	}
+
+	if err := restored.Heartbeat("channel", "third", "", now); err != nil {
+		t.Fatalf("Expected the restored session to be live, got %v", err)
+	}
+
+	counts := restored.Counts(now)
+	if len(counts) != 1 || counts[0].Viewers != 3 || counts[0].UniqueViewers != 3 || counts[0].PeakViewers != 3 {
+		t.Fatalf("Expected the restored counts to carry on, got %+v", counts)
+	}
+}
+
+func TestTrackerSaveSnapshot(t *testing.T) {
+	path := t.TempDir() + "/viewers.json"
+
+	tracker := NewTracker(4, time.Minute, 0)
+	if err := tracker.LoadSnapshot(path); err != nil {
+		t.Fatalf("Expected a missing snapshot to be ignored, got %v", err)
+	}
+
+	tracker.Sync([]Session{{SessionID: "session", ChannelID: 1, Channel: "channel"}})
+	tracker.Heartbeat("channel", "viewer", "", time.Now())
+
+	if err := tracker.SaveSnapshot(path); err != nil {
+		t.Fatalf("an error '%s' was not expected when saving a snapshot", err)
+	}
+
+	restored := NewTracker(4, time.Minute, 0)
+	if err := restored.LoadSnapshot(path); err != nil {
+		t.Fatalf("an error '%s' was not expected when loading a snapshot", err)
+	}
+
+	if counts := restored.Counts(time.Now()); len(counts) != 1 || counts[0].UniqueViewers != 1 {
+		t.Fatalf("Expected the saved session, got %+v", counts)
+	}
+}
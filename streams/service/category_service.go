@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+)
+
+type ICategoryService interface {
+	// Returns the categories for the given page and the total number of categories
+	GetCategories(page, limit int) ([]model.Category, int, error)
+	GetCategory(slug string) (model.Category, error)
+	// SaveCategory creates the category or renames it and replaces its box art
+	SaveCategory(category model.Category) (model.Category, error)
+	// Returns the live streams of the category with the most watched first, and the total number of them
+	GetCategoryStreams(slug string, page, limit int) ([]model.Stream, int, error)
+}
+
+type CategoryService struct {
+	DB db.PgxIface
+}
+
+func NewCategoryService(db db.PgxIface) ICategoryService {
+	return &CategoryService{
+		DB: db,
+	}
+}
+
+func (s *CategoryService) GetCategories(page, limit int) ([]model.Category, int, error) {
+	total, err := s.count("SELECT count(*) FROM categories")
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetCategories: %w", err)
+	}
+
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT slug, name, box_art_url FROM categories ORDER BY name, slug LIMIT $1 OFFSET $2", limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetCategories: %w", err)
+	}
+
+	defer rows.Close()
+
+	categories := []model.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GetCategories: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, total, nil
+}
+
+func (s *CategoryService) GetCategory(slug string) (model.Category, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT slug, name, box_art_url FROM categories WHERE slug = $1", slug)
+	if err != nil {
+		return model.Category{}, fmt.Errorf("GetCategory: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Category{}, fmt.Errorf("GetCategory: %w", model.CategoryNotFoundError)
+	}
+
+	category, err := scanCategory(rows)
+	if err != nil {
+		return model.Category{}, fmt.Errorf("GetCategory: %w", err)
+	}
+
+	return category, nil
+}
+
+func (s *CategoryService) SaveCategory(category model.Category) (model.Category, error) {
+	if !model.ValidSlug(category.Slug) {
+		return model.Category{}, fmt.Errorf("SaveCategory: %w", model.InvalidSlugError)
+	}
+
+	rows, err := s.DB.Query(context.Background(),
+		`INSERT INTO categories (slug, name, box_art_url) VALUES ($1, $2, $3)
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, box_art_url = EXCLUDED.box_art_url
+		RETURNING slug, name, box_art_url`,
+		category.Slug, category.Name, category.BoxArtURL)
+	if err != nil {
+		return model.Category{}, fmt.Errorf("SaveCategory: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Category{}, fmt.Errorf("SaveCategory: %w", rows.Err())
+	}
+
+	category, err = scanCategory(rows)
+	if err != nil {
+		return model.Category{}, fmt.Errorf("SaveCategory: %w", err)
+	}
+
+	return category, nil
+}
+
+func (s *CategoryService) GetCategoryStreams(slug string, page, limit int) ([]model.Stream, int, error) {
+	if _, err := s.GetCategory(slug); err != nil {
+		return nil, 0, fmt.Errorf("GetCategoryStreams: %w", err)
+	}
+
+	total, err := s.count("SELECT count(*) FROM stream_sessions WHERE status = $1 AND category = $2", event.StreamStatusLive, slug)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetCategoryStreams: %w", err)
+	}
+
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+streamColumns+` FROM stream_sessions WHERE status = $1 AND category = $2
+		ORDER BY viewers DESC, started_at DESC LIMIT $3 OFFSET $4`,
+		event.StreamStatusLive, slug, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetCategoryStreams: %w", err)
+	}
+
+	defer rows.Close()
+
+	streams := []model.Stream{}
+	for rows.Next() {
+		stream, err := scanStream(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GetCategoryStreams: %w", err)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, total, nil
+}
+
+func (s *CategoryService) count(query string, args ...interface{}) (int, error) {
+	rows, err := s.DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	var total int
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+
+	return total, err
+}
+
+func scanCategory(row scanner) (model.Category, error) {
+	var category model.Category
+	err := row.Scan(&category.Slug, &category.Name, &category.BoxArtURL)
+
+	return category, err
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+var categoryColumns = []string{"slug", "name", "box_art_url"}
+
+func TestGetCategoryStreams(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &CategoryService{DB: mockDB}
+
+	startedAt := time.Now()
+	mockDB.ExpectQuery("SELECT (.+) FROM categories WHERE slug").WithArgs("chess").
+		WillReturnRows(pgxmock.NewRows(categoryColumns).AddRow("chess", "Chess", "https://cdn.twitchy.dev/chess.png"))
+	mockDB.ExpectQuery("SELECT count").WithArgs(event.StreamStatusLive, "chess").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(2))
+	mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE status = (.+) ORDER BY viewers DESC").
+		WithArgs(event.StreamStatusLive, "chess", 2, 0).
+		WillReturnRows(pgxmock.NewRows(columns).
+			AddRow("popular", 1, "first", event.StreamStatusLive, "", "chess", []string{}, &startedAt, nil, 50, 40, 0).
+			AddRow("new", 2, "second", event.StreamStatusLive, "", "chess", []string{"english"}, &startedAt, nil, 3, 3, 0))
+
+	streams, total, err := sut.GetCategoryStreams("chess", 1, 2)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listing the category streams", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if total != 2 || len(streams) != 2 || streams[0].Viewers != 40 {
+		t.Fatalf("Expected the most watched stream first, got %d: %+v", total, streams)
+	}
+}
+
+func TestGetCategoryStreamsUnknownCategory(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &CategoryService{DB: mockDB}
+
+	mockDB.ExpectQuery("SELECT (.+) FROM categories WHERE slug").WithArgs("unknown").WillReturnRows(pgxmock.NewRows(categoryColumns))
+
+	_, _, err = sut.GetCategoryStreams("unknown", 1, 20)
+	if !errors.Is(err, model.CategoryNotFoundError) {
+		t.Fatalf("Expected %v, got %v", model.CategoryNotFoundError, err)
+	}
+}
+
+func TestSaveCategoryInvalidSlug(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &CategoryService{DB: mockDB}
+
+	for _, slug := range []string{"", "Chess", "just chatting", "-chess", "chess--960"} {
+		_, err = sut.SaveCategory(model.Category{Slug: slug, Name: "Chess"})
+		if !errors.Is(err, model.InvalidSlugError) {
+			t.Fatalf("Expected %v for %q, got %v", model.InvalidSlugError, slug, err)
+		}
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
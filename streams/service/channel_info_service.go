@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+const foreignKeyViolation = "23503"
+
+type IChannelInfoService interface {
+	// GetChannelInfo returns what the channel streams, channels that never set it have no title, category or tags
+	GetChannelInfo(channelID int) (model.ChannelInfo, error)
+	// UpdateChannelInfo replaces the info of the channel and of its live session if there is one.
+	// An empty category clears it, the tags are normalized.
+	UpdateChannelInfo(channelID int, title, category string, tags []string) (model.ChannelInfo, error)
+}
+
+type IInfoPublisher interface {
+	PublishStreamInfoUpdatedEvent(data event.StreamInfoUpdatedEventData) error
+}
+
+type ChannelInfoService struct {
+	DB        db.PgxIface
+	Publisher IInfoPublisher
+}
+
+func NewChannelInfoService(db db.PgxIface, publisher IInfoPublisher) IChannelInfoService {
+	return &ChannelInfoService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+func (s *ChannelInfoService) GetChannelInfo(channelID int) (model.ChannelInfo, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT channel_id, title, COALESCE(category, ''), tags, updated_at FROM channel_info WHERE channel_id = $1", channelID)
+	if err != nil {
+		return model.ChannelInfo{}, fmt.Errorf("GetChannelInfo: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.ChannelInfo{ChannelID: channelID, Tags: []string{}}, nil
+	}
+
+	info, err := scanChannelInfo(rows)
+	if err != nil {
+		return model.ChannelInfo{}, fmt.Errorf("GetChannelInfo: %w", err)
+	}
+
+	return info, nil
+}
+
+func (s *ChannelInfoService) UpdateChannelInfo(channelID int, title, category string, tags []string) (model.ChannelInfo, error) {
+	tags, err := model.NormalizeTags(tags)
+	if err != nil {
+		return model.ChannelInfo{}, fmt.Errorf("UpdateChannelInfo: %w", err)
+	}
+
+	var slug interface{}
+	if category != "" {
+		slug = category
+	}
+
+	rows, err := s.DB.Query(context.Background(),
+		`WITH info AS (
+			INSERT INTO channel_info (channel_id, title, category, tags, updated_at) VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (channel_id) DO UPDATE
+			SET title = EXCLUDED.title, category = EXCLUDED.category, tags = EXCLUDED.tags, updated_at = EXCLUDED.updated_at
+			RETURNING channel_id, title, category, tags, updated_at
+		), live AS (
+			UPDATE stream_sessions s SET title = info.title, category = COALESCE(info.category, ''), tags = info.tags
+			FROM info WHERE s.channel_id = info.channel_id AND s.status = $5
+		)
+		SELECT channel_id, title, COALESCE(category, ''), tags, updated_at FROM info`,
+		channelID, title, slug, tags, event.StreamStatusLive)
+	if err != nil {
+		return model.ChannelInfo{}, fmt.Errorf("UpdateChannelInfo: %w", infoError(err))
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.ChannelInfo{}, fmt.Errorf("UpdateChannelInfo: %w", infoError(rows.Err()))
+	}
+
+	info, err := scanChannelInfo(rows)
+	if err != nil {
+		return model.ChannelInfo{}, fmt.Errorf("UpdateChannelInfo: %w", err)
+	}
+
+	data := event.StreamInfoUpdatedEventData{
+		ChannelID: info.ChannelID,
+		Title:     info.Title,
+		Category:  info.Category,
+		Tags:      info.Tags,
+		UpdatedAt: time.Now(),
+	}
+	if info.UpdatedAt != nil {
+		data.UpdatedAt = *info.UpdatedAt
+	}
+
+	return info, s.Publisher.PublishStreamInfoUpdatedEvent(data)
+}
+
+// infoError maps the foreign key on the category, the catalogue is the only table channel info references
+func infoError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return model.CategoryNotFoundError
+	}
+	return err
+}
+
+func scanChannelInfo(row scanner) (model.ChannelInfo, error) {
+	var info model.ChannelInfo
+	err := row.Scan(&info.ChannelID, &info.Title, &info.Category, &info.Tags, &info.UpdatedAt)
+
+	return info, err
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+)
+
+var infoColumns = []string{"channel_id", "title", "category", "tags", "updated_at"}
+
+func TestUpdateChannelInfo(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.InfoPublisherMock{}
+	sut := &ChannelInfoService{
+		DB:        mockDB,
+		Publisher: publisher,
+	}
+
+	updatedAt := time.Now()
+	tags := []string{"english", "speed-run"}
+
+	mockDB.ExpectQuery("INSERT INTO channel_info").WithArgs(1, "title", "chess", tags, "live").
+		WillReturnRows(pgxmock.NewRows(infoColumns).AddRow(1, "title", "chess", tags, &updatedAt))
+
+	info, err := sut.UpdateChannelInfo(1, "title", "chess", []string{" English", "Speed  Run", "english"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when updating the channel info", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(info.Tags) != 2 || info.Category != "chess" {
+		t.Fatalf("Expected the normalized info, got %+v", info)
+	}
+
+	if len(publisher.Published) != 1 || publisher.Published[0].Category != "chess" || !publisher.Published[0].UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("Expected the info update to be published, got %+v", publisher.Published)
+	}
+}
+
+func TestUpdateChannelInfoUnknownCategory(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.InfoPublisherMock{}
+	sut := &ChannelInfoService{
+		DB:        mockDB,
+		Publisher: publisher,
+	}
+
+	mockDB.ExpectQuery("INSERT INTO channel_info").WillReturnError(&pgconn.PgError{Code: foreignKeyViolation})
+
+	_, err = sut.UpdateChannelInfo(1, "title", "unknown", nil)
+	if !errors.Is(err, model.CategoryNotFoundError) {
+		t.Fatalf("Expected %v, got %v", model.CategoryNotFoundError, err)
+	}
+
+	if len(publisher.Published) != 0 {
+		t.Fatalf("Expected nothing to be published, got %+v", publisher.Published)
+	}
+}
+
+func TestUpdateChannelInfoInvalidTags(t *testing.T) {
+	type tagsTest struct {
+		description string
+		tags        []string
+		expectedErr error
+	}
+
+	for _, scenario := range []tagsTest{
+		{"empty", []string{" "}, model.InvalidTagError},
+		{"punctuation", []string{"c++"}, model.InvalidTagError},
+		{"too long", []string{strings.Repeat("a", 26)}, model.InvalidTagError},
+		{"too many", strings.Split("a b c d e f g h i j k", " "), model.TooManyTagsError},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			sut := &ChannelInfoService{DB: mockDB, Publisher: &mock.InfoPublisherMock{}}
+
+			_, err = sut.UpdateChannelInfo(1, "", "", scenario.tags)
+			if !errors.Is(err, scenario.expectedErr) {
+				t.Fatalf("Expected %v, got %v", scenario.expectedErr, err)
+			}
+		})
+	}
+}
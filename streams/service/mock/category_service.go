@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+)
+
+type CategoryServiceMock struct {
+	Saved []model.Category
+}
+
+func (s *CategoryServiceMock) GetCategories(page, limit int) ([]model.Category, int, error) {
+	return []model.Category{{Slug: "chess", Name: "Chess"}}, 1, nil
+}
+
+func (s *CategoryServiceMock) GetCategory(slug string) (model.Category, error) {
+	if slug != "chess" {
+		return model.Category{}, model.CategoryNotFoundError
+	}
+	return model.Category{Slug: "chess", Name: "Chess"}, nil
+}
+
+func (s *CategoryServiceMock) SaveCategory(category model.Category) (model.Category, error) {
+	if !model.ValidSlug(category.Slug) {
+		return model.Category{}, model.InvalidSlugError
+	}
+	s.Saved = append(s.Saved, category)
+	return category, nil
+}
+
+func (s *CategoryServiceMock) GetCategoryStreams(slug string, page, limit int) ([]model.Stream, int, error) {
+	if slug != "chess" {
+		return nil, 0, model.CategoryNotFoundError
+	}
+	return []model.Stream{{SessionID: "session", ChannelID: 1, Channel: "channel", Status: event.StreamStatusLive, Category: slug, Viewers: 10}}, 1, nil
+}
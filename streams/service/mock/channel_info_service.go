@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+)
+
+type ChannelInfoServiceMock struct {
+	Updated []model.ChannelInfo
+}
+
+func (s *ChannelInfoServiceMock) GetChannelInfo(channelID int) (model.ChannelInfo, error) {
+	return model.ChannelInfo{ChannelID: channelID, Title: "title", Category: "chess", Tags: []string{"english"}}, nil
+}
+
+func (s *ChannelInfoServiceMock) UpdateChannelInfo(channelID int, title, category string, tags []string) (model.ChannelInfo, error) {
+	tags, err := model.NormalizeTags(tags)
+	if err != nil {
+		return model.ChannelInfo{}, err
+	}
+	if category != "" && category != "chess" {
+		return model.ChannelInfo{}, model.CategoryNotFoundError
+	}
+
+	info := model.ChannelInfo{ChannelID: channelID, Title: title, Category: category, Tags: tags}
+	s.Updated = append(s.Updated, info)
+	return info, nil
+}
+
+type InfoPublisherMock struct {
+	Published []event.StreamInfoUpdatedEventData
+}
+
+func (p *InfoPublisherMock) PublishStreamInfoUpdatedEvent(data event.StreamInfoUpdatedEventData) error {
+	p.Published = append(p.Published, data)
+	return nil
+}
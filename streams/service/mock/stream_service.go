@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+)
+
+type StreamServiceMock struct {
+	Started []event.StreamStartedEventData
+	Ended   []event.StreamEndedEventData
+}
+
+func (s *StreamServiceMock) StartStream(ev event.StreamStartedEventData) error {
+	s.Started = append(s.Started, ev)
+	return nil
+}
+
+func (s *StreamServiceMock) EndStream(ev event.StreamEndedEventData) error {
+	s.Ended = append(s.Ended, ev)
+	return nil
+}
+
+func (s *StreamServiceMock) GetLiveStreams(page, limit int) ([]model.Stream, int, error) {
+	return []model.Stream{{SessionID: "session", ChannelID: 1, Channel: "channel", Status: event.StreamStatusLive}}, 1, nil
+}
+
+func (s *StreamServiceMock) GetChannelStream(channel string) (model.Stream, error) {
+	return model.Stream{Channel: channel, Status: event.StreamStatusOffline}, nil
+}
+
+type StatusPublisherMock struct {
+	Published []event.StreamStatusChangedEventData
+}
+
+func (p *StatusPublisherMock) PublishStreamStatusChangedEvent(data event.StreamStatusChangedEventData) error {
+	p.Published = append(p.Published, data)
+	return nil
+}
@@ -0,0 +1,30 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+)
+
+type ViewerServiceMock struct {
+	Heartbeats []string
+}
+
+func (s *ViewerServiceMock) Heartbeat(channel, viewerID, ip string) error {
+	if channel == "crowded" {
+		return model.TooManyViewersError
+	}
+	if channel != "channel" {
+		return model.NotLiveError
+	}
+	s.Heartbeats = append(s.Heartbeats, viewerID)
+	return nil
+}
+
+type ViewerPublisherMock struct {
+	Published []event.ViewerCountUpdatedEventData
+}
+
+func (p *ViewerPublisherMock) PublishViewerCountUpdatedEvent(data event.ViewerCountUpdatedEventData) error {
+	p.Published = append(p.Published, data)
+	return nil
+}
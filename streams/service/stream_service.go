@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"time"
+)
+
+const streamColumns = "id, channel_id, channel, status, title, category, tags, started_at, ended_at, peak_viewers, viewers, unique_viewers"
+
+type IStreamService interface {
+	StartStream(ev event.StreamStartedEventData) error
+	EndStream(ev event.StreamEndedEventData) error
+	// Returns the live streams for the given page and the total number of live streams
+	GetLiveStreams(page, limit int) ([]model.Stream, int, error)
+	GetChannelStream(channel string) (model.Stream, error)
+}
+
+// IStatusPublisher is implemented by the rabbitMQ client, it lives here to avoid an import cycle as the client consumes into the service
+type IStatusPublisher interface {
+	PublishStreamStatusChangedEvent(data event.StreamStatusChangedEventData) error
+}
+
+type StreamService struct {
+	DB        db.PgxIface
+	Publisher IStatusPublisher
+}
+
+func NewStreamService(db db.PgxIface, publisher IStatusPublisher) IStreamService {
+	return &StreamService{
+		DB:        db,
+		Publisher: publisher,
+	}
+}
+
+// StartStream records a new live session. Redelivered events and sessions that already ended are ignored.
+func (s *StreamService) StartStream(ev event.StreamStartedEventData) error {
+	_, err := s.getSession(ev.SessionID)
+	if err == nil {
+		return nil
+	}
+	if err != model.StreamNotFoundError {
+		return fmt.Errorf("StartStream: %w", err)
+	}
+
+	// A broadcaster that reconnects may go live again before we saw the previous session end
+	err = s.endLiveSessions(ev.ChannelID, ev.StartedAt)
+	if err != nil {
+		return fmt.Errorf("StartStream: %w", err)
+	}
+
+	// The info the channel set through the API wins over what the broadcaster sent when connecting
+	rows, err := s.DB.Query(context.Background(),
+		`INSERT INTO stream_sessions (id, channel_id, channel, status, title, category, tags, started_at)
+		SELECT $1, $2, $3, $4, COALESCE(NULLIF(i.title, ''), $5), COALESCE(i.category, $6), COALESCE(i.tags, '{}'), $7
+		FROM (SELECT 1) AS session LEFT JOIN channel_info i ON i.channel_id = $2
+		RETURNING `+streamColumns,
+		ev.SessionID, ev.ChannelID, ev.Channel, event.StreamStatusLive, ev.Title, ev.Category, ev.StartedAt)
+
+	if err != nil {
+		return fmt.Errorf("StartStream: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return fmt.Errorf("StartStream: %w", rows.Err())
+	}
+
+	stream, err := scanStream(rows)
+	if err != nil {
+		return fmt.Errorf("StartStream: %w", err)
+	}
+
+	return s.publishStatus(stream)
+}
+
+// EndStream moves a live session to ended. If the end arrives before the start, the session is stored as ended
+// so the late start is ignored.
+func (s *StreamService) EndStream(ev event.StreamEndedEventData) error {
+	endedAt := ev.EndedAt
+
+	stream, err := s.getSession(ev.SessionID)
+	if err == model.StreamNotFoundError {
+		_, err = s.DB.Exec(context.Background(),
+			"INSERT INTO stream_sessions (id, channel_id, channel, status, ended_at) VALUES ($1,$2,$3,$4,$5) ON CONFLICT (id) DO NOTHING",
+			ev.SessionID, ev.ChannelID, ev.Channel, event.StreamStatusEnded, endedAt)
+		if err != nil {
+			return fmt.Errorf("EndStream: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("EndStream: %w", err)
+	}
+
+	if !model.CanTransition(stream.Status, event.StreamStatusEnded) {
+		// Already ended, the event was redelivered
+		return nil
+	}
+
+	_, err = s.DB.Exec(context.Background(),
+		"UPDATE stream_sessions SET status = $2, ended_at = $3, viewers = 0 WHERE id = $1 AND status = $4",
+		ev.SessionID, event.StreamStatusEnded, endedAt, stream.Status)
+
+	if err != nil {
+		return fmt.Errorf("EndStream: %w", err)
+	}
+
+	stream.Status = event.StreamStatusEnded
+	stream.EndedAt = &endedAt
+
+	return s.publishStatus(stream)
+}
+
+func (s *StreamService) GetLiveStreams(page, limit int) ([]model.Stream, int, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT count(*) FROM stream_sessions WHERE status = $1", event.StreamStatusLive)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetLiveStreams: %w", err)
+	}
+
+	var total int
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+	rows.Close()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetLiveStreams: %w", err)
+	}
+
+	rows, err = s.DB.Query(context.Background(),
+		"SELECT "+streamColumns+" FROM stream_sessions WHERE status = $1 ORDER BY started_at DESC LIMIT $2 OFFSET $3",
+		event.StreamStatusLive, limit, (page-1)*limit)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetLiveStreams: %w", err)
+	}
+
+	defer rows.Close()
+
+	streams := []model.Stream{}
+	for rows.Next() {
+		stream, err := scanStream(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GetLiveStreams: %w", err)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, total, nil
+}
+
+// GetChannelStream returns the latest session of the channel, or an offline stream if it never went live
+func (s *StreamService) GetChannelStream(channel string) (model.Stream, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+streamColumns+" FROM stream_sessions WHERE channel = $1 ORDER BY started_at DESC NULLS LAST LIMIT 1", channel)
+
+	if err != nil {
+		return model.Stream{}, fmt.Errorf("GetChannelStream: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Stream{Channel: channel, Status: event.StreamStatusOffline, Tags: []string{}}, nil
+	}
+
+	stream, err := scanStream(rows)
+	if err != nil {
+		return model.Stream{}, fmt.Errorf("GetChannelStream: %w", err)
+	}
+
+	return stream, nil
+}
+
+func (s *StreamService) getSession(id string) (model.Stream, error) {
+	rows, err := s.DB.Query(context.Background(), "SELECT "+streamColumns+" FROM stream_sessions WHERE id = $1", id)
+	if err != nil {
+		return model.Stream{}, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Stream{}, model.StreamNotFoundError
+	}
+
+	return scanStream(rows)
+}
+
+func (s *StreamService) endLiveSessions(channelID int, endedAt time.Time) error {
+	rows, err := s.DB.Query(context.Background(),
+		"UPDATE stream_sessions SET status = $2, ended_at = $3, viewers = 0 WHERE channel_id = $1 AND status = $4 RETURNING "+streamColumns,
+		channelID, event.StreamStatusEnded, endedAt, event.StreamStatusLive)
+
+	if err != nil {
+		return err
+	}
+
+	var ended []model.Stream
+	for rows.Next() {
+		stream, err := scanStream(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ended = append(ended, stream)
+	}
+	rows.Close()
+
+	for _, stream := range ended {
+		if err := s.publishStatus(stream); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *StreamService) publishStatus(stream model.Stream) error {
+	data := event.StreamStatusChangedEventData{
+		SessionID:   stream.SessionID,
+		ChannelID:   stream.ChannelID,
+		Channel:     stream.Channel,
+		Status:      stream.Status,
+		Title:       stream.Title,
+		Category:    stream.Category,
+		Tags:        stream.Tags,
+		EndedAt:     stream.EndedAt,
+		PeakViewers: stream.PeakViewers,
+	}
+	if stream.StartedAt != nil {
+		data.StartedAt = *stream.StartedAt
+	}
+
+	return s.Publisher.PublishStreamStatusChangedEvent(data)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanStream(row scanner) (model.Stream, error) {
+	var stream model.Stream
+	err := row.Scan(&stream.SessionID, &stream.ChannelID, &stream.Channel, &stream.Status, &stream.Title,
+		&stream.Category, &stream.Tags, &stream.StartedAt, &stream.EndedAt, &stream.PeakViewers, &stream.Viewers, &stream.UniqueViewers)
+
+	return stream, err
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+var columns = []string{"id", "channel_id", "channel", "status", "title", "category", "tags", "started_at", "ended_at", "peak_viewers", "viewers", "unique_viewers"}
+
+func TestStartStream(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.StatusPublisherMock{}
+	sut := &StreamService{
+		DB:        mockDB,
+		Publisher: publisher,
+	}
+
+	startedAt := time.Now()
+	previousStart := startedAt.Add(-time.Hour)
+
+	mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE id").WithArgs("new").WillReturnRows(pgxmock.NewRows(columns))
+	// The previous session never sent its end
+	mockDB.ExpectQuery("UPDATE stream_sessions SET status").
+		WithArgs(1, event.StreamStatusEnded, startedAt, event.StreamStatusLive).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("old", 1, "channel", event.StreamStatusEnded, "", "", []string{}, &previousStart, &startedAt, 10, 0, 0))
+	// The channel set its category and tags through the API before going live
+	mockDB.ExpectQuery("INSERT INTO stream_sessions").
+		WithArgs("new", 1, "channel", event.StreamStatusLive, "title", "category", startedAt).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("new", 1, "channel", event.StreamStatusLive, "title", "chess", []string{"english"}, &startedAt, nil, 0, 0, 0))
+
+	err = sut.StartStream(event.StreamStartedEventData{
+		SessionID: "new",
+		ChannelID: 1,
+		Channel:   "channel",
+		Title:     "title",
+		Category:  "category",
+		StartedAt: startedAt,
+	})
+
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting a stream", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(publisher.Published) != 2 {
+		t.Fatalf("Expected %d status changes, got %d", 2, len(publisher.Published))
+	}
+
+	if got := publisher.Published[0]; got.SessionID != "old" || got.Status != event.StreamStatusEnded {
+		t.Fatalf("Expected the old session to end first, got %+v", got)
+	}
+
+	if got := publisher.Published[1]; got.SessionID != "new" || got.Status != event.StreamStatusLive || got.Title != "title" ||
+		got.Category != "chess" || len(got.Tags) != 1 {
+		t.Fatalf("Expected the new session to go live, got %+v", got)
+	}
+}
+
+func TestStartStreamRedelivered(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.StatusPublisherMock{}
+	sut := &StreamService{
+		DB:        mockDB,
+		Publisher: publisher,
+	}
+
+	startedAt := time.Now()
+	mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE id").WithArgs("session").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("session", 1, "channel", event.StreamStatusLive, "", "", []string{}, &startedAt, nil, 0, 0, 0))
+
+	err = sut.StartStream(event.StreamStartedEventData{SessionID: "session", ChannelID: 1, Channel: "channel"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when starting a stream", err)
+	}
+
+	if len(publisher.Published) != 0 {
+		t.Fatalf("Expected no status changes for a redelivered event, got %v", publisher.Published)
+	}
+}
+
+func TestEndStream(t *testing.T) {
+	type endTest struct {
+		description     string
+		rows            *pgxmock.Rows
+		expectExec      string
+		expectPublished int
+	}
+
+	startedAt := time.Now().Add(-time.Hour)
+	endedAt := time.Now()
+
+	for _, scenario := range []endTest{
+		{
+			description:     "live session",
+			rows:            pgxmock.NewRows(columns).AddRow("session", 1, "channel", event.StreamStatusLive, "", "", []string{}, &startedAt, nil, 5, 0, 0),
+			expectExec:      "UPDATE stream_sessions",
+			expectPublished: 1,
+		},
+		{
+			description:     "already ended",
+			rows:            pgxmock.NewRows(columns).AddRow("session", 1, "channel", event.StreamStatusEnded, "", "", []string{}, &startedAt, &endedAt, 5, 0, 0),
+			expectPublished: 0,
+		},
+		{
+			description:     "end before start",
+			rows:            pgxmock.NewRows(columns),
+			expectExec:      "INSERT INTO stream_sessions",
+			expectPublished: 0,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			mockDB, err := pgxmock.NewConn()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer mockDB.Close(context.Background())
+
+			publisher := &mock.StatusPublisherMock{}
+			sut := &StreamService{
+				DB:        mockDB,
+				Publisher: publisher,
+			}
+
+			mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE id").WithArgs("session").WillReturnRows(scenario.rows)
+			if scenario.expectExec != "" {
+				mockDB.ExpectExec(scenario.expectExec).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			}
+
+			err = sut.EndStream(event.StreamEndedEventData{SessionID: "session", ChannelID: 1, Channel: "channel", EndedAt: endedAt})
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when ending a stream", err)
+			}
+
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Fatalf("there were unfulfilled expectations: %s", err)
+			}
+
+			if len(publisher.Published) != scenario.expectPublished {
+				t.Fatalf("Expected %d status changes, got %d", scenario.expectPublished, len(publisher.Published))
+			}
+
+			if scenario.expectPublished > 0 {
+				if got := publisher.Published[0]; got.Status != event.StreamStatusEnded || got.EndedAt == nil || got.PeakViewers != 5 {
+					t.Fatalf("Expected an ended status change, got %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLiveStreams(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &StreamService{DB: mockDB}
+
+	startedAt := time.Now()
+	mockDB.ExpectQuery("SELECT count").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(21))
+	mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE status").
+		WithArgs(event.StreamStatusLive, 10, 20).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("session", 1, "channel", event.StreamStatusLive, "title", "", []string{}, &startedAt, nil, 3, 0, 0))
+
+	streams, total, err := sut.GetLiveStreams(3, 10)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when listing streams", err)
+	}
+
+	if total != 21 {
+		t.Fatalf("Expected total to be %d got %d", 21, total)
+	}
+
+	if len(streams) != 1 || streams[0].Channel != "channel" || streams[0].Title != "title" {
+		t.Fatalf("Expected the live stream, got %+v", streams)
+	}
+}
+
+func TestGetChannelStreamOffline(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := &StreamService{DB: mockDB}
+
+	mockDB.ExpectQuery("SELECT (.+) FROM stream_sessions WHERE channel").WithArgs("channel").WillReturnRows(pgxmock.NewRows(columns))
+
+	stream, err := sut.GetChannelStream("channel")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when getting a stream", err)
+	}
+
+	if stream.Status != event.StreamStatusOffline || stream.Channel != "channel" {
+		t.Fatalf("Expected an offline stream, got %+v", stream)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/presence"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type IViewerService interface {
+	// Heartbeat marks the viewer as watching the channel, players send one every 30 seconds. Anonymous viewers come
+	// with the IP of the player, only so many of them are counted per IP.
+	Heartbeat(channel, viewerID, ip string) error
+}
+
+type IViewerPublisher interface {
+	PublishViewerCountUpdatedEvent(data event.ViewerCountUpdatedEventData) error
+}
+
+// ViewerService counts the viewers in memory and periodically writes the counts to the live sessions.
+// The tracker learns which sessions are live on every flush, a channel that just went live accepts heartbeats after the next one.
+type ViewerService struct {
+	DB        db.PgxIface
+	Publisher IViewerPublisher
+	Tracker   *presence.Tracker
+	// SnapshotPath is where the tracker is saved after every flush, without it the counts start over on restart
+	SnapshotPath string
+	logger       *zap.SugaredLogger
+	// published holds the last counts sent for every session, unchanged counts aren't sent again
+	published map[string]presence.Count
+}
+
+func NewViewerService(db db.PgxIface, publisher IViewerPublisher, tracker *presence.Tracker, snapshotPath string, logger *zap.SugaredLogger) *ViewerService {
+	return &ViewerService{
+		DB:           db,
+		Publisher:    publisher,
+		Tracker:      tracker,
+		SnapshotPath: snapshotPath,
+		logger:       logger,
+		published:    map[string]presence.Count{},
+	}
+}
+
+func (s *ViewerService) Heartbeat(channel, viewerID, ip string) error {
+	err := s.Tracker.Heartbeat(channel, viewerID, ip, time.Now())
+	if errors.Is(err, presence.ErrNotLive) {
+		return fmt.Errorf("Heartbeat: %w", model.NotLiveError)
+	}
+	if errors.Is(err, presence.ErrTooManyViewers) {
+		return fmt.Errorf("Heartbeat: %w", model.TooManyViewersError)
+	}
+
+	return err
+}
+
+// Flush syncs the tracker with the live sessions, stores their counts and publishes the ones that changed
+func (s *ViewerService) Flush(ctx context.Context, now time.Time) error {
+	rows, err := s.DB.Query(ctx, "SELECT id, channel_id, channel FROM stream_sessions WHERE status = $1", event.StreamStatusLive)
+	if err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+
+	live := []presence.Session{}
+	for rows.Next() {
+		var session presence.Session
+		if err := rows.Scan(&session.SessionID, &session.ChannelID, &session.Channel); err != nil {
+			rows.Close()
+			return fmt.Errorf("Flush: %w", err)
+		}
+		live = append(live, session)
+	}
+	rows.Close()
+
+	s.Tracker.Sync(live)
+	counts := s.Tracker.Counts(now)
+
+	if len(counts) == 0 {
+		s.published = map[string]presence.Count{}
+		return nil
+	}
+
+	ids := make([]string, len(counts))
+	viewers := make([]int, len(counts))
+	uniques := make([]int, len(counts))
+	for i, count := range counts {
+		ids[i], viewers[i], uniques[i] = count.SessionID, count.Viewers, count.UniqueViewers
+	}
+
+	_, err = s.DB.Exec(ctx, `
+		UPDATE stream_sessions s SET viewers = c.viewers, unique_viewers = c.uniques, peak_viewers = GREATEST(s.peak_viewers, c.viewers)
+		FROM unnest($1::varchar[], $2::integer[], $3::integer[]) AS c(id, viewers, uniques)
+		WHERE s.id = c.id AND s.status = $4`,
+		ids, viewers, uniques, event.StreamStatusLive)
+	if err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+
+	published := make(map[string]presence.Count, len(counts))
+	for _, count := range counts {
+		published[count.SessionID] = count
+		if s.published[count.SessionID] == count {
+			continue
+		}
+
+		err := s.Publisher.PublishViewerCountUpdatedEvent(event.ViewerCountUpdatedEventData{
+			SessionID:     count.SessionID,
+			ChannelID:     count.ChannelID,
+			Channel:       count.Channel,
+			Viewers:       count.Viewers,
+			UniqueViewers: count.UniqueViewers,
+			PeakViewers:   count.PeakViewers,
+			CountedAt:     now,
+		})
+		if err != nil {
+			// Sent again on the next flush
+			delete(published, count.SessionID)
+			s.logger.Errorf("failed to publish the viewer count of %s: %v", count.SessionID, err)
+		}
+	}
+	s.published = published
+
+	return nil
+}
+
+// Restore loads the snapshot left by the previous run
+func (s *ViewerService) Restore() error {
+	if s.SnapshotPath == "" {
+		return nil
+	}
+
+	return s.Tracker.LoadSnapshot(s.SnapshotPath)
+}
+
+func (s *ViewerService) Snapshot() error {
+	if s.SnapshotPath == "" {
+		return nil
+	}
+
+	return s.Tracker.SaveSnapshot(s.SnapshotPath)
+}
+
+// RunCounts flushes and snapshots the counts every interval until ctx is cancelled
+func (s *ViewerService) RunCounts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Flush(ctx, time.Now()); err != nil {
+			s.logger.Errorf("failed to flush the viewer counts: %v", err)
+		}
+		if err := s.Snapshot(); err != nil {
+			s.logger.Errorf("failed to snapshot the viewer counts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
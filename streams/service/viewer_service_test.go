@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/streams/model"
+	"nikolamilovic/twitchy/streams/presence"
+	"nikolamilovic/twitchy/streams/service/mock"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+var liveColumns = []string{"id", "channel_id", "channel"}
+
+func TestFlushViewerCounts(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	publisher := &mock.ViewerPublisherMock{}
+	sut := NewViewerService(mockDB, publisher, presence.NewTracker(4, time.Minute, 0), "", zap.L().Sugar())
+
+	now := time.Now()
+
+	// The channel isn't known to be live until the first flush
+	if err := sut.Heartbeat("channel", "viewer", ""); !errors.Is(err, model.NotLiveError) {
+		t.Fatalf("Expected %v before the first flush, got %v", model.NotLiveError, err)
+	}
+
+	mockDB.ExpectQuery("SELECT id, channel_id, channel FROM stream_sessions").WithArgs(event.StreamStatusLive).
+		WillReturnRows(pgxmock.NewRows(liveColumns).AddRow("session", 1, "channel"))
+	mockDB.ExpectExec("UPDATE stream_sessions").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := sut.Flush(context.Background(), now); err != nil {
+		t.Fatalf("an error '%s' was not expected when flushing the counts", err)
+	}
+
+	for _, viewer := range []string{"first", "second", "first"} {
+		if err := sut.Heartbeat("channel", viewer, ""); err != nil {
+			t.Fatalf("an error '%s' was not expected for a heartbeat", err)
+		}
+	}
+
+	// Twice with the same viewers, only the first one changes the counts
+	for i := 0; i < 2; i++ {
+		mockDB.ExpectQuery("SELECT id, channel_id, channel FROM stream_sessions").WithArgs(event.StreamStatusLive).
+			WillReturnRows(pgxmock.NewRows(liveColumns).AddRow("session", 1, "channel"))
+		mockDB.ExpectExec("UPDATE stream_sessions").WithArgs([]string{"session"}, []int{2}, []int{2}, event.StreamStatusLive).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		if err := sut.Flush(context.Background(), now); err != nil {
+			t.Fatalf("an error '%s' was not expected when flushing the counts", err)
+		}
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if len(publisher.Published) != 2 {
+		t.Fatalf("Expected %d viewer count updates, got %+v", 2, publisher.Published)
+	}
+
+	if got := publisher.Published[1]; got.SessionID != "session" || got.Viewers != 2 || got.UniqueViewers != 2 || got.PeakViewers != 2 {
+		t.Fatalf("Expected 2 viewers, got %+v", got)
+	}
+}
+
+func TestFlushEndedSession(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	tracker := presence.NewTracker(4, time.Minute, 0)
+	tracker.Sync([]presence.Session{{SessionID: "session", ChannelID: 1, Channel: "channel"}})
+
+	sut := NewViewerService(mockDB, &mock.ViewerPublisherMock{}, tracker, "", zap.L().Sugar())
+
+	mockDB.ExpectQuery("SELECT id, channel_id, channel FROM stream_sessions").WithArgs(event.StreamStatusLive).
+		WillReturnRows(pgxmock.NewRows(liveColumns))
+
+	if err := sut.Flush(context.Background(), time.Now()); err != nil {
+		t.Fatalf("an error '%s' was not expected when flushing the counts", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	if err := sut.Heartbeat("channel", "viewer", ""); !errors.Is(err, model.NotLiveError) {
+		t.Fatalf("Expected %v once the session ended, got %v", model.NotLiveError, err)
+	}
+}
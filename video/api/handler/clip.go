@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/model/response"
+	"nikolamilovic/twitchy/video/service"
+	"nikolamilovic/twitchy/video/storage"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type ClipHandler struct {
+	router      *chi.Mux
+	validator   *validator.Validate
+	clipService service.IClipService
+	jwtSecret   []byte
+	logger      *zap.SugaredLogger
+}
+
+func NewClipHandler(validator *validator.Validate, clips service.IClipService, jwtSecret []byte, logger *zap.SugaredLogger) *ClipHandler {
+	h := &ClipHandler{}
+
+	h.validator = validator
+	h.clipService = clips
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *ClipHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *ClipHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Post("/", h.handleCreate())
+	r.Get("/", h.handleList())
+	r.Get("/{id}", h.handleDetail())
+	r.Get("/{id}/index.m3u8", h.handlePlaylist())
+	r.Get("/{id}/{segment}", h.handleSegment())
+}
+
+func (h *ClipHandler) handleCreate() http.HandlerFunc {
+	type ClipRequest struct {
+		Channel string `json:"channel" validate:"required"`
+		// Session of a past broadcast, clips the live broadcast when empty
+		Session  string  `json:"session"`
+		Offset   float64 `json:"offset" validate:"gte=0"`
+		Duration float64 `json:"duration" validate:"gt=0,lte=60"`
+		Title    string  `json:"title" validate:"required,max=140"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := token.FromHeaders(r.Header.Get, h.jwtSecret)
+		if err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		var req ClipRequest
+
+		if err := utils.DecodeJSONBody(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !channelPattern.MatchString(req.Channel) || (req.Session != "" && !sessionPattern.MatchString(req.Session)) {
+			http.Error(w, "invalid channel or session", http.StatusBadRequest)
+			return
+		}
+
+		clip, err := h.clipService.CreateClip(r.Context(), model.ClipRequest{
+			CreatorID: claims.UserId,
+			Channel:   req.Channel,
+			SessionID: req.Session,
+			Offset:    req.Offset,
+			Duration:  req.Duration,
+			Title:     req.Title,
+		})
+
+		switch {
+		case err == nil:
+			writeJSONStatus(h.logger, w, http.StatusCreated, clip)
+		case errors.Is(err, model.ChannelOfflineError), errors.Is(err, storage.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, model.ClipOutOfRangeError):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.logger.Errorf("failed to create a clip of %s: %v", req.Channel, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *ClipHandler) handleList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := r.URL.Query().Get("channel")
+		if !channelPattern.MatchString(channel) {
+			http.Error(w, "invalid channel", http.StatusBadRequest)
+			return
+		}
+
+		page, limit, err := pagination(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		clips, total, err := h.clipService.ListClips(r.Context(), channel, page, limit)
+		if err != nil {
+			h.logger.Errorf("failed to list the clips of %s: %v", channel, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, response.ClipsResponse{
+			Clips: clips,
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		})
+	}
+}
+
+func (h *ClipHandler) handleDetail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !sessionPattern.MatchString(id) {
+			http.NotFound(w, r)
+			return
+		}
+
+		clip, err := h.clipService.GetClip(r.Context(), id)
+		if err != nil {
+			writeClipError(h.logger, w, r, err)
+			return
+		}
+
+		writeJSON(h.logger, w, clip)
+	}
+}
+
+func (h *ClipHandler) handlePlaylist() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !sessionPattern.MatchString(id) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.clipService.Playlist(r.Context(), id)
+		if err != nil {
+			writeClipError(h.logger, w, r, err)
+			return
+		}
+
+		// Every fetch counts as a view so the playlist can't be cached
+		writeMedia(w, data, playlistContentType, "no-cache")
+	}
+}
+
+func (h *ClipHandler) handleSegment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		segment := chi.URLParam(r, "segment")
+
+		if !sessionPattern.MatchString(id) || !segmentPattern.MatchString(segment) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.clipService.Segment(r.Context(), id, segment)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		writeMedia(w, data, segmentContentType, segmentCacheControl)
+	}
+}
+
+func writeClipError(logger *zap.SugaredLogger, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, model.ClipNotFoundError) {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeBlobError(logger, w, r, err)
+}
+
+// pagination reads the page and limit query parameters, page starts at 1
+func pagination(r *http.Request) (int, int, error) {
+	query := r.URL.Query()
+
+	page, limit := query.Get("page"), query.Get("limit")
+	if page == "" {
+		page = "1"
+	}
+	if limit == "" {
+		limit = strconv.Itoa(defaultLimit)
+	}
+
+	p, pageErr := strconv.Atoi(page)
+	l, limitErr := strconv.Atoi(limit)
+
+	if pageErr != nil || limitErr != nil || p < 1 || l < 1 || l > maxLimit {
+		return 0, 0, errors.New("page must be positive and limit between 1 and 100")
+	}
+
+	return p, l, nil
+}
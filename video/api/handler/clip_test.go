@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/model/response"
+	"nikolamilovic/twitchy/video/service/mock"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+func TestCreateClip(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(7, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	type createTest struct {
+		description    string
+		token          string
+		body           string
+		expectedStatus int
+	}
+
+	for _, scenario := range []createTest{
+		{
+			description:    "live clip",
+			token:          "Bearer " + jwt,
+			body:           `{"channel":"test","duration":30,"title":"title"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			description:    "missing token",
+			body:           `{"channel":"test","duration":30,"title":"title"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "too long",
+			token:          "Bearer " + jwt,
+			body:           `{"channel":"test","duration":61,"title":"title"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "invalid session",
+			token:          "Bearer " + jwt,
+			body:           `{"channel":"test","session":"../live","duration":30,"title":"title"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "offline channel",
+			token:          "Bearer " + jwt,
+			body:           `{"channel":"offline","duration":30,"title":"title"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", scenario.token)
+			w := httptest.NewRecorder()
+
+			clips := &mock.ClipServiceMock{}
+			srv := NewClipHandler(validator.New(), clips, []byte(secret), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if scenario.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var clip model.Clip
+			json.NewDecoder(w.Result().Body).Decode(&clip)
+
+			if clip.CreatorID != 7 || len(clips.Requests) != 1 || clips.Requests[0].Duration != 30 {
+				t.Fatalf("expected the clip to be created by the token owner, instead got: %+v", clip)
+			}
+		})
+	}
+}
+
+func TestListClips(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?channel=test&page=2", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewClipHandler(validator.New(), &mock.ClipServiceMock{}, []byte("secret"), zap.NewNop().Sugar())
+	srv.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.ClipsResponse
+	json.Unmarshal(data, &responseData)
+
+	if responseData.Page != 2 || responseData.Limit != defaultLimit || len(responseData.Clips) != 1 {
+		t.Fatalf("expected the second page of clips, instead got: %+v", responseData)
+	}
+
+	for _, query := range []string{"", "channel=test&limit=101", "channel=test&page=0"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+
+		if want, got := http.StatusBadRequest, w.Result().StatusCode; want != got {
+			t.Fatalf("expected a %d for %q, instead got: %d", want, query, got)
+		}
+	}
+}
+
+func TestClipRoutes(t *testing.T) {
+	for path, status := range map[string]int{
+		"/0123456789abcdef":            http.StatusOK,
+		"/ffffffffffffffff":            http.StatusNotFound,
+		"/0123456789abcdef/index.m3u8": http.StatusOK,
+		"/ffffffffffffffff/index.m3u8": http.StatusNotFound,
+		"/0123456789abcdef/0.ts":       http.StatusOK,
+		"/0123456789abcdef/secret.ts":  http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+
+		srv := NewClipHandler(validator.New(), &mock.ClipServiceMock{}, []byte("secret"), zap.NewNop().Sugar())
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("expected a %d for %s, instead got: %d", want, path, got)
+		}
+	}
+}
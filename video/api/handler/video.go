@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/video/flv"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/service"
+	"nikolamilovic/twitchy/video/storage"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+const (
+	// Live playlists change every segment, let caches hold them for a fraction of the target duration at most
+	playlistCacheControl = "public, max-age=1"
+	// Segment URIs are unique per session so once written they never change
+	segmentCacheControl = "public, max-age=31536000, immutable"
+
+	playlistContentType = "application/vnd.apple.mpegurl"
+	segmentContentType  = "video/mp2t"
+)
+
+var (
+	// Channels are named after the usernames of their owners
+	channelPattern = utils.UsernamePattern
+	sessionPattern = regexp.MustCompile(`^[a-f0-9]{16}$`)
+	segmentPattern = regexp.MustCompile(`^[0-9]+\.ts$`)
+)
+
+type VideoHandler struct {
+	router         *chi.Mux
+	liveService    service.ILiveService
+	channelService service.IChannelService
+	jwtSecret      []byte
+	logger         *zap.SugaredLogger
+}
+
+func NewVideoHandler(live service.ILiveService, channels service.IChannelService, jwtSecret []byte, logger *zap.SugaredLogger) *VideoHandler {
+	h := &VideoHandler{}
+
+	h.liveService = live
+	h.channelService = channels
+	h.jwtSecret = jwtSecret
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *VideoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *VideoHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Post("/ingest/{channel}", h.handleIngest())
+	r.Get("/live/{channel}/index.m3u8", h.handlePlaylist())
+	r.Get("/live/{channel}/{session}/{segment}", h.handleSegment())
+}
+
+// handleIngest accepts an HTTP-FLV push, the request body is the FLV stream and the request lasts as long as the broadcast.
+// The stream title and category are passed as query parameters, broadcasters can only ingest into their own channel.
+func (h *VideoHandler) handleIngest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		if !channelPattern.MatchString(channel) {
+			http.Error(w, "invalid channel", http.StatusBadRequest)
+			return
+		}
+
+		claims, err := token.FromHeaders(r.Header.Get, h.jwtSecret)
+		if err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		owned, err := h.channelService.GetChannel(r.Context(), claims.UserId)
+		switch {
+		case errors.Is(err, model.ChannelNotFoundError):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			h.logger.Errorf("failed to get the channel of user %d: %v", claims.UserId, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if owned != channel {
+			http.Error(w, model.ChannelNotOwnedError.Error(), http.StatusForbidden)
+			return
+		}
+
+		broadcast := model.Broadcast{
+			ChannelID: claims.UserId,
+			Channel:   channel,
+			Title:     r.URL.Query().Get("title"),
+			Category:  r.URL.Query().Get("category"),
+		}
+
+		err = h.liveService.Ingest(r.Context(), broadcast, r.Body)
+
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, service.ErrAlreadyLive):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, flv.ErrInvalidHeader), errors.Is(err, flv.ErrUnsupportedCodec), errors.Is(err, flv.ErrShortPacket):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.logger.Errorf("failed to ingest the stream of %s: %v", channel, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *VideoHandler) handlePlaylist() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		if !channelPattern.MatchString(channel) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.liveService.Playlist(r.Context(), channel)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		writeMedia(w, data, playlistContentType, playlistCacheControl)
+	}
+}
+
+func (h *VideoHandler) handleSegment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		session := chi.URLParam(r, "session")
+		segment := chi.URLParam(r, "segment")
+
+		if !channelPattern.MatchString(channel) || !sessionPattern.MatchString(session) || !segmentPattern.MatchString(segment) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.liveService.Segment(r.Context(), channel, session, segment)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		writeMedia(w, data, segmentContentType, segmentCacheControl)
+	}
+}
+
+func writeMedia(w http.ResponseWriter, data []byte, contentType, cacheControl string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	// Players are usually served from a different origin than the API
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func writeBlobError(logger *zap.SugaredLogger, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		// Don't let caches hold on to a miss, the stream may start any moment
+		w.Header().Set("Cache-Control", "no-cache")
+		http.NotFound(w, r)
+		return
+	}
+
+	logger.Errorf("failed to read the blob: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/video/service/mock"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPlaylist(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/live/test/index.m3u8", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewVideoHandler(&mock.LiveServiceMock{}, &mock.ChannelServiceMock{}, []byte("secret"), zap.NewNop().Sugar())
+	srv.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	if want, got := "#EXTM3U\n", string(data); want != got {
+		t.Fatalf("expected a %s, instead got: %s", want, got)
+	}
+
+	if want, got := playlistContentType, res.Header.Get("Content-Type"); want != got {
+		t.Fatalf("expected a %s, instead got: %s", want, got)
+	}
+
+	if want, got := playlistCacheControl, res.Header.Get("Cache-Control"); want != got {
+		t.Fatalf("expected a %s, instead got: %s", want, got)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	type segmentTest struct {
+		description    string
+		path           string
+		expectedStatus int
+		expectedCache  string
+	}
+
+	for _, scenario := range []segmentTest{
+		{
+			description:    "existing segment",
+			path:           "/live/test/0123456789abcdef/12.ts",
+			expectedStatus: http.StatusOK,
+			expectedCache:  segmentCacheControl,
+		},
+		{
+			description:    "invalid session",
+			path:           "/live/test/session/12.ts",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			description:    "invalid segment name",
+			path:           "/live/test/0123456789abcdef/index.m3u8",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			description:    "offline channel playlist",
+			path:           "/live/offline/index.m3u8",
+			expectedStatus: http.StatusNotFound,
+			expectedCache:  "no-cache",
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, scenario.path, nil)
+			w := httptest.NewRecorder()
+
+			srv := NewVideoHandler(&mock.LiveServiceMock{}, &mock.ChannelServiceMock{}, []byte("secret"), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if want, got := scenario.expectedCache, w.Result().Header.Get("Cache-Control"); want != got {
+				t.Fatalf("expected a %s, instead got: %s", want, got)
+			}
+		})
+	}
+}
+
+func TestIngestRequiresToken(t *testing.T) {
+	secret := "secret"
+	jwt, err := test_util.GenerateTokens(1, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	for token, status := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer broken": http.StatusUnauthorized,
+		"Bearer " + jwt: http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/ingest/test", strings.NewReader("FLV"))
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+
+		srv := NewVideoHandler(&mock.LiveServiceMock{}, &mock.ChannelServiceMock{Channels: map[int]string{1: "test"}}, []byte(secret), zap.NewNop().Sugar())
+		srv.ServeHTTP(w, req)
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("expected a %d for %q, instead got: %d", want, token, got)
+		}
+	}
+}
+
+func TestIngestIntoOwnChannel(t *testing.T) {
+	secret := "secret"
+	channels := &mock.ChannelServiceMock{Channels: map[int]string{1: "test", 2: "other"}}
+
+	type ingestTest struct {
+		description    string
+		userID         int
+		expectedStatus int
+	}
+
+	for _, scenario := range []ingestTest{
+		{
+			description:    "own channel",
+			userID:         1,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			description:    "someone else's channel",
+			userID:         2,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			description:    "user without a channel",
+			userID:         3,
+			expectedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			jwt, err := test_util.GenerateTokens(scenario.userID, secret)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/ingest/test?title=title", strings.NewReader("FLV"))
+			req.Header.Set("Authorization", "Bearer "+jwt)
+			w := httptest.NewRecorder()
+
+			live := &mock.LiveServiceMock{}
+			srv := NewVideoHandler(live, channels, []byte(secret), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if scenario.expectedStatus != http.StatusNoContent {
+				if len(live.Broadcasts) != 0 {
+					t.Fatalf("expected no broadcast to start, instead got: %+v", live.Broadcasts)
+				}
+				return
+			}
+
+			if len(live.Broadcasts) != 1 || live.Broadcasts[0].ChannelID != 1 || live.Broadcasts[0].Channel != "test" {
+				t.Fatalf("expected a broadcast of channel 1 as test, instead got: %+v", live.Broadcasts)
+			}
+		})
+	}
+}
+
+func TestIngestWithForwardedUser(t *testing.T) {
+	channels := &mock.ChannelServiceMock{Channels: map[int]string{1: "test"}}
+
+	type forwardedTest struct {
+		description    string
+		trustGateway   bool
+		userID         string
+		expectedStatus int
+	}
+
+	for _, scenario := range []forwardedTest{
+		{
+			description:    "user forwarded by the gateway",
+			trustGateway:   true,
+			userID:         "1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			description:    "someone else forwarded by the gateway",
+			trustGateway:   true,
+			userID:         "2",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			description:    "forwarded user without trusting the gateway",
+			userID:         "1",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			token.TrustGateway(scenario.trustGateway)
+			defer token.TrustGateway(false)
+
+			req := httptest.NewRequest(http.MethodPost, "/ingest/test?title=title", strings.NewReader("FLV"))
+			req.Header.Set(token.HeaderUserID, scenario.userID)
+			w := httptest.NewRecorder()
+
+			srv := NewVideoHandler(&mock.LiveServiceMock{}, channels, []byte("secret"), zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
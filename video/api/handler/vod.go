@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"nikolamilovic/twitchy/video/model/response"
+	"nikolamilovic/twitchy/video/service"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+)
+
+type VodHandler struct {
+	router     *chi.Mux
+	vodService service.IVodService
+	logger     *zap.SugaredLogger
+}
+
+func NewVodHandler(vods service.IVodService, logger *zap.SugaredLogger) *VodHandler {
+	h := &VodHandler{}
+
+	h.vodService = vods
+	h.logger = logger
+
+	h.Routes()
+
+	return h
+}
+
+func (h *VodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *VodHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Get("/{channel}", h.handleList())
+	r.Get("/{channel}/{session}", h.handleDetail())
+	r.Get("/{channel}/{session}/index.m3u8", h.handlePlaylist())
+	r.Get("/{channel}/{session}/{segment}", h.handleSegment())
+}
+
+func (h *VodHandler) handleList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		if !channelPattern.MatchString(channel) {
+			http.NotFound(w, r)
+			return
+		}
+
+		vods, err := h.vodService.ListVods(r.Context(), channel)
+		if err != nil {
+			h.logger.Errorf("failed to list the VODs of %s: %v", channel, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(h.logger, w, response.VodsResponse{Vods: vods})
+	}
+}
+
+func (h *VodHandler) handleDetail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		session := chi.URLParam(r, "session")
+
+		if !channelPattern.MatchString(channel) || !sessionPattern.MatchString(session) {
+			http.NotFound(w, r)
+			return
+		}
+
+		vod, err := h.vodService.GetVod(r.Context(), channel, session)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		writeJSON(h.logger, w, vod)
+	}
+}
+
+func (h *VodHandler) handlePlaylist() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		session := chi.URLParam(r, "session")
+
+		if !channelPattern.MatchString(channel) || !sessionPattern.MatchString(session) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.vodService.Playlist(r.Context(), channel, session)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		// A recording that is still running keeps growing like a live playlist
+		writeMedia(w, data, playlistContentType, playlistCacheControl)
+	}
+}
+
+func (h *VodHandler) handleSegment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channel := chi.URLParam(r, "channel")
+		session := chi.URLParam(r, "session")
+		segment := chi.URLParam(r, "segment")
+
+		if !channelPattern.MatchString(channel) || !sessionPattern.MatchString(session) || !segmentPattern.MatchString(segment) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := h.vodService.Segment(r.Context(), channel, session, segment)
+		if err != nil {
+			writeBlobError(h.logger, w, r, err)
+			return
+		}
+
+		writeMedia(w, data, segmentContentType, segmentCacheControl)
+	}
+}
+
+func writeJSON(logger *zap.SugaredLogger, w http.ResponseWriter, data interface{}) {
+	writeJSONStatus(logger, w, http.StatusOK, data)
+}
+
+func writeJSONStatus(logger *zap.SugaredLogger, w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("failed to write the response: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/video/model/response"
+	"nikolamilovic/twitchy/video/service/mock"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestListVods(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewVodHandler(&mock.VodServiceMock{}, zap.NewNop().Sugar())
+	srv.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.VodsResponse
+	json.Unmarshal(data, &responseData)
+
+	if len(responseData.Vods) != 1 || responseData.Vods[0].Channel != "test" {
+		t.Fatalf("expected the channel VODs, instead got: %+v", responseData)
+	}
+}
+
+func TestVodRoutes(t *testing.T) {
+	type vodTest struct {
+		description    string
+		path           string
+		expectedStatus int
+		expectedType   string
+	}
+
+	for _, scenario := range []vodTest{
+		{
+			description:    "detail",
+			path:           "/test/0123456789abcdef",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+		},
+		{
+			description:    "missing detail",
+			path:           "/offline/0123456789abcdef",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			description:    "playlist",
+			path:           "/test/0123456789abcdef/index.m3u8",
+			expectedStatus: http.StatusOK,
+			expectedType:   playlistContentType,
+		},
+		{
+			description:    "segment",
+			path:           "/test/0123456789abcdef/3.ts",
+			expectedStatus: http.StatusOK,
+			expectedType:   segmentContentType,
+		},
+		{
+			description:    "invalid segment name",
+			path:           "/test/0123456789abcdef/manifest.json",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			description:    "invalid session",
+			path:           "/test/session/index.m3u8",
+			expectedStatus: http.StatusNotFound,
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, scenario.path, nil)
+			w := httptest.NewRecorder()
+
+			srv := NewVodHandler(&mock.VodServiceMock{}, zap.NewNop().Sugar())
+			srv.ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+
+			if scenario.expectedType != "" {
+				if want, got := scenario.expectedType, w.Result().Header.Get("Content-Type"); want != got {
+					t.Fatalf("expected a %s, instead got: %s", want, got)
+				}
+			}
+		})
+	}
+}
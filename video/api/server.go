@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/video/api/handler"
+	"nikolamilovic/twitchy/video/service"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+type Server struct {
+	mux         *chi.Mux
+	validator   *validator.Validate
+	liveService service.ILiveService
+	vodService  service.IVodService
+	clipService service.IClipService
+	channels    service.IChannelService
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func NewServer(live service.ILiveService, vods service.IVodService, clips service.IClipService, channels service.IChannelService, jwtSecret []byte, logger *zap.SugaredLogger) (*Server, error) {
+	s := &Server{
+		mux:         chi.NewMux(),
+		validator:   validator.New(),
+		liveService: live,
+		vodService:  vods,
+		clipService: clips,
+		channels:    channels,
+	}
+
+	s.mux.Use(metrics.Middleware)
+
+	//Routing
+	h := handler.NewVideoHandler(s.liveService, s.channels, jwtSecret, logger.Named("video_handler"))
+
+	vh := handler.NewVodHandler(s.vodService, logger.Named("vod_handler"))
+
+	ch := handler.NewClipHandler(s.validator, s.clipService, jwtSecret, logger.Named("clip_handler"))
+
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/video/vods", vh)
+	s.mux.Mount("/v1/video/clips", ch)
+	s.mux.Mount("/v1/video", h)
+	return s, nil
+}
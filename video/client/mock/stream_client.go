@@ -0,0 +1,25 @@
+package mock
+
+import "nikolamilovic/twitchy/common/event"
+
+// StreamClientMock records the published events
+type StreamClientMock struct {
+	Started []event.StreamStartedEventData
+	Ended   []event.StreamEndedEventData
+	Clips   []event.ClipCreatedEventData
+}
+
+func (c *StreamClientMock) PublishStreamStartedEvent(data event.StreamStartedEventData) error {
+	c.Started = append(c.Started, data)
+	return nil
+}
+
+func (c *StreamClientMock) PublishStreamEndedEvent(data event.StreamEndedEventData) error {
+	c.Ended = append(c.Ended, data)
+	return nil
+}
+
+func (c *StreamClientMock) PublishClipCreatedEvent(data event.ClipCreatedEventData) error {
+	c.Clips = append(c.Clips, data)
+	return nil
+}
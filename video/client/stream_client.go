@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// How long closing waits for the messages being processed
+	closeTimeout = 10 * time.Second
+)
+
+// IChannelRegistry records the channel of every new account, see service.ChannelService
+type IChannelRegistry interface {
+	CreateChannel(ctx context.Context, channelID int, channel string) error
+}
+
+type IStreamClient interface {
+	PublishStreamStartedEvent(data event.StreamStartedEventData) error
+	PublishStreamEndedEvent(data event.StreamEndedEventData) error
+	PublishClipCreatedEvent(data event.ClipCreatedEventData) error
+}
+
+// StreamClient publishes the lifecycle events of live broadcasts and the clips cut from them.
+// It consumes the created accounts to know which channel every broadcaster owns.
+type StreamClient struct {
+	logger     *zap.SugaredLogger
+	channels   IChannelRegistry
+	connection *rabbitmq.ClientConnection
+	wg         *sync.WaitGroup
+	stop       context.CancelFunc
+}
+
+func New(addr string, l *zap.SugaredLogger, channels IChannelRegistry, connection *rabbitmq.ClientConnection) *StreamClient {
+	client := StreamClient{
+		logger:     l,
+		channels:   channels,
+		connection: connection,
+		wg:         &sync.WaitGroup{},
+	}
+
+	go client.connection.HandleReconnect(addr, client.connect)
+
+	return &client
+}
+
+func (c *StreamClient) Consume(cancelCtx context.Context) {
+	ctx, stop := context.WithCancel(cancelCtx)
+	c.stop = stop
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			err := c.stream(ctx)
+			if errors.Is(err, rabbitmq.ErrDisconnected) {
+				continue
+			}
+			if err != nil && ctx.Err() == nil {
+				c.logger.Errorf("stopped consuming: %v", err)
+			}
+			break
+		}
+	}()
+}
+
+func (c *StreamClient) PublishStreamStartedEvent(data event.StreamStartedEventData) error {
+	return c.publish(constants.StreamStartedKey, event.StreamStartedType, data)
+}
+
+func (c *StreamClient) PublishStreamEndedEvent(data event.StreamEndedEventData) error {
+	return c.publish(constants.StreamEndedKey, event.StreamEndedType, data)
+}
+
+func (c *StreamClient) PublishClipCreatedEvent(data event.ClipCreatedEventData) error {
+	return c.publish(constants.ClipCreatedKey, event.ClipCreatedType, data)
+}
+
+func (c *StreamClient) publish(key, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	baseEv := event.BaseEvent{
+		Type:    eventType,
+		Payload: string(payload),
+	}
+
+	ev, err := json.Marshal(baseEv)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	return c.push(key, ev)
+}
+
+// push publishes the event and blocks until the broker confirms it, resending it until then
+func (c *StreamClient) push(key string, data []byte) error {
+	return c.connection.PublishConfirmed(context.Background(), constants.StreamsExchange, key, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        data,
+	})
+}
+
+// connect declares the streams exchange and the queue the streams service consumes,
+// so events published before the streams service first starts aren't lost, and the queue of the created accounts
+func (c *StreamClient) connect(ch rabbitmq.Channel) bool {
+	err := ch.ExchangeDeclare(constants.StreamsExchange, "topic", true, false, false, false, nil)
+
+	if err != nil {
+		c.logger.Errorf("failed to declare exchange: %v", err)
+		return false
+	}
+
+	_, err = ch.QueueDeclare(
+		constants.StreamsQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.StreamsQueue, err)
+		return false
+	}
+
+	for _, key := range []string{constants.StreamStartedKey, constants.StreamEndedKey} {
+		err = ch.QueueBind(constants.StreamsQueue, key, constants.StreamsExchange, false, nil)
+		if err != nil {
+			c.logger.Errorf("failed to bind %s to the streams queue: %v", key, err)
+			return false
+		}
+	}
+
+	err = ch.ExchangeDeclare(constants.AccountsExchange, "topic", true, false, false, false, nil)
+	if err != nil {
+		c.logger.Errorf("failed to declare exchange %s: %v", constants.AccountsExchange, err)
+		return false
+	}
+
+	_, err = ch.QueueDeclare(
+		constants.VideoQueue,
+		true,  // Durable
+		false, // Delete when unused
+		false, // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		c.logger.Errorf("failed to declare %s queue: %v", constants.VideoQueue, err)
+		return false
+	}
+
+	err = ch.QueueBind(constants.VideoQueue, constants.AccountCreatedKey, constants.AccountsExchange, false, nil)
+	if err != nil {
+		c.logger.Errorf("failed to bind %s to the video queue: %v", constants.AccountCreatedKey, err)
+		return false
+	}
+
+	return true
+}
+
+func (c *StreamClient) stream(ctx context.Context) error {
+	if err := c.connection.WaitConnected(ctx); err != nil {
+		return err
+	}
+
+	var msgs <-chan amqp.Delivery
+	err := c.connection.WithChannel(func(ch rabbitmq.Channel) error {
+		if err := ch.Qos(1, 0, false); err != nil {
+			return err
+		}
+
+		var err error
+		msgs, err = ch.Consume(
+			constants.VideoQueue,
+			"",    // Consumer
+			false, // Auto-Ack
+			false, // Exclusive
+			false, // No-local
+			false, // No-Wait
+			nil,   // Args
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// The consumer only stops early when the channel closes
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return rabbitmq.ErrDisconnected
+			}
+			metrics.Consume(constants.VideoQueue, msg, c.parseEvent)
+		}
+	}
+}
+
+func (c *StreamClient) parseEvent(msg amqp.Delivery) {
+	l := c.logger.Named("parseEvent")
+	startTime := time.Now()
+
+	var evt event.BaseEvent
+	if err := json.Unmarshal(msg.Body, &evt); err != nil {
+		logAndNack(msg, l, startTime, "unmarshalling body: %s - %s", string(msg.Body), err.Error())
+		return
+	}
+
+	if evt.Type != event.AccountCreatedType {
+		msg.Reject(false)
+		return
+	}
+
+	payload := &event.AccountCreatedEventData{}
+	err := json.Unmarshal([]byte(evt.Payload), payload)
+	if err == nil {
+		err = c.channels.CreateChannel(context.Background(), payload.ID, payload.Username)
+	}
+
+	if err != nil {
+		logAndNack(msg, l, startTime, "%s", err.Error())
+		return
+	}
+
+	l.Infof("Took ms %d, succeeded %s", time.Since(startTime).Milliseconds(), evt.Type)
+	msg.Ack(false)
+}
+
+func logAndNack(msg amqp.Delivery, l *zap.SugaredLogger, t time.Time, err string, args ...interface{}) {
+	msg.Nack(false, false)
+	l.Errorf("Took ms %d, %s", time.Since(t).Milliseconds(), fmt.Sprintf(err, args...))
+}
+
+func (c *StreamClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if c.stop != nil {
+		c.stop()
+	}
+	if err := rabbitmq.Wait(ctx, c.wg); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	if err := c.connection.Close(ctx); err != nil {
+		return err
+	}
+
+	c.logger.Info("gracefully stopped rabbitMQ connection")
+	return nil
+}
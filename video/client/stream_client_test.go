@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/constants"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// channelRegistry records the channels of the consumed accounts
+type channelRegistry struct {
+	mu       sync.Mutex
+	channels map[int]string
+}
+
+func (r *channelRegistry) CreateChannel(ctx context.Context, channelID int, channel string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[channelID] = channel
+	return nil
+}
+
+func (r *channelRegistry) get(channelID int) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	channel, ok := r.channels[channelID]
+	return channel, ok
+}
+
+func TestConsumeAccountCreated(t *testing.T) {
+	broker := rabbitmq.NewMemoryBroker()
+	connection := rabbitmq.NewClientConnection(zap.NewNop().Sugar()).WithDialer(broker.Dial)
+	channels := &channelRegistry{channels: map[int]string{}}
+	client := New("amqp://test", zap.NewNop().Sugar(), channels, connection)
+	client.Consume(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := connection.WaitConnected(ctx); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	// Auth publishes the created accounts
+	body := `{"type":"account_created","payload":"{\"id\":1,\"email\":\"test@gmail.com\",\"username\":\"test\"}"}`
+	if _, err := connection.Publish(constants.AccountsExchange, constants.AccountCreatedKey, amqp.Publishing{Body: []byte(body)}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	for {
+		if channel, ok := channels.get(1); ok {
+			if channel != "test" {
+				t.Fatalf("expected the channel test, instead got: %s", channel)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("expected the channel of the created account to be recorded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close the client: %v", err)
+	}
+	if want, got := 0, broker.Messages(constants.VideoQueue); want != got {
+		t.Fatalf("expected %d messages in the video queue, instead got: %d", want, got)
+	}
+}
@@ -0,0 +1,56 @@
+package codec
+
+import "errors"
+
+var ErrInvalidAACConfig = errors.New("invalid AudioSpecificConfig")
+
+const adtsHeaderSize = 7
+
+var sampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// AACConfig holds the fields of the AudioSpecificConfig needed to build ADTS headers
+type AACConfig struct {
+	ObjectType      uint8
+	SampleRateIndex uint8
+	ChannelConfig   uint8
+}
+
+// ParseAACConfig parses an AudioSpecificConfig (ISO/IEC 14496-3 1.6.2.1)
+func ParseAACConfig(data []byte) (*AACConfig, error) {
+	if len(data) < 2 {
+		return nil, ErrInvalidAACConfig
+	}
+
+	cfg := &AACConfig{
+		ObjectType:      data[0] >> 3,
+		SampleRateIndex: (data[0]&0x07)<<1 | data[1]>>7,
+		ChannelConfig:   (data[1] >> 3) & 0x0f,
+	}
+
+	if cfg.ObjectType == 0 || int(cfg.SampleRateIndex) >= len(sampleRates) {
+		return nil, ErrInvalidAACConfig
+	}
+
+	return cfg, nil
+}
+
+// SampleRate returns the sampling frequency in Hz
+func (c *AACConfig) SampleRate() int {
+	return sampleRates[c.SampleRateIndex]
+}
+
+// ToADTS prefixes a raw AAC frame with an ADTS header as required by MPEG-TS
+func (c *AACConfig) ToADTS(frame []byte) []byte {
+	length := len(frame) + adtsHeaderSize
+
+	out := make([]byte, adtsHeaderSize, length)
+	out[0] = 0xff
+	out[1] = 0xf1 // MPEG-4, layer 0, no CRC
+	out[2] = (c.ObjectType-1)<<6 | c.SampleRateIndex<<2 | c.ChannelConfig>>2
+	out[3] = c.ChannelConfig<<6 | byte(length>>11)&0x03
+	out[4] = byte(length >> 3)
+	out[5] = byte(length<<5) | 0x1f // buffer fullness 0x7ff, variable bitrate
+	out[6] = 0xfc
+
+	return append(out, frame...)
+}
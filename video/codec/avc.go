@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// NAL unit types we care about when packaging
+const (
+	NALUTypeIDR = 5
+	NALUTypeSPS = 7
+	NALUTypePPS = 8
+	NALUTypeAUD = 9
+)
+
+var (
+	ErrInvalidAVCConfig = errors.New("invalid AVCDecoderConfigurationRecord")
+	ErrInvalidNALU      = errors.New("NAL unit length exceeds packet size")
+)
+
+var (
+	startCode = []byte{0, 0, 0, 1}
+	// Access unit delimiter, primary_pic_type 7 (any slice type)
+	accessUnitDelimiter = []byte{0, 0, 0, 1, NALUTypeAUD, 0xf0}
+)
+
+// AVCConfig holds the parameter sets from the AVC sequence header sent at the start of an FLV stream
+type AVCConfig struct {
+	NALULengthSize int
+	SPS            [][]byte
+	PPS            [][]byte
+}
+
+// ParseAVCConfig parses an AVCDecoderConfigurationRecord (ISO/IEC 14496-15 5.2.4.1)
+func ParseAVCConfig(data []byte) (*AVCConfig, error) {
+	if len(data) < 7 || data[0] != 1 {
+		return nil, ErrInvalidAVCConfig
+	}
+
+	cfg := &AVCConfig{NALULengthSize: int(data[4]&0x03) + 1}
+
+	pos := 6
+	readSets := func(count int) ([][]byte, error) {
+		sets := make([][]byte, 0, count)
+		for i := 0; i < count; i++ {
+			if pos+2 > len(data) {
+				return nil, ErrInvalidAVCConfig
+			}
+			size := int(binary.BigEndian.Uint16(data[pos:]))
+			pos += 2
+			if pos+size > len(data) {
+				return nil, ErrInvalidAVCConfig
+			}
+			sets = append(sets, data[pos:pos+size])
+			pos += size
+		}
+		return sets, nil
+	}
+
+	var err error
+	cfg.SPS, err = readSets(int(data[5] & 0x1f))
+	if err != nil {
+		return nil, err
+	}
+
+	if pos >= len(data) {
+		return nil, ErrInvalidAVCConfig
+	}
+	count := int(data[pos])
+	pos++
+	cfg.PPS, err = readSets(count)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// ToAnnexB converts a length prefixed (AVCC) access unit into an Annex B byte stream as required by MPEG-TS.
+// Every access unit is prefixed with an AUD and keyframes get the SPS and PPS so each segment is decodable on its own.
+func (c *AVCConfig) ToAnnexB(data []byte, keyframe bool) ([]byte, error) {
+	out := make([]byte, 0, len(data)+64)
+	out = append(out, accessUnitDelimiter...)
+
+	if keyframe {
+		for _, sps := range c.SPS {
+			out = append(out, startCode...)
+			out = append(out, sps...)
+		}
+		for _, pps := range c.PPS {
+			out = append(out, startCode...)
+			out = append(out, pps...)
+		}
+	}
+
+	for pos := 0; pos < len(data); {
+		if pos+c.NALULengthSize > len(data) {
+			return nil, ErrInvalidNALU
+		}
+
+		size := 0
+		for i := 0; i < c.NALULengthSize; i++ {
+			size = size<<8 | int(data[pos+i])
+		}
+		pos += c.NALULengthSize
+
+		if size == 0 {
+			continue
+		}
+		if pos+size > len(data) {
+			return nil, ErrInvalidNALU
+		}
+
+		nalu := data[pos : pos+size]
+		pos += size
+
+		switch nalu[0] & 0x1f {
+		case NALUTypeAUD:
+			// Already added our own
+			continue
+		case NALUTypeSPS, NALUTypePPS:
+			if keyframe {
+				continue
+			}
+		}
+
+		out = append(out, startCode...)
+		out = append(out, nalu...)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"bytes"
+	"nikolamilovic/twitchy/video/test_util"
+	"testing"
+)
+
+func TestAVCConfigToAnnexB(t *testing.T) {
+	cfg, err := ParseAVCConfig(test_util.AVCDecoderConfig())
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if cfg.NALULengthSize != 4 {
+		t.Fatalf("Expected NALU length size to be 4, got %d", cfg.NALULengthSize)
+	}
+
+	if len(cfg.SPS) != 1 || !bytes.Equal(cfg.SPS[0], test_util.SPS) {
+		t.Fatalf("Expected SPS to be %v, got %v", test_util.SPS, cfg.SPS)
+	}
+
+	if len(cfg.PPS) != 1 || !bytes.Equal(cfg.PPS[0], test_util.PPS) {
+		t.Fatalf("Expected PPS to be %v, got %v", test_util.PPS, cfg.PPS)
+	}
+
+	frame := test_util.AVCCFrame(true, 10)
+	out, err := cfg.ToAnnexB(frame, true)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	var want []byte
+	want = append(want, 0, 0, 0, 1, NALUTypeAUD, 0xf0)
+	want = append(want, 0, 0, 0, 1)
+	want = append(want, test_util.SPS...)
+	want = append(want, 0, 0, 0, 1)
+	want = append(want, test_util.PPS...)
+	want = append(want, 0, 0, 0, 1)
+	want = append(want, frame[4:]...)
+
+	if !bytes.Equal(out, want) {
+		t.Fatalf("Expected Annex B keyframe to be %v, got %v", want, out)
+	}
+
+	// Inter frames only get the AUD
+	out, err = cfg.ToAnnexB(test_util.AVCCFrame(false, 10), false)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if want := 6 + 4 + 10; len(out) != want {
+		t.Fatalf("Expected Annex B frame to be %d bytes, got %d", want, len(out))
+	}
+
+	if _, err := cfg.ToAnnexB([]byte{0, 0, 0, 9, 0x41}, false); err != ErrInvalidNALU {
+		t.Fatalf("Expected error to be %v, got %v", ErrInvalidNALU, err)
+	}
+}
+
+func TestAACConfigToADTS(t *testing.T) {
+	cfg, err := ParseAACConfig(test_util.AudioSpecificConfig)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if cfg.ObjectType != 2 || cfg.SampleRate() != 44100 || cfg.ChannelConfig != 2 {
+		t.Fatalf("Expected AAC-LC 44100Hz stereo, got %+v", cfg)
+	}
+
+	frame := bytes.Repeat([]byte{0x21}, 300)
+	out := cfg.ToADTS(frame)
+
+	if out[0] != 0xff || out[1]&0xf0 != 0xf0 {
+		t.Fatalf("Expected ADTS sync word, got %x %x", out[0], out[1])
+	}
+
+	length := int(out[3]&0x03)<<11 | int(out[4])<<3 | int(out[5])>>5
+	if length != len(frame)+7 {
+		t.Fatalf("Expected ADTS frame length to be %d, got %d", len(frame)+7, length)
+	}
+
+	if profile := out[2] >> 6; profile != 1 {
+		t.Fatalf("Expected ADTS profile to be 1 (LC), got %d", profile)
+	}
+}
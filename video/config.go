@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"nikolamilovic/twitchy/common/config"
+)
+
+// Config is read from the environment, or the YAML file at CONFIG_FILE
+type Config struct {
+	Port      int    `env:"PORT" yaml:"port" default:"80"`
+	JWTSecret string `env:"JWT_SECRET" yaml:"jwt_secret" required:"true"`
+	// TrustGateway takes the user the gateway verified from its headers, for services only reachable through it
+	TrustGateway bool `env:"TRUST_GATEWAY" yaml:"trust_gateway"`
+	// StorageDriver is where the segments are stored, "disk" rooted at StoragePath or "memory"
+	StorageDriver string `env:"STORAGE_DRIVER" yaml:"storage_driver" default:"disk"`
+	StoragePath   string `env:"STORAGE_PATH" yaml:"storage_path" default:"data"`
+	// VODRetentionDays is how long VODs are kept, forever when it's 0
+	VODRetentionDays int             `env:"VOD_RETENTION_DAYS" yaml:"vod_retention_days"`
+	Postgres         config.Postgres `yaml:"postgres"`
+	RabbitMQ         config.RabbitMQ `yaml:"rabbitmq"`
+}
+
+func (c *Config) Validate() error {
+	if c.StorageDriver != "disk" && c.StorageDriver != "memory" {
+		return fmt.Errorf("STORAGE_DRIVER must be disk or memory, got %s", c.StorageDriver)
+	}
+	if c.VODRetentionDays < 0 {
+		return fmt.Errorf("VOD_RETENTION_DAYS must be a positive number of days, got %d", c.VODRetentionDays)
+	}
+	return nil
+}
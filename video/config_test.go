@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	for _, scenario := range []struct {
+		description string
+		cfg         Config
+		expectedErr string
+	}{
+		{
+			description: "segments on disk",
+			cfg:         Config{StorageDriver: "disk", VODRetentionDays: 30},
+		},
+		{
+			description: "segments in memory, VODs kept forever",
+			cfg:         Config{StorageDriver: "memory"},
+		},
+		{
+			description: "unknown storage driver",
+			cfg:         Config{StorageDriver: "tape"},
+			expectedErr: "STORAGE_DRIVER must be disk or memory, got tape",
+		},
+		{
+			description: "negative retention",
+			cfg:         Config{StorageDriver: "disk", VODRetentionDays: -1},
+			expectedErr: "VOD_RETENTION_DAYS must be a positive number of days, got -1",
+		},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			err := scenario.cfg.Validate()
+
+			if scenario.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("Expected error to be nil, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != scenario.expectedErr {
+				t.Fatalf("Expected the error %q, got %v", scenario.expectedErr, err)
+			}
+		})
+	}
+}
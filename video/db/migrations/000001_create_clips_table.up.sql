@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS clips(
+   id VARCHAR(16) PRIMARY KEY,
+   channel_id INTEGER NOT NULL,
+   channel VARCHAR(50) NOT NULL,
+   session_id VARCHAR(16) NOT NULL,
+   creator_id INTEGER NOT NULL,
+   title VARCHAR(140) NOT NULL,
+   start_offset DOUBLE PRECISION NOT NULL,
+   duration DOUBLE PRECISION NOT NULL,
+   views INTEGER NOT NULL DEFAULT 0,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS clips_channel_idx ON clips (channel, created_at DESC);
@@ -0,0 +1,5 @@
+-- The channel of every account, a broadcaster can only ingest into their own
+CREATE TABLE IF NOT EXISTS channels(
+   channel_id INTEGER PRIMARY KEY,
+   channel VARCHAR(100) UNIQUE NOT NULL
+);
@@ -0,0 +1,163 @@
+package flv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tag types as defined by the FLV spec (Annex E of the Video File Format Specification v10)
+const (
+	TagAudio  uint8 = 8
+	TagVideo  uint8 = 9
+	TagScript uint8 = 18
+)
+
+// AVC and AAC packet types, both codecs use 0 for the decoder configuration and 1 for media data
+const (
+	PacketSequenceHeader uint8 = 0
+	PacketData           uint8 = 1
+	PacketEndOfSequence  uint8 = 2
+)
+
+const (
+	codecAVC       = 7
+	soundFormatAAC = 10
+	frameKey       = 1
+	headerSize     = 9
+	tagHeaderSize  = 11
+)
+
+var (
+	ErrInvalidHeader    = errors.New("invalid FLV header")
+	ErrUnsupportedCodec = errors.New("unsupported codec, only H.264 and AAC are supported")
+	ErrShortPacket      = errors.New("FLV packet is too short")
+)
+
+type Tag struct {
+	Type      uint8
+	Timestamp uint32 // milliseconds
+	Data      []byte
+}
+
+type VideoPacket struct {
+	Keyframe        bool
+	PacketType      uint8
+	CompositionTime int32 // milliseconds, PTS - DTS
+	Data            []byte
+}
+
+type AudioPacket struct {
+	PacketType uint8
+	Data       []byte
+}
+
+// Reader reads tags from an FLV byte stream, such as the body of an HTTP-FLV push
+type Reader struct {
+	r          io.Reader
+	headerRead bool
+	header     [tagHeaderSize]byte
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadTag returns the next tag in the stream, io.EOF is returned once the stream ends cleanly
+func (r *Reader) ReadTag() (*Tag, error) {
+	if !r.headerRead {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+		r.headerRead = true
+	}
+
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("ReadTag: %w", err)
+		}
+		return nil, err
+	}
+
+	h := r.header
+	size := uint32(h[1])<<16 | uint32(h[2])<<8 | uint32(h[3])
+	timestamp := uint32(h[7])<<24 | uint32(h[4])<<16 | uint32(h[5])<<8 | uint32(h[6])
+
+	tag := &Tag{
+		Type:      h[0] & 0x1f,
+		Timestamp: timestamp,
+		Data:      make([]byte, size),
+	}
+
+	if _, err := io.ReadFull(r.r, tag.Data); err != nil {
+		return nil, fmt.Errorf("ReadTag: %w", err)
+	}
+
+	// Previous tag size, we don't need it as the tag header already carries the size
+	var prev [4]byte
+	if _, err := io.ReadFull(r.r, prev[:]); err != nil {
+		return nil, fmt.Errorf("ReadTag: %w", err)
+	}
+
+	return tag, nil
+}
+
+func (r *Reader) readHeader() error {
+	var h [headerSize]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return fmt.Errorf("readHeader: %w", err)
+	}
+
+	if h[0] != 'F' || h[1] != 'L' || h[2] != 'V' {
+		return ErrInvalidHeader
+	}
+
+	offset := binary.BigEndian.Uint32(h[5:])
+	if offset < headerSize {
+		return ErrInvalidHeader
+	}
+
+	// Skip any extra header bytes and the first PreviousTagSize which is always 0
+	if _, err := io.CopyN(io.Discard, r.r, int64(offset-headerSize)+4); err != nil {
+		return fmt.Errorf("readHeader: %w", err)
+	}
+
+	return nil
+}
+
+// ParseVideo parses the body of a video tag, only AVC (H.264) is supported
+func ParseVideo(data []byte) (*VideoPacket, error) {
+	if len(data) < 5 {
+		return nil, ErrShortPacket
+	}
+
+	if data[0]&0x0f != codecAVC {
+		return nil, ErrUnsupportedCodec
+	}
+
+	cts := int32(uint32(data[2])<<16|uint32(data[3])<<8|uint32(data[4])) << 8 >> 8
+
+	return &VideoPacket{
+		Keyframe:        data[0]>>4 == frameKey,
+		PacketType:      data[1],
+		CompositionTime: cts,
+		Data:            data[5:],
+	}, nil
+}
+
+// ParseAudio parses the body of an audio tag, only AAC is supported
+func ParseAudio(data []byte) (*AudioPacket, error) {
+	if len(data) < 2 {
+		return nil, ErrShortPacket
+	}
+
+	if data[0]>>4 != soundFormatAAC {
+		return nil, ErrUnsupportedCodec
+	}
+
+	return &AudioPacket{
+		PacketType: data[1],
+		Data:       data[2:],
+	}, nil
+}
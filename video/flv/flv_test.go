@@ -0,0 +1,77 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReadWriteTags(t *testing.T) {
+	tags := []*Tag{
+		NewVideoTag(0, true, PacketSequenceHeader, 0, []byte{1, 2, 3}),
+		NewAudioTag(10, PacketData, []byte{4, 5}),
+		// Timestamps over 24 bits use the extended byte
+		NewVideoTag(0x01020304, false, PacketData, -40, []byte{6}),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, tag := range tags {
+		if err := w.WriteTag(tag); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for i, want := range tags {
+		got, err := r.ReadTag()
+		if err != nil {
+			t.Fatalf("Expected error to be nil reading tag %d, got %v", i, err)
+		}
+
+		if got.Type != want.Type || got.Timestamp != want.Timestamp || !bytes.Equal(got.Data, want.Data) {
+			t.Fatalf("Expected tag %d to be %v, got %v", i, want, got)
+		}
+	}
+
+	if _, err := r.ReadTag(); err != io.EOF {
+		t.Fatalf("Expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestParseVideo(t *testing.T) {
+	tag := NewVideoTag(0, true, PacketData, -40, []byte{0xaa})
+
+	pkt, err := ParseVideo(tag.Data)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if !pkt.Keyframe || pkt.PacketType != PacketData {
+		t.Fatalf("Expected a keyframe data packet, got %+v", pkt)
+	}
+
+	if pkt.CompositionTime != -40 {
+		t.Fatalf("Expected composition time to be %d, got %d", -40, pkt.CompositionTime)
+	}
+
+	if !bytes.Equal(pkt.Data, []byte{0xaa}) {
+		t.Fatalf("Expected data to be %v, got %v", []byte{0xaa}, pkt.Data)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	// VP6 video and MP3 audio
+	if _, err := ParseVideo([]byte{0x14, 1, 0, 0, 0}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Expected error to be %v, got %v", ErrUnsupportedCodec, err)
+	}
+
+	if _, err := ParseAudio([]byte{0x2f, 1}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Expected error to be %v, got %v", ErrUnsupportedCodec, err)
+	}
+
+	if _, err := NewReader(bytes.NewReader([]byte("GIF89a\x00\x00\x00\x00\x00\x00\x00"))).ReadTag(); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("Expected error to be %v, got %v", ErrInvalidHeader, err)
+	}
+}
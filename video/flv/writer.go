@@ -0,0 +1,71 @@
+package flv
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Writer writes an FLV byte stream, it's mostly useful for producing synthetic streams in tests
+type Writer struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) WriteTag(tag *Tag) error {
+	if !w.headerWritten {
+		// FLV version 1 with both the audio and video flags set, followed by PreviousTagSize0
+		header := []byte{'F', 'L', 'V', 1, 0x05, 0, 0, 0, headerSize, 0, 0, 0, 0}
+		if _, err := w.w.Write(header); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	size := len(tag.Data)
+	h := []byte{
+		tag.Type,
+		byte(size >> 16), byte(size >> 8), byte(size),
+		byte(tag.Timestamp >> 16), byte(tag.Timestamp >> 8), byte(tag.Timestamp), byte(tag.Timestamp >> 24),
+		0, 0, 0,
+	}
+
+	if _, err := w.w.Write(h); err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write(tag.Data); err != nil {
+		return err
+	}
+
+	var prev [4]byte
+	binary.BigEndian.PutUint32(prev[:], uint32(tagHeaderSize+size))
+	_, err := w.w.Write(prev[:])
+	return err
+}
+
+// NewVideoTag builds an AVC video tag
+func NewVideoTag(timestamp uint32, keyframe bool, packetType uint8, cts int32, data []byte) *Tag {
+	frameType := byte(2)
+	if keyframe {
+		frameType = frameKey
+	}
+
+	body := make([]byte, 0, len(data)+5)
+	body = append(body, frameType<<4|codecAVC, packetType, byte(cts>>16), byte(cts>>8), byte(cts))
+	body = append(body, data...)
+
+	return &Tag{Type: TagVideo, Timestamp: timestamp, Data: body}
+}
+
+// NewAudioTag builds an AAC audio tag, 44kHz stereo 16 bit as the spec mandates for AAC
+func NewAudioTag(timestamp uint32, packetType uint8, data []byte) *Tag {
+	body := make([]byte, 0, len(data)+2)
+	body = append(body, soundFormatAAC<<4|0x0f, packetType)
+	body = append(body, data...)
+
+	return &Tag{Type: TagAudio, Timestamp: timestamp, Data: body}
+}
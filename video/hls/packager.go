@@ -0,0 +1,302 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"nikolamilovic/twitchy/video/codec"
+	"nikolamilovic/twitchy/video/flv"
+	"nikolamilovic/twitchy/video/mpegts"
+	"nikolamilovic/twitchy/video/storage"
+)
+
+const (
+	DefaultTargetDuration = 4 * time.Second
+	DefaultWindowSize     = 6
+
+	PlaylistName = "index.m3u8"
+
+	// MPEG timestamps are 33 bits in a 90kHz clock
+	timestampMask = 1<<33 - 1
+)
+
+type Config struct {
+	// TargetDuration is the minimum segment length, segments are only cut on keyframes so they can run longer
+	TargetDuration time.Duration
+	// WindowSize is the number of segments kept in the live playlist
+	WindowSize int
+}
+
+func (c Config) withDefaults() Config {
+	if c.TargetDuration <= 0 {
+		c.TargetDuration = DefaultTargetDuration
+	}
+	if c.WindowSize <= 0 {
+		c.WindowSize = DefaultWindowSize
+	}
+	return c
+}
+
+// SegmentRecorder receives every completed segment, e.g. to archive the broadcast past the live window
+type SegmentRecorder interface {
+	RecordSegment(ctx context.Context, segment Segment, data []byte) error
+}
+
+// Packager remuxes a FLV H.264/AAC stream into MPEG-TS segments and maintains a sliding window live playlist.
+// The playlist lives at <prefix>/index.m3u8 and segments at <prefix>/<session>/<sequence>.ts.
+// A Packager isn't safe for concurrent use, it's fed by a single ingest connection.
+type Packager struct {
+	store   storage.BlobStore
+	prefix  string
+	session string
+	cfg     Config
+
+	recorder SegmentRecorder
+
+	avc   *codec.AVCConfig
+	aac   *codec.AACConfig
+	muxer *mpegts.Muxer
+	buf   bytes.Buffer
+
+	open          bool
+	segmentStart  uint32
+	lastTimestamp uint32
+	sequence      int
+	maxDuration   time.Duration
+	// discontinuity marks the open segment as starting with other tracks than the last one
+	discontinuity         bool
+	discontinuitySequence int
+
+	// segments currently in the playlist, retired ones are kept around for a window so slow clients can finish them
+	segments []Segment
+	retired  []Segment
+}
+
+func NewPackager(store storage.BlobStore, prefix, session string, cfg Config) *Packager {
+	cfg = cfg.withDefaults()
+
+	return &Packager{
+		store:       store,
+		prefix:      prefix,
+		session:     session,
+		cfg:         cfg,
+		maxDuration: cfg.TargetDuration,
+	}
+}
+
+// SetRecorder hands every segment cut from now on to recorder as well
+func (p *Packager) SetRecorder(recorder SegmentRecorder) {
+	p.recorder = recorder
+}
+
+// WriteTag feeds the next FLV tag into the packager, completed segments are written to the store as they're cut
+func (p *Packager) WriteTag(ctx context.Context, tag *flv.Tag) error {
+	switch tag.Type {
+	case flv.TagVideo:
+		pkt, err := flv.ParseVideo(tag.Data)
+		if err != nil {
+			return fmt.Errorf("WriteTag: %w", err)
+		}
+		return p.writeVideo(ctx, tag.Timestamp, pkt)
+	case flv.TagAudio:
+		pkt, err := flv.ParseAudio(tag.Data)
+		if err != nil {
+			return fmt.Errorf("WriteTag: %w", err)
+		}
+		return p.writeAudio(ctx, tag.Timestamp, pkt)
+	default:
+		// Script data (onMetaData) isn't needed for packaging
+		return nil
+	}
+}
+
+// Close flushes the last segment and marks the playlist as ended
+func (p *Packager) Close(ctx context.Context) error {
+	if p.open {
+		if err := p.flush(ctx, p.lastTimestamp); err != nil {
+			return err
+		}
+	}
+
+	return p.writePlaylist(ctx, true)
+}
+
+// Segments returns the segments currently in the live playlist
+func (p *Packager) Segments() []Segment {
+	return append([]Segment(nil), p.segments...)
+}
+
+func (p *Packager) writeVideo(ctx context.Context, timestamp uint32, pkt *flv.VideoPacket) error {
+	switch pkt.PacketType {
+	case flv.PacketSequenceHeader:
+		cfg, err := codec.ParseAVCConfig(pkt.Data)
+		if err != nil {
+			return fmt.Errorf("writeVideo: %w", err)
+		}
+		p.avc = cfg
+		return nil
+	case flv.PacketData:
+	default:
+		return nil
+	}
+
+	// Frames before the decoder configuration or the first keyframe can't be decoded
+	if p.avc == nil || (!p.open && !pkt.Keyframe) {
+		return nil
+	}
+
+	// Video joining an audio only stream starts a new segment on its first keyframe, the open one has no video track
+	if !p.open || (pkt.Keyframe && (p.elapsed(timestamp) >= p.cfg.TargetDuration || !p.muxer.HasVideo())) {
+		if err := p.cut(ctx, timestamp); err != nil {
+			return err
+		}
+	}
+
+	if !p.muxer.HasVideo() {
+		return nil
+	}
+
+	data, err := p.avc.ToAnnexB(pkt.Data, pkt.Keyframe)
+	if err != nil {
+		return fmt.Errorf("writeVideo: %w", err)
+	}
+
+	dts := toMPEGTime(int64(timestamp))
+	pts := toMPEGTime(int64(timestamp) + int64(pkt.CompositionTime))
+
+	p.lastTimestamp = timestamp
+	return p.muxer.WriteVideo(pts, dts, pkt.Keyframe, data)
+}
+
+func (p *Packager) writeAudio(ctx context.Context, timestamp uint32, pkt *flv.AudioPacket) error {
+	if pkt.PacketType == flv.PacketSequenceHeader {
+		cfg, err := codec.ParseAACConfig(pkt.Data)
+		if err != nil {
+			return fmt.Errorf("writeAudio: %w", err)
+		}
+		p.aac = cfg
+		return nil
+	}
+
+	if p.aac == nil {
+		return nil
+	}
+
+	if p.avc != nil {
+		// With video present segments are cut on video keyframes, drop audio until the first one
+		if !p.open {
+			return nil
+		}
+	} else if !p.open || p.elapsed(timestamp) >= p.cfg.TargetDuration {
+		if err := p.cut(ctx, timestamp); err != nil {
+			return err
+		}
+	}
+
+	if !p.muxer.HasAudio() {
+		return nil
+	}
+
+	p.lastTimestamp = timestamp
+	return p.muxer.WriteAudio(toMPEGTime(int64(timestamp)), p.aac.ToADTS(pkt.Data))
+}
+
+func (p *Packager) elapsed(timestamp uint32) time.Duration {
+	return time.Duration(timestamp-p.segmentStart) * time.Millisecond
+}
+
+// cut finishes the current segment, if any, and starts a new one at timestamp
+func (p *Packager) cut(ctx context.Context, timestamp uint32) error {
+	if p.open {
+		if err := p.flush(ctx, timestamp); err != nil {
+			return err
+		}
+	}
+
+	// A sequence header can arrive after the first segment was cut, the tracks it adds start with the next segment
+	if p.muxer == nil || p.muxer.HasVideo() != (p.avc != nil) || p.muxer.HasAudio() != (p.aac != nil) {
+		p.discontinuity = p.muxer != nil
+		p.muxer = mpegts.NewMuxer(&p.buf, p.avc != nil, p.aac != nil)
+	}
+
+	p.buf.Reset()
+	p.open = true
+	p.segmentStart = timestamp
+
+	return p.muxer.WriteTables()
+}
+
+func (p *Packager) flush(ctx context.Context, end uint32) error {
+	p.open = false
+
+	segment := Segment{
+		Sequence: p.sequence,
+		Duration: p.elapsed(end),
+		URI:      path.Join(p.session, fmt.Sprintf("%d.ts", p.sequence)),
+
+		Discontinuity: p.discontinuity,
+	}
+	p.sequence++
+	p.discontinuity = false
+
+	if err := p.store.Put(ctx, path.Join(p.prefix, segment.URI), p.buf.Bytes()); err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+
+	if p.recorder != nil {
+		if err := p.recorder.RecordSegment(ctx, segment, p.buf.Bytes()); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+	}
+
+	if segment.Duration > p.maxDuration {
+		p.maxDuration = segment.Duration
+	}
+
+	p.segments = append(p.segments, segment)
+	if len(p.segments) > p.cfg.WindowSize {
+		if p.segments[0].Discontinuity {
+			p.discontinuitySequence++
+		}
+		p.retired = append(p.retired, p.segments[0])
+		p.segments = p.segments[1:]
+	}
+
+	for len(p.retired) > p.cfg.WindowSize {
+		if err := p.store.Delete(ctx, path.Join(p.prefix, p.retired[0].URI)); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+		p.retired = p.retired[1:]
+	}
+
+	return p.writePlaylist(ctx, false)
+}
+
+func (p *Packager) writePlaylist(ctx context.Context, ended bool) error {
+	playlist := Playlist{
+		TargetDuration: p.maxDuration,
+		Segments:       p.segments,
+		Ended:          ended,
+
+		DiscontinuitySequence: p.discontinuitySequence,
+	}
+	if len(p.segments) > 0 {
+		playlist.MediaSequence = p.segments[0].Sequence
+	}
+
+	if err := p.store.Put(ctx, path.Join(p.prefix, PlaylistName), playlist.Encode()); err != nil {
+		return fmt.Errorf("writePlaylist: %w", err)
+	}
+
+	return nil
+}
+
+func toMPEGTime(ms int64) uint64 {
+	if ms < 0 {
+		ms = 0
+	}
+	return uint64(ms*90) & timestampMask
+}
@@ -0,0 +1,215 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/video/flv"
+	"nikolamilovic/twitchy/video/mpegts"
+	"nikolamilovic/twitchy/video/storage"
+	"nikolamilovic/twitchy/video/test_util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackagerSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// 2 second GOPs with a 4 second target gives exactly 4 second segments
+	tags := test_util.Tags(test_util.StreamOptions{DurationMs: 40000, FPS: 25, GOP: 50, Video: true, Audio: true})
+
+	p := NewPackager(store, "live/test", "abc", Config{TargetDuration: 4 * time.Second, WindowSize: 3})
+	for _, tag := range tags {
+		if err := p.WriteTag(ctx, tag); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	segments := p.Segments()
+	if len(segments) != 3 {
+		t.Fatalf("Expected %d segments in the window, got %d", 3, len(segments))
+	}
+
+	// The last GOP is still open, 9 segments have been cut
+	if first := segments[0].Sequence; first != 6 {
+		t.Fatalf("Expected the window to start at sequence %d, got %d", 6, first)
+	}
+
+	for _, s := range segments {
+		if s.Duration != 4*time.Second {
+			t.Fatalf("Expected segment %d to be 4s long, got %v", s.Sequence, s.Duration)
+		}
+	}
+
+	playlist, err := store.Get(ctx, "live/test/index.m3u8")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	want := "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		"#EXT-X-MEDIA-SEQUENCE:6\n" +
+		"#EXTINF:4.000,\nabc/6.ts\n" +
+		"#EXTINF:4.000,\nabc/7.ts\n" +
+		"#EXTINF:4.000,\nabc/8.ts\n"
+
+	if string(playlist) != want {
+		t.Fatalf("Expected playlist\n%s\ngot\n%s", want, playlist)
+	}
+
+	// Segments that left the playlist are kept for one more window, older ones are deleted
+	if _, err := store.Get(ctx, "live/test/abc/3.ts"); err != nil {
+		t.Fatalf("Expected retired segment to still exist, got %v", err)
+	}
+
+	if _, err := store.Get(ctx, "live/test/abc/2.ts"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected old segment to be deleted, got %v", err)
+	}
+
+	segment, err := store.Get(ctx, "live/test/abc/8.ts")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(segment) == 0 || len(segment)%188 != 0 || segment[0] != 0x47 {
+		t.Fatalf("Expected segment to be a transport stream, got %d bytes", len(segment))
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	playlist, _ = store.Get(ctx, "live/test/index.m3u8")
+	if !strings.HasSuffix(string(playlist), "abc/9.ts\n#EXT-X-ENDLIST\n") {
+		t.Fatalf("Expected the final segment and end tag after close, got\n%s", playlist)
+	}
+}
+
+func TestPackagerCutsOnKeyframes(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// 3 second GOPs can't be cut at 4 seconds, segments have to stretch to 6
+	tags := test_util.Tags(test_util.StreamOptions{DurationMs: 13000, FPS: 25, GOP: 75, Video: true})
+
+	p := NewPackager(store, "live/test", "abc", Config{TargetDuration: 4 * time.Second, WindowSize: 5})
+	for _, tag := range tags {
+		if err := p.WriteTag(ctx, tag); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	segments := p.Segments()
+	if len(segments) != 2 || segments[0].Duration != 6*time.Second || segments[1].Duration != 6*time.Second {
+		t.Fatalf("Expected two 6s segments, got %+v", segments)
+	}
+
+	playlist, _ := store.Get(ctx, "live/test/index.m3u8")
+	if !strings.Contains(string(playlist), "#EXT-X-TARGETDURATION:6\n") {
+		t.Fatalf("Expected the target duration to grow to the longest segment, got\n%s", playlist)
+	}
+}
+
+func TestPackagerAudioOnly(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	tags := test_util.Tags(test_util.StreamOptions{DurationMs: 10000, Audio: true, FPS: 1, GOP: 1})
+
+	p := NewPackager(store, "live/radio", "abc", Config{TargetDuration: 2 * time.Second})
+	for _, tag := range tags {
+		if err := p.WriteTag(ctx, tag); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	segments := p.Segments()
+	if len(segments) < 4 {
+		t.Fatalf("Expected audio only streams to be cut by duration, got %d segments", len(segments))
+	}
+
+	for _, s := range segments[:len(segments)-1] {
+		if s.Duration < 2*time.Second || s.Duration > 2100*time.Millisecond {
+			t.Fatalf("Expected segments of about 2s, got %v", s.Duration)
+		}
+	}
+}
+
+func TestPackagerAddsLateTracks(t *testing.T) {
+	tests := []struct {
+		description string
+		// late is the track whose sequence header arrives after the first segment was cut
+		late uint8
+		pid  uint16
+	}{
+		{description: "audio", late: flv.TagAudio, pid: mpegts.PIDAudio},
+		{description: "video", late: flv.TagVideo, pid: mpegts.PIDVideo},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+
+			// The sequence header of the late track comes a second in, its frames before that are dropped
+			var header *flv.Tag
+			var tags []*flv.Tag
+			for _, tag := range test_util.Tags(test_util.StreamOptions{DurationMs: 12000, FPS: 25, GOP: 50, Video: true, Audio: true}) {
+				if tag.Type == test.late && tag.Timestamp == 0 && header == nil {
+					header = tag
+					continue
+				}
+				if header != nil && tag.Timestamp >= 1000 {
+					tags = append(tags, header)
+					header = nil
+				}
+				tags = append(tags, tag)
+			}
+
+			p := NewPackager(store, "live/test", "abc", Config{TargetDuration: 4 * time.Second, WindowSize: 5})
+			for _, tag := range tags {
+				if err := p.WriteTag(ctx, tag); err != nil {
+					t.Fatalf("Expected error to be nil, got %v", err)
+				}
+			}
+			if err := p.Close(ctx); err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			segments := p.Segments()
+			if len(segments) < 3 || segments[0].Discontinuity || !segments[1].Discontinuity || segments[2].Discontinuity {
+				t.Fatalf("Expected the second segment to start the new tracks, got %+v", segments)
+			}
+
+			for _, s := range segments[1:] {
+				data, err := store.Get(ctx, "live/test/"+s.URI)
+				if err != nil {
+					t.Fatalf("Expected error to be nil, got %v", err)
+				}
+				if !hasPID(data, test.pid) {
+					t.Fatalf("Expected segment %d to carry the %s track", s.Sequence, test.description)
+				}
+			}
+
+			playlist, _ := store.Get(ctx, "live/test/index.m3u8")
+			if !strings.Contains(string(playlist), "#EXT-X-DISCONTINUITY\n#EXTINF:") {
+				t.Fatalf("Expected the playlist to mark the discontinuity, got\n%s", playlist)
+			}
+		})
+	}
+}
+
+func hasPID(ts []byte, pid uint16) bool {
+	for i := 0; i+mpegts.PacketSize <= len(ts); i += mpegts.PacketSize {
+		if uint16(ts[i+1]&0x1f)<<8|uint16(ts[i+2]) == pid {
+			return true
+		}
+	}
+	return false
+}
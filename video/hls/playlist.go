@@ -0,0 +1,73 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Segment struct {
+	Sequence int
+	Duration time.Duration
+	// URI relative to the playlist
+	URI string
+	// Discontinuity marks a segment whose tracks differ from the one before it
+	Discontinuity bool
+}
+
+// PlaylistTypeVOD marks a playlist that will never change, live playlists have no type
+const PlaylistTypeVOD = "VOD"
+
+// Playlist is an HLS media playlist (RFC 8216 section 4.3.3)
+type Playlist struct {
+	TargetDuration time.Duration
+	MediaSequence  int
+	Segments       []Segment
+	Ended          bool
+	Type           string
+	// Start is where players should begin playback, used when the content doesn't start on a segment boundary
+	Start time.Duration
+	// DiscontinuitySequence counts the discontinuities that left a sliding window playlist
+	DiscontinuitySequence int
+}
+
+func (p *Playlist) Encode() []byte {
+	target := p.TargetDuration
+	for _, s := range p.Segments {
+		if s.Duration > target {
+			target = s.Duration
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	// The EXTINF durations rounded to the nearest integer must not exceed the target duration
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target.Seconds())))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	if p.Type != "" {
+		fmt.Fprintf(&b, "#EXT-X-PLAYLIST-TYPE:%s\n", p.Type)
+	}
+	if p.Start > 0 {
+		fmt.Fprintf(&b, "#EXT-X-START:TIME-OFFSET=%.3f,PRECISE=YES\n", p.Start.Seconds())
+	}
+
+	for _, s := range p.Segments {
+		if s.Discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", s.Duration.Seconds())
+		b.WriteString(s.URI)
+		b.WriteByte('\n')
+	}
+
+	if p.Ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.Bytes()
+}
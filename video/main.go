@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nikolamilovic/twitchy/common/config"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/rabbitmq"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/video/api"
+	"nikolamilovic/twitchy/video/client"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/service"
+	"nikolamilovic/twitchy/video/storage"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	logger, _ = zap.NewProduction(zap.Fields(zap.String("type", "main")))
+	shutdowns []func() error
+)
+
+func main() {
+	var (
+		shutdown = make(chan struct{})
+		ctx      = context.Background()
+		sigint   = make(chan os.Signal, 1)
+	)
+
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		logger.Fatal("failed to load the config", zap.Error(err))
+	}
+	token.TrustGateway(cfg.TrustGateway)
+
+	store, err := initStorage(cfg)
+	if err != nil {
+		logger.Fatal("failed to init the segment storage", zap.Error(err))
+	}
+
+	dbConn, dbCleanup, err := db.InitDb(ctx, cfg.Postgres, logger.Sugar().Named("db"))
+	if err != nil {
+		logger.Fatal("failed to init the db", zap.Error(err))
+	}
+
+	amqpServerURL := cfg.RabbitMQ.URL()
+
+	clientConnection := rabbitmq.NewClientConnection(logger.Sugar().Named("client_connection"))
+	channelService := service.NewChannelService(dbConn)
+
+	client := client.New(amqpServerURL, logger.Sugar().Named("streams_rabbitmq_client"), channelService, clientConnection)
+	client.Consume(ctx)
+
+	liveService := service.NewLiveService(store, hls.Config{}, client, logger.Sugar().Named("live_service"))
+
+	// VODs are kept forever when no retention is set
+	retention := time.Duration(cfg.VODRetentionDays) * 24 * time.Hour
+	vodService := service.NewVodService(store, retention, logger.Sugar().Named("vod_service"))
+
+	retentionCtx, stopRetention := context.WithCancel(ctx)
+	go vodService.RunRetention(retentionCtx, time.Hour)
+
+	clipService := service.NewClipService(dbConn, store, liveService, vodService, client, logger.Sugar().Named("clip_service"))
+
+	srv, err := api.NewServer(liveService, vodService, clipService, channelService, []byte(cfg.JWTSecret), logger.Sugar().Named("server"))
+
+	if err != nil {
+		logger.Fatal("Unable to initialize the server", zap.Error(err))
+		os.Exit(1)
+	}
+
+	port := fmt.Sprintf(":%d", cfg.Port)
+	server := http.Server{
+		Addr:    port,
+		Handler: srv,
+	}
+
+	shutdowns = append(shutdowns, dbCleanup, client.Close, func() error {
+		stopRetention()
+		return nil
+	})
+
+	defer logger.Sync()
+
+	go gracefulShutdown(&server, shutdown, ctx, sigint)
+
+	logger.Info("Server starting and listening at port " + server.Addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal("server error", zap.Error(err))
+	}
+}
+
+// initStorage picks the segment storage from STORAGE_DRIVER, either "disk" rooted at STORAGE_PATH or "memory"
+func initStorage(cfg Config) (storage.BlobStore, error) {
+	if cfg.StorageDriver == "memory" {
+		return storage.NewMemoryStore(), nil
+	}
+	return storage.NewDiskStore(cfg.StoragePath)
+}
+
+func gracefulShutdown(server *http.Server, shutdown chan struct{}, ctx context.Context, sigint chan os.Signal) {
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+	<-sigint
+
+	logger.Info("shutting down server gracefully")
+
+	// stop receiving any request.
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("shutdown error", zap.Error(err))
+	}
+
+	// close any other modules.
+	for i := range shutdowns {
+		shutdowns[i]()
+	}
+
+	close(shutdown)
+}
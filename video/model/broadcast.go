@@ -0,0 +1,16 @@
+package model
+
+import "errors"
+
+var (
+	ChannelNotFoundError = errors.New("channel not found")
+	ChannelNotOwnedError = errors.New("channel belongs to another user")
+)
+
+// Broadcast describes who is going live, the channel ID comes from the broadcaster's JWT
+type Broadcast struct {
+	ChannelID int
+	Channel   string
+	Title     string
+	Category  string
+}
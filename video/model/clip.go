@@ -0,0 +1,38 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ClipNotFoundError   = errors.New("clip not found")
+	ClipOutOfRangeError = errors.New("clip is outside of the recorded broadcast")
+	ChannelOfflineError = errors.New("channel is not live")
+)
+
+// Clip is a short excerpt of a broadcast stored as a standalone asset
+type Clip struct {
+	ID        string `json:"id"`
+	ChannelID int    `json:"channel_id"`
+	Channel   string `json:"channel"`
+	SessionID string `json:"session_id"`
+	CreatorID int    `json:"creator_id"`
+	Title     string `json:"title"`
+	// StartOffset is where the clip starts within the broadcast, in seconds
+	StartOffset float64   `json:"start_offset"`
+	Duration    float64   `json:"duration"`
+	Views       int       `json:"views"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClipRequest cuts Duration seconds starting Offset seconds into a recorded session. Without a session the clip is cut
+// from the live broadcast and ends Offset seconds before the live edge.
+type ClipRequest struct {
+	CreatorID int
+	Channel   string
+	SessionID string
+	Offset    float64
+	Duration  float64
+	Title     string
+}
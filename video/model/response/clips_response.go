@@ -0,0 +1,10 @@
+package response
+
+import "nikolamilovic/twitchy/video/model"
+
+type ClipsResponse struct {
+	Clips []model.Clip `json:"clips"`
+	Page  int          `json:"page"`
+	Limit int          `json:"limit"`
+	Total int          `json:"total"`
+}
@@ -0,0 +1,7 @@
+package response
+
+import "nikolamilovic/twitchy/video/model"
+
+type VodsResponse struct {
+	Vods []model.Vod `json:"vods"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Vod is the manifest of an archived broadcast, stored next to its segments
+type Vod struct {
+	SessionID string     `json:"session_id"`
+	ChannelID int        `json:"channel_id"`
+	Channel   string     `json:"channel"`
+	Title     string     `json:"title"`
+	Category  string     `json:"category"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	// Duration in seconds
+	Duration float64      `json:"duration"`
+	Segments []VodSegment `json:"segments,omitempty"`
+}
+
+type VodSegment struct {
+	Sequence int `json:"sequence"`
+	// Duration in milliseconds
+	Duration      int64  `json:"duration"`
+	Name          string `json:"name"`
+	Discontinuity bool   `json:"discontinuity,omitempty"`
+}
+
+// Expired reports whether the VOD ended before the cutoff, recordings that haven't ended never expire
+func (v *Vod) Expired(cutoff time.Time) bool {
+	return v.EndedAt != nil && v.EndedAt.Before(cutoff)
+}
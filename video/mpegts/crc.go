@@ -0,0 +1,31 @@
+package mpegts
+
+// PSI sections use CRC-32/MPEG-2, which unlike hash/crc32 isn't bit reflected and has no final xor
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc32(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+func appendCRC(section []byte) []byte {
+	crc := crc32(section)
+	return append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
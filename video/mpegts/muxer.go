@@ -0,0 +1,277 @@
+package mpegts
+
+import (
+	"io"
+)
+
+const (
+	PacketSize = 188
+
+	PIDPAT   uint16 = 0x0000
+	PIDPMT   uint16 = 0x1000
+	PIDVideo uint16 = 0x0100
+	PIDAudio uint16 = 0x0101
+
+	StreamTypeH264 uint8 = 0x1b
+	StreamTypeAAC  uint8 = 0x0f
+
+	streamIDVideo uint8 = 0xe0
+	streamIDAudio uint8 = 0xc0
+
+	syncByte    = 0x47
+	payloadSize = PacketSize - 4
+)
+
+// Muxer writes H.264 and AAC access units as an MPEG transport stream.
+// Timestamps are in the 90kHz MPEG clock.
+type Muxer struct {
+	w          io.Writer
+	hasVideo   bool
+	hasAudio   bool
+	continuity map[uint16]uint8
+	packet     [PacketSize]byte
+}
+
+func NewMuxer(w io.Writer, hasVideo, hasAudio bool) *Muxer {
+	return &Muxer{
+		w:          w,
+		hasVideo:   hasVideo,
+		hasAudio:   hasAudio,
+		continuity: make(map[uint16]uint8),
+	}
+}
+
+// WriteTables writes the PAT and PMT, every segment has to start with them
+func (m *Muxer) WriteTables() error {
+	if err := m.writeSection(PIDPAT, m.pat()); err != nil {
+		return err
+	}
+	return m.writeSection(PIDPMT, m.pmt())
+}
+
+// WriteVideo writes an Annex B access unit, keyframes carry the PCR and the random access indicator
+func (m *Muxer) WriteVideo(pts, dts uint64, keyframe bool, data []byte) error {
+	header := pesHeader(streamIDVideo, pts, dts, 0)
+	var pcr *uint64
+	if keyframe {
+		pcr = &dts
+	}
+	return m.writePES(PIDVideo, append(header, data...), pcr, keyframe)
+}
+
+// WriteAudio writes an ADTS framed AAC frame. Without a video stream the audio PID carries the PCR.
+func (m *Muxer) WriteAudio(pts uint64, data []byte) error {
+	header := pesHeader(streamIDAudio, pts, pts, len(data))
+	var pcr *uint64
+	if !m.hasVideo {
+		pcr = &pts
+	}
+	return m.writePES(PIDAudio, append(header, data...), pcr, !m.hasVideo)
+}
+
+// HasVideo reports whether the PMT advertises a video stream
+func (m *Muxer) HasVideo() bool {
+	return m.hasVideo
+}
+
+// HasAudio reports whether the PMT advertises an audio stream
+func (m *Muxer) HasAudio() bool {
+	return m.hasAudio
+}
+
+func (m *Muxer) pcrPID() uint16 {
+	if m.hasVideo {
+		return PIDVideo
+	}
+	return PIDAudio
+}
+
+func (m *Muxer) pat() []byte {
+	section := []byte{
+		0x00,       // table_id
+		0xb0, 0x0d, // section_syntax_indicator, section_length 13
+		0x00, 0x01, // transport_stream_id
+		0xc1,       // version 0, current_next_indicator
+		0x00, 0x00, // section_number, last_section_number
+		0x00, 0x01, // program_number
+		0xe0 | byte(PIDPMT>>8), byte(PIDPMT & 0xff),
+	}
+	return appendCRC(section)
+}
+
+func (m *Muxer) pmt() []byte {
+	var streams []byte
+	if m.hasVideo {
+		streams = append(streams, StreamTypeH264, 0xe0|byte(PIDVideo>>8), byte(PIDVideo&0xff), 0xf0, 0x00)
+	}
+	if m.hasAudio {
+		streams = append(streams, StreamTypeAAC, 0xe0|byte(PIDAudio>>8), byte(PIDAudio&0xff), 0xf0, 0x00)
+	}
+
+	length := 9 + len(streams) + 4
+	pcr := m.pcrPID()
+	section := []byte{
+		0x02, // table_id
+		0xb0 | byte(length>>8), byte(length),
+		0x00, 0x01, // program_number
+		0xc1,
+		0x00, 0x00,
+		0xe0 | byte(pcr>>8), byte(pcr),
+		0xf0, 0x00, // program_info_length
+	}
+	section = append(section, streams...)
+	return appendCRC(section)
+}
+
+func (m *Muxer) writeSection(pid uint16, section []byte) error {
+	p := m.packet[:]
+	p[0] = syncByte
+	p[1] = 0x40 | byte(pid>>8)
+	p[2] = byte(pid)
+	p[3] = 0x10 | m.nextContinuity(pid)
+	p[4] = 0x00 // pointer_field
+
+	n := copy(p[5:], section)
+	for i := 5 + n; i < PacketSize; i++ {
+		p[i] = 0xff
+	}
+
+	_, err := m.w.Write(p)
+	return err
+}
+
+// writePES splits a PES packet into transport packets, padding the last one with adaptation field stuffing
+func (m *Muxer) writePES(pid uint16, pes []byte, pcr *uint64, randomAccess bool) error {
+	first := true
+
+	for len(pes) > 0 {
+		var adaptation []byte
+		if first && (pcr != nil || randomAccess) {
+			var flags byte
+			if randomAccess {
+				flags |= 0x40
+			}
+			if pcr != nil {
+				flags |= 0x10
+			}
+			adaptation = append(adaptation, flags)
+			if pcr != nil {
+				adaptation = append(adaptation, encodePCR(*pcr)...)
+			}
+		}
+
+		space := payloadSize
+		if adaptation != nil {
+			space -= 1 + len(adaptation)
+		}
+
+		n := len(pes)
+		if n > space {
+			n = space
+		}
+
+		if stuffing := space - n; stuffing > 0 {
+			if adaptation == nil {
+				// The adaptation field length byte takes the first stuffing byte
+				stuffing--
+				adaptation = []byte{}
+				if stuffing > 0 {
+					adaptation = append(adaptation, 0x00)
+					stuffing--
+				}
+			}
+			for i := 0; i < stuffing; i++ {
+				adaptation = append(adaptation, 0xff)
+			}
+		}
+
+		p := m.packet[:0]
+		pusi := byte(0)
+		if first {
+			pusi = 0x40
+		}
+		control := byte(0x10)
+		if adaptation != nil {
+			control = 0x30
+		}
+		p = append(p, syncByte, pusi|byte(pid>>8), byte(pid), control|m.nextContinuity(pid))
+		if adaptation != nil {
+			p = append(p, byte(len(adaptation)))
+			p = append(p, adaptation...)
+		}
+		p = append(p, pes[:n]...)
+
+		if _, err := m.w.Write(p); err != nil {
+			return err
+		}
+
+		pes = pes[n:]
+		first = false
+	}
+
+	return nil
+}
+
+func (m *Muxer) nextContinuity(pid uint16) uint8 {
+	cc := m.continuity[pid]
+	m.continuity[pid] = (cc + 1) & 0x0f
+	return cc
+}
+
+// pesHeader builds a PES header, a zero length means unbounded which is only allowed for video
+func pesHeader(streamID uint8, pts, dts uint64, length int) []byte {
+	withDTS := pts != dts
+
+	headerLength := 5
+	flags := byte(0x80)
+	if withDTS {
+		headerLength = 10
+		flags = 0xc0
+	}
+
+	packetLength := 0
+	if length > 0 {
+		packetLength = length + 3 + headerLength
+		if packetLength > 0xffff {
+			packetLength = 0
+		}
+	}
+
+	h := []byte{
+		0x00, 0x00, 0x01, streamID,
+		byte(packetLength >> 8), byte(packetLength),
+		0x80, // marker bits, no scrambling
+		flags,
+		byte(headerLength),
+	}
+
+	if withDTS {
+		h = append(h, encodeTimestamp(0x3, pts)...)
+		h = append(h, encodeTimestamp(0x1, dts)...)
+	} else {
+		h = append(h, encodeTimestamp(0x2, pts)...)
+	}
+
+	return h
+}
+
+func encodeTimestamp(marker byte, ts uint64) []byte {
+	return []byte{
+		marker<<4 | byte(ts>>29)&0x0e | 1,
+		byte(ts >> 22),
+		byte(ts>>14)&0xfe | 1,
+		byte(ts >> 7),
+		byte(ts<<1)&0xfe | 1,
+	}
+}
+
+func encodePCR(base uint64) []byte {
+	return []byte{
+		byte(base >> 25),
+		byte(base >> 17),
+		byte(base >> 9),
+		byte(base >> 1),
+		byte(base<<7)&0x80 | 0x7e,
+		0x00,
+	}
+}
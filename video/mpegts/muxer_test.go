@@ -0,0 +1,92 @@
+package mpegts
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMuxerPackets(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMuxer(&buf, true, true)
+
+	if err := m.WriteTables(); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	video := bytes.Repeat([]byte{0xab}, 1000)
+	if err := m.WriteVideo(93000, 90000, true, video); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := m.WriteAudio(90000, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if buf.Len()%PacketSize != 0 {
+		t.Fatalf("Expected output to be a multiple of %d bytes, got %d", PacketSize, buf.Len())
+	}
+
+	continuity := map[uint16]int{}
+	var pes = map[uint16][]byte{}
+
+	for data := buf.Bytes(); len(data) > 0; data = data[PacketSize:] {
+		p := data[:PacketSize]
+		if p[0] != syncByte {
+			t.Fatalf("Expected sync byte, got %x", p[0])
+		}
+
+		pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+		cc := int(p[3] & 0x0f)
+		if want := continuity[pid]; cc != want {
+			t.Fatalf("Expected continuity counter %d on PID %x, got %d", want, pid, cc)
+		}
+		continuity[pid] = (cc + 1) & 0x0f
+
+		payload := p[4:]
+		if p[3]&0x20 != 0 {
+			payload = payload[1+int(payload[0]):]
+		}
+
+		if pid == PIDPAT || pid == PIDPMT {
+			section := payload[1:]
+			length := int(section[1]&0x0f)<<8 | int(section[2])
+			// Running the CRC over a section including its CRC yields zero
+			if crc := crc32(section[:3+length]); crc != 0 {
+				t.Fatalf("Expected valid CRC on PID %x, got residue %x", pid, crc)
+			}
+			continue
+		}
+
+		pes[pid] = append(pes[pid], payload...)
+	}
+
+	if continuity[PIDVideo] != 6 {
+		t.Fatalf("Expected the video access unit to span 6 packets, got %d", continuity[PIDVideo])
+	}
+
+	v := pes[PIDVideo]
+	if !bytes.Equal(v[:4], []byte{0, 0, 1, streamIDVideo}) {
+		t.Fatalf("Expected a video PES start code, got %x", v[:4])
+	}
+
+	if got := decodeTimestamp(v[9:]); got != 93000 {
+		t.Fatalf("Expected PTS to be %d, got %d", 93000, got)
+	}
+
+	if got := decodeTimestamp(v[14:]); got != 90000 {
+		t.Fatalf("Expected DTS to be %d, got %d", 90000, got)
+	}
+
+	if !bytes.Equal(v[19:], video) {
+		t.Fatalf("Expected the video payload to survive muxing")
+	}
+
+	a := pes[PIDAudio]
+	if length := int(a[4])<<8 | int(a[5]); length != len(a)-6 {
+		t.Fatalf("Expected audio PES length to be %d, got %d", len(a)-6, length)
+	}
+}
+
+func decodeTimestamp(b []byte) uint64 {
+	return uint64(b[0]>>1&0x07)<<30 | uint64(b[1])<<22 | uint64(b[2]>>1)<<15 | uint64(b[3])<<7 | uint64(b[4]>>1)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/video/model"
+)
+
+// IChannelService knows the channel of every account, the channel is named after the account's username
+type IChannelService interface {
+	CreateChannel(ctx context.Context, channelID int, channel string) error
+	GetChannel(ctx context.Context, channelID int) (string, error)
+}
+
+type ChannelService struct {
+	DB db.PgxIface
+}
+
+func NewChannelService(db db.PgxIface) *ChannelService {
+	return &ChannelService{DB: db}
+}
+
+// CreateChannel records the channel of a new account, redelivered events are ignored
+func (s *ChannelService) CreateChannel(ctx context.Context, channelID int, channel string) error {
+	_, err := s.DB.Exec(ctx, "INSERT INTO channels (channel_id, channel) VALUES ($1, $2) ON CONFLICT (channel_id) DO NOTHING", channelID, channel)
+	if err != nil {
+		return fmt.Errorf("CreateChannel: %w", err)
+	}
+	return nil
+}
+
+func (s *ChannelService) GetChannel(ctx context.Context, channelID int) (string, error) {
+	rows, err := s.DB.Query(ctx, "SELECT channel FROM channels WHERE channel_id = $1", channelID)
+	if err != nil {
+		return "", fmt.Errorf("GetChannel: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", fmt.Errorf("GetChannel: %w", model.ChannelNotFoundError)
+	}
+
+	var channel string
+	if err := rows.Scan(&channel); err != nil {
+		return "", fmt.Errorf("GetChannel: %w", err)
+	}
+
+	return channel, nil
+}
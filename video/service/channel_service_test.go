@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/video/model"
+	"testing"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestChannelService(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(ctx)
+
+	sut := NewChannelService(mockDB)
+
+	mockDB.ExpectExec("INSERT INTO channels").WithArgs(1, "test").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mockDB.ExpectQuery("SELECT channel FROM channels").WithArgs(1).WillReturnRows(pgxmock.NewRows([]string{"channel"}).AddRow("test"))
+	mockDB.ExpectQuery("SELECT channel FROM channels").WithArgs(2).WillReturnRows(pgxmock.NewRows([]string{"channel"}))
+
+	if err := sut.CreateChannel(ctx, 1, "test"); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	channel, err := sut.GetChannel(ctx, 1)
+	if err != nil || channel != "test" {
+		t.Fatalf("Expected channel test, got %q, %v", channel, err)
+	}
+
+	if _, err := sut.GetChannel(ctx, 2); !errors.Is(err, model.ChannelNotFoundError) {
+		t.Fatalf("Expected error to be %v, got %v", model.ChannelNotFoundError, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
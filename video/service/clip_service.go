@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/video/client"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+
+	"go.uber.org/zap"
+)
+
+const clipColumns = "id, channel_id, channel, session_id, creator_id, title, start_offset, duration, views, created_at"
+
+type IClipService interface {
+	CreateClip(ctx context.Context, req model.ClipRequest) (model.Clip, error)
+	GetClip(ctx context.Context, id string) (model.Clip, error)
+	// ListClips returns the clips of the channel for the given page, newest first, and the total number of clips
+	ListClips(ctx context.Context, channel string, page, limit int) ([]model.Clip, int, error)
+	// Playlist returns the clip playlist and counts a view
+	Playlist(ctx context.Context, id string) ([]byte, error)
+	Segment(ctx context.Context, id, name string) ([]byte, error)
+}
+
+type ClipService struct {
+	DB           db.PgxIface
+	Store        storage.BlobStore
+	Live         ILiveService
+	Vods         IVodService
+	StreamClient client.IStreamClient
+	logger       *zap.SugaredLogger
+}
+
+func NewClipService(db db.PgxIface, store storage.BlobStore, live ILiveService, vods IVodService, streamClient client.IStreamClient, logger *zap.SugaredLogger) *ClipService {
+	return &ClipService{
+		DB:           db,
+		Store:        store,
+		Live:         live,
+		Vods:         vods,
+		StreamClient: streamClient,
+		logger:       logger,
+	}
+}
+
+func ClipPrefix(id string) string {
+	return path.Join("clips", id)
+}
+
+// CreateClip copies the segments covering the requested range into a standalone asset. Segments can't be cut
+// without re-encoding so the clip starts on a segment boundary and the playlist points players at the requested start.
+func (s *ClipService) CreateClip(ctx context.Context, req model.ClipRequest) (model.Clip, error) {
+	session := req.SessionID
+	live := session == ""
+	if live {
+		var ok bool
+		session, ok = s.Live.CurrentSession(req.Channel)
+		if !ok {
+			return model.Clip{}, fmt.Errorf("CreateClip: %w", model.ChannelOfflineError)
+		}
+	}
+
+	vod, err := s.Vods.GetVod(ctx, req.Channel, session)
+	if err != nil {
+		// The live recording has no manifest until the first segment is cut
+		if live && errors.Is(err, storage.ErrNotFound) {
+			return model.Clip{}, fmt.Errorf("CreateClip: %w", model.ClipOutOfRangeError)
+		}
+		return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+	}
+
+	start, end := req.Offset, req.Offset+req.Duration
+	if live {
+		end = vod.Duration - req.Offset
+		start = end - req.Duration
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > vod.Duration {
+		end = vod.Duration
+	}
+	if start >= end {
+		return model.Clip{}, fmt.Errorf("CreateClip: %w", model.ClipOutOfRangeError)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+	}
+
+	segments, first := coveringSegments(vod.Segments, start, end)
+
+	playlist := hls.Playlist{
+		Ended: true,
+		Type:  hls.PlaylistTypeVOD,
+		Start: time.Duration((start - first) * float64(time.Second)),
+	}
+
+	for i, segment := range segments {
+		data, err := s.Vods.Segment(ctx, req.Channel, session, segment.Name)
+		if err != nil {
+			return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+		}
+
+		name := fmt.Sprintf("%d.ts", i)
+		if err := s.Store.Put(ctx, path.Join(ClipPrefix(id), name), data); err != nil {
+			return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+		}
+
+		playlist.Segments = append(playlist.Segments, hls.Segment{
+			Sequence: i,
+			Duration: time.Duration(segment.Duration) * time.Millisecond,
+			URI:      name,
+
+			// The clip starts over, its first segment doesn't follow anything
+			Discontinuity: i > 0 && segment.Discontinuity,
+		})
+	}
+
+	if err := s.Store.Put(ctx, path.Join(ClipPrefix(id), hls.PlaylistName), playlist.Encode()); err != nil {
+		return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+	}
+
+	clip := model.Clip{
+		ID:          id,
+		ChannelID:   vod.ChannelID,
+		Channel:     vod.Channel,
+		SessionID:   session,
+		CreatorID:   req.CreatorID,
+		Title:       req.Title,
+		StartOffset: start,
+		Duration:    end - start,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	_, err = s.DB.Exec(ctx,
+		"INSERT INTO clips (id, channel_id, channel, session_id, creator_id, title, start_offset, duration, created_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)",
+		clip.ID, clip.ChannelID, clip.Channel, clip.SessionID, clip.CreatorID, clip.Title, clip.StartOffset, clip.Duration, clip.CreatedAt)
+
+	if err != nil {
+		return model.Clip{}, fmt.Errorf("CreateClip: %w", err)
+	}
+
+	// The clip exists either way, failing the request now would only make the creator clip it again
+	err = s.StreamClient.PublishClipCreatedEvent(event.ClipCreatedEventData{
+		ID:        clip.ID,
+		ChannelID: clip.ChannelID,
+		Channel:   clip.Channel,
+		SessionID: clip.SessionID,
+		CreatorID: clip.CreatorID,
+		Title:     clip.Title,
+		Duration:  clip.Duration,
+		CreatedAt: clip.CreatedAt,
+	})
+	if err != nil {
+		s.logger.Errorf("failed to publish clip created for clip %s: %v", clip.ID, err)
+	}
+
+	return clip, nil
+}
+
+func (s *ClipService) GetClip(ctx context.Context, id string) (model.Clip, error) {
+	rows, err := s.DB.Query(ctx, "SELECT "+clipColumns+" FROM clips WHERE id = $1", id)
+	if err != nil {
+		return model.Clip{}, fmt.Errorf("GetClip: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Clip{}, fmt.Errorf("GetClip: %w", model.ClipNotFoundError)
+	}
+
+	clip, err := scanClip(rows)
+	if err != nil {
+		return model.Clip{}, fmt.Errorf("GetClip: %w", err)
+	}
+
+	return clip, nil
+}
+
+func (s *ClipService) ListClips(ctx context.Context, channel string, page, limit int) ([]model.Clip, int, error) {
+	rows, err := s.DB.Query(ctx, "SELECT count(*) FROM clips WHERE channel = $1", channel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ListClips: %w", err)
+	}
+
+	var total int
+	if rows.Next() {
+		err = rows.Scan(&total)
+	}
+	rows.Close()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("ListClips: %w", err)
+	}
+
+	rows, err = s.DB.Query(ctx,
+		"SELECT "+clipColumns+" FROM clips WHERE channel = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3",
+		channel, limit, (page-1)*limit)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("ListClips: %w", err)
+	}
+
+	defer rows.Close()
+
+	clips := []model.Clip{}
+	for rows.Next() {
+		clip, err := scanClip(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("ListClips: %w", err)
+		}
+		clips = append(clips, clip)
+	}
+
+	return clips, total, nil
+}
+
+func (s *ClipService) Playlist(ctx context.Context, id string) ([]byte, error) {
+	tag, err := s.DB.Exec(ctx, "UPDATE clips SET views = views + 1 WHERE id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("Playlist: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("Playlist: %w", model.ClipNotFoundError)
+	}
+
+	data, err := s.Store.Get(ctx, path.Join(ClipPrefix(id), hls.PlaylistName))
+	if err != nil {
+		return nil, fmt.Errorf("Playlist: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *ClipService) Segment(ctx context.Context, id, name string) ([]byte, error) {
+	data, err := s.Store.Get(ctx, path.Join(ClipPrefix(id), name))
+	if err != nil {
+		return nil, fmt.Errorf("Segment: %w", err)
+	}
+
+	return data, nil
+}
+
+// coveringSegments returns the segments overlapping [start, end) in seconds and where the first of them starts
+func coveringSegments(segments []model.VodSegment, start, end float64) ([]model.VodSegment, float64) {
+	var (
+		covering []model.VodSegment
+		first    float64
+		position float64
+	)
+
+	for _, segment := range segments {
+		segmentEnd := position + float64(segment.Duration)/1000
+		if segmentEnd > start && position < end {
+			if len(covering) == 0 {
+				first = position
+			}
+			covering = append(covering, segment)
+		}
+		position = segmentEnd
+	}
+
+	return covering, first
+}
+
+type clipScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanClip(row clipScanner) (model.Clip, error) {
+	var clip model.Clip
+	err := row.Scan(&clip.ID, &clip.ChannelID, &clip.Channel, &clip.SessionID, &clip.CreatorID, &clip.Title,
+		&clip.StartOffset, &clip.Duration, &clip.Views, &clip.CreatedAt)
+
+	return clip, err
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/video/client/mock"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+	"nikolamilovic/twitchy/video/test_util"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+// recordedSession ingests a 10 second broadcast cut into 2 second segments and returns its session
+func recordedSession(t *testing.T, live *LiveService, streamClient *mock.StreamClientMock) string {
+	stream := test_util.Stream(test_util.StreamOptions{DurationMs: 10000, FPS: 25, GOP: 50, Video: true, Audio: true})
+
+	err := live.Ingest(context.Background(), model.Broadcast{ChannelID: 1, Channel: "test"}, bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	return streamClient.Started[len(streamClient.Started)-1].SessionID
+}
+
+func TestCreateClipFromVod(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	streamClient := &mock.StreamClientMock{}
+	logger := zap.L().Sugar().Named("test")
+
+	live := NewLiveService(store, hls.Config{TargetDuration: 2 * time.Second}, streamClient, logger)
+	vods := NewVodService(store, 0, logger)
+
+	session := recordedSession(t, live, streamClient)
+
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(ctx)
+
+	sut := NewClipService(mockDB, store, live, vods, streamClient, logger)
+
+	mockDB.ExpectExec("INSERT INTO clips").
+		WithArgs(pgxmock.AnyArg(), 1, "test", session, 2, "title", 3.0, 2.5, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	// 3s to 5.5s spans the second and third segment
+	clip, err := sut.CreateClip(ctx, model.ClipRequest{
+		CreatorID: 2,
+		Channel:   "test",
+		SessionID: session,
+		Offset:    3,
+		Duration:  2.5,
+		Title:     "title",
+	})
+
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+
+	keys, _ := store.List(ctx, ClipPrefix(clip.ID)+"/")
+	if len(keys) != 3 {
+		t.Fatalf("Expected a playlist and %d segments, got %v", 2, keys)
+	}
+
+	playlist, _ := store.Get(ctx, ClipPrefix(clip.ID)+"/"+hls.PlaylistName)
+	if !strings.Contains(string(playlist), "#EXT-X-START:TIME-OFFSET=1.000,PRECISE=YES\n#EXTINF:2.000,\n0.ts\n#EXTINF:2.000,\n1.ts\n#EXT-X-ENDLIST") {
+		t.Fatalf("Expected the clip playlist to start 1s into the first segment, got\n%s", playlist)
+	}
+
+	if len(streamClient.Clips) != 1 || streamClient.Clips[0].ID != clip.ID || streamClient.Clips[0].CreatorID != 2 {
+		t.Fatalf("Expected a clip created event, got %+v", streamClient.Clips)
+	}
+}
+
+func TestCreateClipErrors(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	streamClient := &mock.StreamClientMock{}
+	logger := zap.L().Sugar().Named("test")
+
+	live := NewLiveService(store, hls.Config{TargetDuration: 2 * time.Second}, streamClient, logger)
+	vods := NewVodService(store, 0, logger)
+
+	session := recordedSession(t, live, streamClient)
+
+	sut := NewClipService(nil, store, live, vods, streamClient, logger)
+
+	for _, scenario := range []struct {
+		description string
+		request     model.ClipRequest
+		expected    error
+	}{
+		{"offline channel", model.ClipRequest{Channel: "test", Duration: 10}, model.ChannelOfflineError},
+		{"missing session", model.ClipRequest{Channel: "test", SessionID: "0123456789abcdef", Duration: 10}, storage.ErrNotFound},
+		{"past the end", model.ClipRequest{Channel: "test", SessionID: session, Offset: 12, Duration: 10}, model.ClipOutOfRangeError},
+	} {
+		_, err := sut.CreateClip(ctx, scenario.request)
+		if !errors.Is(err, scenario.expected) {
+			t.Fatalf("Expected error to be %v for %s, got %v", scenario.expected, scenario.description, err)
+		}
+	}
+}
+
+func TestCoveringSegments(t *testing.T) {
+	segments := []model.VodSegment{
+		{Sequence: 0, Duration: 4000},
+		{Sequence: 1, Duration: 4000},
+		{Sequence: 2, Duration: 4000},
+	}
+
+	for _, scenario := range []struct {
+		start, end float64
+		expected   []int
+		first      float64
+	}{
+		{0, 4, []int{0}, 0},
+		{3, 5, []int{0, 1}, 0},
+		{4, 12, []int{1, 2}, 4},
+		{11, 30, []int{2}, 8},
+	} {
+		covering, first := coveringSegments(segments, scenario.start, scenario.end)
+
+		var got []int
+		for _, s := range covering {
+			got = append(got, s.Sequence)
+		}
+
+		if len(got) != len(scenario.expected) || first != scenario.first {
+			t.Fatalf("Expected %v starting at %v for [%v, %v), got %v starting at %v", scenario.expected, scenario.first, scenario.start, scenario.end, got, first)
+		}
+		for i := range got {
+			if got[i] != scenario.expected[i] {
+				t.Fatalf("Expected %v for [%v, %v), got %v", scenario.expected, scenario.start, scenario.end, got)
+			}
+		}
+	}
+}
+
+func TestClipPlaylistCountsViews(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(ctx)
+
+	sut := NewClipService(mockDB, store, nil, nil, nil, zap.L().Sugar().Named("test"))
+
+	store.Put(ctx, ClipPrefix("abc")+"/"+hls.PlaylistName, []byte("#EXTM3U\n"))
+
+	mockDB.ExpectExec("UPDATE clips SET views").WithArgs("abc").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mockDB.ExpectExec("UPDATE clips SET views").WithArgs("missing").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if _, err := sut.Playlist(ctx, "abc"); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if _, err := sut.Playlist(ctx, "missing"); !errors.Is(err, model.ClipNotFoundError) {
+		t.Fatalf("Expected error to be %v, got %v", model.ClipNotFoundError, err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
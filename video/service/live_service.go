@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/video/client"
+	"nikolamilovic/twitchy/video/flv"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+
+	"go.uber.org/zap"
+)
+
+var ErrAlreadyLive = errors.New("channel is already live")
+
+type ILiveService interface {
+	// Ingest packages the FLV stream read from r until it ends, blocking for the duration of the broadcast
+	Ingest(ctx context.Context, broadcast model.Broadcast, r io.Reader) error
+	Playlist(ctx context.Context, channel string) ([]byte, error)
+	Segment(ctx context.Context, channel, session, name string) ([]byte, error)
+	// CurrentSession returns the session the channel is broadcasting, false if it's offline
+	CurrentSession(channel string) (string, bool)
+}
+
+type LiveService struct {
+	Store        storage.BlobStore
+	Config       hls.Config
+	StreamClient client.IStreamClient
+	logger       *zap.SugaredLogger
+
+	mu sync.Mutex
+	// channel -> session, the session is empty while the ingest is starting up
+	live map[string]string
+}
+
+func NewLiveService(store storage.BlobStore, cfg hls.Config, streamClient client.IStreamClient, logger *zap.SugaredLogger) *LiveService {
+	return &LiveService{
+		Store:        store,
+		Config:       cfg,
+		StreamClient: streamClient,
+		logger:       logger,
+		live:         make(map[string]string),
+	}
+}
+
+func LivePrefix(channel string) string {
+	return path.Join("live", channel)
+}
+
+func (s *LiveService) Ingest(ctx context.Context, broadcast model.Broadcast, r io.Reader) error {
+	channel := broadcast.Channel
+	if !s.acquire(channel) {
+		return fmt.Errorf("Ingest: %w", ErrAlreadyLive)
+	}
+	defer s.release(channel)
+
+	session, err := newID()
+	if err != nil {
+		return fmt.Errorf("Ingest: %w", err)
+	}
+
+	prefix := LivePrefix(channel)
+
+	// Segments of the previous broadcast are kept until the channel goes live again so late viewers can finish them
+	if err := s.purge(ctx, prefix); err != nil {
+		return fmt.Errorf("Ingest: %w", err)
+	}
+
+	startedAt := time.Now().UTC()
+
+	err = s.StreamClient.PublishStreamStartedEvent(event.StreamStartedEventData{
+		SessionID: session,
+		ChannelID: broadcast.ChannelID,
+		Channel:   channel,
+		Title:     broadcast.Title,
+		Category:  broadcast.Category,
+		StartedAt: startedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("Ingest: %w", err)
+	}
+
+	s.setSession(channel, session)
+	s.logger.Infof("Channel %s went live with session %s", channel, session)
+
+	// However the broadcast ends, the rest of the platform has to know it's over
+	defer func() {
+		err := s.StreamClient.PublishStreamEndedEvent(event.StreamEndedEventData{
+			SessionID: session,
+			ChannelID: broadcast.ChannelID,
+			Channel:   channel,
+			EndedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			s.logger.Errorf("failed to publish stream ended for session %s: %v", session, err)
+		}
+	}()
+
+	recorder := newVodRecorder(s.Store, broadcast, session, startedAt)
+	packager := hls.NewPackager(s.Store, prefix, session, s.Config)
+	packager.SetRecorder(recorder)
+
+	ingestErr := s.packageStream(ctx, packager, r)
+
+	s.logger.Infof("Channel %s session %s ended", channel, session)
+
+	// The request context is likely already cancelled when the broadcaster hangs up
+	err = packager.Close(context.Background())
+	if err == nil {
+		err = recorder.Finish(context.Background(), time.Now().UTC())
+	}
+
+	if ingestErr != nil {
+		return fmt.Errorf("Ingest: %w", ingestErr)
+	}
+	if err != nil {
+		return fmt.Errorf("Ingest: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LiveService) packageStream(ctx context.Context, packager *hls.Packager, r io.Reader) error {
+	reader := flv.NewReader(r)
+
+	for {
+		tag, err := reader.ReadTag()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return err
+			}
+			return nil
+		}
+
+		if err := packager.WriteTag(ctx, tag); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *LiveService) Playlist(ctx context.Context, channel string) ([]byte, error) {
+	data, err := s.Store.Get(ctx, path.Join(LivePrefix(channel), hls.PlaylistName))
+	if err != nil {
+		return nil, fmt.Errorf("Playlist: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *LiveService) Segment(ctx context.Context, channel, session, name string) ([]byte, error) {
+	data, err := s.Store.Get(ctx, path.Join(LivePrefix(channel), session, name))
+	if err != nil {
+		return nil, fmt.Errorf("Segment: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *LiveService) CurrentSession(channel string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.live[channel]
+	return session, session != ""
+}
+
+func (s *LiveService) acquire(channel string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.live[channel]; ok {
+		return false
+	}
+	s.live[channel] = ""
+
+	return true
+}
+
+func (s *LiveService) setSession(channel, session string) {
+	s.mu.Lock()
+	s.live[channel] = session
+	s.mu.Unlock()
+}
+
+func (s *LiveService) release(channel string) {
+	s.mu.Lock()
+	delete(s.live, channel)
+	s.mu.Unlock()
+}
+
+func (s *LiveService) purge(ctx context.Context, prefix string) error {
+	keys, err := s.Store.List(ctx, prefix+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.Store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newID returns a random 16 character hex ID, used for sessions and clips
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
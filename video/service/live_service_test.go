@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/video/client/mock"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+	"nikolamilovic/twitchy/video/test_util"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestIngest(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// Leftovers of a previous broadcast
+	store.Put(ctx, "live/test/old/0.ts", []byte{0x47})
+
+	streamClient := &mock.StreamClientMock{}
+	sut := NewLiveService(store, hls.Config{TargetDuration: 2 * time.Second}, streamClient, zap.L().Sugar().Named("test"))
+
+	stream := test_util.Stream(test_util.StreamOptions{DurationMs: 6000, FPS: 25, GOP: 50, Video: true, Audio: true})
+
+	err := sut.Ingest(ctx, model.Broadcast{ChannelID: 1, Channel: "test", Title: "title"}, bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(streamClient.Started) != 1 || len(streamClient.Ended) != 1 {
+		t.Fatalf("Expected one started and one ended event, got %d and %d", len(streamClient.Started), len(streamClient.Ended))
+	}
+
+	started, ended := streamClient.Started[0], streamClient.Ended[0]
+	if started.ChannelID != 1 || started.Channel != "test" || started.Title != "title" {
+		t.Fatalf("Expected the started event to describe the broadcast, got %+v", started)
+	}
+
+	if started.SessionID == "" || started.SessionID != ended.SessionID {
+		t.Fatalf("Expected both events to carry the same session, got %s and %s", started.SessionID, ended.SessionID)
+	}
+
+	playlist, err := sut.Playlist(ctx, "test")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if !strings.HasSuffix(string(playlist), "#EXT-X-ENDLIST\n") {
+		t.Fatalf("Expected an ended playlist, got\n%s", playlist)
+	}
+
+	if _, err := store.Get(ctx, "live/test/old/0.ts"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected segments of the previous session to be purged, got %v", err)
+	}
+
+	keys, _ := store.List(ctx, "live/test/")
+	// Playlist and 3 segments
+	if len(keys) != 4 {
+		t.Fatalf("Expected %d blobs, got %v", 4, keys)
+	}
+}
+
+func TestIngestAlreadyLive(t *testing.T) {
+	streamClient := &mock.StreamClientMock{}
+	sut := NewLiveService(storage.NewMemoryStore(), hls.Config{}, streamClient, zap.L().Sugar().Named("test"))
+
+	if !sut.acquire("test") {
+		t.Fatalf("Expected to acquire the channel")
+	}
+
+	err := sut.Ingest(context.Background(), model.Broadcast{Channel: "test"}, bytes.NewReader(nil))
+	if !errors.Is(err, ErrAlreadyLive) {
+		t.Fatalf("Expected error to be %v, got %v", ErrAlreadyLive, err)
+	}
+
+	if len(streamClient.Started) != 0 {
+		t.Fatalf("Expected no events for a rejected ingest, got %v", streamClient.Started)
+	}
+}
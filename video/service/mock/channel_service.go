@@ -0,0 +1,29 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/video/model"
+)
+
+// ChannelServiceMock knows the channels in Channels, keyed by their owner
+type ChannelServiceMock struct {
+	Channels map[int]string
+}
+
+func (s *ChannelServiceMock) CreateChannel(ctx context.Context, channelID int, channel string) error {
+	if s.Channels == nil {
+		s.Channels = map[int]string{}
+	}
+	if _, ok := s.Channels[channelID]; !ok {
+		s.Channels[channelID] = channel
+	}
+	return nil
+}
+
+func (s *ChannelServiceMock) GetChannel(ctx context.Context, channelID int) (string, error) {
+	channel, ok := s.Channels[channelID]
+	if !ok {
+		return "", model.ChannelNotFoundError
+	}
+	return channel, nil
+}
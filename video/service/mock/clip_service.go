@@ -0,0 +1,44 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+)
+
+type ClipServiceMock struct {
+	Requests []model.ClipRequest
+}
+
+func (s *ClipServiceMock) CreateClip(ctx context.Context, req model.ClipRequest) (model.Clip, error) {
+	if req.Channel == "offline" {
+		return model.Clip{}, model.ChannelOfflineError
+	}
+	s.Requests = append(s.Requests, req)
+	return model.Clip{ID: "0123456789abcdef", Channel: req.Channel, CreatorID: req.CreatorID, Title: req.Title}, nil
+}
+
+func (s *ClipServiceMock) GetClip(ctx context.Context, id string) (model.Clip, error) {
+	if id == "ffffffffffffffff" {
+		return model.Clip{}, model.ClipNotFoundError
+	}
+	return model.Clip{ID: id}, nil
+}
+
+func (s *ClipServiceMock) ListClips(ctx context.Context, channel string, page, limit int) ([]model.Clip, int, error) {
+	return []model.Clip{{ID: "0123456789abcdef", Channel: channel}}, 1, nil
+}
+
+func (s *ClipServiceMock) Playlist(ctx context.Context, id string) ([]byte, error) {
+	if id == "ffffffffffffffff" {
+		return nil, model.ClipNotFoundError
+	}
+	return []byte("#EXTM3U\n"), nil
+}
+
+func (s *ClipServiceMock) Segment(ctx context.Context, id, name string) ([]byte, error) {
+	if id == "ffffffffffffffff" {
+		return nil, storage.ErrNotFound
+	}
+	return []byte{0x47}, nil
+}
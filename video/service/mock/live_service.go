@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"context"
+	"io"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+)
+
+// LiveServiceMock records the ingested broadcasts
+type LiveServiceMock struct {
+	Broadcasts []model.Broadcast
+}
+
+func (s *LiveServiceMock) Ingest(ctx context.Context, broadcast model.Broadcast, r io.Reader) error {
+	s.Broadcasts = append(s.Broadcasts, broadcast)
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func (s *LiveServiceMock) Playlist(ctx context.Context, channel string) ([]byte, error) {
+	if channel == "offline" {
+		return nil, storage.ErrNotFound
+	}
+	return []byte("#EXTM3U\n"), nil
+}
+
+func (s *LiveServiceMock) Segment(ctx context.Context, channel, session, name string) ([]byte, error) {
+	return []byte{0x47}, nil
+}
+
+func (s *LiveServiceMock) CurrentSession(channel string) (string, bool) {
+	if channel == "offline" {
+		return "", false
+	}
+	return "0123456789abcdef", true
+}
@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+	"time"
+)
+
+type VodServiceMock struct {
+}
+
+func (s *VodServiceMock) ListVods(ctx context.Context, channel string) ([]model.Vod, error) {
+	if channel == "offline" {
+		return []model.Vod{}, nil
+	}
+	return []model.Vod{{SessionID: "0123456789abcdef", Channel: channel, Duration: 8}}, nil
+}
+
+func (s *VodServiceMock) GetVod(ctx context.Context, channel, session string) (model.Vod, error) {
+	if channel == "offline" {
+		return model.Vod{}, storage.ErrNotFound
+	}
+	return model.Vod{SessionID: session, Channel: channel}, nil
+}
+
+func (s *VodServiceMock) Playlist(ctx context.Context, channel, session string) ([]byte, error) {
+	if channel == "offline" {
+		return nil, storage.ErrNotFound
+	}
+	return []byte("#EXTM3U\n"), nil
+}
+
+func (s *VodServiceMock) Segment(ctx context.Context, channel, session, name string) ([]byte, error) {
+	return []byte{0x47}, nil
+}
+
+func (s *VodServiceMock) DeleteExpired(ctx context.Context, cutoff time.Time) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+)
+
+// vodRecorder copies the segments of a live session into the archive. The manifest is written with the first segment
+// and every segment is appended to the segment log next to it, so a crashed ingest still leaves a playable VOD behind.
+type vodRecorder struct {
+	store    storage.BlobStore
+	prefix   string
+	vod      model.Vod
+	segments int
+}
+
+func newVodRecorder(store storage.BlobStore, broadcast model.Broadcast, session string, startedAt time.Time) *vodRecorder {
+	return &vodRecorder{
+		store:  store,
+		prefix: VodPrefix(broadcast.Channel, session),
+		vod: model.Vod{
+			SessionID: session,
+			ChannelID: broadcast.ChannelID,
+			Channel:   broadcast.Channel,
+			Title:     broadcast.Title,
+			Category:  broadcast.Category,
+			StartedAt: startedAt,
+		},
+	}
+}
+
+func (r *vodRecorder) RecordSegment(ctx context.Context, segment hls.Segment, data []byte) error {
+	name := path.Base(segment.URI)
+
+	if err := r.store.Put(ctx, path.Join(r.prefix, name), data); err != nil {
+		return fmt.Errorf("RecordSegment: %w", err)
+	}
+
+	if r.segments == 0 {
+		if err := r.writeManifest(ctx); err != nil {
+			return fmt.Errorf("RecordSegment: %w", err)
+		}
+	}
+
+	line, err := json.Marshal(model.VodSegment{
+		Sequence: segment.Sequence,
+		Duration: segment.Duration.Milliseconds(),
+		Name:     name,
+
+		Discontinuity: segment.Discontinuity,
+	})
+	if err != nil {
+		return fmt.Errorf("RecordSegment: %w", err)
+	}
+
+	if err := r.store.Append(ctx, path.Join(r.prefix, SegmentLogName), append(line, '\n')); err != nil {
+		return fmt.Errorf("RecordSegment: %w", err)
+	}
+
+	r.segments++
+	r.vod.Duration += segment.Duration.Seconds()
+
+	return nil
+}
+
+// Finish marks the recording as ended, broadcasts that never produced a segment leave nothing behind
+func (r *vodRecorder) Finish(ctx context.Context, endedAt time.Time) error {
+	if r.segments == 0 {
+		return nil
+	}
+
+	r.vod.EndedAt = &endedAt
+
+	if err := r.writeManifest(ctx); err != nil {
+		return fmt.Errorf("Finish: %w", err)
+	}
+
+	return nil
+}
+
+func (r *vodRecorder) writeManifest(ctx context.Context) error {
+	data, err := json.Marshal(r.vod)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Put(ctx, path.Join(r.prefix, ManifestName), data)
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ManifestName = "manifest.json"
+	// SegmentLogName holds the segments of a VOD, a JSON object per line in the order they were recorded
+	SegmentLogName = "segments.jsonl"
+)
+
+type IVodService interface {
+	// ListVods returns the archived broadcasts of the channel, newest first and without their segments
+	ListVods(ctx context.Context, channel string) ([]model.Vod, error)
+	GetVod(ctx context.Context, channel, session string) (model.Vod, error)
+	Playlist(ctx context.Context, channel, session string) ([]byte, error)
+	Segment(ctx context.Context, channel, session, name string) ([]byte, error)
+	// DeleteExpired removes every VOD that ended before the cutoff and returns how many were removed
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type VodService struct {
+	Store storage.BlobStore
+	// Retention is how long VODs are kept after they end, zero keeps them forever
+	Retention time.Duration
+	logger    *zap.SugaredLogger
+}
+
+func NewVodService(store storage.BlobStore, retention time.Duration, logger *zap.SugaredLogger) *VodService {
+	return &VodService{
+		Store:     store,
+		Retention: retention,
+		logger:    logger,
+	}
+}
+
+func VodPrefix(channel, session string) string {
+	return path.Join("vod", channel, session)
+}
+
+func (s *VodService) ListVods(ctx context.Context, channel string) ([]model.Vod, error) {
+	vods, err := s.manifests(ctx, path.Join("vod", channel)+"/")
+	if err != nil {
+		return nil, fmt.Errorf("ListVods: %w", err)
+	}
+
+	sort.Slice(vods, func(i, j int) bool {
+		return vods[i].StartedAt.After(vods[j].StartedAt)
+	})
+
+	for i := range vods {
+		// The duration of a recording that is still running is only known from its segments
+		if vods[i].EndedAt == nil {
+			if err := s.readSegments(ctx, &vods[i]); err != nil {
+				return nil, fmt.Errorf("ListVods: %w", err)
+			}
+		}
+		vods[i].Segments = nil
+	}
+
+	return vods, nil
+}
+
+func (s *VodService) GetVod(ctx context.Context, channel, session string) (model.Vod, error) {
+	vod, err := s.manifest(ctx, path.Join(VodPrefix(channel, session), ManifestName))
+	if err != nil {
+		return model.Vod{}, fmt.Errorf("GetVod: %w", err)
+	}
+
+	if err := s.readSegments(ctx, &vod); err != nil {
+		return model.Vod{}, fmt.Errorf("GetVod: %w", err)
+	}
+
+	return vod, nil
+}
+
+// Playlist builds the VOD playlist from the manifest, a recording that is still running is served without the end tag
+// so players keep polling it
+func (s *VodService) Playlist(ctx context.Context, channel, session string) ([]byte, error) {
+	vod, err := s.GetVod(ctx, channel, session)
+	if err != nil {
+		return nil, fmt.Errorf("Playlist: %w", err)
+	}
+
+	playlist := hls.Playlist{
+		Ended: vod.EndedAt != nil,
+	}
+	if playlist.Ended {
+		playlist.Type = hls.PlaylistTypeVOD
+	}
+
+	for _, segment := range vod.Segments {
+		playlist.Segments = append(playlist.Segments, hls.Segment{
+			Sequence: segment.Sequence,
+			Duration: time.Duration(segment.Duration) * time.Millisecond,
+			URI:      segment.Name,
+
+			Discontinuity: segment.Discontinuity,
+		})
+	}
+	if len(playlist.Segments) > 0 {
+		playlist.MediaSequence = playlist.Segments[0].Sequence
+	}
+
+	return playlist.Encode(), nil
+}
+
+func (s *VodService) Segment(ctx context.Context, channel, session, name string) ([]byte, error) {
+	data, err := s.Store.Get(ctx, path.Join(VodPrefix(channel, session), name))
+	if err != nil {
+		return nil, fmt.Errorf("Segment: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *VodService) DeleteExpired(ctx context.Context, cutoff time.Time) (int, error) {
+	vods, err := s.manifests(ctx, "vod/")
+	if err != nil {
+		return 0, fmt.Errorf("DeleteExpired: %w", err)
+	}
+
+	deleted := 0
+	for _, vod := range vods {
+		if !vod.Expired(cutoff) {
+			continue
+		}
+
+		keys, err := s.Store.List(ctx, VodPrefix(vod.Channel, vod.SessionID)+"/")
+		if err != nil {
+			return deleted, fmt.Errorf("DeleteExpired: %w", err)
+		}
+
+		// The manifest goes last so a failed run is picked up again by the next one
+		sort.SliceStable(keys, func(i, j int) bool {
+			return path.Base(keys[j]) == ManifestName && path.Base(keys[i]) != ManifestName
+		})
+
+		for _, key := range keys {
+			if err := s.Store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return deleted, fmt.Errorf("DeleteExpired: %w", err)
+			}
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// RunRetention enforces the retention policy every interval until ctx is cancelled
+func (s *VodService) RunRetention(ctx context.Context, interval time.Duration) {
+	if s.Retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpired(ctx, time.Now().Add(-s.Retention))
+		if err != nil {
+			s.logger.Errorf("failed to enforce the VOD retention: %v", err)
+		} else if deleted > 0 {
+			s.logger.Infof("Deleted %d expired VODs", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *VodService) manifests(ctx context.Context, prefix string) ([]model.Vod, error) {
+	keys, err := s.Store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	vods := []model.Vod{}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/"+ManifestName) {
+			continue
+		}
+
+		vod, err := s.manifest(ctx, key)
+		if err != nil {
+			// Deleted in between listing and reading it
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		vods = append(vods, vod)
+	}
+
+	return vods, nil
+}
+
+// readSegments adds the segments in the log of the VOD to it and sums up its duration. Manifests written before the
+// log existed carry their segments themselves and have no log.
+func (s *VodService) readSegments(ctx context.Context, vod *model.Vod) error {
+	data, err := s.Store.Get(ctx, path.Join(VodPrefix(vod.Channel, vod.SessionID), SegmentLogName))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Whatever follows the last newline is a segment the recorder didn't finish appending
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines[:len(lines)-1] {
+		var segment model.VodSegment
+		if err := json.Unmarshal(line, &segment); err != nil {
+			return err
+		}
+		vod.Segments = append(vod.Segments, segment)
+	}
+
+	var duration int64
+	for _, segment := range vod.Segments {
+		duration += segment.Duration
+	}
+	vod.Duration = float64(duration) / 1000
+
+	return nil
+}
+
+func (s *VodService) manifest(ctx context.Context, key string) (model.Vod, error) {
+	data, err := s.Store.Get(ctx, key)
+	if err != nil {
+		return model.Vod{}, err
+	}
+
+	var vod model.Vod
+	if err := json.Unmarshal(data, &vod); err != nil {
+		return model.Vod{}, err
+	}
+
+	return vod, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"nikolamilovic/twitchy/video/client/mock"
+	"nikolamilovic/twitchy/video/hls"
+	"nikolamilovic/twitchy/video/model"
+	"nikolamilovic/twitchy/video/storage"
+	"nikolamilovic/twitchy/video/test_util"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestIngestRecordsVod(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	streamClient := &mock.StreamClientMock{}
+	live := NewLiveService(store, hls.Config{TargetDuration: 2 * time.Second, WindowSize: 1}, streamClient, zap.L().Sugar().Named("test"))
+	sut := NewVodService(store, 0, zap.L().Sugar().Named("test"))
+
+	stream := test_util.Stream(test_util.StreamOptions{DurationMs: 10000, FPS: 25, GOP: 50, Video: true, Audio: true})
+
+	err := live.Ingest(ctx, model.Broadcast{ChannelID: 1, Channel: "test", Title: "title"}, bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	session := streamClient.Started[0].SessionID
+
+	vods, err := sut.ListVods(ctx, "test")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(vods) != 1 || vods[0].SessionID != session || vods[0].Title != "title" || vods[0].EndedAt == nil {
+		t.Fatalf("Expected the finished broadcast, got %+v", vods)
+	}
+
+	if len(vods[0].Segments) != 0 {
+		t.Fatalf("Expected the listing to leave out the segments, got %v", vods[0].Segments)
+	}
+
+	// The live window only held one segment, the VOD has all of them
+	vod, err := sut.GetVod(ctx, "test", session)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	// The last segment ends on the last frame, not at the end of the stream
+	if len(vod.Segments) != 5 || vod.Duration < 9.9 || vod.Duration > 10 {
+		t.Fatalf("Expected %d segments and about 10s, got %d and %v", 5, len(vod.Segments), vod.Duration)
+	}
+
+	for _, segment := range vod.Segments {
+		if _, err := sut.Segment(ctx, "test", session, segment.Name); err != nil {
+			t.Fatalf("Expected segment %s to be archived, got %v", segment.Name, err)
+		}
+	}
+
+	playlist, err := sut.Playlist(ctx, "test", session)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if !strings.Contains(string(playlist), "#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:2.000,\n0.ts\n") ||
+		!strings.HasSuffix(string(playlist), "4.ts\n#EXT-X-ENDLIST\n") {
+		t.Fatalf("Expected a VOD playlist of the whole broadcast, got\n%s", playlist)
+	}
+}
+
+func TestPlaylistOfRunningRecording(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	sut := NewVodService(store, 0, zap.L().Sugar().Named("test"))
+
+	putVod(t, store, model.Vod{
+		SessionID: "abc",
+		Channel:   "test",
+		StartedAt: time.Now(),
+		Segments:  []model.VodSegment{{Sequence: 0, Duration: 4000, Name: "0.ts"}},
+	})
+
+	playlist, err := sut.Playlist(ctx, "test", "abc")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if strings.Contains(string(playlist), "#EXT-X-ENDLIST") || strings.Contains(string(playlist), "#EXT-X-PLAYLIST-TYPE") {
+		t.Fatalf("Expected an open playlist, got\n%s", playlist)
+	}
+
+	if _, err := sut.Playlist(ctx, "test", "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected error to be %v, got %v", storage.ErrNotFound, err)
+	}
+}
+
+func TestVodFromSegmentLog(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	sut := NewVodService(store, 0, zap.L().Sugar().Named("test"))
+
+	putVod(t, store, model.Vod{SessionID: "abc", Channel: "test", StartedAt: time.Now()})
+
+	// The ingest died while appending the third segment
+	log := `{"sequence":0,"duration":4000,"name":"0.ts"}` + "\n" +
+		`{"sequence":1,"duration":2500,"name":"1.ts","discontinuity":true}` + "\n" +
+		`{"sequence":2,"dura`
+	if err := store.Append(ctx, VodPrefix("test", "abc")+"/"+SegmentLogName, []byte(log)); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	vod, err := sut.GetVod(ctx, "test", "abc")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(vod.Segments) != 2 || !vod.Segments[1].Discontinuity || vod.Duration != 6.5 {
+		t.Fatalf("Expected the 2 complete segments and 6.5s, got %+v", vod)
+	}
+
+	vods, err := sut.ListVods(ctx, "test")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if len(vods) != 1 || vods[0].Duration != 6.5 || vods[0].Segments != nil {
+		t.Fatalf("Expected the running VOD with its duration so far, got %+v", vods)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	sut := NewVodService(store, 7*24*time.Hour, zap.L().Sugar().Named("test"))
+
+	now := time.Now()
+	old := now.Add(-10 * 24 * time.Hour)
+	recent := now.Add(-24 * time.Hour)
+
+	putVod(t, store, model.Vod{SessionID: "old", Channel: "test", StartedAt: old, EndedAt: &old})
+	putVod(t, store, model.Vod{SessionID: "recent", Channel: "test", StartedAt: old, EndedAt: &recent})
+	// Still recording, or the ingest crashed, either way it's kept
+	putVod(t, store, model.Vod{SessionID: "running", Channel: "other", StartedAt: old})
+	store.Put(ctx, "vod/test/old/0.ts", []byte{0x47})
+
+	deleted, err := sut.DeleteExpired(ctx, now.Add(-sut.Retention))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if deleted != 1 {
+		t.Fatalf("Expected %d VOD to be deleted, got %d", 1, deleted)
+	}
+
+	keys, _ := store.List(ctx, "vod/")
+	if len(keys) != 2 || keys[0] != "vod/other/running/manifest.json" || keys[1] != "vod/test/recent/manifest.json" {
+		t.Fatalf("Expected the recent and the running VOD to be left, got %v", keys)
+	}
+}
+
+func putVod(t *testing.T, store storage.BlobStore, vod model.Vod) {
+	data, err := json.Marshal(vod)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := store.Put(context.Background(), VodPrefix(vod.Channel, vod.SessionID)+"/"+ManifestName, data); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+}
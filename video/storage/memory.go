@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps blobs in memory, used in tests and for single instance deployments where losing segments on restart is fine
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, data []byte) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	blob := make([]byte, len(data))
+	copy(blob, data)
+
+	s.mu.Lock()
+	s.blobs[key] = blob
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, key string, data []byte) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	s.blobs[key] = append(s.blobs[key], data...)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Blobs are never mutated in place, Put always swaps in a fresh slice and Append only writes past the end of the
+	// slices handed out before
+	return blob, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.blobs, key)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.blobs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
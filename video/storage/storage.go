@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobStore stores opaque blobs under slash separated keys, e.g. live/channel/session/0.ts
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Append adds data to the end of the blob at key, creating it if it doesn't exist
+	Append(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// List returns all keys starting with prefix in lexical order
+	List(ctx context.Context, prefix string) ([]string, error)
+}
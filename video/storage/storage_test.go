@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBlobStores(t *testing.T) {
+	disk, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	for name, store := range map[string]BlobStore{
+		"memory": NewMemoryStore(),
+		"disk":   disk,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			//GIVEN
+			for _, key := range []string{"live/a/index.m3u8", "live/a/s1/0.ts", "live/a/s1/1.ts", "live/b/index.m3u8"} {
+				if err := store.Put(ctx, key, []byte(key)); err != nil {
+					t.Fatalf("Expected error to be nil, got %v", err)
+				}
+			}
+
+			//SHOULD
+			data, err := store.Get(ctx, "live/a/s1/1.ts")
+			if err != nil || string(data) != "live/a/s1/1.ts" {
+				t.Fatalf("Expected to read back the blob, got %q, %v", data, err)
+			}
+
+			keys, err := store.List(ctx, "live/a/")
+			if err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			if want := []string{"live/a/index.m3u8", "live/a/s1/0.ts", "live/a/s1/1.ts"}; !reflect.DeepEqual(keys, want) {
+				t.Fatalf("Expected keys %v, got %v", want, keys)
+			}
+
+			if err := store.Put(ctx, "live/a/index.m3u8", []byte("updated")); err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			if data, _ := store.Get(ctx, "live/a/index.m3u8"); string(data) != "updated" {
+				t.Fatalf("Expected overwritten blob, got %q", data)
+			}
+
+			if err := store.Delete(ctx, "live/a/s1/0.ts"); err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+
+			if _, err := store.Get(ctx, "live/a/s1/0.ts"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Expected error to be %v, got %v", ErrNotFound, err)
+			}
+
+			for _, part := range []string{"a\n", "b\n"} {
+				if err := store.Append(ctx, "live/a/s1/log", []byte(part)); err != nil {
+					t.Fatalf("Expected error to be nil, got %v", err)
+				}
+			}
+
+			if data, _ := store.Get(ctx, "live/a/s1/log"); string(data) != "a\nb\n" {
+				t.Fatalf("Expected the appended blob, got %q", data)
+			}
+
+			// Deleting a missing blob is not an error
+			if err := store.Delete(ctx, "live/a/s1/0.ts"); err != nil {
+				t.Fatalf("Expected error to be nil, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiskStoreRejectsTraversal(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	for _, key := range []string{"", "../escape", "live/../../escape", "/absolute"} {
+		if err := store.Put(context.Background(), key, []byte("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Expected error to be %v for key %q, got %v", ErrInvalidKey, key, err)
+		}
+	}
+}
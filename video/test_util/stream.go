@@ -0,0 +1,104 @@
+package test_util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"nikolamilovic/twitchy/video/flv"
+)
+
+var (
+	SPS = []byte{0x67, 0x42, 0xc0, 0x1e, 0xda, 0x02, 0x80, 0xbf, 0xe5}
+	PPS = []byte{0x68, 0xce, 0x3c, 0x80}
+
+	// AAC-LC, 44.1kHz, stereo
+	AudioSpecificConfig = []byte{0x12, 0x10}
+)
+
+// StreamOptions describes a synthetic FLV stream, the elementary streams carry dummy payloads
+// with valid framing so they exercise the whole remux path without needing a real encoder
+type StreamOptions struct {
+	DurationMs int
+	FPS        int
+	// GOP is the number of frames between keyframes
+	GOP   int
+	Video bool
+	Audio bool
+}
+
+// AVCDecoderConfigurationRecord with 4 byte NALU lengths and a single SPS and PPS
+func AVCDecoderConfig() []byte {
+	cfg := []byte{0x01, SPS[1], SPS[2], SPS[3], 0xff, 0xe1}
+	cfg = append(cfg, byte(len(SPS)>>8), byte(len(SPS)))
+	cfg = append(cfg, SPS...)
+	cfg = append(cfg, 0x01, byte(len(PPS)>>8), byte(len(PPS)))
+	return append(cfg, PPS...)
+}
+
+// AVCCFrame builds a length prefixed access unit with a single slice NALU
+func AVCCFrame(keyframe bool, size int) []byte {
+	nalu := make([]byte, size)
+	nalu[0] = 0x41
+	if keyframe {
+		nalu[0] = 0x65
+	}
+	for i := 1; i < size; i++ {
+		nalu[i] = byte(i)
+	}
+
+	frame := make([]byte, 4, 4+size)
+	binary.BigEndian.PutUint32(frame, uint32(size))
+	return append(frame, nalu...)
+}
+
+// Tags returns the tags of a synthetic stream in timestamp order
+func Tags(opts StreamOptions) []*flv.Tag {
+	var tags []*flv.Tag
+
+	if opts.Video {
+		tags = append(tags, flv.NewVideoTag(0, true, flv.PacketSequenceHeader, 0, AVCDecoderConfig()))
+	}
+	if opts.Audio {
+		tags = append(tags, flv.NewAudioTag(0, flv.PacketSequenceHeader, AudioSpecificConfig))
+	}
+
+	frameMs := 1000 / opts.FPS
+	// 1024 samples per AAC frame at 44.1kHz
+	audioMs := 23
+
+	video, audio, frame := 0, 0, 0
+	for {
+		nextVideo := opts.Video && video < opts.DurationMs
+		nextAudio := opts.Audio && audio < opts.DurationMs
+		if !nextVideo && !nextAudio {
+			break
+		}
+
+		if nextVideo && (!nextAudio || video <= audio) {
+			keyframe := frame%opts.GOP == 0
+			size := 300
+			if keyframe {
+				size = 2000
+			}
+			tags = append(tags, flv.NewVideoTag(uint32(video), keyframe, flv.PacketData, 0, AVCCFrame(keyframe, size)))
+			video += frameMs
+			frame++
+		} else {
+			tags = append(tags, flv.NewAudioTag(uint32(audio), flv.PacketData, bytes.Repeat([]byte{0x21}, 200)))
+			audio += audioMs
+		}
+	}
+
+	return tags
+}
+
+// Stream encodes a synthetic stream as FLV
+func Stream(opts StreamOptions) []byte {
+	var buf bytes.Buffer
+	w := flv.NewWriter(&buf)
+
+	for _, tag := range Tags(opts) {
+		w.WriteTag(tag)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nikolamilovic/twitchy/common/authz"
+	"nikolamilovic/twitchy/common/utils"
+	"nikolamilovic/twitchy/webhooks/model"
+	"nikolamilovic/twitchy/webhooks/model/response"
+	"nikolamilovic/twitchy/webhooks/service"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// typePolicies are the subscription types and who may subscribe to them, the channel is the broadcaster of the condition
+var typePolicies = map[string]authz.Policy{
+	model.TypeStreamOnline:     authz.Authenticated,
+	model.TypeStreamOffline:    authz.Authenticated,
+	model.TypeChannelUpdate:    authz.Authenticated,
+	model.TypeChannelFollow:    authz.ModeratorOf,
+	model.TypeChannelSubscribe: authz.BroadcasterOf,
+}
+
+type SubscriptionHandler struct {
+	router              *chi.Mux
+	validator           *validator.Validate
+	subscriptionService service.ISubscriptionService
+	jwtSecret           []byte
+}
+
+func NewSubscriptionHandler(validator *validator.Validate, subscriptions service.ISubscriptionService, jwtSecret []byte) *SubscriptionHandler {
+	h := &SubscriptionHandler{}
+
+	h.validator = validator
+	h.subscriptionService = subscriptions
+	h.jwtSecret = jwtSecret
+
+	h.Routes()
+
+	return h
+}
+
+func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *SubscriptionHandler) Routes() {
+	r := chi.NewRouter()
+	h.router = r
+
+	r.Use(authz.Middleware(h.jwtSecret, authz.Authenticated, nil))
+
+	r.Post("/", h.handleCreateSubscription())
+	r.Get("/", h.handleGetSubscriptions())
+	r.Delete("/{id}", h.handleDeleteSubscription())
+	r.Get("/{id}/deliveries", h.handleGetDeliveries())
+}
+
+// handleCreateSubscription accepts the subscription, it's enabled once the callback answers the verification challenge
+func (h *SubscriptionHandler) handleCreateSubscription() http.HandlerFunc {
+	type ConditionRequest struct {
+		BroadcasterUserID int `json:"broadcaster_user_id" validate:"required,gt=0"`
+	}
+
+	type TransportRequest struct {
+		Method   string `json:"method" validate:"eq=webhook"`
+		Callback string `json:"callback" validate:"required,url,startswith=https://,max=2048"`
+		Secret   string `json:"secret" validate:"required,min=10,max=100"`
+	}
+
+	type SubscriptionRequest struct {
+		Type      string           `json:"type" validate:"required"`
+		Version   string           `json:"version" validate:"omitempty,eq=1"`
+		Condition ConditionRequest `json:"condition"`
+		Transport TransportRequest `json:"transport"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		var req SubscriptionRequest
+
+		if err := utils.DecodeJSONBody(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.validator.Struct(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy, ok := typePolicies[req.Type]
+		if !ok {
+			http.Error(w, "unknown subscription type", http.StatusBadRequest)
+			return
+		}
+
+		if !policy(claims, req.Condition.BroadcasterUserID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		sub, err := h.subscriptionService.CreateSubscription(model.Subscription{
+			UserID:    claims.UserId,
+			Type:      req.Type,
+			Version:   model.Version,
+			Condition: model.Condition{BroadcasterUserID: req.Condition.BroadcasterUserID},
+			Transport: model.Transport{Method: model.TransportWebhook, Callback: req.Transport.Callback},
+			Secret:    req.Transport.Secret,
+		})
+
+		switch {
+		case err == nil:
+			writeJSONStatus(w, http.StatusAccepted, sub)
+		case errors.Is(err, model.SubscriptionExistsError):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			fmt.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleGetSubscriptions lists the subscriptions of the user, ?status= narrows them down to one status
+func (h *SubscriptionHandler) handleGetSubscriptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		subscriptions, err := h.subscriptionService.GetSubscriptions(claims.UserId, r.URL.Query().Get("status"))
+		if err != nil {
+			fmt.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, response.SubscriptionsResponse{Subscriptions: subscriptions})
+	}
+}
+
+func (h *SubscriptionHandler) handleDeleteSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		err := h.subscriptionService.DeleteSubscription(claims.UserId, chi.URLParam(r, "id"))
+
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, model.SubscriptionNotFoundError):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			fmt.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleGetDeliveries pages through the delivery log of the subscription with ?page= and ?limit=
+func (h *SubscriptionHandler) handleGetDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := authz.ClaimsFromContext(r.Context())
+
+		page, pageErr := strconv.Atoi(queryParam(r, "page", "1"))
+		limit, limitErr := strconv.Atoi(queryParam(r, "limit", strconv.Itoa(defaultLimit)))
+
+		if pageErr != nil || limitErr != nil || page < 1 || limit < 1 || limit > maxLimit {
+			http.Error(w, "page must be positive and limit between 1 and 100", http.StatusBadRequest)
+			return
+		}
+
+		deliveries, total, err := h.subscriptionService.GetDeliveries(claims.UserId, chi.URLParam(r, "id"), page, limit)
+
+		switch {
+		case err == nil:
+			writeJSON(w, response.DeliveriesResponse{
+				Deliveries: deliveries,
+				Page:       page,
+				Limit:      limit,
+				Total:      total,
+			})
+		case errors.Is(err, model.SubscriptionNotFoundError):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			fmt.Println(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func queryParam(r *http.Request, key, fallback string) string {
+	if value := r.URL.Query().Get(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	writeJSONStatus(w, http.StatusOK, data)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		fmt.Println(err.Error())
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/test_util"
+	"nikolamilovic/twitchy/common/token"
+	"nikolamilovic/twitchy/webhooks/model"
+	"nikolamilovic/twitchy/webhooks/model/response"
+	"nikolamilovic/twitchy/webhooks/service/mock"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestCreateSubscription(t *testing.T) {
+	secret := "secret"
+	viewer, err := test_util.GenerateTokens(2, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	moderator, err := test_util.GenerateTokens(3, secret, token.ChannelScope(token.RoleModerator, 1))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	subscription := func(subscriptionType, callback, secret string) string {
+		return `{"type":"` + subscriptionType + `","version":"1","condition":{"broadcaster_user_id":1},` +
+			`"transport":{"method":"webhook","callback":"` + callback + `","secret":"` + secret + `"}}`
+	}
+
+	type subscriptionTest struct {
+		description    string
+		body           string
+		token          string
+		expectedStatus int
+	}
+
+	for _, scenario := range []subscriptionTest{
+		{"public event", subscription("stream.online", "https://example.com/webhooks", "s3cr3t-s3cr3t"), viewer, http.StatusAccepted},
+		{"follows as a moderator", subscription("channel.follow", "https://example.com/webhooks", "s3cr3t-s3cr3t"), moderator, http.StatusAccepted},
+		{"follows of someone else's channel", subscription("channel.follow", "https://example.com/webhooks", "s3cr3t-s3cr3t"), viewer, http.StatusForbidden},
+		{"subscribers as a moderator", subscription("channel.subscribe", "https://example.com/webhooks", "s3cr3t-s3cr3t"), moderator, http.StatusForbidden},
+		{"unknown type", subscription("channel.raid", "https://example.com/webhooks", "s3cr3t-s3cr3t"), viewer, http.StatusBadRequest},
+		{"plain http callback", subscription("stream.online", "http://example.com/webhooks", "s3cr3t-s3cr3t"), viewer, http.StatusBadRequest},
+		{"short secret", subscription("stream.online", "https://example.com/webhooks", "secret"), viewer, http.StatusBadRequest},
+		{"duplicate", subscription("stream.online", "https://example.com/taken", "s3cr3t-s3cr3t"), viewer, http.StatusConflict},
+		{"without token", subscription("stream.online", "https://example.com/webhooks", "s3cr3t-s3cr3t"), "", http.StatusUnauthorized},
+	} {
+		t.Run(scenario.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+			w := httptest.NewRecorder()
+
+			NewSubscriptionHandler(validator.New(), &mock.SubscriptionServiceMock{}, []byte(secret)).ServeHTTP(w, req)
+
+			if want, got := scenario.expectedStatus, w.Result().StatusCode; want != got {
+				t.Fatalf("expected a %d, instead got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionHidesSecret(t *testing.T) {
+	secret := "secret"
+	viewer, err := test_util.GenerateTokens(2, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	body := `{"type":"stream.online","condition":{"broadcaster_user_id":1},` +
+		`"transport":{"method":"webhook","callback":"https://example.com/webhooks","secret":"s3cr3t-s3cr3t"}}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+viewer)
+	w := httptest.NewRecorder()
+
+	subscriptions := &mock.SubscriptionServiceMock{}
+	NewSubscriptionHandler(validator.New(), subscriptions, []byte(secret)).ServeHTTP(w, req)
+
+	if want, got := http.StatusAccepted, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	if strings.Contains(w.Body.String(), "s3cr3t") {
+		t.Fatalf("expected the secret to stay hidden, instead got: %s", w.Body.String())
+	}
+
+	var sub model.Subscription
+	if err := json.NewDecoder(w.Result().Body).Decode(&sub); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if sub.Status != model.StatusVerificationPending || sub.Version != model.Version {
+		t.Fatalf("expected a pending subscription, instead got: %+v", sub)
+	}
+
+	if len(subscriptions.Created) != 1 || subscriptions.Created[0].UserID != 2 || subscriptions.Created[0].Secret != "s3cr3t-s3cr3t" {
+		t.Fatalf("expected the subscription to be stored for the user, instead got: %+v", subscriptions.Created)
+	}
+}
+
+func TestGetDeliveries(t *testing.T) {
+	secret := "secret"
+	viewer, err := test_util.GenerateTokens(2, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/abc/deliveries?page=1&limit=10", nil)
+	req.Header.Set("Authorization", "Bearer "+viewer)
+	w := httptest.NewRecorder()
+
+	NewSubscriptionHandler(validator.New(), &mock.SubscriptionServiceMock{}, []byte(secret)).ServeHTTP(w, req)
+
+	if want, got := http.StatusOK, w.Result().StatusCode; want != got {
+		t.Fatalf("expected a %d, instead got: %d", want, got)
+	}
+
+	var responseData response.DeliveriesResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	if responseData.Total != 1 || len(responseData.Deliveries) != 1 || responseData.Limit != 10 {
+		t.Fatalf("expected the delivery log, instead got: %+v", responseData)
+	}
+
+	for path, status := range map[string]int{
+		"/def/deliveries":          http.StatusNotFound,
+		"/abc/deliveries?limit=0":  http.StatusBadRequest,
+		"/abc/deliveries?page=one": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+viewer)
+		w := httptest.NewRecorder()
+
+		NewSubscriptionHandler(validator.New(), &mock.SubscriptionServiceMock{}, []byte(secret)).ServeHTTP(w, req)
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("%s: expected a %d, instead got: %d", path, want, got)
+		}
+	}
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	secret := "secret"
+	viewer, err := test_util.GenerateTokens(2, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	for path, status := range map[string]int{"/abc": http.StatusNoContent, "/def": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		req.Header.Set("Authorization", "Bearer "+viewer)
+		w := httptest.NewRecorder()
+
+		NewSubscriptionHandler(validator.New(), &mock.SubscriptionServiceMock{}, []byte(secret)).ServeHTTP(w, req)
+
+		if want, got := status, w.Result().StatusCode; want != got {
+			t.Fatalf("%s: expected a %d, instead got: %d", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"nikolamilovic/twitchy/common/metrics"
+	"nikolamilovic/twitchy/webhooks/api/handler"
+	"nikolamilovic/twitchy/webhooks/service"
+
+	"github.com/go-chi/chi"
+	"github.com/go-playground/validator/v10"
+)
+
+type Server struct {
+	mux                 *chi.Mux
+	validator           *validator.Validate
+	subscriptionService service.ISubscriptionService
+	jwtSecret           []byte
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func NewServer(subscriptions service.ISubscriptionService, jwtSecret []byte) (*Server, error) {
+	s := &Server{
+		mux:                 chi.NewMux(),
+		validator:           validator.New(),
+		subscriptionService: subscriptions,
+		jwtSecret:           jwtSecret,
+	}
+
+	s.mux.Use(metrics.Middleware)
+
+	//Routing
+	subscriptionHandler := handler.NewSubscriptionHandler(s.validator, s.subscriptionService, s.jwtSecret)
+
+	s.mux.Handle("/metrics", metrics.Handler())
+	s.mux.Mount("/v1/webhooks/subscriptions", subscriptionHandler)
+	return s, nil
+}
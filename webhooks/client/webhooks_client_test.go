@@ -0,0 +1,93 @@
+package client
+
+import (
+	"nikolamilovic/twitchy/common/test_util/amqpmock"
+	"nikolamilovic/twitchy/webhooks/service/mock"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestParseEventUserFollowed(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.EventServiceMock{}
+	client := &WebhooksClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"user_followed",
+ 	  "payload":"{\"follower_id\":2,\"followed_id\":1,\"followed_at\":\"2022-10-01T12:00:00Z\"}"
+		}`),
+		},
+	)
+
+	if len(service.Follows) != 1 || service.Follows[0].FollowedID != 1 || service.Follows[0].FollowedAt.IsZero() {
+		t.Fatalf("Expected the follow to be queued, got %+v", service.Follows)
+	}
+}
+
+func TestParseEventSubscriptionStarted(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	service := &mock.EventServiceMock{}
+	client := &WebhooksClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: service,
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Ack(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body: []byte(`{
+ 	  "type":"subscription_started",
+ 	  "payload":"{\"subscription_id\":7,\"subscriber_id\":2,\"channel_id\":1,\"tier\":2}"
+		}`),
+		},
+	)
+
+	if len(service.Subscriptions) != 1 || service.Subscriptions[0].Tier != 2 {
+		t.Fatalf("Expected the subscription to be queued, got %+v", service.Subscriptions)
+	}
+}
+
+func TestParseEventRejectUnknown(t *testing.T) {
+	ctl := gomock.NewController(t)
+	defer ctl.Finish()
+
+	client := &WebhooksClient{
+		logger:  zap.L().Sugar().Named("test"),
+		service: &mock.EventServiceMock{},
+	}
+
+	ack := amqpmock.NewMockAcknowledger(ctl)
+
+	ack.EXPECT().Reject(gomock.Any(), false)
+
+	client.parseEvent(
+		amqp091.Delivery{
+			Acknowledger: ack,
+			ContentType:  "application/json",
+			Body:         []byte(`{"type":"chat_message_sent","payload":"{}"}`),
+		},
+	)
+}
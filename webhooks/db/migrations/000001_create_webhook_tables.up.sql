@@ -0,0 +1,41 @@
+-- A subscription POSTs the events of its type and condition to the callback, signed with the secret.
+-- It's enabled once the callback answers the verification challenge.
+CREATE TABLE IF NOT EXISTS subscriptions(
+   id VARCHAR(36) PRIMARY KEY DEFAULT gen_random_uuid()::text,
+   user_id INTEGER NOT NULL,
+   type VARCHAR(50) NOT NULL,
+   version VARCHAR(10) NOT NULL,
+   broadcaster_user_id INTEGER NOT NULL,
+   callback VARCHAR(2048) NOT NULL,
+   secret VARCHAR(100) NOT NULL,
+   challenge VARCHAR(64) NOT NULL,
+   status VARCHAR(50) NOT NULL DEFAULT 'webhook_callback_verification_pending',
+   -- Deliveries that failed in a row, too many disable the subscription
+   failures INTEGER NOT NULL DEFAULT 0,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   UNIQUE (user_id, type, broadcaster_user_id, callback)
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_user_idx ON subscriptions (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS subscriptions_enabled_idx ON subscriptions (type, broadcaster_user_id) WHERE status = 'enabled';
+
+-- Every message sent to a subscription, the id is the message id the callback sees on every attempt.
+-- event_key is the event the message is about, so an event redelivered by the broker is only sent once.
+CREATE TABLE IF NOT EXISTS deliveries(
+   id VARCHAR(36) PRIMARY KEY DEFAULT gen_random_uuid()::text,
+   subscription_id VARCHAR(36) NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+   message_type VARCHAR(50) NOT NULL,
+   event_key VARCHAR(200) NOT NULL,
+   event JSONB,
+   status VARCHAR(20) NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   response_status INTEGER NOT NULL DEFAULT 0,
+   error VARCHAR(500) NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   delivered_at TIMESTAMPTZ,
+   UNIQUE (subscription_id, event_key)
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_pending_idx ON deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS deliveries_subscription_idx ON deliveries (subscription_id, created_at DESC);
@@ -0,0 +1,8 @@
+package model
+
+import "errors"
+
+var (
+	SubscriptionNotFoundError = errors.New("subscription not found")
+	SubscriptionExistsError   = errors.New("subscription already exists")
+)
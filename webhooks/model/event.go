@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// The events sent to the callbacks, one per subscription type
+
+type StreamOnlineEvent struct {
+	ID                   string    `json:"id"`
+	BroadcasterUserID    int       `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	Type                 string    `json:"type"`
+	StartedAt            time.Time `json:"started_at"`
+}
+
+type StreamOfflineEvent struct {
+	BroadcasterUserID    int    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+}
+
+type ChannelUpdateEvent struct {
+	BroadcasterUserID int      `json:"broadcaster_user_id"`
+	Title             string   `json:"title"`
+	Category          string   `json:"category"`
+	Tags              []string `json:"tags"`
+}
+
+type ChannelFollowEvent struct {
+	UserID            int       `json:"user_id"`
+	BroadcasterUserID int       `json:"broadcaster_user_id"`
+	FollowedAt        time.Time `json:"followed_at"`
+}
+
+type ChannelSubscribeEvent struct {
+	UserID            int `json:"user_id"`
+	BroadcasterUserID int `json:"broadcaster_user_id"`
+	Tier              int `json:"tier"`
+}
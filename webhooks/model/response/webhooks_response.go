@@ -0,0 +1,14 @@
+package response
+
+import "nikolamilovic/twitchy/webhooks/model"
+
+type SubscriptionsResponse struct {
+	Subscriptions []model.Subscription `json:"subscriptions"`
+}
+
+type DeliveriesResponse struct {
+	Deliveries []model.Delivery `json:"deliveries"`
+	Page       int              `json:"page"`
+	Limit      int              `json:"limit"`
+	Total      int              `json:"total"`
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Subscription types, named after the event the callback receives
+const (
+	TypeStreamOnline     = "stream.online"
+	TypeStreamOffline    = "stream.offline"
+	TypeChannelUpdate    = "channel.update"
+	TypeChannelFollow    = "channel.follow"
+	TypeChannelSubscribe = "channel.subscribe"
+)
+
+// Subscription statuses, a subscription starts pending and is enabled once the callback answers the challenge
+const (
+	StatusVerificationPending = "webhook_callback_verification_pending"
+	StatusEnabled             = "enabled"
+	StatusVerificationFailed  = "webhook_callback_verification_failed"
+	StatusFailuresExceeded    = "notification_failures_exceeded"
+)
+
+const (
+	MessageTypeVerification = "webhook_callback_verification"
+	MessageTypeNotification = "notification"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	TransportWebhook = "webhook"
+	Version          = "1"
+)
+
+type Subscription struct {
+	ID        string    `json:"id"`
+	UserID    int       `json:"-"`
+	Status    string    `json:"status"`
+	Type      string    `json:"type"`
+	Version   string    `json:"version"`
+	Condition Condition `json:"condition"`
+	Transport Transport `json:"transport"`
+	CreatedAt time.Time `json:"created_at"`
+	// Secret signs the deliveries, it's never sent back
+	Secret    string `json:"-"`
+	Challenge string `json:"-"`
+}
+
+// Condition picks the events of the type the subscription receives
+type Condition struct {
+	BroadcasterUserID int `json:"broadcaster_user_id"`
+}
+
+type Transport struct {
+	Method   string `json:"method"`
+	Callback string `json:"callback"`
+}
+
+// Delivery is a message sent to a subscription, with the outcome of its last attempt
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	MessageType    string          `json:"message_type"`
+	Event          json.RawMessage `json:"event,omitempty"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/common/webhook"
+	"nikolamilovic/twitchy/webhooks/model"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultBatchSize   = 100
+	DefaultConcurrency = 10
+	// A claimed delivery is attempted again if the outcome isn't recorded in time
+	DefaultLease = time.Minute
+	// Attempts of a delivery before it fails, waiting RetryBackoff after the first and twice as long after every next one
+	DefaultMaxAttempts  = 6
+	DefaultRetryBackoff = 30 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	// Deliveries that may fail in a row before the subscription is disabled
+	DefaultMaxFailures = 5
+
+	callbackTimeout = 10 * time.Second
+	maxErrorLength  = 500
+)
+
+// The headers of every message, the signature is the HMAC-SHA256 of the message id, timestamp and body
+const (
+	HeaderMessageID           = "Twitchy-Eventsub-Message-Id"
+	HeaderMessageRetry        = "Twitchy-Eventsub-Message-Retry"
+	HeaderMessageType         = "Twitchy-Eventsub-Message-Type"
+	HeaderMessageSignature    = "Twitchy-Eventsub-Message-Signature"
+	HeaderMessageTimestamp    = "Twitchy-Eventsub-Message-Timestamp"
+	HeaderSubscriptionType    = "Twitchy-Eventsub-Subscription-Type"
+	HeaderSubscriptionVersion = "Twitchy-Eventsub-Subscription-Version"
+)
+
+var errChallengeMismatch = errors.New("the response doesn't echo the challenge")
+
+// message is a claimed delivery with the subscription it goes to
+type message struct {
+	model.Delivery
+	Subscription model.Subscription
+}
+
+type messageBody struct {
+	Subscription model.Subscription `json:"subscription"`
+	Challenge    string             `json:"challenge,omitempty"`
+	Event        json.RawMessage    `json:"event,omitempty"`
+}
+
+// Dispatcher sends the pending deliveries to the callbacks, retrying failed ones with exponential backoff
+type Dispatcher struct {
+	DB           db.PgxIface
+	Client       *http.Client
+	BatchSize    int
+	Concurrency  int
+	Lease        time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	MaxFailures  int
+	logger       *zap.SugaredLogger
+}
+
+func NewDispatcher(db db.PgxIface, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		DB: db,
+		// The callbacks are handed in by users, the client only reaches public addresses
+		Client:       webhook.NewClient(callbackTimeout),
+		BatchSize:    DefaultBatchSize,
+		Concurrency:  DefaultConcurrency,
+		Lease:        DefaultLease,
+		MaxAttempts:  DefaultMaxAttempts,
+		RetryBackoff: DefaultRetryBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		MaxFailures:  DefaultMaxFailures,
+		logger:       logger,
+	}
+}
+
+// Run dispatches rounds until ctx is cancelled, sleeping for idle whenever there is nothing to do
+func (d *Dispatcher) Run(ctx context.Context, idle time.Duration) {
+	for {
+		n, err := d.DispatchRound(ctx)
+		if err != nil {
+			d.logger.Errorf("failed to dispatch webhooks: %v", err)
+		}
+
+		if n > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(idle):
+		}
+	}
+}
+
+// DispatchRound claims the deliveries that are due and attempts each of them once, returning how many it attempted
+func (d *Dispatcher) DispatchRound(ctx context.Context) (int, error) {
+	messages, err := d.claim(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("DispatchRound: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, d.Concurrency)
+
+	for _, m := range messages {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(m message) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := d.deliver(ctx, m); err != nil {
+				d.logger.Errorf("failed to record delivery %s: %v", m.ID, err)
+			}
+		}(m)
+	}
+
+	wg.Wait()
+
+	return len(messages), nil
+}
+
+// claim leases the due deliveries of the enabled subscriptions, and the verifications of the pending ones
+func (d *Dispatcher) claim(ctx context.Context) ([]message, error) {
+	rows, err := d.DB.Query(ctx, `
+		UPDATE deliveries d SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT pd.id FROM deliveries pd JOIN subscriptions ps ON ps.id = pd.subscription_id
+			WHERE pd.status = 'pending' AND pd.next_attempt_at <= NOW()
+			AND (ps.status = 'enabled' OR (pd.message_type = $3 AND ps.status = $4))
+			ORDER BY pd.next_attempt_at LIMIT $1 FOR UPDATE OF pd SKIP LOCKED
+		)
+		RETURNING d.id, d.message_type, d.event, d.attempts,
+			s.id, s.status, s.type, s.version, s.broadcaster_user_id, s.callback, s.secret, s.challenge, s.created_at`,
+		d.BatchSize, d.Lease.Seconds(), model.MessageTypeVerification, model.StatusVerificationPending)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []message
+	for rows.Next() {
+		m := message{Subscription: model.Subscription{Transport: model.Transport{Method: model.TransportWebhook}}}
+		var event []byte
+		err := rows.Scan(&m.ID, &m.MessageType, &event, &m.Attempts,
+			&m.Subscription.ID, &m.Subscription.Status, &m.Subscription.Type, &m.Subscription.Version,
+			&m.Subscription.Condition.BroadcasterUserID, &m.Subscription.Transport.Callback, &m.Subscription.Secret,
+			&m.Subscription.Challenge, &m.Subscription.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		m.Event = event
+		m.SubscriptionID = m.Subscription.ID
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// deliver attempts the delivery and records how it went
+func (d *Dispatcher) deliver(ctx context.Context, m message) error {
+	status, err := d.send(ctx, m)
+
+	switch {
+	case err == nil:
+		return d.succeeded(ctx, m, status)
+	case errors.Is(err, errChallengeMismatch):
+		// The callback answered, just not correctly, trying again won't change that
+		return d.failed(ctx, m, status, err, true)
+	default:
+		return d.failed(ctx, m, status, err, m.Attempts+1 >= d.MaxAttempts)
+	}
+}
+
+// send POSTs the message to the callback and returns the status it responded with
+func (d *Dispatcher) send(ctx context.Context, m message) (int, error) {
+	body := messageBody{Subscription: m.Subscription}
+	if m.MessageType == model.MessageTypeVerification {
+		body.Challenge = m.Subscription.Challenge
+	} else {
+		body.Event = m.Event
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Subscription.Transport.Callback, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, m.ID)
+	req.Header.Set(HeaderMessageRetry, strconv.Itoa(m.Attempts))
+	req.Header.Set(HeaderMessageType, m.MessageType)
+	req.Header.Set(HeaderMessageSignature, webhook.Signature(m.Subscription.Secret, m.ID, timestamp, data))
+	req.Header.Set(HeaderMessageTimestamp, timestamp)
+	req.Header.Set(HeaderSubscriptionType, m.Subscription.Type)
+	req.Header.Set(HeaderSubscriptionVersion, m.Subscription.Version)
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	if m.MessageType == model.MessageTypeVerification {
+		answer, err := io.ReadAll(io.LimitReader(res.Body, int64(len(m.Subscription.Challenge)+1)))
+		if err != nil {
+			return res.StatusCode, err
+		}
+		if string(answer) != m.Subscription.Challenge {
+			return res.StatusCode, errChallengeMismatch
+		}
+	}
+
+	return res.StatusCode, nil
+}
+
+// succeeded marks the delivery delivered and resets the failures of the subscription, enabling it if it was verified
+func (d *Dispatcher) succeeded(ctx context.Context, m message, status int) error {
+	_, err := d.DB.Exec(ctx, `
+		WITH delivered AS (
+			UPDATE deliveries SET status = 'delivered', attempts = attempts + 1, response_status = $2, error = '', delivered_at = NOW()
+			WHERE id = $1 RETURNING subscription_id, message_type
+		)
+		UPDATE subscriptions s SET failures = 0,
+			status = CASE WHEN delivered.message_type = $3 AND s.status = $4 THEN $5 ELSE s.status END
+		FROM delivered WHERE s.id = delivered.subscription_id`,
+		m.ID, status, model.MessageTypeVerification, model.StatusVerificationPending, model.StatusEnabled)
+
+	return err
+}
+
+// failed schedules the next attempt of the delivery, or fails it for good if final. A failed verification fails the
+// subscription and too many failed deliveries in a row disable it, dropping what's still pending for it.
+func (d *Dispatcher) failed(ctx context.Context, m message, status int, cause error, final bool) error {
+	reason := cause.Error()
+	if len(reason) > maxErrorLength {
+		reason = reason[:maxErrorLength]
+	}
+
+	if !final {
+		_, err := d.DB.Exec(ctx, `
+			UPDATE deliveries SET attempts = attempts + 1, response_status = $2, error = $3, next_attempt_at = NOW() + make_interval(secs => $4)
+			WHERE id = $1`,
+			m.ID, status, reason, d.backoff(m.Attempts+1).Seconds())
+
+		return err
+	}
+
+	_, err := d.DB.Exec(ctx, `
+		WITH failed AS (
+			UPDATE deliveries SET status = 'failed', attempts = attempts + 1, response_status = $2, error = $3
+			WHERE id = $1 RETURNING subscription_id, message_type
+		), disabled AS (
+			UPDATE subscriptions s SET failures = s.failures + 1,
+				status = CASE
+					WHEN failed.message_type = $4 THEN $5
+					WHEN s.failures + 1 >= $6 THEN $7
+					ELSE s.status END
+			FROM failed WHERE s.id = failed.subscription_id
+			RETURNING s.id, s.status
+		)
+		UPDATE deliveries SET status = 'failed', error = 'subscription ' || disabled.status
+		FROM disabled WHERE deliveries.subscription_id = disabled.id AND disabled.status <> 'enabled'
+		AND deliveries.status = 'pending' AND deliveries.id <> $1`,
+		m.ID, status, reason, model.MessageTypeVerification, model.StatusVerificationFailed, d.MaxFailures,
+		model.StatusFailuresExceeded)
+
+	return err
+}
+
+// backoff is how long to wait after the attempt before the next one
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.RetryBackoff
+	for i := 1; i < attempt && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > d.MaxBackoff {
+		return d.MaxBackoff
+	}
+
+	return wait
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nikolamilovic/twitchy/common/webhook"
+	"nikolamilovic/twitchy/webhooks/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"go.uber.org/zap"
+)
+
+var messageRowColumns = []string{"id", "message_type", "event", "attempts", "subscription_id", "status", "type", "version",
+	"broadcaster_user_id", "callback", "secret", "challenge", "created_at"}
+
+const challenge = "pogchamp-kappa-123"
+
+// callback answers like a well behaved integration, checking the signature and echoing the challenge
+func callback(t *testing.T, received *[]messageBody, status int) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		signature := webhook.Signature("s3cr3t-s3cr3t", r.Header.Get(HeaderMessageID), r.Header.Get(HeaderMessageTimestamp), body)
+		if r.Header.Get(HeaderMessageSignature) != signature {
+			t.Errorf("Expected the message to be signed with %s, got %s", signature, r.Header.Get(HeaderMessageSignature))
+		}
+
+		var message messageBody
+		json.Unmarshal(body, &message)
+		*received = append(*received, message)
+
+		w.WriteHeader(status)
+		io.WriteString(w, message.Challenge)
+	}))
+}
+
+func newTestDispatcher(t *testing.T, srv *httptest.Server) (*Dispatcher, pgxmock.PgxConnIface) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	d := NewDispatcher(mockDB, zap.L().Sugar())
+	d.Client = srv.Client()
+	d.Concurrency = 1
+
+	return d, mockDB
+}
+
+func messageRow(srv *httptest.Server, messageType string, attempts int, event []byte) *pgxmock.Rows {
+	return pgxmock.NewRows(messageRowColumns).AddRow("message", messageType, event, attempts, "abc",
+		model.StatusVerificationPending, model.TypeStreamOnline, "1", 1, srv.URL, "s3cr3t-s3cr3t", challenge, time.Now())
+}
+
+func TestDispatchVerification(t *testing.T) {
+	var received []messageBody
+	srv := callback(t, &received, http.StatusOK)
+	defer srv.Close()
+
+	d, mockDB := newTestDispatcher(t, srv)
+	defer mockDB.Close(context.Background())
+
+	mockDB.ExpectQuery("UPDATE deliveries d SET next_attempt_at").
+		WithArgs(DefaultBatchSize, DefaultLease.Seconds(), model.MessageTypeVerification, model.StatusVerificationPending).
+		WillReturnRows(messageRow(srv, model.MessageTypeVerification, 0, nil))
+	mockDB.ExpectExec("UPDATE deliveries SET status = 'delivered'(.+) UPDATE subscriptions").
+		WithArgs("message", http.StatusOK, model.MessageTypeVerification, model.StatusVerificationPending, model.StatusEnabled).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	n, err := d.DispatchRound(context.Background())
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if n != 1 || len(received) != 1 || received[0].Challenge != challenge || received[0].Subscription.ID != "abc" {
+		t.Fatalf("Expected the challenge to be sent, got %d %+v", n, received)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDispatchChallengeMismatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	d, mockDB := newTestDispatcher(t, srv)
+	defer mockDB.Close(context.Background())
+
+	mockDB.ExpectQuery("UPDATE deliveries d SET next_attempt_at").
+		WithArgs(DefaultBatchSize, DefaultLease.Seconds(), model.MessageTypeVerification, model.StatusVerificationPending).
+		WillReturnRows(messageRow(srv, model.MessageTypeVerification, 0, nil))
+	mockDB.ExpectExec("UPDATE deliveries SET status = 'failed'(.+) UPDATE subscriptions").
+		WithArgs("message", http.StatusOK, errChallengeMismatch.Error(), model.MessageTypeVerification, model.StatusVerificationFailed,
+			DefaultMaxFailures, model.StatusFailuresExceeded).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	if _, err := d.DispatchRound(context.Background()); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	var received []messageBody
+	srv := callback(t, &received, http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	d, mockDB := newTestDispatcher(t, srv)
+	defer mockDB.Close(context.Background())
+
+	event := []byte(`{"id":"session","broadcaster_user_id":1}`)
+
+	// The third attempt failed, the fourth one is in two minutes
+	mockDB.ExpectQuery("UPDATE deliveries d SET next_attempt_at").
+		WithArgs(DefaultBatchSize, DefaultLease.Seconds(), model.MessageTypeVerification, model.StatusVerificationPending).
+		WillReturnRows(messageRow(srv, model.MessageTypeNotification, 2, event))
+	mockDB.ExpectExec("UPDATE deliveries SET attempts = attempts \\+ 1").
+		WithArgs("message", http.StatusServiceUnavailable, "unexpected status 503", (2 * time.Minute).Seconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	// The last attempt fails the delivery, counting towards disabling the subscription
+	mockDB.ExpectQuery("UPDATE deliveries d SET next_attempt_at").
+		WithArgs(DefaultBatchSize, DefaultLease.Seconds(), model.MessageTypeVerification, model.StatusVerificationPending).
+		WillReturnRows(messageRow(srv, model.MessageTypeNotification, DefaultMaxAttempts-1, event))
+	mockDB.ExpectExec("UPDATE deliveries SET status = 'failed'(.+) UPDATE subscriptions (.+) UPDATE deliveries SET status = 'failed'").
+		WithArgs("message", http.StatusServiceUnavailable, "unexpected status 503", model.MessageTypeVerification,
+			model.StatusVerificationFailed, DefaultMaxFailures, model.StatusFailuresExceeded).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 4))
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.DispatchRound(context.Background()); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	if len(received) != 2 || string(received[1].Event) != string(event) || received[1].Challenge != "" {
+		t.Fatalf("Expected the event to be sent twice, got %+v", received)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, zap.L().Sugar())
+
+	for attempt, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		8:  time.Hour,
+		40: time.Hour,
+	} {
+		if got := d.backoff(attempt); got != want {
+			t.Fatalf("Expected to wait %v after attempt %d, got %v", want, attempt, got)
+		}
+	}
+}
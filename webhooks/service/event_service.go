@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	event "nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/webhooks/model"
+	"strconv"
+)
+
+// IEventService turns the platform events into deliveries for the subscriptions they match, every method is idempotent
+type IEventService interface {
+	StreamStatusChanged(ev event.StreamStatusChangedEventData) error
+	StreamInfoUpdated(ev event.StreamInfoUpdatedEventData) error
+	UserFollowed(ev event.UserFollowedEventData) error
+	SubscriptionStarted(ev event.SubscriptionStartedEventData) error
+}
+
+type EventService struct {
+	DB db.PgxIface
+}
+
+func NewEventService(db db.PgxIface) *EventService {
+	return &EventService{
+		DB: db,
+	}
+}
+
+func (s *EventService) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	var err error
+
+	switch ev.Status {
+	case event.StreamStatusLive:
+		err = s.enqueue(model.TypeStreamOnline, ev.ChannelID, "stream.online:"+ev.SessionID, model.StreamOnlineEvent{
+			ID:                   ev.SessionID,
+			BroadcasterUserID:    ev.ChannelID,
+			BroadcasterUserLogin: ev.Channel,
+			Type:                 "live",
+			StartedAt:            ev.StartedAt,
+		})
+	case event.StreamStatusEnded:
+		err = s.enqueue(model.TypeStreamOffline, ev.ChannelID, "stream.offline:"+ev.SessionID, model.StreamOfflineEvent{
+			BroadcasterUserID:    ev.ChannelID,
+			BroadcasterUserLogin: ev.Channel,
+		})
+	}
+
+	if err != nil {
+		return fmt.Errorf("StreamStatusChanged: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventService) StreamInfoUpdated(ev event.StreamInfoUpdatedEventData) error {
+	key := "channel.update:" + strconv.FormatInt(ev.UpdatedAt.UnixNano(), 10)
+
+	err := s.enqueue(model.TypeChannelUpdate, ev.ChannelID, key, model.ChannelUpdateEvent{
+		BroadcasterUserID: ev.ChannelID,
+		Title:             ev.Title,
+		Category:          ev.Category,
+		Tags:              ev.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("StreamInfoUpdated: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EventService) UserFollowed(ev event.UserFollowedEventData) error {
+	key := "channel.follow:" + strconv.Itoa(ev.FollowerID) + ":" + strconv.FormatInt(ev.FollowedAt.UnixNano(), 10)
+
+	err := s.enqueue(model.TypeChannelFollow, ev.FollowedID, key, model.ChannelFollowEvent{
+		UserID:            ev.FollowerID,
+		BroadcasterUserID: ev.FollowedID,
+		FollowedAt:        ev.FollowedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("UserFollowed: %w", err)
+	}
+
+	return nil
+}
+
+// SubscriptionStarted is sent for new subscriptions and renewals alike, every paid period is an event
+func (s *EventService) SubscriptionStarted(ev event.SubscriptionStartedEventData) error {
+	key := "channel.subscribe:" + strconv.Itoa(ev.SubscriptionID) + ":" + strconv.FormatInt(ev.PeriodEnd.UnixNano(), 10)
+
+	err := s.enqueue(model.TypeChannelSubscribe, ev.ChannelID, key, model.ChannelSubscribeEvent{
+		UserID:            ev.SubscriberID,
+		BroadcasterUserID: ev.ChannelID,
+		Tier:              ev.Tier,
+	})
+	if err != nil {
+		return fmt.Errorf("SubscriptionStarted: %w", err)
+	}
+
+	return nil
+}
+
+// enqueue queues a delivery of the event for every enabled subscription to it, the key keeps a redelivered event from
+// being queued twice
+func (s *EventService) enqueue(subscriptionType string, broadcasterID int, key string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(context.Background(), `
+		INSERT INTO deliveries (subscription_id, message_type, event_key, event)
+		SELECT id, $3, $4, $5::jsonb FROM subscriptions WHERE type = $1 AND broadcaster_user_id = $2 AND status = 'enabled'
+		ON CONFLICT (subscription_id, event_key) DO NOTHING`,
+		subscriptionType, broadcasterID, model.MessageTypeNotification, key, string(data))
+
+	return err
+}
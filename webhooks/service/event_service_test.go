@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/webhooks/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestStreamStatusChanged(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewEventService(mockDB)
+
+	startedAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	endedAt := startedAt.Add(time.Hour)
+
+	mockDB.ExpectExec("INSERT INTO deliveries (.+) ON CONFLICT \\(subscription_id, event_key\\) DO NOTHING").
+		WithArgs(model.TypeStreamOnline, 1, model.MessageTypeNotification, "stream.online:abc",
+			`{"id":"abc","broadcaster_user_id":1,"broadcaster_user_login":"nik","type":"live","started_at":"2022-10-01T12:00:00Z"}`).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mockDB.ExpectExec("INSERT INTO deliveries").
+		WithArgs(model.TypeStreamOffline, 1, model.MessageTypeNotification, "stream.offline:abc",
+			`{"broadcaster_user_id":1,"broadcaster_user_login":"nik"}`).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+	statuses := []event.StreamStatusChangedEventData{
+		{SessionID: "abc", ChannelID: 1, Channel: "nik", Status: event.StreamStatusOffline},
+		{SessionID: "abc", ChannelID: 1, Channel: "nik", Status: event.StreamStatusLive, StartedAt: startedAt},
+		{SessionID: "abc", ChannelID: 1, Channel: "nik", Status: event.StreamStatusEnded, StartedAt: startedAt, EndedAt: &endedAt},
+	}
+
+	for _, ev := range statuses {
+		if err := sut.StreamStatusChanged(ev); err != nil {
+			t.Fatalf("Expected error to be nil, got %v", err)
+		}
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserFollowed(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewEventService(mockDB)
+
+	followedAt := time.Unix(1664625600, 0).UTC()
+
+	mockDB.ExpectExec("INSERT INTO deliveries").
+		WithArgs(model.TypeChannelFollow, 1, model.MessageTypeNotification, "channel.follow:2:1664625600000000000",
+			`{"user_id":2,"broadcaster_user_id":1,"followed_at":"2022-10-01T12:00:00Z"}`).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := sut.UserFollowed(event.UserFollowedEventData{FollowerID: 2, FollowedID: 1, FollowedAt: followedAt}); err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,69 @@
+package mock
+
+import (
+	"nikolamilovic/twitchy/common/event"
+	"nikolamilovic/twitchy/webhooks/model"
+	"time"
+)
+
+type SubscriptionServiceMock struct {
+	Created []model.Subscription
+}
+
+func (s *SubscriptionServiceMock) CreateSubscription(sub model.Subscription) (model.Subscription, error) {
+	if sub.Transport.Callback == "https://example.com/taken" {
+		return model.Subscription{}, model.SubscriptionExistsError
+	}
+
+	s.Created = append(s.Created, sub)
+
+	sub.ID = "abc"
+	sub.Status = model.StatusVerificationPending
+	sub.CreatedAt = time.Now()
+	return sub, nil
+}
+
+func (s *SubscriptionServiceMock) GetSubscriptions(userID int, status string) ([]model.Subscription, error) {
+	return []model.Subscription{{ID: "abc", UserID: userID, Status: model.StatusEnabled}}, nil
+}
+
+func (s *SubscriptionServiceMock) DeleteSubscription(userID int, id string) error {
+	if id != "abc" {
+		return model.SubscriptionNotFoundError
+	}
+	return nil
+}
+
+func (s *SubscriptionServiceMock) GetDeliveries(userID int, subscriptionID string, page, limit int) ([]model.Delivery, int, error) {
+	if subscriptionID != "abc" {
+		return nil, 0, model.SubscriptionNotFoundError
+	}
+	return []model.Delivery{{ID: "message", SubscriptionID: subscriptionID, Status: model.DeliveryDelivered}}, 1, nil
+}
+
+type EventServiceMock struct {
+	Statuses      []event.StreamStatusChangedEventData
+	Infos         []event.StreamInfoUpdatedEventData
+	Follows       []event.UserFollowedEventData
+	Subscriptions []event.SubscriptionStartedEventData
+}
+
+func (s *EventServiceMock) StreamStatusChanged(ev event.StreamStatusChangedEventData) error {
+	s.Statuses = append(s.Statuses, ev)
+	return nil
+}
+
+func (s *EventServiceMock) StreamInfoUpdated(ev event.StreamInfoUpdatedEventData) error {
+	s.Infos = append(s.Infos, ev)
+	return nil
+}
+
+func (s *EventServiceMock) UserFollowed(ev event.UserFollowedEventData) error {
+	s.Follows = append(s.Follows, ev)
+	return nil
+}
+
+func (s *EventServiceMock) SubscriptionStarted(ev event.SubscriptionStartedEventData) error {
+	s.Subscriptions = append(s.Subscriptions, ev)
+	return nil
+}
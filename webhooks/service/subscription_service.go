@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	db "nikolamilovic/twitchy/common/db"
+	"nikolamilovic/twitchy/webhooks/model"
+)
+
+const subscriptionColumns = "id, user_id, status, type, version, broadcaster_user_id, callback, created_at"
+
+type ISubscriptionService interface {
+	// CreateSubscription stores the subscription and queues the verification of its callback
+	CreateSubscription(sub model.Subscription) (model.Subscription, error)
+	// GetSubscriptions returns the subscriptions of the user, latest first, only those with the status if it isn't empty
+	GetSubscriptions(userID int, status string) ([]model.Subscription, error)
+	DeleteSubscription(userID int, id string) error
+	// GetDeliveries returns a page of the delivery log of the subscription, latest first, and the number of deliveries
+	GetDeliveries(userID int, subscriptionID string, page, limit int) ([]model.Delivery, int, error)
+}
+
+type SubscriptionService struct {
+	DB db.PgxIface
+}
+
+func NewSubscriptionService(db db.PgxIface) *SubscriptionService {
+	return &SubscriptionService{
+		DB: db,
+	}
+}
+
+func (s *SubscriptionService) CreateSubscription(sub model.Subscription) (model.Subscription, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("CreateSubscription: %w", err)
+	}
+
+	// The verification is queued with the subscription, a subscription is never left without one
+	rows, err := s.DB.Query(context.Background(), `
+		WITH sub AS (
+			INSERT INTO subscriptions (user_id, type, version, broadcaster_user_id, callback, secret, challenge)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (user_id, type, broadcaster_user_id, callback) DO NOTHING
+			RETURNING id, status, created_at
+		), verification AS (
+			INSERT INTO deliveries (subscription_id, message_type, event_key) SELECT id, $8, $8 FROM sub
+		)
+		SELECT id, status, created_at FROM sub`,
+		sub.UserID, sub.Type, sub.Version, sub.Condition.BroadcasterUserID, sub.Transport.Callback, sub.Secret, challenge,
+		model.MessageTypeVerification)
+	if err != nil {
+		return model.Subscription{}, fmt.Errorf("CreateSubscription: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return model.Subscription{}, fmt.Errorf("CreateSubscription: %w", model.SubscriptionExistsError)
+	}
+
+	if err := rows.Scan(&sub.ID, &sub.Status, &sub.CreatedAt); err != nil {
+		return model.Subscription{}, fmt.Errorf("CreateSubscription: %w", err)
+	}
+
+	sub.Challenge = challenge
+
+	return sub, nil
+}
+
+func (s *SubscriptionService) GetSubscriptions(userID int, status string) ([]model.Subscription, error) {
+	rows, err := s.DB.Query(context.Background(),
+		"SELECT "+subscriptionColumns+" FROM subscriptions WHERE user_id = $1 AND ($2 = '' OR status = $2) ORDER BY created_at DESC",
+		userID, status)
+	if err != nil {
+		return nil, fmt.Errorf("GetSubscriptions: %w", err)
+	}
+
+	defer rows.Close()
+
+	subscriptions := []model.Subscription{}
+	for rows.Next() {
+		sub := model.Subscription{Transport: model.Transport{Method: model.TransportWebhook}}
+		err := rows.Scan(&sub.ID, &sub.UserID, &sub.Status, &sub.Type, &sub.Version, &sub.Condition.BroadcasterUserID,
+			&sub.Transport.Callback, &sub.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("GetSubscriptions: %w", err)
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	return subscriptions, nil
+}
+
+func (s *SubscriptionService) DeleteSubscription(userID int, id string) error {
+	tag, err := s.DB.Exec(context.Background(), "DELETE FROM subscriptions WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteSubscription: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteSubscription: %w", model.SubscriptionNotFoundError)
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) GetDeliveries(userID int, subscriptionID string, page, limit int) ([]model.Delivery, int, error) {
+	ctx := context.Background()
+
+	// Only the owner sees the log, someone else's subscription is as good as missing
+	rows, err := s.DB.Query(ctx,
+		"SELECT (SELECT count(*) FROM deliveries WHERE subscription_id = s.id) FROM subscriptions s WHERE s.id = $1 AND s.user_id = $2",
+		subscriptionID, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetDeliveries: %w", err)
+	}
+
+	var total int
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&total)
+	}
+	rows.Close()
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetDeliveries: %w", err)
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("GetDeliveries: %w", model.SubscriptionNotFoundError)
+	}
+
+	rows, err = s.DB.Query(ctx, `
+		SELECT id, subscription_id, message_type, event, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END, response_status, error, created_at, delivered_at
+		FROM deliveries WHERE subscription_id = $1
+		ORDER BY created_at DESC, id LIMIT $2 OFFSET $3`,
+		subscriptionID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetDeliveries: %w", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := []model.Delivery{}
+	for rows.Next() {
+		var d model.Delivery
+		var event []byte
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.MessageType, &event, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.ResponseStatus, &d.Error, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GetDeliveries: %w", err)
+		}
+		d.Event = event
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, total, nil
+}
+
+// newChallenge returns the random string the callback has to echo back to verify it
+func newChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
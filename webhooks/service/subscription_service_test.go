@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"nikolamilovic/twitchy/webhooks/model"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+)
+
+func TestCreateSubscription(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSubscriptionService(mockDB)
+
+	sub := model.Subscription{
+		UserID:    2,
+		Type:      model.TypeStreamOnline,
+		Version:   model.Version,
+		Condition: model.Condition{BroadcasterUserID: 1},
+		Transport: model.Transport{Method: model.TransportWebhook, Callback: "https://example.com/webhooks"},
+		Secret:    "s3cr3t-s3cr3t",
+	}
+
+	mockDB.ExpectQuery("INSERT INTO subscriptions (.+) INSERT INTO deliveries").
+		WithArgs(2, model.TypeStreamOnline, "1", 1, "https://example.com/webhooks", "s3cr3t-s3cr3t", pgxmock.AnyArg(), model.MessageTypeVerification).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "status", "created_at"}).AddRow("abc", model.StatusVerificationPending, time.Now()))
+	mockDB.ExpectQuery("INSERT INTO subscriptions").
+		WithArgs(2, model.TypeStreamOnline, "1", 1, "https://example.com/webhooks", "s3cr3t-s3cr3t", pgxmock.AnyArg(), model.MessageTypeVerification).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "status", "created_at"}))
+
+	created, err := sut.CreateSubscription(sub)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if created.ID != "abc" || created.Status != model.StatusVerificationPending || len(created.Challenge) != 64 {
+		t.Fatalf("Expected a pending subscription with a challenge, got %+v", created)
+	}
+
+	if _, err := sut.CreateSubscription(sub); !errors.Is(err, model.SubscriptionExistsError) {
+		t.Fatalf("Expected the duplicate to be rejected, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetDeliveries(t *testing.T) {
+	mockDB, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close(context.Background())
+
+	sut := NewSubscriptionService(mockDB)
+
+	mockDB.ExpectQuery("SELECT \\(SELECT count").WithArgs("abc", 2).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+	mockDB.ExpectQuery("SELECT id, (.+) FROM deliveries").WithArgs("abc", 2, 2).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "subscription_id", "message_type", "event", "status", "attempts",
+			"next_attempt_at", "response_status", "error", "created_at", "delivered_at"}).
+			AddRow("message", "abc", model.MessageTypeNotification, []byte(`{"broadcaster_user_id":1}`), model.DeliveryPending, 2,
+				nil, 503, "unexpected status 503", time.Now(), nil))
+	mockDB.ExpectQuery("SELECT \\(SELECT count").WithArgs("abc", 3).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}))
+
+	deliveries, total, err := sut.GetDeliveries(2, "abc", 2, 2)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %v", err)
+	}
+
+	if total != 3 || len(deliveries) != 1 || deliveries[0].ResponseStatus != 503 || string(deliveries[0].Event) != `{"broadcaster_user_id":1}` {
+		t.Fatalf("Expected 1 of 3 deliveries, got %d %+v", total, deliveries)
+	}
+
+	// Only the owner sees the log
+	if _, _, err := sut.GetDeliveries(3, "abc", 1, 2); !errors.Is(err, model.SubscriptionNotFoundError) {
+		t.Fatalf("Expected a subscription not found error, got %v", err)
+	}
+
+	if err := mockDB.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %s", err)
+	}
+}